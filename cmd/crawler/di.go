@@ -8,10 +8,15 @@ import (
 	"github.com/reugn/go-quartz/quartz"
 	"github.com/sealbro/go-feed-me/graph/model"
 	"github.com/sealbro/go-feed-me/internal/api"
+	"github.com/sealbro/go-feed-me/internal/auth"
 	"github.com/sealbro/go-feed-me/internal/db"
+	"github.com/sealbro/go-feed-me/internal/duplicates"
 	"github.com/sealbro/go-feed-me/internal/graphql_api"
 	"github.com/sealbro/go-feed-me/internal/job"
+	"github.com/sealbro/go-feed-me/internal/links"
 	"github.com/sealbro/go-feed-me/internal/metrics"
+	"github.com/sealbro/go-feed-me/internal/outbox"
+	"github.com/sealbro/go-feed-me/internal/rules"
 	"github.com/sealbro/go-feed-me/internal/storage"
 	"github.com/sealbro/go-feed-me/internal/subscribers"
 	"github.com/sealbro/go-feed-me/internal/traces"
@@ -20,6 +25,7 @@ import (
 	"github.com/sealbro/go-feed-me/pkg/notifier"
 	"go.uber.org/dig"
 	"golang.org/x/sync/errgroup"
+	"log/slog"
 	"os"
 )
 
@@ -28,13 +34,35 @@ type jobGroup struct {
 	Jobs []quartz.Job `group:"jobs"`
 }
 
+type subscriberGroup struct {
+	dig.In
+	Subscribers []subscribers.Subscriber `group:"subscribers"`
+}
+
 type CrawlerSettings struct {
 	LoggerConfig *logger.Config
 	DbConfig     *db.Config
 	*api.PublicApiConfig
 	*api.PrivateApiConfig
 	*subscribers.DiscordConfig
-	TracesConfig *traces.Config
+	*subscribers.SlackConfig
+	*subscribers.TelegramConfig
+	*subscribers.TeamsConfig
+	*subscribers.MattermostConfig
+	*subscribers.MatrixConfig
+	*subscribers.NtfyConfig
+	*subscribers.GotifyConfig
+	*subscribers.PushoverConfig
+	*subscribers.EmailConfig
+	*subscribers.WebhookConfig
+	SubscribersConfig *subscribers.Config
+	AuthConfig        *auth.Config
+	OidcConfig        *auth.OidcConfig
+	NotifierConfig    *notifier.Config
+	OutboxConfig      *outbox.Config
+	DuplicatesConfig  *duplicates.Config
+	LinksConfig       *links.Config
+	TracesConfig      *traces.Config
 	*job.DaemonConfig
 }
 
@@ -45,6 +73,23 @@ func newSettings() (
 	*api.PublicApiConfig,
 	*api.PrivateApiConfig,
 	*subscribers.DiscordConfig,
+	*subscribers.SlackConfig,
+	*subscribers.TelegramConfig,
+	*subscribers.TeamsConfig,
+	*subscribers.MattermostConfig,
+	*subscribers.MatrixConfig,
+	*subscribers.NtfyConfig,
+	*subscribers.GotifyConfig,
+	*subscribers.PushoverConfig,
+	*subscribers.EmailConfig,
+	*subscribers.WebhookConfig,
+	*subscribers.Config,
+	*auth.Config,
+	*auth.OidcConfig,
+	*notifier.Config,
+	*outbox.Config,
+	*duplicates.Config,
+	*links.Config,
 	*traces.Config,
 	*job.DaemonConfig,
 ) {
@@ -61,6 +106,23 @@ func newSettings() (
 		settings.PublicApiConfig,
 		settings.PrivateApiConfig,
 		settings.DiscordConfig,
+		settings.SlackConfig,
+		settings.TelegramConfig,
+		settings.TeamsConfig,
+		settings.MattermostConfig,
+		settings.MatrixConfig,
+		settings.NtfyConfig,
+		settings.GotifyConfig,
+		settings.PushoverConfig,
+		settings.EmailConfig,
+		settings.WebhookConfig,
+		settings.SubscribersConfig,
+		settings.AuthConfig,
+		settings.OidcConfig,
+		settings.NotifierConfig,
+		settings.OutboxConfig,
+		settings.DuplicatesConfig,
+		settings.LinksConfig,
 		settings.TracesConfig,
 		settings.DaemonConfig
 }
@@ -80,9 +142,42 @@ func provideApp() (graceful.Application, error) {
 	provideOrPanic(container, db.NewDatabase)
 	provideOrPanic(container, storage.NewResourceRepository)
 	provideOrPanic(container, storage.NewArticleRepository)
+	provideOrPanic(container, storage.NewUserRepository)
+	provideOrPanic(container, storage.NewApiKeyRepository)
+	provideOrPanic(container, storage.NewOutboxRepository)
+	provideOrPanic(container, storage.NewWebhookDeliveryRepository)
+	provideOrPanic(container, storage.NewMatrixThreadRepository)
+	provideOrPanic(container, storage.NewRuleRepository)
+	provideOrPanic(container, rules.NewEngine)
+	provideOrPanic(container, duplicates.NewDetector)
+	provideOrPanic(container, links.NewCanonicalizer)
+	provideOrPanic(container, auth.NewSessions)
+	provideOrPanic(container, auth.NewAuthenticator)
+	provideOrPanic(container, auth.NewOidcHandler)
 
 	provideOrPanic(container, notifier.NewSubscriptionManager[*model.FeedArticle])
-	provideOrPanic(container, subscribers.NewDiscordSubscriber)
+	provideOrPanic(container, subscribers.NewDiscordSubscribers, dig.Group("subscribers,flatten"))
+	provideOrPanic(container, subscribers.NewSlackSubscribers, dig.Group("subscribers,flatten"))
+	provideOrPanic(container, subscribers.NewTelegramSubscribers, dig.Group("subscribers,flatten"))
+	provideOrPanic(container, subscribers.NewTeamsSubscribers, dig.Group("subscribers,flatten"))
+	provideOrPanic(container, subscribers.NewMattermostSubscribers, dig.Group("subscribers,flatten"))
+	provideOrPanic(container, subscribers.NewMatrixSubscribers, dig.Group("subscribers,flatten"))
+	provideOrPanic(container, subscribers.NewNtfySubscribers, dig.Group("subscribers,flatten"))
+	provideOrPanic(container, subscribers.NewGotifySubscribers, dig.Group("subscribers,flatten"))
+	provideOrPanic(container, subscribers.NewPushoverSubscribers, dig.Group("subscribers,flatten"))
+	provideOrPanic(container, subscribers.NewEmailSubscribers, dig.Group("subscribers,flatten"))
+	provideOrPanic(container, subscribers.NewWebhookSubscribers, dig.Group("subscribers,flatten"))
+	provideOrPanic(container, func(group subscriberGroup) []subscribers.Subscriber { return group.Subscribers })
+	provideOrPanic(container, subscribers.NewRegistry)
+	provideOrPanic(container, func(registry *subscribers.Registry) []outbox.Deliverer {
+		deliverers := make([]outbox.Deliverer, 0, len(registry.List()))
+		for _, subscriber := range registry.List() {
+			deliverers = append(deliverers, subscriber)
+		}
+		return deliverers
+	})
+	provideOrPanic(container, outbox.NewArticleLoader)
+	provideOrPanic(container, outbox.NewRelay)
 	provideOrPanic(container, job.NewDaemon)
 	provideOrPanic(container, job.NewParserFeedJob, dig.Group("jobs"))
 	provideOrPanic(container, func(group jobGroup) []quartz.Job { return group.Jobs })
@@ -104,9 +199,13 @@ func provideApp() (graceful.Application, error) {
 func newApplication(logger *logger.Logger,
 	collection *graceful.ShutdownCloser,
 	daemon *job.Daemon,
-	discordSubscriber *subscribers.DiscordSubscriber,
+	registry *subscribers.Registry,
+	relay *outbox.Relay,
+	subscriptionManager *notifier.SubscriptionManager[*model.FeedArticle],
 	publicApi *api.PublicApi,
 	privateApi *api.PrivateApi,
+	authenticator *auth.Authenticator,
+	oidcHandler *auth.OidcHandler,
 	graphqlServer *graphql_api.GraphqlServer,
 	tracerProvider traces.ShutdownTracerProvider,
 	prometheusRegisterer prometheusclient.Registerer,
@@ -114,8 +213,11 @@ func newApplication(logger *logger.Logger,
 	// Register prometheus metrics for promhttp.Handler()
 	graphql_api.RegisterOn(prometheusRegisterer)
 	metrics.RegisterOn(prometheusRegisterer)
+	metrics.RegisterSubscriptionsOn(prometheusRegisterer, subscriptionManager)
 
 	// Register and build api servers
+	publicApi.Router.Use(authenticator.Middleware)
+	oidcHandler.RegisterRoutes(publicApi)
 	graphqlServer.RegisterRoutes(publicApi)
 	privateApi.RegisterPrivateRoutes()
 	publicServer := publicApi.Build()
@@ -135,7 +237,10 @@ func newApplication(logger *logger.Logger,
 				return daemon.Start(errCtx)
 			})
 			group.Go(func() error {
-				return discordSubscriber.Subscribe(errCtx)
+				if err := registry.Start(errCtx); err != nil {
+					return err
+				}
+				return relay.Start(errCtx)
 			})
 			group.Go(func() error {
 				return privateServer.ListenAndServe()
@@ -150,6 +255,7 @@ func newApplication(logger *logger.Logger,
 			defer span.End()
 			defer func() {
 				metrics.UnRegisterFrom(prometheusRegisterer)
+				metrics.UnRegisterSubscriptionsFrom(prometheusRegisterer)
 				graphql_api.UnRegisterFrom(prometheusRegisterer)
 			}()
 
@@ -180,7 +286,7 @@ func provideOrPanic(container *dig.Container, constructor interface{}, opts ...d
 	}
 
 	_ = container.Invoke(func(logger *logger.Logger) {
-		logger.Error("DI container registration wrong or does not exist", err)
+		logger.Error("DI container registration wrong or does not exist", slog.Any("error", err))
 		os.Exit(1)
 	})
 }
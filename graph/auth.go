@@ -0,0 +1,32 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/auth"
+)
+
+// HasRole is the implementation of the @hasRole directive.
+func (r *Resolver) HasRole(ctx context.Context, _ interface{}, next graphql.Resolver, role model.Role) (interface{}, error) {
+	principal := r.Authenticator.Principal(ctx)
+	if !principal.Role.Allows(toAuthRole(role)) {
+		if principal.IsAnonymous() {
+			return nil, auth.ErrUnauthenticated
+		}
+		return nil, auth.ErrForbidden
+	}
+
+	return next(ctx)
+}
+
+// currentUser returns authenticated principal, per-user features are not available for anonymous
+func (r *Resolver) currentUser(ctx context.Context) (*auth.Principal, error) {
+	principal := r.Authenticator.Principal(ctx)
+	if principal.IsAnonymous() {
+		return nil, auth.ErrUnauthenticated
+	}
+
+	return principal, nil
+}
@@ -0,0 +1,80 @@
+package graph_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sealbro/go-feed-me/graph"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/auth"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAuthenticator(t *testing.T, config *auth.Config) (*auth.Authenticator, error) {
+	newLogger, err := logger.NewLogger(&logger.Config{LogLevel: "ERROR"})
+	assert.NoError(t, err, "error should be nil")
+	sessions, err := auth.NewSessions(&auth.OidcConfig{})
+	assert.NoError(t, err, "error should be nil")
+
+	return auth.NewAuthenticator(newLogger, config, nil, nil, sessions)
+}
+
+func TestResolver_HasRole(t *testing.T) {
+	viewer := &auth.Principal{UserId: 1, Name: "viewer", Role: auth.RoleViewer}
+	editor := &auth.Principal{UserId: 2, Name: "editor", Role: auth.RoleEditor}
+	admin := &auth.Principal{UserId: 3, Name: "admin", Role: auth.RoleAdmin}
+
+	testCases := []struct {
+		name          string
+		anonymousRole string
+		principal     *auth.Principal
+		required      model.Role
+		expectErr     error
+	}{
+		{name: "anonymous viewer reads", anonymousRole: "viewer", required: model.RoleViewer},
+		{name: "anonymous viewer can't edit", anonymousRole: "viewer", required: model.RoleEditor, expectErr: auth.ErrUnauthenticated},
+		{name: "anonymous access disabled", required: model.RoleViewer, expectErr: auth.ErrUnauthenticated},
+		{name: "viewer reads", principal: viewer, required: model.RoleViewer},
+		{name: "viewer can't edit", principal: viewer, required: model.RoleEditor, expectErr: auth.ErrForbidden},
+		{name: "editor edits", principal: editor, required: model.RoleEditor},
+		{name: "editor can't manage users", principal: editor, required: model.RoleAdmin, expectErr: auth.ErrForbidden},
+		{name: "admin manages users", principal: admin, required: model.RoleAdmin},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			authenticator, err := newAuthenticator(t, &auth.Config{AnonymousRole: testCase.anonymousRole})
+			assert.NoError(t, err, "error should be nil")
+			resolver := &graph.Resolver{Authenticator: authenticator}
+
+			ctx := context.Background()
+			if testCase.principal != nil {
+				ctx = auth.WithPrincipal(ctx, testCase.principal)
+			}
+
+			called := false
+			_, err = resolver.HasRole(ctx, nil, func(ctx context.Context) (interface{}, error) {
+				called = true
+				return nil, nil
+			}, testCase.required)
+
+			assert.Equal(t, testCase.expectErr, err)
+			assert.Equal(t, testCase.expectErr == nil, called, "resolver should be called only when role allows")
+		})
+	}
+}
+
+func TestNewAuthenticator_AnonymousWrite(t *testing.T) {
+	_, err := newAuthenticator(t, &auth.Config{AnonymousRole: "admin"})
+	assert.ErrorContains(t, err, "AUTH_ANONYMOUS_WRITE", "anonymous admin should require explicit opt-in")
+
+	_, err = newAuthenticator(t, &auth.Config{AnonymousRole: "editor"})
+	assert.ErrorContains(t, err, "AUTH_ANONYMOUS_WRITE", "anonymous editor should require explicit opt-in")
+
+	authenticator, err := newAuthenticator(t, &auth.Config{AnonymousRole: "editor", AnonymousWrite: true})
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, auth.RoleEditor, authenticator.Principal(context.Background()).Role)
+}
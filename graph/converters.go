@@ -0,0 +1,210 @@
+package graph
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/auth"
+	"github.com/sealbro/go-feed-me/internal/rules"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/pkg/notifier"
+)
+
+func toAuthRole(role model.Role) auth.Role {
+	return auth.Role(strings.ToLower(role.String()))
+}
+
+func toModelRole(role string) model.Role {
+	return model.Role(strings.ToUpper(role))
+}
+
+func toModelUser(user *storage.User) *model.User {
+	return &model.User{
+		ID:      user.IdString(),
+		Name:    user.Name,
+		Role:    toModelRole(user.Role),
+		Created: user.Created,
+	}
+}
+
+func toModelResource(resource *storage.Resource) *model.FeedResource {
+	return &model.FeedResource{
+		URL:             resource.Url,
+		Title:           resource.Title,
+		Created:         resource.Created,
+		Modified:        resource.Modified,
+		Published:       resource.Published,
+		Active:          resource.Active,
+		Tags:            resource.TagNames(),
+		IncludePatterns: nonNil(resource.IncludePatterns),
+		ExcludePatterns: nonNil(resource.ExcludePatterns),
+		Description:     resource.Description,
+		Link:            resource.Link,
+		Image:           resource.Image,
+		Language:        resource.Language,
+		Generator:       resource.Generator,
+	}
+}
+
+// toPatterns trims patterns and checks that they can be compiled
+func toPatterns(include []string, exclude []string) ([]string, []string, error) {
+	include, exclude = trimAll(include), trimAll(exclude)
+	if _, err := rules.NewPatterns(include, exclude); err != nil {
+		return nil, nil, err
+	}
+
+	return include, exclude, nil
+}
+
+func toModelDelivery(cursor *storage.OutboxCursor) *model.Delivery {
+	delivery := &model.Delivery{
+		Subscriber: cursor.Subscriber,
+		Cursor:     strconv.FormatUint(cursor.EventId, 10),
+		Attempts:   cursor.Attempts,
+		LastError:  cursor.LastError,
+		Updated:    cursor.Updated,
+	}
+	if !cursor.NextAttempt.IsZero() {
+		delivery.NextAttempt = &cursor.NextAttempt
+	}
+
+	return delivery
+}
+
+func toBatching(input *model.BatchInput) (notifier.Batching, error) {
+	batching := notifier.Batching{Digest: input.Digest}
+	if input.Size != nil {
+		batching.Size = *input.Size
+	}
+	if input.MaxWait != nil && *input.MaxWait != "" {
+		maxWait, err := time.ParseDuration(*input.MaxWait)
+		if err != nil {
+			return batching, fmt.Errorf("invalid max_wait %q: %w", *input.MaxWait, err)
+		}
+		batching.MaxWait = maxWait
+	}
+
+	return batching, batching.Validate()
+}
+
+func toModelRule(rule *storage.Rule) *model.Rule {
+	return &model.Rule{
+		ID:           rule.IdString(),
+		Name:         rule.Name,
+		Position:     rule.Position,
+		Enabled:      rule.Enabled,
+		Created:      rule.Created,
+		Modified:     rule.Modified,
+		Resources:    nonNil(rule.Resources),
+		Tags:         nonNil(rule.Tags),
+		TitleRegex:   rule.TitleRegex,
+		ContentRegex: rule.ContentRegex,
+		Author:       rule.Author,
+		HasImage:     rule.HasImage,
+		MinAge:       formatAge(rule.MinAge),
+		MaxAge:       formatAge(rule.MaxAge),
+		Drop:         rule.Drop,
+		MarkRead:     rule.MarkRead,
+		Star:         rule.Star,
+		AddTags:      nonNil(rule.AddTags),
+		Subscribers:  nonNil(rule.Subscribers),
+		Priority:     rule.Priority,
+		Stop:         rule.Stop,
+	}
+}
+
+// toStorageRule replaces conditions and actions of rule by input, position is kept when input has no position
+func toStorageRule(input *model.RuleInput, rule *storage.Rule) error {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return fmt.Errorf("rule name should not be empty")
+	}
+
+	minAge, err := parseAge("min_age", input.MinAge)
+	if err != nil {
+		return err
+	}
+	maxAge, err := parseAge("max_age", input.MaxAge)
+	if err != nil {
+		return err
+	}
+
+	rule.Name = name
+	if input.Position != nil {
+		rule.Position = *input.Position
+	}
+	rule.Enabled = input.Enabled
+	rule.Resources = trimAll(input.Resources)
+	rule.Tags = storage.NormalizeTags(input.Tags)
+	rule.TitleRegex = input.TitleRegex
+	rule.ContentRegex = input.ContentRegex
+	rule.Author = strings.TrimSpace(input.Author)
+	rule.HasImage = input.HasImage
+	rule.MinAge = minAge
+	rule.MaxAge = maxAge
+	rule.Drop = input.Drop
+	rule.MarkRead = input.MarkRead
+	rule.Star = input.Star
+	rule.AddTags = storage.NormalizeTags(input.AddTags)
+	rule.Subscribers = trimAll(input.Subscribers)
+	rule.Priority = input.Priority
+	rule.Stop = input.Stop
+
+	return rules.Validate(rule)
+}
+
+func parseAge(field string, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	age, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", field, value, err)
+	}
+
+	return age, nil
+}
+
+func formatAge(age time.Duration) string {
+	if age == 0 {
+		return ""
+	}
+
+	return age.String()
+}
+
+func trimAll(values []string) []string {
+	trimmed := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			trimmed = append(trimmed, value)
+		}
+	}
+
+	return trimmed
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+
+	return values
+}
+
+func parseIds(ids []string) ([]uint64, error) {
+	parsed := make([]uint64, len(ids))
+	for i, id := range ids {
+		value, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %q: %w", id, err)
+		}
+		parsed[i] = value
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,99 @@
+package graph
+
+import (
+	"context"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"sync"
+	"time"
+)
+
+// duplicatesWait time to collect links of sibling articles, their duplicates are loaded by one query
+const duplicatesWait = 2 * time.Millisecond
+
+type duplicatesLoaderKey struct{}
+
+// duplicatesLoader batches duplicates of articles resolved by one operation
+type duplicatesLoader struct {
+	ctx      context.Context
+	resolver *Resolver
+	m        sync.Mutex
+	batch    *duplicatesBatch
+}
+
+type duplicatesBatch struct {
+	links      []string
+	done       chan struct{}
+	duplicates map[string][]*model.FeedArticle
+	err        error
+}
+
+// WithDuplicatesLoader returns context of operation, duplicates of its articles are batch loaded
+func (r *Resolver) WithDuplicatesLoader(ctx context.Context) context.Context {
+	return context.WithValue(ctx, duplicatesLoaderKey{}, &duplicatesLoader{ctx: ctx, resolver: r})
+}
+
+// loadDuplicates returns duplicates of article with link, without loader of operation they are loaded by own query
+func (r *Resolver) loadDuplicates(ctx context.Context, link string) ([]*model.FeedArticle, error) {
+	loader, ok := ctx.Value(duplicatesLoaderKey{}).(*duplicatesLoader)
+	if !ok {
+		duplicates, err := r.duplicates(ctx, []string{link})
+		return duplicates[link], err
+	}
+
+	return loader.load(link)
+}
+
+func (l *duplicatesLoader) load(link string) ([]*model.FeedArticle, error) {
+	l.m.Lock()
+	batch := l.batch
+	if batch == nil {
+		batch = &duplicatesBatch{done: make(chan struct{})}
+		l.batch = batch
+		time.AfterFunc(duplicatesWait, func() { l.fetch(batch) })
+	}
+	batch.links = append(batch.links, link)
+	l.m.Unlock()
+
+	<-batch.done
+
+	return batch.duplicates[link], batch.err
+}
+
+func (l *duplicatesLoader) fetch(batch *duplicatesBatch) {
+	l.m.Lock()
+	l.batch = nil
+	l.m.Unlock()
+
+	batch.duplicates, batch.err = l.resolver.duplicates(l.ctx, batch.links)
+	close(batch.done)
+}
+
+// duplicates returns duplicates of articles grouped by link
+func (r *Resolver) duplicates(ctx context.Context, links []string) (map[string][]*model.FeedArticle, error) {
+	stored, err := r.ArticleRepository.Duplicates(ctx, links)
+	if err != nil || len(stored) == 0 {
+		return nil, err
+	}
+
+	originals := make([]string, 0, len(stored))
+	articles := make([]*storage.Article, 0, len(stored))
+	for link, duplicates := range stored {
+		for _, article := range duplicates {
+			originals = append(originals, link)
+			articles = append(articles, article)
+		}
+	}
+
+	feedArticles, err := r.ArticleLoader.LoadArticles(ctx, articles)
+	if err != nil {
+		return nil, err
+	}
+
+	duplicates := make(map[string][]*model.FeedArticle, len(stored))
+	for i, feedArticle := range feedArticles {
+		duplicates[originals[i]] = append(duplicates[originals[i]], feedArticle)
+	}
+
+	return duplicates, nil
+}
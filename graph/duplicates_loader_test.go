@@ -0,0 +1,63 @@
+package graph_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestFeedArticle_DuplicatesBatchLoaded(t *testing.T) {
+	f := newTestFeed(t)
+	ctx := context.Background()
+
+	originals := []string{"a", "b", "c"}
+	for _, link := range originals {
+		f.publish(t, link)
+	}
+	for _, duplicate := range []struct{ link, duplicateOf string }{{"a-1", "a"}, {"a-2", "a"}, {"b-1", "b"}} {
+		err := f.resolver.ArticleRepository.Upsert(ctx, &storage.Article{
+			ResourceId: testResource, Link: duplicate.link, DuplicateOf: duplicate.duplicateOf, Created: time.Now(), Published: time.Now(),
+		})
+		assert.NoError(t, err, "error should be nil")
+	}
+
+	var queries atomic.Int32
+	err := f.database.Callback().Query().After("gorm:query").Register("count_duplicates", func(tx *gorm.DB) {
+		if strings.Contains(tx.Statement.SQL.String(), "duplicate_of IN") {
+			queries.Add(1)
+		}
+	})
+	assert.NoError(t, err, "error should be nil")
+
+	operationCtx := f.resolver.WithDuplicatesLoader(ctx)
+	duplicates := make([][]*model.FeedArticle, len(originals))
+	wg := sync.WaitGroup{}
+	for i, link := range originals {
+		wg.Add(1)
+		go func(i int, link string) {
+			defer wg.Done()
+			articles, err := f.resolver.FeedArticle().Duplicates(operationCtx, &model.FeedArticle{Link: link})
+			assert.NoError(t, err, "error should be nil")
+			duplicates[i] = articles
+		}(i, link)
+	}
+	wg.Wait()
+
+	links := make([][]string, len(duplicates))
+	for i, articles := range duplicates {
+		links[i] = []string{}
+		for _, article := range articles {
+			links[i] = append(links[i], article.Link)
+		}
+	}
+	assert.Equal(t, [][]string{{"a-1", "a-2"}, {"b-1"}, {}}, links, "duplicates should be grouped by article oldest first")
+	assert.Equal(t, int32(1), queries.Load(), "duplicates of sibling articles should be loaded by one query")
+}
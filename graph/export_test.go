@@ -0,0 +1,6 @@
+package graph
+
+// SetReplayBatchSize changes number of articles in replayed batches of resolver
+func SetReplayBatchSize(r *Resolver, size int) {
+	r.replayBatch = size
+}
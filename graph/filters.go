@@ -0,0 +1,69 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const maxRegexLength = 256
+
+// articleMatcher builds subscription filter, subscribed narrows resources to subscriptions of current user
+func (r *Resolver) articleMatcher(ctx context.Context, resources []string, tags []string, keyword *string, regex *string, author *string, categories []string, subscribed *bool) (*model.ArticleMatcher, error) {
+	matcher := &model.ArticleMatcher{
+		Resources: resources,
+		Tags:      storage.NormalizeTags(tags),
+	}
+	for _, category := range categories {
+		if category = strings.TrimSpace(category); category != "" {
+			matcher.Categories = append(matcher.Categories, category)
+		}
+	}
+
+	if subscribed != nil && *subscribed {
+		user, err := r.currentUser(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		subscribedUrls, err := r.UserRepository.SubscribedUrls(ctx, user.UserId)
+		if err != nil {
+			return nil, err
+		}
+
+		if matcher.Resources == nil {
+			matcher.Resources = subscribedUrls
+		} else {
+			matcher.Resources = slices.DeleteFunc(slices.Clone(matcher.Resources), func(url string) bool {
+				return !slices.Contains(subscribedUrls, url)
+			})
+		}
+		if matcher.Resources == nil {
+			matcher.Resources = []string{}
+		}
+	}
+
+	if keyword != nil {
+		matcher.Keyword = strings.ToLower(strings.TrimSpace(*keyword))
+	}
+	if author != nil {
+		matcher.Author = strings.TrimSpace(*author)
+	}
+	if regex != nil && *regex != "" {
+		if len(*regex) > maxRegexLength {
+			return nil, fmt.Errorf("regex is longer than %d characters", maxRegexLength)
+		}
+
+		pattern, err := regexp.Compile(*regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+		matcher.Pattern = pattern
+	}
+
+	return matcher, nil
+}
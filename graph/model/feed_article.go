@@ -0,0 +1,153 @@
+package model
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+type FeedArticle struct {
+	Created       time.Time `json:"created"`
+	Published     time.Time `json:"published"`
+	ResourceID    string    `json:"resource_id"`
+	ResourceTitle string    `json:"resource_title"`
+	Link          string    `json:"link"`
+	OriginalLink  string    `json:"original_link"`
+	Guid          string    `json:"guid"`
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	Content       string    `json:"content"`
+	Author        string    `json:"author"`
+	Authors       []*Author `json:"authors"`
+	Categories    []string  `json:"categories"`
+	Image         string    `json:"image"`
+	Tags          []string  `json:"tags"`
+	Read          bool      `json:"read"`
+	Starred       bool      `json:"starred"`
+	// Updated last change of item, nil when feed has no such time
+	Updated *time.Time `json:"updated,omitempty"`
+	// Enclosures media files of article
+	Enclosures []*Enclosure `json:"enclosures"`
+	// Priority added to priority of push notifications by rules
+	Priority int `json:"priority"`
+	// Subscribers names of subscribers which get article, empty means all subscribers
+	Subscribers []string `json:"-"`
+	// EventId outbox event which published article, 0 when article is not read from outbox
+	EventId uint64 `json:"-"`
+}
+
+// HasAnyTag reports whether article has at least one of tags, empty tags match any article
+func (a *FeedArticle) HasAnyTag(tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+
+	for _, tag := range tags {
+		if slices.Contains(a.Tags, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RoutedTo reports whether article is delivered to subscriber
+func (a *FeedArticle) RoutedTo(subscriber string) bool {
+	return len(a.Subscribers) == 0 || slices.Contains(a.Subscribers, subscriber)
+}
+
+// FilterByTags returns only articles having any of tags
+func FilterByTags(articles []*FeedArticle, tags []string) []*FeedArticle {
+	if len(tags) == 0 {
+		return articles
+	}
+
+	filtered := make([]*FeedArticle, 0, len(articles))
+	for _, article := range articles {
+		if article.HasAnyTag(tags) {
+			filtered = append(filtered, article)
+		}
+	}
+
+	return filtered
+}
+
+// ArticleMatcher server side filter of articles, zero values match any article
+type ArticleMatcher struct {
+	// Resources only articles of resources with urls, nil matches any resource and empty matches none
+	Resources []string
+	// Tags only articles having any of normalized tags
+	Tags []string
+	// Keyword lower case text in title, description or content, compared case-insensitively
+	Keyword string
+	// Pattern regular expression on title, description or content
+	Pattern *regexp.Regexp
+	// Author name of any author, compared case-insensitively
+	Author string
+	// Categories only articles of any of categories, compared case-insensitively
+	Categories []string
+}
+
+func (m *ArticleMatcher) Match(article *FeedArticle) bool {
+	if m.Resources != nil && !slices.Contains(m.Resources, article.ResourceID) {
+		return false
+	}
+	if !article.HasAnyTag(m.Tags) {
+		return false
+	}
+	if m.Author != "" && !article.hasAuthor(m.Author) {
+		return false
+	}
+	if len(m.Categories) > 0 && !article.hasAnyCategory(m.Categories) {
+		return false
+	}
+	if m.Keyword != "" && !article.hasText(func(text string) bool {
+		return strings.Contains(strings.ToLower(text), m.Keyword)
+	}) {
+		return false
+	}
+	if m.Pattern != nil && !article.hasText(m.Pattern.MatchString) {
+		return false
+	}
+
+	return true
+}
+
+// Filter returns only matching articles
+func (m *ArticleMatcher) Filter(articles []*FeedArticle) []*FeedArticle {
+	filtered := make([]*FeedArticle, 0, len(articles))
+	for _, article := range articles {
+		if m.Match(article) {
+			filtered = append(filtered, article)
+		}
+	}
+
+	return filtered
+}
+
+func (a *FeedArticle) hasText(match func(text string) bool) bool {
+	return match(a.Title) || match(a.Description) || match(a.Content)
+}
+
+func (a *FeedArticle) hasAuthor(name string) bool {
+	if strings.EqualFold(strings.TrimSpace(a.Author), name) {
+		return true
+	}
+
+	return slices.ContainsFunc(a.Authors, func(author *Author) bool {
+		return strings.EqualFold(strings.TrimSpace(author.Name), name)
+	})
+}
+
+func (a *FeedArticle) hasAnyCategory(categories []string) bool {
+	for _, category := range categories {
+		if slices.ContainsFunc(a.Categories, func(articleCategory string) bool {
+			return strings.EqualFold(articleCategory, category)
+		}) {
+			return true
+		}
+	}
+
+	return false
+}
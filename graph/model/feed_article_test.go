@@ -0,0 +1,77 @@
+package model_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArticleMatcher_Match(t *testing.T) {
+	article := &model.FeedArticle{
+		ResourceID:  "https://github.com/opencv/opencv/releases.atom",
+		Title:       "OpenCV 4.10.0",
+		Description: "Bug fixes",
+		Content:     "<p>New DNN backend</p>",
+		Author:      "asmorkalov",
+		Tags:        []string{"release"},
+	}
+
+	tests := []struct {
+		name    string
+		matcher *model.ArticleMatcher
+		want    bool
+	}{
+		{name: "empty matcher", matcher: &model.ArticleMatcher{}, want: true},
+		{name: "resource", matcher: &model.ArticleMatcher{Resources: []string{article.ResourceID}}, want: true},
+		{name: "other resource", matcher: &model.ArticleMatcher{Resources: []string{"https://example.com"}}, want: false},
+		{name: "no resources", matcher: &model.ArticleMatcher{Resources: []string{}}, want: false},
+		{name: "tag", matcher: &model.ArticleMatcher{Tags: []string{"go", "release"}}, want: true},
+		{name: "other tag", matcher: &model.ArticleMatcher{Tags: []string{"go"}}, want: false},
+		{name: "keyword in title", matcher: &model.ArticleMatcher{Keyword: "opencv"}, want: true},
+		{name: "keyword in content", matcher: &model.ArticleMatcher{Keyword: "dnn"}, want: true},
+		{name: "missing keyword", matcher: &model.ArticleMatcher{Keyword: "cuda"}, want: false},
+		{name: "regex", matcher: &model.ArticleMatcher{Pattern: regexp.MustCompile(`4\.\d+\.0`)}, want: true},
+		{name: "not matching regex", matcher: &model.ArticleMatcher{Pattern: regexp.MustCompile(`^5\.`)}, want: false},
+		{name: "author", matcher: &model.ArticleMatcher{Author: "ASmorkalov"}, want: true},
+		{name: "other author", matcher: &model.ArticleMatcher{Author: "alalek"}, want: false},
+		{name: "all filters", matcher: &model.ArticleMatcher{Tags: []string{"release"}, Keyword: "bug", Author: "asmorkalov"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.matcher.Match(article))
+		})
+	}
+}
+
+func TestArticleMatcher_MatchAuthorsAndCategories(t *testing.T) {
+	article := &model.FeedArticle{
+		ResourceID: "https://github.com/opencv/opencv/releases.atom",
+		Title:      "OpenCV 4.10.0",
+		Author:     "asmorkalov",
+		Tags:       []string{"release"},
+		Authors:    []*model.Author{{Name: "asmorkalov"}, {Name: "alalek"}},
+		Categories: []string{"Computer Vision", "C++"},
+	}
+
+	tests := []struct {
+		name    string
+		matcher *model.ArticleMatcher
+		want    bool
+	}{
+		{name: "first author", matcher: &model.ArticleMatcher{Author: "ASmorkalov"}, want: true},
+		{name: "second author", matcher: &model.ArticleMatcher{Author: "Alalek"}, want: true},
+		{name: "other author", matcher: &model.ArticleMatcher{Author: "vpisarev"}, want: false},
+		{name: "category", matcher: &model.ArticleMatcher{Categories: []string{"python", "computer vision"}}, want: true},
+		{name: "other category", matcher: &model.ArticleMatcher{Categories: []string{"python"}}, want: false},
+		{name: "all filters", matcher: &model.ArticleMatcher{Tags: []string{"release"}, Author: "alalek", Categories: []string{"c++"}}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.matcher.Match(article))
+		})
+	}
+}
@@ -3,41 +3,207 @@
 package model
 
 import (
+	"fmt"
+	"io"
+	"strconv"
 	"time"
 )
 
-type FeedArticle struct {
-	Created       time.Time `json:"created"`
-	Published     time.Time `json:"published"`
-	ResourceID    string    `json:"resource_id"`
-	ResourceTitle string    `json:"resource_title"`
-	Link          string    `json:"link"`
-	Title         string    `json:"title"`
-	Description   string    `json:"description"`
-	Content       string    `json:"content"`
-	Author        string    `json:"author"`
-	Image         string    `json:"image"`
+type APIKey struct {
+	ID       string     `json:"id"`
+	Name     string     `json:"name"`
+	Created  time.Time  `json:"created"`
+	LastUsed *time.Time `json:"last_used,omitempty"`
+}
+
+type ArticleBatch struct {
+	Cursor   string         `json:"cursor"`
+	Articles []*FeedArticle `json:"articles"`
+}
+
+type Author struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type BatchInput struct {
+	Size    *int     `json:"size,omitempty"`
+	MaxWait *string  `json:"max_wait,omitempty"`
+	Digest  []string `json:"digest,omitempty"`
+}
+
+type DeadLetter struct {
+	ID         string    `json:"id"`
+	Subscriber string    `json:"subscriber"`
+	Link       string    `json:"link"`
+	Attempts   int       `json:"attempts"`
+	LastError  string    `json:"last_error"`
+	Created    time.Time `json:"created"`
+	Replay     bool      `json:"replay"`
+}
+
+type Delivery struct {
+	Subscriber  string     `json:"subscriber"`
+	Cursor      string     `json:"cursor"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error"`
+	NextAttempt *time.Time `json:"next_attempt,omitempty"`
+	Updated     time.Time  `json:"updated"`
+}
+
+type Enclosure struct {
+	URL       string `json:"url"`
+	Type      string `json:"type"`
+	Length    int    `json:"length"`
+	Duration  *int   `json:"duration,omitempty"`
+	Episode   *int   `json:"episode,omitempty"`
+	Season    *int   `json:"season,omitempty"`
+	Thumbnail string `json:"thumbnail"`
 }
 
 type FeedResource struct {
-	URL       string    `json:"url"`
-	Title     string    `json:"title"`
-	Created   time.Time `json:"created"`
-	Modified  time.Time `json:"modified"`
-	Published time.Time `json:"published"`
-	Active    bool      `json:"active"`
+	URL             string    `json:"url"`
+	Title           string    `json:"title"`
+	Created         time.Time `json:"created"`
+	Modified        time.Time `json:"modified"`
+	Published       time.Time `json:"published"`
+	Active          bool      `json:"active"`
+	Tags            []string  `json:"tags"`
+	IncludePatterns []string  `json:"include_patterns"`
+	ExcludePatterns []string  `json:"exclude_patterns"`
+	Description     string    `json:"description"`
+	Link            string    `json:"link"`
+	Image           string    `json:"image"`
+	Language        string    `json:"language"`
+	Generator       string    `json:"generator"`
 }
 
 type Mutation struct {
 }
 
+type NewAPIKey struct {
+	ID  string `json:"id"`
+	Key string `json:"key"`
+}
+
 type NewResource struct {
-	URL    string `json:"url"`
-	Active bool   `json:"active"`
+	URL             string   `json:"url"`
+	Active          bool     `json:"active"`
+	Tags            []string `json:"tags,omitempty"`
+	IncludePatterns []string `json:"include_patterns,omitempty"`
+	ExcludePatterns []string `json:"exclude_patterns,omitempty"`
 }
 
 type Query struct {
 }
 
+type Rule struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Position     int       `json:"position"`
+	Enabled      bool      `json:"enabled"`
+	Created      time.Time `json:"created"`
+	Modified     time.Time `json:"modified"`
+	Resources    []string  `json:"resources"`
+	Tags         []string  `json:"tags"`
+	TitleRegex   string    `json:"title_regex"`
+	ContentRegex string    `json:"content_regex"`
+	Author       string    `json:"author"`
+	HasImage     *bool     `json:"has_image,omitempty"`
+	MinAge       string    `json:"min_age"`
+	MaxAge       string    `json:"max_age"`
+	Drop         bool      `json:"drop"`
+	MarkRead     bool      `json:"mark_read"`
+	Star         bool      `json:"star"`
+	AddTags      []string  `json:"add_tags"`
+	Subscribers  []string  `json:"subscribers"`
+	Priority     int       `json:"priority"`
+	Stop         bool      `json:"stop"`
+}
+
+type RuleInput struct {
+	Name         string   `json:"name"`
+	Position     *int     `json:"position,omitempty"`
+	Enabled      bool     `json:"enabled"`
+	Resources    []string `json:"resources"`
+	Tags         []string `json:"tags"`
+	TitleRegex   string   `json:"title_regex"`
+	ContentRegex string   `json:"content_regex"`
+	Author       string   `json:"author"`
+	HasImage     *bool    `json:"has_image,omitempty"`
+	MinAge       string   `json:"min_age"`
+	MaxAge       string   `json:"max_age"`
+	Drop         bool     `json:"drop"`
+	MarkRead     bool     `json:"mark_read"`
+	Star         bool     `json:"star"`
+	AddTags      []string `json:"add_tags"`
+	Subscribers  []string `json:"subscribers"`
+	Priority     int      `json:"priority"`
+	Stop         bool     `json:"stop"`
+}
+
 type Subscription struct {
 }
+
+type User struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Role    Role      `json:"role"`
+	Created time.Time `json:"created"`
+}
+
+type WebhookDelivery struct {
+	ID         string    `json:"id"`
+	Subscriber string    `json:"subscriber"`
+	URL        string    `json:"url"`
+	DeliveryID string    `json:"delivery_id"`
+	Attempt    int       `json:"attempt"`
+	Articles   int       `json:"articles"`
+	StatusCode int       `json:"status_code"`
+	DurationMs int       `json:"duration_ms"`
+	Error      string    `json:"error"`
+	Created    time.Time `json:"created"`
+}
+
+type Role string
+
+const (
+	RoleAdmin  Role = "ADMIN"
+	RoleEditor Role = "EDITOR"
+	RoleViewer Role = "VIEWER"
+)
+
+var AllRole = []Role{
+	RoleAdmin,
+	RoleEditor,
+	RoleViewer,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleAdmin, RoleEditor, RoleViewer:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
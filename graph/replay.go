@@ -0,0 +1,167 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const defaultReplayBatchSize = 50
+
+// prunedCursorSeparator separates created time and link of the last replayed article of pruned events,
+// articles of one fetch share created time, so link breaks the tie
+const prunedCursorSeparator = "|"
+
+var ErrCursorExpired = errors.New("cursor expired, resume with RFC3339 time instead")
+
+// subscriberIds numbers graphql subscribers, subscriptions opened at the same time must not share id
+var subscriberIds atomic.Uint64
+
+func newSubscriberId() string {
+	return "graphql-" + strconv.FormatUint(subscriberIds.Add(1), 10)
+}
+
+// articleReplay stored articles which subscriber missed, live events up to latestId are already replayed
+type articleReplay struct {
+	// after articles of pruned events created after time, zero when cursor is event id
+	after time.Time
+	// afterLink articles created at after time with greater link are replayed too, empty for RFC3339 cursor
+	afterLink string
+	afterId   uint64
+	latestId  uint64
+}
+
+// sendBatch emits batch, returns false when subscriber is gone
+type sendBatch func(cursor string, articles []*model.FeedArticle) bool
+
+// newReplay resolves cursor, must be called after subscriber is added to not miss events published in between
+func (r *Resolver) newReplay(ctx context.Context, since string) (*articleReplay, error) {
+	afterId, after, afterLink, err := parseCursor(since)
+	if err != nil {
+		return nil, err
+	}
+
+	latestId, err := r.OutboxRepository.LatestId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !after.IsZero() {
+		afterId, err = r.OutboxRepository.LatestIdBefore(ctx, after)
+		if err != nil {
+			return nil, err
+		}
+	} else if afterId > 0 {
+		// outbox is pruned from the oldest events, so a kept cursor event guarantees no gaps after it
+		exists, err := r.OutboxRepository.Exists(ctx, afterId)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrCursorExpired
+		}
+	}
+
+	return &articleReplay{after: after, afterLink: afterLink, afterId: afterId, latestId: latestId}, nil
+}
+
+// replayArticles sends matching articles of pruned events first and then articles of kept events up to latestId
+func (r *Resolver) replayArticles(ctx context.Context, replay *articleReplay, matcher *model.ArticleMatcher, send sendBatch) (bool, error) {
+	if !replay.after.IsZero() {
+		pruned, err := r.ArticleRepository.ListPruned(ctx, replay.after, replay.afterLink)
+		if err != nil {
+			return false, err
+		}
+
+		batchSize := r.replayBatchSize()
+		for start := 0; start < len(pruned); start += batchSize {
+			batch := pruned[start:min(start+batchSize, len(pruned))]
+			articles, err := r.ArticleLoader.LoadArticles(ctx, batch)
+			if err != nil {
+				return false, err
+			}
+
+			if !send(prunedCursor(batch[len(batch)-1]), matcher.Filter(articles)) {
+				return false, nil
+			}
+		}
+	}
+
+	afterId := replay.afterId
+	for afterId < replay.latestId {
+		events, err := r.OutboxRepository.Between(ctx, afterId, replay.latestId, r.replayBatchSize())
+		if err != nil || len(events) == 0 {
+			return err == nil, err
+		}
+		afterId = events[len(events)-1].Id
+
+		articles, err := r.ArticleLoader.Load(ctx, events)
+		if err != nil {
+			return false, err
+		}
+
+		if !send(eventCursor(afterId), matcher.Filter(articles)) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (r *Resolver) replayBatchSize() int {
+	if r.replayBatch > 0 {
+		return r.replayBatch
+	}
+
+	return defaultReplayBatchSize
+}
+
+// skipReplayed drops live events which were already sent by replay
+func skipReplayed(articles []*model.FeedArticle, latestId uint64) []*model.FeedArticle {
+	live := make([]*model.FeedArticle, 0, len(articles))
+	for _, article := range articles {
+		if article.EventId > latestId {
+			live = append(live, article)
+		}
+	}
+
+	return live
+}
+
+func liveCursor(articles []*model.FeedArticle) string {
+	var eventId uint64
+	for _, article := range articles {
+		eventId = max(eventId, article.EventId)
+	}
+
+	return eventCursor(eventId)
+}
+
+func eventCursor(eventId uint64) string {
+	return strconv.FormatUint(eventId, 10)
+}
+
+func prunedCursor(article *storage.Article) string {
+	return article.Created.Format(time.RFC3339Nano) + prunedCursorSeparator + article.Link
+}
+
+// parseCursor accepts batch cursor (outbox event id or created time with link of pruned article) or RFC3339 time
+func parseCursor(cursor string) (uint64, time.Time, string, error) {
+	if eventId, err := strconv.ParseUint(cursor, 10, 64); err == nil {
+		return eventId, time.Time{}, "", nil
+	}
+
+	created, afterLink, _ := strings.Cut(cursor, prunedCursorSeparator)
+	after, err := time.Parse(time.RFC3339Nano, created)
+	if err != nil {
+		return 0, time.Time{}, "", fmt.Errorf("invalid cursor %q: expected batch cursor or RFC3339 time", cursor)
+	}
+
+	return 0, after, afterLink, nil
+}
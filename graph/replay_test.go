@@ -0,0 +1,290 @@
+package graph_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sealbro/go-feed-me/graph"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/db"
+	"github.com/sealbro/go-feed-me/internal/outbox"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/pkg/graceful"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"github.com/sealbro/go-feed-me/pkg/notifier"
+	"github.com/stretchr/testify/assert"
+)
+
+const testResource = "https://example.com/feed"
+
+type testFeed struct {
+	resolver *graph.Resolver
+	database *db.DB
+}
+
+func newTestFeed(t *testing.T) *testFeed {
+	newLogger, err := logger.NewLogger(&logger.Config{LogLevel: "ERROR"})
+	assert.NoError(t, err, "error should be nil")
+
+	database, err := db.NewSqliteDatabase(logger.NewGormLogger(newLogger), &db.Config{
+		SqliteConnection: filepath.Join(t.TempDir(), "feed.db"),
+	})
+	assert.NoError(t, err, "error should be nil")
+
+	resourceRepository, err := storage.NewResourceRepository(database)
+	assert.NoError(t, err, "error should be nil")
+	articleRepository, err := storage.NewArticleRepository(database)
+	assert.NoError(t, err, "error should be nil")
+	outboxRepository, err := storage.NewOutboxRepository(database)
+	assert.NoError(t, err, "error should be nil")
+
+	closer := graceful.NewShutdownCloser()
+	t.Cleanup(func() { _ = closer.Close() })
+	manager, err := notifier.NewSubscriptionManager[*model.FeedArticle](newLogger, &notifier.Config{
+		BatchSize:      1,
+		BatchTime:      time.Millisecond,
+		BufferSize:     16,
+		OverflowPolicy: notifier.DropOldest,
+	}, closer)
+	assert.NoError(t, err, "error should be nil")
+
+	err = resourceRepository.Upsert(context.Background(), &storage.Resource{Url: testResource, Title: "Example", Active: true})
+	assert.NoError(t, err, "error should be nil")
+
+	articleLoader := outbox.NewArticleLoader(articleRepository, resourceRepository)
+
+	return &testFeed{
+		resolver: &graph.Resolver{
+			ArticleRepository:   articleRepository,
+			ResourceRepository:  resourceRepository,
+			OutboxRepository:    outboxRepository,
+			SubscriptionManager: manager,
+			ArticleLoader:       articleLoader,
+		},
+		database: database,
+	}
+}
+
+// publish saves article like parser job does and notifies live subscribers
+func (f *testFeed) publish(t *testing.T, link string) *model.FeedArticle {
+	article := &storage.Article{ResourceId: testResource, Link: link, Title: link, Created: time.Now(), Published: time.Now()}
+	eventId, err := f.resolver.ArticleRepository.UpsertAndPublish(context.Background(), article)
+	assert.NoError(t, err, "error should be nil")
+
+	feedArticle := outbox.NewFeedArticle(article, nil)
+	feedArticle.EventId = eventId
+	f.resolver.SubscriptionManager.Notify(feedArticle)
+
+	return feedArticle
+}
+
+func (f *testFeed) subscribe(t *testing.T, ctx context.Context, since *string) <-chan *model.ArticleBatch {
+	batches, err := f.resolver.Subscription().Articles(ctx, nil, nil, nil, nil, nil, nil, nil, since, nil)
+	assert.NoError(t, err, "error should be nil")
+
+	return batches
+}
+
+func receive(t *testing.T, batches <-chan *model.ArticleBatch, count int) ([]string, string) {
+	var links []string
+	var cursor string
+	for len(links) < count {
+		select {
+		case batch := <-batches:
+			for _, article := range batch.Articles {
+				links = append(links, article.Link)
+			}
+			cursor = batch.Cursor
+		case <-time.After(2 * time.Second):
+			assert.Fail(t, "timeout waiting for articles", "received %v", links)
+			return links, cursor
+		}
+	}
+
+	return links, cursor
+}
+
+func TestSubscriptionArticles_ResumeFromCursor(t *testing.T) {
+	f := newTestFeed(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	batches := f.subscribe(t, ctx, nil)
+	f.publish(t, "b")
+
+	links, cursor := receive(t, batches, 1)
+	assert.Equal(t, []string{"b"}, links)
+	cancel()
+
+	// published while client was offline
+	f.publish(t, "c")
+	f.publish(t, "d")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	batches = f.subscribe(t, ctx, &cursor)
+	f.publish(t, "e")
+
+	links, _ = receive(t, batches, 3)
+	assert.Equal(t, []string{"c", "d", "e"}, links, "replay should switch to live events without gaps or duplicates")
+
+	select {
+	case batch := <-batches:
+		assert.Fail(t, "unexpected batch", "cursor %s", batch.Cursor)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSubscriptionArticles_ResumeFromTime(t *testing.T) {
+	f := newTestFeed(t)
+
+	f.publish(t, "a")
+	since := time.Now().Format(time.RFC3339Nano)
+	time.Sleep(time.Millisecond)
+	f.publish(t, "b")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	batches := f.subscribe(t, ctx, &since)
+
+	links, cursor := receive(t, batches, 1)
+	assert.Equal(t, []string{"b"}, links)
+	assert.NotEmpty(t, cursor)
+}
+
+func TestSubscriptionArticles_InvalidCursor(t *testing.T) {
+	f := newTestFeed(t)
+	ctx := context.Background()
+
+	invalid := "yesterday"
+	_, err := f.resolver.Subscription().Articles(ctx, nil, nil, nil, nil, nil, nil, nil, &invalid, nil)
+	assert.Error(t, err, "error should not be nil")
+
+	expired := "42"
+	_, err = f.resolver.Subscription().Articles(ctx, nil, nil, nil, nil, nil, nil, nil, &expired, nil)
+	assert.ErrorIs(t, err, graph.ErrCursorExpired)
+}
+
+func TestSubscriptionArticles_FiltersReplayAndLive(t *testing.T) {
+	f := newTestFeed(t)
+
+	f.publish(t, "opencv-4.9")
+	since := "0"
+	f.publish(t, "gocv-0.36")
+	f.publish(t, "opencv-4.10")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	keyword := "OpenCV"
+	batches, err := f.resolver.Subscription().Articles(ctx, nil, nil, &keyword, nil, nil, nil, nil, &since, nil)
+	assert.NoError(t, err, "error should be nil")
+
+	f.publish(t, "gocv-0.37")
+	f.publish(t, "opencv-4.11")
+
+	links, _ := receive(t, batches, 3)
+	assert.Equal(t, []string{"opencv-4.9", "opencv-4.10", "opencv-4.11"}, links, "replayed and live articles should be filtered")
+
+	regex := "("
+	_, err = f.resolver.Subscription().Articles(ctx, nil, nil, nil, &regex, nil, nil, nil, nil, nil)
+	assert.Error(t, err, "invalid regex should be rejected")
+}
+
+func TestSubscriptionArticles_Batching(t *testing.T) {
+	f := newTestFeed(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	size := 2
+	maxWait := "1h"
+	batches, err := f.resolver.Subscription().Articles(ctx, nil, nil, nil, nil, nil, nil, nil, nil, &model.BatchInput{Size: &size, MaxWait: &maxWait})
+	assert.NoError(t, err, "error should be nil")
+
+	f.publish(t, "a")
+	f.publish(t, "b")
+	f.publish(t, "c")
+
+	batch := <-batches
+	assert.Len(t, batch.Articles, 2, "articles should be collected up to batch size")
+	assert.Equal(t, "2", batch.Cursor)
+
+	invalid := "soon"
+	_, err = f.resolver.Subscription().Articles(ctx, nil, nil, nil, nil, nil, nil, nil, nil, &model.BatchInput{MaxWait: &invalid})
+	assert.Error(t, err, "invalid max wait should be rejected")
+}
+
+func TestSubscriptionArticles_Filters(t *testing.T) {
+	f := newTestFeed(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tags := []string{"release"}
+	batches, err := f.resolver.Subscription().Articles(ctx, nil, tags, nil, nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err, "error should be nil")
+
+	f.publish(t, "a")
+	f.resolver.SubscriptionManager.Notify(&model.FeedArticle{EventId: 2, ResourceID: testResource, Link: "b", Tags: tags})
+
+	select {
+	case received := <-batches:
+		assert.Len(t, received.Articles, 1)
+		assert.Equal(t, "b", received.Articles[0].Link, "articles should be filtered")
+		assert.Equal(t, "2", received.Cursor)
+	case <-time.After(2 * time.Second):
+		assert.Fail(t, "timeout waiting for articles")
+	}
+}
+
+func TestSubscriptionArticles_ConcurrentSubscriptions(t *testing.T) {
+	f := newTestFeed(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	subscriptions := make([]<-chan *model.ArticleBatch, 20)
+	wg := sync.WaitGroup{}
+	for i := range subscriptions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			subscriptions[i] = f.subscribe(t, ctx, nil)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Len(t, f.resolver.SubscriptionManager.Stats(), len(subscriptions), "subscriptions opened at the same time should get own ids")
+
+	f.publish(t, "a")
+	for _, batches := range subscriptions {
+		links, _ := receive(t, batches, 1)
+		assert.Equal(t, []string{"a"}, links)
+	}
+}
+
+func TestSubscriptionArticles_ResumeInsideFetch(t *testing.T) {
+	f := newTestFeed(t)
+	graph.SetReplayBatchSize(f.resolver, 1)
+
+	// articles of one fetch share created time, their outbox events are already pruned
+	created := time.Now()
+	for _, link := range []string{"a", "b", "c"} {
+		err := f.resolver.ArticleRepository.Upsert(context.Background(), &storage.Article{ResourceId: testResource, Link: link, Created: created, Published: created})
+		assert.NoError(t, err, "error should be nil")
+	}
+
+	since := created.Add(-time.Second).Format(time.RFC3339Nano)
+	ctx, cancel := context.WithCancel(context.Background())
+	batches := f.subscribe(t, ctx, &since)
+
+	links, cursor := receive(t, batches, 1)
+	assert.Equal(t, []string{"a"}, links)
+	cancel()
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	batches = f.subscribe(t, ctx, &cursor)
+
+	links, _ = receive(t, batches, 2)
+	assert.Equal(t, []string{"b", "c"}, links, "articles created with the last received one should be replayed")
+}
@@ -2,7 +2,10 @@ package graph
 
 import (
 	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/auth"
+	"github.com/sealbro/go-feed-me/internal/outbox"
 	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/internal/subscribers"
 	"github.com/sealbro/go-feed-me/internal/traces"
 	"github.com/sealbro/go-feed-me/pkg/notifier"
 )
@@ -14,6 +17,16 @@ import (
 type Resolver struct {
 	*storage.ArticleRepository
 	*storage.ResourceRepository
+	*storage.UserRepository
+	*storage.ApiKeyRepository
+	*storage.OutboxRepository
+	*storage.WebhookDeliveryRepository
+	*storage.RuleRepository
 	*notifier.SubscriptionManager[*model.FeedArticle]
+	ArticleLoader  *outbox.ArticleLoader
+	Subscribers    *subscribers.Registry
+	Authenticator  *auth.Authenticator
 	TracerProvider traces.ShutdownTracerProvider
+	// replayBatch number of articles in replayed batches, defaultReplayBatchSize when 0
+	replayBatch int
 }
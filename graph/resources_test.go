@@ -0,0 +1,41 @@
+package graph_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMutationAddResources_InvalidPattern(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFeed(t)
+
+	_, err := f.resolver.Mutation().AddResources(ctx, []*model.NewResource{
+		{URL: "https://go.dev/blog/feed.atom", Active: true, IncludePatterns: []string{"*release*"}},
+		{URL: "https://github.com/golang/go/releases.atom", Active: true, ExcludePatterns: []string{"*beta*", "/rc[/"}},
+	})
+	assert.ErrorContains(t, err, "https://github.com/golang/go/releases.atom", "error should name resource")
+	assert.ErrorContains(t, err, `invalid exclude pattern "/rc[/"`, "error should name pattern")
+
+	for _, url := range []string{"https://go.dev/blog/feed.atom", "https://github.com/golang/go/releases.atom"} {
+		resource, _ := f.resolver.ResourceRepository.Get(ctx, url)
+		assert.Nil(t, resource, "resources should not be added when any pattern is invalid")
+	}
+}
+
+func TestMutationSetResourcePatterns_InvalidPattern(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFeed(t)
+
+	_, err := f.resolver.Mutation().SetResourcePatterns(ctx, []string{testResource}, []string{" *release* "}, nil)
+	assert.NoError(t, err, "error should be nil")
+
+	_, err = f.resolver.Mutation().SetResourcePatterns(ctx, []string{testResource}, []string{"/(go/"}, nil)
+	assert.ErrorContains(t, err, `invalid include pattern "/(go/"`, "error should name pattern")
+
+	resource, err := f.resolver.ResourceRepository.Get(ctx, testResource)
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, []string{"*release*"}, resource.IncludePatterns, "invalid patterns should not be saved")
+}
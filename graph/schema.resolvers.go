@@ -7,31 +7,58 @@ package graph
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
-	snowflake "github.com/disgoorg/snowflake/v2"
 	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/auth"
 	"github.com/sealbro/go-feed-me/internal/metrics"
 	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/pkg/notifier"
 )
 
+// Duplicates is the resolver for the duplicates field.
+func (r *feedArticleResolver) Duplicates(ctx context.Context, obj *model.FeedArticle) ([]*model.FeedArticle, error) {
+	duplicates, err := r.loadDuplicates(ctx, obj.Link)
+	if err != nil || len(duplicates) == 0 {
+		return []*model.FeedArticle{}, err
+	}
+
+	return duplicates, nil
+}
+
 // AddResources is the resolver for the addResources field.
 func (r *mutationResolver) AddResources(ctx context.Context, resources []*model.NewResource) (*string, error) {
 	var errs []error
+	for _, resource := range resources {
+		if _, _, err := toPatterns(resource.IncludePatterns, resource.ExcludePatterns); err != nil {
+			errs = append(errs, fmt.Errorf("resource %s: %w", strings.TrimSpace(resource.URL), err))
+		}
+	}
+	if len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+
 	for _, resource := range resources {
 		res, err := r.ResourceRepository.Get(ctx, resource.URL)
 		if err == nil && res != nil {
 			return nil, nil
 		}
 
+		include, exclude, _ := toPatterns(resource.IncludePatterns, resource.ExcludePatterns)
+		url := strings.TrimSpace(resource.URL)
 		errInner := r.ResourceRepository.Upsert(ctx, &storage.Resource{
-			Created: time.Now(),
-			Url:     strings.TrimSpace(resource.URL),
-			Active:  resource.Active,
+			Created:         time.Now(),
+			Url:             url,
+			Active:          resource.Active,
+			IncludePatterns: include,
+			ExcludePatterns: exclude,
 		})
 		if errInner == nil {
 			metrics.AddedResourcesCounter.Inc()
+			errInner = r.ResourceRepository.AddTags(ctx, []string{url}, resource.Tags)
 		}
 
 		errs = append(errs, errInner)
@@ -56,60 +83,562 @@ func (r *mutationResolver) ActivateResources(ctx context.Context, urls []string,
 	return nil, r.ResourceRepository.Activate(ctx, urls, active)
 }
 
+// AddTags is the resolver for the addTags field.
+func (r *mutationResolver) AddTags(ctx context.Context, urls []string, tags []string) (*string, error) {
+	return nil, r.ResourceRepository.AddTags(ctx, urls, tags)
+}
+
+// RemoveTags is the resolver for the removeTags field.
+func (r *mutationResolver) RemoveTags(ctx context.Context, urls []string, tags []string) (*string, error) {
+	return nil, r.ResourceRepository.RemoveTags(ctx, urls, tags)
+}
+
+// SetResourcePatterns is the resolver for the setResourcePatterns field.
+func (r *mutationResolver) SetResourcePatterns(ctx context.Context, urls []string, include []string, exclude []string) (*string, error) {
+	include, exclude, err := toPatterns(include, exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, r.ResourceRepository.SetPatterns(ctx, urls, include, exclude)
+}
+
+// SubscribeResources is the resolver for the subscribeResources field.
+func (r *mutationResolver) SubscribeResources(ctx context.Context, urls []string) (*string, error) {
+	user, err := r.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, r.UserRepository.Subscribe(ctx, user.UserId, urls)
+}
+
+// UnsubscribeResources is the resolver for the unsubscribeResources field.
+func (r *mutationResolver) UnsubscribeResources(ctx context.Context, urls []string) (*string, error) {
+	user, err := r.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, r.UserRepository.Unsubscribe(ctx, user.UserId, urls)
+}
+
+// MarkRead is the resolver for the markRead field.
+func (r *mutationResolver) MarkRead(ctx context.Context, links []string, read bool) (*string, error) {
+	user, err := r.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, r.UserRepository.MarkRead(ctx, user.UserId, links, read)
+}
+
+// AddUser is the resolver for the addUser field.
+func (r *mutationResolver) AddUser(ctx context.Context, name string, role model.Role) (*model.User, error) {
+	user := &storage.User{
+		Name: strings.TrimSpace(name),
+		Role: string(toAuthRole(role)),
+	}
+	if user.Name == "" {
+		return nil, errors.New("user name is empty")
+	}
+
+	err := r.UserRepository.Create(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return toModelUser(user), nil
+}
+
+// SetUserRole is the resolver for the setUserRole field.
+func (r *mutationResolver) SetUserRole(ctx context.Context, name string, role model.Role) (*string, error) {
+	return nil, r.UserRepository.SetRole(ctx, name, string(toAuthRole(role)))
+}
+
+// RemoveUsers is the resolver for the removeUsers field.
+func (r *mutationResolver) RemoveUsers(ctx context.Context, names []string) (*string, error) {
+	return nil, r.UserRepository.Delete(ctx, names)
+}
+
+// CreateAPIKey is the resolver for the createApiKey field.
+func (r *mutationResolver) CreateAPIKey(ctx context.Context, name string, user *string) (*model.NewAPIKey, error) {
+	principal := r.Authenticator.Principal(ctx)
+
+	userId := principal.UserId
+	if user != nil && *user != principal.Name {
+		if !principal.Role.Allows(auth.RoleAdmin) {
+			return nil, auth.ErrForbidden
+		}
+
+		owner, err := r.UserRepository.GetByName(ctx, *user)
+		if err != nil {
+			return nil, err
+		}
+		if owner == nil {
+			return nil, fmt.Errorf("user %q not found", *user)
+		}
+		userId = owner.Id
+	}
+
+	if userId == 0 {
+		return nil, auth.ErrUnauthenticated
+	}
+
+	key, hash, err := auth.GenerateApiKey()
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey := &storage.ApiKey{
+		UserId: userId,
+		Name:   strings.TrimSpace(name),
+		Hash:   hash,
+	}
+
+	err = r.ApiKeyRepository.Create(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.NewAPIKey{ID: apiKey.IdString(), Key: key}, nil
+}
+
+// RevokeAPIKeys is the resolver for the revokeApiKeys field.
+func (r *mutationResolver) RevokeAPIKeys(ctx context.Context, ids []string) (*string, error) {
+	keyIds, err := parseIds(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	principal := r.Authenticator.Principal(ctx)
+	if principal.Role.Allows(auth.RoleAdmin) {
+		return nil, r.ApiKeyRepository.Delete(ctx, 0, keyIds)
+	}
+
+	user, err := r.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, r.ApiKeyRepository.Delete(ctx, user.UserId, keyIds)
+}
+
+// ReplayDeadLetters is the resolver for the replayDeadLetters field.
+func (r *mutationResolver) ReplayDeadLetters(ctx context.Context, ids []string) (*string, error) {
+	deadLetterIds, err := parseIds(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, r.OutboxRepository.Replay(ctx, deadLetterIds)
+}
+
+// RemoveDeadLetters is the resolver for the removeDeadLetters field.
+func (r *mutationResolver) RemoveDeadLetters(ctx context.Context, ids []string) (*string, error) {
+	deadLetterIds, err := parseIds(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, r.OutboxRepository.DeleteDeadLetters(ctx, deadLetterIds)
+}
+
+// AddRule is the resolver for the addRule field.
+func (r *mutationResolver) AddRule(ctx context.Context, rule model.RuleInput) (*model.Rule, error) {
+	stored := &storage.Rule{}
+	if err := toStorageRule(&rule, stored); err != nil {
+		return nil, err
+	}
+
+	if rule.Position == nil {
+		position, err := r.RuleRepository.NextPosition(ctx)
+		if err != nil {
+			return nil, err
+		}
+		stored.Position = position
+	}
+
+	if err := r.RuleRepository.Create(ctx, stored); err != nil {
+		return nil, err
+	}
+
+	return toModelRule(stored), nil
+}
+
+// UpdateRule is the resolver for the updateRule field.
+func (r *mutationResolver) UpdateRule(ctx context.Context, id string, rule model.RuleInput) (*model.Rule, error) {
+	ruleIds, err := parseIds([]string{id})
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := r.RuleRepository.Get(ctx, ruleIds[0])
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, fmt.Errorf("rule %s not found", id)
+	}
+
+	if err := toStorageRule(&rule, stored); err != nil {
+		return nil, err
+	}
+
+	if err := r.RuleRepository.Update(ctx, stored); err != nil {
+		return nil, err
+	}
+
+	return toModelRule(stored), nil
+}
+
+// RemoveRules is the resolver for the removeRules field.
+func (r *mutationResolver) RemoveRules(ctx context.Context, ids []string) (*string, error) {
+	ruleIds, err := parseIds(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, r.RuleRepository.Delete(ctx, ruleIds)
+}
+
 // Resources is the resolver for the resources field.
-func (r *queryResolver) Resources(ctx context.Context, active bool) ([]*model.FeedResource, error) {
+func (r *queryResolver) Resources(ctx context.Context, active bool, tags []string) ([]*model.FeedResource, error) {
 	resources := make([]*model.FeedResource, 0)
 
-	list, err := r.ResourceRepository.List(ctx, active)
+	list, err := r.ResourceRepository.List(ctx, active, tags...)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, resource := range list {
-		resources = append(resources, &model.FeedResource{
-			URL:       resource.Url,
-			Title:     resource.Title,
-			Created:   resource.Created,
-			Modified:  resource.Modified,
-			Published: resource.Published,
-			Active:    resource.Active,
-		})
+		resources = append(resources, toModelResource(resource))
 	}
 
 	return resources, err
 }
 
 // Articles is the resolver for the articles field.
-func (r *queryResolver) Articles(ctx context.Context, after time.Time) ([]*model.FeedArticle, error) {
-	feedArticles := make([]*model.FeedArticle, 0)
+func (r *queryResolver) Articles(ctx context.Context, after time.Time, tags []string, subscribed *bool, unread *bool, starred *bool, categories []string) ([]*model.FeedArticle, error) {
+	filter := &storage.ArticleFilter{
+		After:      after,
+		Tags:       tags,
+		Subscribed: subscribed != nil && *subscribed,
+		Unread:     unread != nil && *unread,
+		Starred:    starred != nil && *starred,
+		Categories: categories,
+	}
+
+	principal := r.Authenticator.Principal(ctx)
+	if (filter.Subscribed || filter.Unread) && principal.IsAnonymous() {
+		return nil, auth.ErrUnauthenticated
+	}
+	filter.UserId = principal.UserId
 
-	list, err := r.ArticleRepository.List(ctx, after)
+	list, err := r.ArticleRepository.List(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, article := range list {
-		feedArticles = append(feedArticles, &model.FeedArticle{
-			Created:     article.Created,
-			Published:   article.Published,
-			ResourceID:  article.ResourceId,
-			Link:        article.Link,
-			Title:       article.Title,
-			Description: article.Description,
-			Content:     article.Content,
-			Author:      article.Author,
-			Image:       article.Image,
-		})
+	feedArticles, err := r.ArticleLoader.LoadArticles(ctx, list)
+	if err != nil {
+		return nil, err
+	}
+
+	if !principal.IsAnonymous() {
+		links := make([]string, len(list))
+		for i, article := range list {
+			links[i] = article.Link
+		}
+
+		readLinks, err := r.UserRepository.ReadLinks(ctx, principal.UserId, links)
+		if err != nil {
+			return nil, err
+		}
+		for _, article := range feedArticles {
+			article.Read = readLinks[article.Link]
+		}
+	}
+
+	return feedArticles, nil
+}
+
+// Subscriptions is the resolver for the subscriptions field.
+func (r *queryResolver) Subscriptions(ctx context.Context) ([]*model.FeedResource, error) {
+	user, err := r.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	urls, err := r.UserRepository.SubscribedUrls(ctx, user.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]*model.FeedResource, 0, len(urls))
+	for _, url := range urls {
+		resource, err := r.ResourceRepository.Get(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		if resource != nil {
+			resources = append(resources, toModelResource(resource))
+		}
+	}
+
+	return resources, nil
+}
+
+// Me is the resolver for the me field.
+func (r *queryResolver) Me(ctx context.Context) (*model.User, error) {
+	principal := r.Authenticator.Principal(ctx)
+	if principal.IsAnonymous() {
+		return nil, nil
+	}
+
+	user, err := r.UserRepository.Get(ctx, principal.UserId)
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	return toModelUser(user), nil
+}
+
+// Users is the resolver for the users field.
+func (r *queryResolver) Users(ctx context.Context) ([]*model.User, error) {
+	list, err := r.UserRepository.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*model.User, len(list))
+	for i, user := range list {
+		users[i] = toModelUser(user)
+	}
+
+	return users, nil
+}
+
+// APIKeys is the resolver for the apiKeys field.
+func (r *queryResolver) APIKeys(ctx context.Context) ([]*model.APIKey, error) {
+	user, err := r.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := r.ApiKeyRepository.List(ctx, user.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKeys := make([]*model.APIKey, len(list))
+	for i, apiKey := range list {
+		apiKeys[i] = &model.APIKey{
+			ID:       apiKey.IdString(),
+			Name:     apiKey.Name,
+			Created:  apiKey.Created,
+			LastUsed: apiKey.LastUsed,
+		}
+	}
+
+	return apiKeys, nil
+}
+
+// Deliveries is the resolver for the deliveries field.
+func (r *queryResolver) Deliveries(ctx context.Context) ([]*model.Delivery, error) {
+	cursors, err := r.OutboxRepository.Cursors(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]*model.Delivery, len(cursors))
+	for i, cursor := range cursors {
+		deliveries[i] = toModelDelivery(cursor)
+	}
+
+	return deliveries, nil
+}
+
+// DeadLetters is the resolver for the deadLetters field.
+func (r *queryResolver) DeadLetters(ctx context.Context, subscriber *string) ([]*model.DeadLetter, error) {
+	name := ""
+	if subscriber != nil {
+		name = *subscriber
+	}
+
+	list, err := r.OutboxRepository.DeadLetters(ctx, name)
+	if err != nil {
+		return nil, err
 	}
 
-	return feedArticles, err
+	deadLetters := make([]*model.DeadLetter, len(list))
+	for i, deadLetter := range list {
+		deadLetters[i] = &model.DeadLetter{
+			ID:         deadLetter.IdString(),
+			Subscriber: deadLetter.Subscriber,
+			Link:       deadLetter.ArticleLink,
+			Attempts:   deadLetter.Attempts,
+			LastError:  deadLetter.LastError,
+			Created:    deadLetter.Created,
+			Replay:     deadLetter.Replay,
+		}
+	}
+
+	return deadLetters, nil
+}
+
+// WebhookDeliveries is the resolver for the webhookDeliveries field.
+func (r *queryResolver) WebhookDeliveries(ctx context.Context, subscriber *string, limit *int) ([]*model.WebhookDelivery, error) {
+	name := ""
+	if subscriber != nil {
+		name = *subscriber
+	}
+	count := 100
+	if limit != nil && *limit > 0 {
+		count = min(*limit, 1000)
+	}
+
+	list, err := r.WebhookDeliveryRepository.WebhookDeliveries(ctx, name, count)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]*model.WebhookDelivery, len(list))
+	for i, delivery := range list {
+		deliveries[i] = &model.WebhookDelivery{
+			ID:         strconv.FormatUint(delivery.Id, 10),
+			Subscriber: delivery.Subscriber,
+			URL:        delivery.Url,
+			DeliveryID: delivery.DeliveryId,
+			Attempt:    delivery.Attempt,
+			Articles:   delivery.Articles,
+			StatusCode: delivery.StatusCode,
+			DurationMs: int(delivery.DurationMs),
+			Error:      delivery.Error,
+			Created:    delivery.Created,
+		}
+	}
+
+	return deliveries, nil
+}
+
+// RenderNotification is the resolver for the renderNotification field.
+func (r *queryResolver) RenderNotification(ctx context.Context, subscriber string, articleID string) (string, error) {
+	articles, err := r.ArticleRepository.GetByLinks(ctx, []string{articleID})
+	if err != nil {
+		return "", err
+	}
+	if len(articles) == 0 {
+		return "", fmt.Errorf("article %q not found", articleID)
+	}
+
+	feedArticles, err := r.ArticleLoader.LoadArticles(ctx, articles)
+	if err != nil {
+		return "", err
+	}
+
+	return r.Subscribers.Render(ctx, subscriber, feedArticles[0])
+}
+
+// Rules is the resolver for the rules field.
+func (r *queryResolver) Rules(ctx context.Context) ([]*model.Rule, error) {
+	list, err := r.RuleRepository.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]*model.Rule, len(list))
+	for i, rule := range list {
+		rules[i] = toModelRule(rule)
+	}
+
+	return rules, nil
 }
 
 // Articles is the resolver for the articles field.
-func (r *subscriptionResolver) Articles(ctx context.Context) (<-chan []*model.FeedArticle, error) {
-	return r.SubscriptionManager.AddSubscriber(ctx, snowflake.New(time.Now()).String())
+func (r *subscriptionResolver) Articles(ctx context.Context, resources []string, tags []string, keyword *string, regex *string, author *string, categories []string, subscribed *bool, since *string, batch *model.BatchInput) (<-chan *model.ArticleBatch, error) {
+	matcher, err := r.articleMatcher(ctx, resources, tags, keyword, regex, author, categories, subscribed)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []notifier.SubscriberOption[*model.FeedArticle]{
+		notifier.WithKind[*model.FeedArticle]("graphql"),
+		notifier.WithFilter(matcher.Match),
+	}
+	if batch != nil {
+		batching, err := toBatching(batch)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, notifier.WithBatching[*model.FeedArticle](batching))
+	}
+	if since != nil {
+		// live events wait in buffer while stored ones are replayed, dropping them would leave a gap,
+		// so slow resumed subscription ends and client resumes again from its last cursor
+		options = append(options, notifier.WithOverflowPolicy[*model.FeedArticle](notifier.Disconnect))
+	}
+
+	subscriberId := newSubscriberId()
+	events, err := r.SubscriptionManager.AddSubscriber(ctx, subscriberId, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	var replay *articleReplay
+	if since != nil {
+		replay, err = r.newReplay(ctx, *since)
+		if err != nil {
+			r.SubscriptionManager.RemoveSubscriber(subscriberId)
+			return nil, err
+		}
+	}
+
+	batches := make(chan *model.ArticleBatch)
+	send := func(cursor string, articles []*model.FeedArticle) bool {
+		if len(articles) == 0 {
+			return true
+		}
+
+		select {
+		case batches <- &model.ArticleBatch{Cursor: cursor, Articles: articles}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	go func() {
+		defer close(batches)
+		defer r.SubscriptionManager.RemoveSubscriber(subscriberId)
+
+		var latestId uint64
+		if replay != nil {
+			// failed replay ends subscription, client resumes from the last received cursor
+			if ok, err := r.replayArticles(ctx, replay, matcher, send); !ok || err != nil {
+				return
+			}
+			latestId = replay.latestId
+		}
+
+		for articles := range events {
+			articles = skipReplayed(articles, latestId)
+			if len(articles) == 0 {
+				continue
+			}
+
+			if !send(liveCursor(articles), articles) {
+				return
+			}
+		}
+	}()
+
+	return batches, nil
 }
 
+// FeedArticle returns FeedArticleResolver implementation.
+func (r *Resolver) FeedArticle() FeedArticleResolver { return &feedArticleResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
@@ -119,6 +648,7 @@ func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 // Subscription returns SubscriptionResolver implementation.
 func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
 
+type feedArticleResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
 type subscriptionResolver struct{ *Resolver }
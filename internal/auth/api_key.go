@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// ApiKeyPrefix distinguishes api keys from jwt in bearer tokens
+const ApiKeyPrefix = "fm_"
+
+// minApiKeyLength characters of key after prefix, generated keys have 43
+const minApiKeyLength = 32
+
+// GenerateApiKey returns new plain api key and its hash for storing
+func GenerateApiKey() (string, string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", err
+	}
+
+	key := ApiKeyPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	return key, HashApiKey(key), nil
+}
+
+func HashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func IsApiKey(token string) bool {
+	return strings.HasPrefix(token, ApiKeyPrefix)
+}
@@ -0,0 +1,13 @@
+package auth
+
+type Config struct {
+	AnonymousRole  string   `envconfig:"AUTH_ANONYMOUS_ROLE" default:"viewer"`
+	AnonymousWrite bool     `envconfig:"AUTH_ANONYMOUS_WRITE" default:"false"`
+	AdminApiKey    string   `envconfig:"AUTH_ADMIN_API_KEY" default:""`
+	JwtSecret      string   `envconfig:"AUTH_JWT_SECRET" default:""`
+	JwksFile       string   `envconfig:"AUTH_JWKS_FILE" default:""`
+	JwtIssuer      string   `envconfig:"AUTH_JWT_ISSUER" default:""`
+	JwtAudience    string   `envconfig:"AUTH_JWT_AUDIENCE" default:""`
+	JwtUserClaim   string   `envconfig:"AUTH_JWT_USER_CLAIM" default:"sub"`
+	AllowedOrigins []string `envconfig:"AUTH_ALLOWED_ORIGINS"`
+}
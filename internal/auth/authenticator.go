@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const ApiKeyHeader = "X-Api-Key"
+
+// AdminUserName owner of AUTH_ADMIN_API_KEY
+const AdminUserName = "admin"
+
+type Authenticator struct {
+	logger           *logger.Logger
+	config           *Config
+	userRepository   *storage.UserRepository
+	apiKeyRepository *storage.ApiKeyRepository
+	sessions         *Sessions
+	jwtVerifier      *JwtVerifier
+	anonymousRole    Role
+}
+
+func NewAuthenticator(logger *logger.Logger, config *Config,
+	userRepository *storage.UserRepository, apiKeyRepository *storage.ApiKeyRepository, sessions *Sessions) (*Authenticator, error) {
+	var anonymousRole Role
+	if config.AnonymousRole != "" {
+		role, err := ParseRole(config.AnonymousRole)
+		if err != nil {
+			return nil, err
+		}
+		// anonymous editors and admins change resources, users and api keys, it is allowed only explicitly
+		if role.Allows(RoleEditor) && !config.AnonymousWrite {
+			return nil, fmt.Errorf("anonymous role %q allows changes, set AUTH_ANONYMOUS_WRITE to allow it", role)
+		}
+		anonymousRole = role
+	}
+
+	jwtVerifier, err := NewJwtVerifier(config)
+	if err != nil {
+		return nil, err
+	}
+
+	authenticator := &Authenticator{
+		logger:           logger,
+		config:           config,
+		userRepository:   userRepository,
+		apiKeyRepository: apiKeyRepository,
+		sessions:         sessions,
+		jwtVerifier:      jwtVerifier,
+		anonymousRole:    anonymousRole,
+	}
+
+	if config.AdminApiKey != "" {
+		if err = authenticator.bootstrapAdmin(context.Background(), config.AdminApiKey); err != nil {
+			return nil, fmt.Errorf("can't bootstrap admin: %w", err)
+		}
+	}
+
+	return authenticator, nil
+}
+
+// Principal returns authenticated principal from context or anonymous principal with configured role
+func (a *Authenticator) Principal(ctx context.Context) *Principal {
+	if principal := PrincipalFrom(ctx); principal != nil {
+		return principal
+	}
+
+	return &Principal{Name: "anonymous", Role: a.anonymousRole}
+}
+
+// Middleware authenticates request by api key, bearer token or session cookie,
+// request without credentials stays anonymous, request with invalid credentials is rejected
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		principal, err := a.authenticateRequest(request)
+		if err != nil {
+			a.logger.WarnContext(request.Context(), "can't authenticate request", slog.Any("error", err))
+			writer.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(writer, ErrUnauthenticated.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		if principal != nil {
+			request = request.WithContext(WithPrincipal(request.Context(), principal))
+		}
+
+		next.ServeHTTP(writer, request)
+	})
+}
+
+// AuthenticateToken returns principal of api key or jwt owner
+func (a *Authenticator) AuthenticateToken(ctx context.Context, token string) (*Principal, error) {
+	if IsApiKey(token) {
+		return a.apiKeyPrincipal(ctx, token)
+	}
+
+	name, err := a.jwtVerifier.Verify(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bearer token: %w", err)
+	}
+
+	return a.userPrincipal(ctx, name)
+}
+
+// CheckOrigin allows websocket connections from the same origin and configured origins,
+// "*" allows any origin including clients without Origin header
+func (a *Authenticator) CheckOrigin(request *http.Request) bool {
+	if slices.Contains(a.config.AllowedOrigins, "*") {
+		return true
+	}
+
+	origin := request.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	if slices.Contains(a.config.AllowedOrigins, origin) {
+		return true
+	}
+
+	originUrl, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(originUrl.Host, request.Host)
+}
+
+func (a *Authenticator) authenticateRequest(request *http.Request) (*Principal, error) {
+	ctx := request.Context()
+
+	if key := request.Header.Get(ApiKeyHeader); key != "" {
+		return a.AuthenticateToken(ctx, key)
+	}
+
+	if authorization := request.Header.Get("Authorization"); authorization != "" {
+		token, ok := strings.CutPrefix(authorization, "Bearer ")
+		if !ok {
+			return nil, fmt.Errorf("unsupported authorization scheme")
+		}
+		return a.AuthenticateToken(ctx, strings.TrimSpace(token))
+	}
+
+	if userId, ok := a.sessions.UserId(request); ok {
+		return a.sessionPrincipal(ctx, userId)
+	}
+
+	return nil, nil
+}
+
+// sessionPrincipal returns principal of session owner, session of removed user stays anonymous
+// bootstrapAdmin stores key of admin user, so the first admin of deployment is created without anonymous writes
+func (a *Authenticator) bootstrapAdmin(ctx context.Context, key string) error {
+	if !IsApiKey(key) || len(key) < len(ApiKeyPrefix)+minApiKeyLength {
+		return fmt.Errorf("AUTH_ADMIN_API_KEY should start with %q and have at least %d more characters", ApiKeyPrefix, minApiKeyLength)
+	}
+
+	apiKey, err := a.apiKeyRepository.GetByHash(ctx, HashApiKey(key))
+	if err != nil || apiKey != nil {
+		return err
+	}
+
+	admin, err := a.userRepository.GetByName(ctx, AdminUserName)
+	if err != nil {
+		return err
+	}
+	if admin == nil {
+		admin = &storage.User{Name: AdminUserName, Role: string(RoleAdmin)}
+		if err = a.userRepository.Create(ctx, admin); err != nil {
+			return err
+		}
+	} else if admin.Role != string(RoleAdmin) {
+		return fmt.Errorf("user %q exists with role %q", AdminUserName, admin.Role)
+	}
+
+	err = a.apiKeyRepository.Create(ctx, &storage.ApiKey{UserId: admin.Id, Name: "AUTH_ADMIN_API_KEY", Hash: HashApiKey(key)})
+	if err != nil {
+		return err
+	}
+
+	a.logger.InfoContext(ctx, "admin api key is stored", slog.String("user", AdminUserName))
+
+	return nil
+}
+
+func (a *Authenticator) sessionPrincipal(ctx context.Context, userId uint64) (*Principal, error) {
+	user, err := a.userRepository.Get(ctx, userId)
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	return toPrincipal(user)
+}
+
+func (a *Authenticator) apiKeyPrincipal(ctx context.Context, key string) (*Principal, error) {
+	apiKey, err := a.apiKeyRepository.GetByHash(ctx, HashApiKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == nil {
+		return nil, fmt.Errorf("unknown api key")
+	}
+
+	if err = a.apiKeyRepository.Touch(ctx, apiKey.Id); err != nil {
+		a.logger.WarnContext(ctx, "can't update api key usage", slog.Any("error", err))
+	}
+
+	user, err := a.userRepository.Get(ctx, apiKey.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return toPrincipal(user)
+}
+
+func (a *Authenticator) userPrincipal(ctx context.Context, name string) (*Principal, error) {
+	user, err := a.userRepository.GetByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return toPrincipal(user)
+}
+
+func toPrincipal(user *storage.User) (*Principal, error) {
+	if user == nil {
+		return nil, fmt.Errorf("unknown user")
+	}
+
+	role, err := ParseRole(user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Principal{UserId: user.Id, Name: user.Name, Role: role}, nil
+}
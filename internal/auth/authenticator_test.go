@@ -0,0 +1,94 @@
+package auth_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sealbro/go-feed-me/internal/auth"
+	"github.com/sealbro/go-feed-me/internal/db"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticator_CheckOrigin(t *testing.T) {
+	testCases := []struct {
+		name    string
+		allowed []string
+		origin  string
+		expect  bool
+	}{
+		{name: "same origin", origin: "https://feed.example.com", expect: true},
+		{name: "same origin in other case", origin: "https://FEED.example.com", expect: true},
+		{name: "cross origin", origin: "https://evil.example.com", expect: false},
+		{name: "other port", origin: "https://feed.example.com:8443", expect: false},
+		{name: "no origin", origin: "", expect: false},
+		{name: "allowed origin", allowed: []string{"https://app.example.com"}, origin: "https://app.example.com", expect: true},
+		{name: "not allowed origin", allowed: []string{"https://app.example.com"}, origin: "https://evil.example.com", expect: false},
+		{name: "any origin", allowed: []string{"*"}, origin: "https://evil.example.com", expect: true},
+		{name: "any origin without header", allowed: []string{"*"}, origin: "", expect: true},
+	}
+
+	newLogger, err := logger.NewLogger(&logger.Config{LogLevel: "ERROR"})
+	assert.NoError(t, err, "error should be nil")
+	sessions, err := auth.NewSessions(&auth.OidcConfig{})
+	assert.NoError(t, err, "error should be nil")
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			authenticator, err := auth.NewAuthenticator(newLogger, &auth.Config{AllowedOrigins: testCase.allowed}, nil, nil, sessions)
+			assert.NoError(t, err, "error should be nil")
+
+			request := httptest.NewRequest("GET", "https://feed.example.com/feed/graphql/query", nil)
+			if testCase.origin != "" {
+				request.Header.Set("Origin", testCase.origin)
+			}
+
+			assert.Equal(t, testCase.expect, authenticator.CheckOrigin(request))
+		})
+	}
+}
+
+func TestNewAuthenticator_AdminApiKey(t *testing.T) {
+	ctx := context.Background()
+	newLogger, err := logger.NewLogger(&logger.Config{LogLevel: "ERROR"})
+	assert.NoError(t, err, "error should be nil")
+	database, err := db.NewSqliteDatabase(logger.NewGormLogger(newLogger), &db.Config{
+		SqliteConnection: filepath.Join(t.TempDir(), "feed.db"),
+	})
+	assert.NoError(t, err, "error should be nil")
+	userRepository, err := storage.NewUserRepository(database)
+	assert.NoError(t, err, "error should be nil")
+	apiKeyRepository, err := storage.NewApiKeyRepository(database)
+	assert.NoError(t, err, "error should be nil")
+	sessions, err := auth.NewSessions(&auth.OidcConfig{})
+	assert.NoError(t, err, "error should be nil")
+
+	key := auth.ApiKeyPrefix + strings.Repeat("k", 43)
+	var authenticator *auth.Authenticator
+	for range 2 {
+		authenticator, err = auth.NewAuthenticator(newLogger, &auth.Config{AdminApiKey: key}, userRepository, apiKeyRepository, sessions)
+		assert.NoError(t, err, "error should be nil")
+	}
+
+	principal, err := authenticator.AuthenticateToken(ctx, key)
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, auth.AdminUserName, principal.Name)
+	assert.Equal(t, auth.RoleAdmin, principal.Role)
+
+	keys, err := apiKeyRepository.List(ctx, principal.UserId)
+	assert.NoError(t, err, "error should be nil")
+	assert.Len(t, keys, 1, "restart should not store the same key again")
+
+	_, err = auth.NewAuthenticator(newLogger, &auth.Config{AdminApiKey: "secret"}, userRepository, apiKeyRepository, sessions)
+	assert.ErrorContains(t, err, "AUTH_ADMIN_API_KEY", "short key should be rejected")
+
+	assert.NoError(t, userRepository.SetRole(ctx, auth.AdminUserName, string(auth.RoleViewer)), "error should be nil")
+	_, err = auth.NewAuthenticator(newLogger, &auth.Config{AdminApiKey: key + "2"}, userRepository, apiKeyRepository, sessions)
+	assert.Error(t, err, "existing user should not be promoted to admin")
+}
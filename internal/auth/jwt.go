@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+)
+
+var ErrJwtNotConfigured = errors.New("jwt authentication is not configured")
+
+// JwtVerifier validates HS256 tokens with shared secret and RS256 tokens with keys from JWKS
+type JwtVerifier struct {
+	secret    []byte
+	keys      map[string]*rsa.PublicKey
+	userClaim string
+	parser    *jwt.Parser
+}
+
+func NewJwtVerifier(config *Config) (*JwtVerifier, error) {
+	verifier := &JwtVerifier{
+		userClaim: config.JwtUserClaim,
+		keys:      map[string]*rsa.PublicKey{},
+	}
+
+	var methods []string
+	if config.JwtSecret != "" {
+		verifier.secret = []byte(config.JwtSecret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	if config.JwksFile != "" {
+		data, err := os.ReadFile(config.JwksFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't read jwks file: %w", err)
+		}
+
+		verifier.keys, err = ParseJwks(data)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	options := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+	if config.JwtIssuer != "" {
+		options = append(options, jwt.WithIssuer(config.JwtIssuer))
+	}
+	if config.JwtAudience != "" {
+		options = append(options, jwt.WithAudience(config.JwtAudience))
+	}
+	verifier.parser = jwt.NewParser(options...)
+
+	return verifier, nil
+}
+
+func (v *JwtVerifier) Enabled() bool {
+	return len(v.secret) > 0 || len(v.keys) > 0
+}
+
+// Verify validates token and returns user name from configured claim
+func (v *JwtVerifier) Verify(token string) (string, error) {
+	if !v.Enabled() {
+		return "", ErrJwtNotConfigured
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, v.key)
+	if err != nil {
+		return "", err
+	}
+
+	name, ok := claims[v.userClaim].(string)
+	if !ok || name == "" {
+		return "", fmt.Errorf("claim %q is missing", v.userClaim)
+	}
+
+	return name, nil
+}
+
+func (v *JwtVerifier) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		return v.secret, nil
+	case jwt.SigningMethodRS256.Alg():
+		return LookupJwk(v.keys, token)
+	default:
+		return nil, fmt.Errorf("unexpected signing method: %s", token.Method.Alg())
+	}
+}
+
+// LookupJwk returns key by token kid header, token without kid is allowed for single key set
+func LookupJwk(keys map[string]*rsa.PublicKey, token *jwt.Token) (*rsa.PublicKey, error) {
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown key id: %q", kid)
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// ParseJwks returns RSA signing keys of JSON Web Key Set by key id
+func ParseJwks(data []byte) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("can't parse jwks: %w", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, fmt.Errorf("can't decode modulus of key %q: %w", key.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return nil, fmt.Errorf("can't decode exponent of key %q: %w", key.Kid, err)
+		}
+
+		keys[key.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("jwks has no RSA signing keys")
+	}
+
+	return keys, nil
+}
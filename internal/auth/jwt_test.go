@@ -0,0 +1,171 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sealbro/go-feed-me/internal/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJwtVerifier(t *testing.T) {
+	secret := "test-secret"
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err, "error should be nil")
+	otherRsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err, "error should be nil")
+
+	config := &auth.Config{
+		JwtSecret:    secret,
+		JwksFile:     writeJwks(t, "key-1", &rsaKey.PublicKey),
+		JwtIssuer:    "https://issuer.test",
+		JwtUserClaim: "sub",
+	}
+	verifier, err := auth.NewJwtVerifier(config)
+	assert.NoError(t, err, "error should be nil")
+
+	validClaims := jwt.MapClaims{
+		"sub": "alice",
+		"iss": "https://issuer.test",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+
+	testCases := []struct {
+		name      string
+		token     string
+		expectErr bool
+	}{
+		{
+			name:  "HS256 valid",
+			token: signHS256(t, secret, validClaims),
+		},
+		{
+			name:      "HS256 wrong secret",
+			token:     signHS256(t, "wrong", validClaims),
+			expectErr: true,
+		},
+		{
+			name:  "RS256 valid",
+			token: signRS256(t, rsaKey, "key-1", validClaims),
+		},
+		{
+			name:      "RS256 unknown key id",
+			token:     signRS256(t, rsaKey, "key-2", validClaims),
+			expectErr: true,
+		},
+		{
+			name:      "RS256 wrong key",
+			token:     signRS256(t, otherRsaKey, "key-1", validClaims),
+			expectErr: true,
+		},
+		{
+			name: "expired",
+			token: signHS256(t, secret, jwt.MapClaims{
+				"sub": "alice",
+				"iss": "https://issuer.test",
+				"exp": time.Now().Add(-time.Hour).Unix(),
+			}),
+			expectErr: true,
+		},
+		{
+			name: "without expiration",
+			token: signHS256(t, secret, jwt.MapClaims{
+				"sub": "alice",
+				"iss": "https://issuer.test",
+			}),
+			expectErr: true,
+		},
+		{
+			name: "wrong issuer",
+			token: signHS256(t, secret, jwt.MapClaims{
+				"sub": "alice",
+				"iss": "https://other.test",
+				"exp": time.Now().Add(time.Hour).Unix(),
+			}),
+			expectErr: true,
+		},
+		{
+			name: "without user claim",
+			token: signHS256(t, secret, jwt.MapClaims{
+				"iss": "https://issuer.test",
+				"exp": time.Now().Add(time.Hour).Unix(),
+			}),
+			expectErr: true,
+		},
+		{
+			name:      "none algorithm",
+			token:     signNone(t, validClaims),
+			expectErr: true,
+		},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			name, err := verifier.Verify(testCase.token)
+			if testCase.expectErr {
+				assert.Error(t, err, "error should not be nil")
+				return
+			}
+
+			assert.NoError(t, err, "error should be nil")
+			assert.Equal(t, "alice", name, "user name should be taken from sub claim")
+		})
+	}
+}
+
+func TestJwtVerifierNotConfigured(t *testing.T) {
+	verifier, err := auth.NewJwtVerifier(&auth.Config{JwtUserClaim: "sub"})
+	assert.NoError(t, err, "error should be nil")
+
+	_, err = verifier.Verify(signHS256(t, "secret", jwt.MapClaims{"sub": "alice"}))
+	assert.ErrorIs(t, err, auth.ErrJwtNotConfigured, "error should be ErrJwtNotConfigured")
+}
+
+func writeJwks(t *testing.T, kid string, key *rsa.PublicKey) string {
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	}
+	data, err := json.Marshal(jwks)
+	assert.NoError(t, err, "error should be nil")
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, data, 0o600), "error should be nil")
+
+	return path
+}
+
+func signHS256(t *testing.T, secret string, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	assert.NoError(t, err, "error should be nil")
+	return token
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	assert.NoError(t, err, "error should be nil")
+	return signed
+}
+
+func signNone(t *testing.T, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	assert.NoError(t, err, "error should be nil")
+	return token
+}
@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+	EndSessionEndpoint    string `json:"end_session_endpoint"`
+}
+
+// OidcProvider is a client of OpenID Connect identity provider for authorization code flow with PKCE,
+// provider metadata is discovered lazily on first use, so unavailable provider doesn't break startup
+type OidcProvider struct {
+	config *OidcConfig
+	client *http.Client
+
+	m        sync.Mutex
+	metadata *oidcMetadata
+	keys     map[string]*rsa.PublicKey
+}
+
+func NewOidcProvider(config *OidcConfig, client *http.Client) *OidcProvider {
+	return &OidcProvider{
+		config: config,
+		client: client,
+	}
+}
+
+// AuthCodeUrl returns authorization endpoint url with S256 challenge of verifier
+func (p *OidcProvider) AuthCodeUrl(ctx context.Context, state string, nonce string, verifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientId},
+		"redirect_uri":          {p.config.RedirectUrl},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	return appendQuery(metadata.AuthorizationEndpoint, query), nil
+}
+
+// Exchange redeems authorization code and returns verified id token claims
+func (p *OidcProvider) Exchange(ctx context.Context, code string, verifier string, nonce string) (jwt.MapClaims, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectUrl},
+		"client_id":     {p.config.ClientId},
+		"code_verifier": {verifier},
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(p.config.ClientId), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	var tokens struct {
+		IdToken string `json:"id_token"`
+	}
+	if err = p.doJson(request, &tokens); err != nil {
+		return nil, fmt.Errorf("can't exchange code: %w", err)
+	}
+	if tokens.IdToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	return p.verifyIdToken(ctx, metadata, tokens.IdToken, nonce)
+}
+
+// EndSessionUrl returns logout url of provider or empty string if provider doesn't support it
+func (p *OidcProvider) EndSessionUrl(ctx context.Context) string {
+	metadata, err := p.discover(ctx)
+	if err != nil || metadata.EndSessionEndpoint == "" {
+		return ""
+	}
+
+	return appendQuery(metadata.EndSessionEndpoint, url.Values{"client_id": {p.config.ClientId}})
+}
+
+func (p *OidcProvider) verifyIdToken(ctx context.Context, metadata *oidcMetadata, idToken string, nonce string) (jwt.MapClaims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.config.ClientId),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return p.key(ctx, metadata, token)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %w", err)
+	}
+
+	if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+		return nil, errors.New("invalid id token: nonce mismatch")
+	}
+
+	return claims, nil
+}
+
+// key returns signing key of token, keys are refetched once when key id is unknown after rotation
+func (p *OidcProvider) key(ctx context.Context, metadata *oidcMetadata, token *jwt.Token) (*rsa.PublicKey, error) {
+	p.m.Lock()
+	keys := p.keys
+	p.m.Unlock()
+
+	if keys != nil {
+		if key, err := LookupJwk(keys, token); err == nil {
+			return key, nil
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, metadata.JwksUri, nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := p.do(request)
+	if err != nil {
+		return nil, fmt.Errorf("can't fetch jwks: %w", err)
+	}
+	keys, err = ParseJwks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	p.m.Lock()
+	p.keys = keys
+	p.m.Unlock()
+
+	return LookupJwk(keys, token)
+}
+
+func (p *OidcProvider) discover(ctx context.Context) (*oidcMetadata, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	discoveryUrl := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &oidcMetadata{}
+	if err = p.doJson(request, metadata); err != nil {
+		return nil, fmt.Errorf("can't discover oidc provider: %w", err)
+	}
+	if metadata.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("oidc issuer mismatch: %q", metadata.Issuer)
+	}
+
+	p.metadata = metadata
+
+	return metadata, nil
+}
+
+func (p *OidcProvider) doJson(request *http.Request, value any) error {
+	data, err := p.do(request)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, value)
+}
+
+func (p *OidcProvider) do(request *http.Request) ([]byte, error) {
+	response, err := p.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d: %s", response.StatusCode, data)
+	}
+
+	return data, nil
+}
+
+func appendQuery(endpoint string, query url.Values) string {
+	separator := "?"
+	if strings.Contains(endpoint, "?") {
+		separator = "&"
+	}
+
+	return endpoint + separator + query.Encode()
+}
+
+// randomString returns url safe random string for state, nonce and PKCE verifier
+func randomString() (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
@@ -0,0 +1,24 @@
+package auth
+
+import "time"
+
+type OidcConfig struct {
+	Issuer       string `envconfig:"OIDC_ISSUER" default:""`
+	ClientId     string `envconfig:"OIDC_CLIENT_ID" default:""`
+	ClientSecret string `envconfig:"OIDC_CLIENT_SECRET" default:""`
+	// RedirectUrl external url of callback route, e.g. https://feed.example.com/feed/auth/callback
+	RedirectUrl string   `envconfig:"OIDC_REDIRECT_URL" default:""`
+	Scopes      []string `envconfig:"OIDC_SCOPES" default:"openid,profile,email"`
+	UserClaim   string   `envconfig:"OIDC_USER_CLAIM" default:"preferred_username"`
+	RolesClaim  string   `envconfig:"OIDC_ROLES_CLAIM" default:"groups"`
+	// RoleMapping maps values of roles claim to roles, e.g. feed-admins:admin,feed-editors:editor
+	RoleMapping map[string]string `envconfig:"OIDC_ROLE_MAPPING"`
+	// DefaultRole role of users without mapped roles, empty denies login
+	DefaultRole   string        `envconfig:"OIDC_DEFAULT_ROLE" default:"viewer"`
+	SessionSecret string        `envconfig:"SESSION_SECRET" default:""`
+	SessionTtl    time.Duration `envconfig:"SESSION_TTL" default:"12h"`
+}
+
+func (c *OidcConfig) Enabled() bool {
+	return c.Issuer != "" && c.ClientId != ""
+}
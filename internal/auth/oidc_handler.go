@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/sealbro/go-feed-me/internal/api"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const loginCookie = "feed_oidc"
+
+type loginState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Redirect string `json:"redirect"`
+}
+
+// OidcHandler serves login, callback and logout routes of OpenID Connect authorization code flow
+type OidcHandler struct {
+	logger         *logger.Logger
+	config         *OidcConfig
+	provider       *OidcProvider
+	sessions       *Sessions
+	userRepository *storage.UserRepository
+	loginPath      string
+	homePath       string
+}
+
+func NewOidcHandler(logger *logger.Logger, config *OidcConfig, sessions *Sessions,
+	userRepository *storage.UserRepository) *OidcHandler {
+	return &OidcHandler{
+		logger:         logger,
+		config:         config,
+		provider:       NewOidcProvider(config, &http.Client{Timeout: 10 * time.Second}),
+		sessions:       sessions,
+		userRepository: userRepository,
+	}
+}
+
+func (h *OidcHandler) RegisterRoutes(registrar api.Registrar) {
+	if !h.config.Enabled() {
+		return
+	}
+
+	urlPrefix := "auth"
+	h.loginPath = registrar.Prefix(urlPrefix, "/login")
+	h.homePath = registrar.Prefix("graphql", "/")
+	callbackPath := registrar.Prefix(urlPrefix, "/callback")
+	logoutPath := registrar.Prefix(urlPrefix, "/logout")
+
+	registrar.RegisterRoutesFunc(func(router *mux.Router) {
+		router.HandleFunc(h.loginPath, h.login).Methods("GET")
+		router.HandleFunc(callbackPath, h.callback).Methods("GET")
+		router.HandleFunc(logoutPath, h.logout).Methods("GET", "POST")
+	})
+
+	h.logger.Info("OIDC login", slog.String("url", fmt.Sprintf("http://%s%s", registrar.Addr(), h.loginPath)))
+}
+
+// LoginRequired redirects unauthenticated browsers to login when OIDC is enabled
+func (h *OidcHandler) LoginRequired(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if !h.config.Enabled() || h.loginPath == "" || PrincipalFrom(request.Context()) != nil {
+			next.ServeHTTP(writer, request)
+			return
+		}
+
+		query := url.Values{"redirect": {request.URL.RequestURI()}}
+		http.Redirect(writer, request, h.loginPath+"?"+query.Encode(), http.StatusFound)
+	})
+}
+
+func (h *OidcHandler) login(writer http.ResponseWriter, request *http.Request) {
+	state := &loginState{Redirect: h.safeRedirect(request.URL.Query().Get("redirect"))}
+
+	var err error
+	for _, value := range []*string{&state.State, &state.Nonce, &state.Verifier} {
+		if *value, err = randomString(); err != nil {
+			h.fail(writer, request, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	authUrl, err := h.provider.AuthCodeUrl(request.Context(), state.State, state.Nonce, state.Verifier)
+	if err != nil {
+		h.fail(writer, request, http.StatusBadGateway, err)
+		return
+	}
+
+	err = h.sessions.SetCookie(writer, request, loginCookie, state, time.Now().Add(10*time.Minute))
+	if err != nil {
+		h.fail(writer, request, http.StatusInternalServerError, err)
+		return
+	}
+
+	http.Redirect(writer, request, authUrl, http.StatusFound)
+}
+
+func (h *OidcHandler) callback(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+	query := request.URL.Query()
+
+	state := &loginState{}
+	err := h.sessions.ReadCookie(request, loginCookie, state)
+	h.sessions.ClearCookie(writer, request, loginCookie)
+	if err != nil || state.State == "" || query.Get("state") != state.State {
+		h.fail(writer, request, http.StatusBadRequest, errors.New("invalid login state"))
+		return
+	}
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		h.fail(writer, request, http.StatusUnauthorized, fmt.Errorf("provider error: %s", providerErr))
+		return
+	}
+
+	claims, err := h.provider.Exchange(ctx, query.Get("code"), state.Verifier, state.Nonce)
+	if err != nil {
+		h.fail(writer, request, http.StatusUnauthorized, err)
+		return
+	}
+
+	user, err := h.provisionUser(ctx, claims)
+	if err != nil {
+		h.fail(writer, request, http.StatusForbidden, err)
+		return
+	}
+
+	if err = h.sessions.Issue(writer, request, user.Id); err != nil {
+		h.fail(writer, request, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.logger.InfoContext(ctx, "user logged in", slog.String("user", user.Name), slog.String("role", user.Role))
+
+	http.Redirect(writer, request, state.Redirect, http.StatusFound)
+}
+
+func (h *OidcHandler) logout(writer http.ResponseWriter, request *http.Request) {
+	h.sessions.Clear(writer, request)
+
+	redirect := h.provider.EndSessionUrl(request.Context())
+	if redirect == "" {
+		redirect = h.safeRedirect(request.URL.Query().Get("redirect"))
+	}
+
+	http.Redirect(writer, request, redirect, http.StatusFound)
+}
+
+// provisionUser creates user on first login and keeps role in sync with identity provider claims,
+// users are found by issuer and subject, so identity never takes over a local user with the same name
+func (h *OidcHandler) provisionUser(ctx context.Context, claims jwt.MapClaims) (*storage.User, error) {
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errors.New(`claim "sub" is missing`)
+	}
+	name, _ := claims[h.config.UserClaim].(string)
+	if name == "" {
+		name = subject
+	}
+
+	role, err := h.mapRole(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := h.userRepository.GetByOidcSubject(ctx, h.config.Issuer, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		existing, err := h.userRepository.GetByName(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, fmt.Errorf("user %q already exists and is not created by identity provider", name)
+		}
+
+		user = &storage.User{Name: name, Role: string(role), OidcIssuer: h.config.Issuer, OidcSubject: subject}
+		return user, h.userRepository.Create(ctx, user)
+	}
+
+	if user.Role != string(role) {
+		user.Role = string(role)
+		return user, h.userRepository.SetRole(ctx, user.Name, user.Role)
+	}
+
+	return user, nil
+}
+
+// mapRole returns the highest role mapped from roles claim or default role
+func (h *OidcHandler) mapRole(claims jwt.MapClaims) (Role, error) {
+	var values []string
+	switch claim := claims[h.config.RolesClaim].(type) {
+	case string:
+		values = strings.Fields(claim)
+	case []interface{}:
+		for _, value := range claim {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	var mapped Role
+	for _, value := range values {
+		role, err := ParseRole(h.config.RoleMapping[value])
+		if err == nil && !mapped.Allows(role) {
+			mapped = role
+		}
+	}
+
+	if mapped != "" {
+		return mapped, nil
+	}
+	if h.config.DefaultRole == "" {
+		return "", errors.New("user has no mapped role")
+	}
+
+	return ParseRole(h.config.DefaultRole)
+}
+
+func (h *OidcHandler) fail(writer http.ResponseWriter, request *http.Request, status int, err error) {
+	h.logger.WarnContext(request.Context(), "OIDC login failed", slog.Any("error", err))
+	http.Error(writer, http.StatusText(status), status)
+}
+
+// safeRedirect allows only local paths to prevent open redirects, playground is the default
+func (h *OidcHandler) safeRedirect(redirect string) string {
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+		return h.homePath
+	}
+
+	return redirect
+}
@@ -0,0 +1,341 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sealbro/go-feed-me/internal/api"
+	"github.com/sealbro/go-feed-me/internal/auth"
+	"github.com/sealbro/go-feed-me/internal/db"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testClientId     = "feed-client"
+	testClientSecret = "feed-secret"
+)
+
+// fakeIdentityProvider is a stand-in OpenID Connect provider which approves every authorization request
+type fakeIdentityProvider struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+	// authorization requests by code
+	requests map[string]url.Values
+}
+
+func newFakeIdentityProvider(t *testing.T, claims jwt.MapClaims) *fakeIdentityProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err, "error should be nil")
+
+	idp := &fakeIdentityProvider{key: key, claims: claims, requests: map[string]url.Values{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(writer http.ResponseWriter, _ *http.Request) {
+		writeJson(writer, map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+			"end_session_endpoint":   idp.URL + "/logout",
+		})
+	})
+	mux.HandleFunc("/authorize", func(writer http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query()
+		if query.Get("client_id") != testClientId || query.Get("code_challenge_method") != "S256" {
+			http.Error(writer, "invalid_request", http.StatusBadRequest)
+			return
+		}
+
+		code := fmt.Sprintf("code-%d", len(idp.requests))
+		idp.requests[code] = query
+
+		redirect, _ := url.Parse(query.Get("redirect_uri"))
+		redirect.RawQuery = url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+		http.Redirect(writer, request, redirect.String(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(writer http.ResponseWriter, request *http.Request) {
+		clientId, clientSecret, ok := request.BasicAuth()
+		authorization, found := idp.requests[request.PostFormValue("code")]
+		if !ok || clientId != testClientId || clientSecret != testClientSecret || !found ||
+			request.PostFormValue("grant_type") != "authorization_code" ||
+			request.PostFormValue("redirect_uri") != authorization.Get("redirect_uri") {
+			http.Error(writer, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		challenge := sha256.Sum256([]byte(request.PostFormValue("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(challenge[:]) != authorization.Get("code_challenge") {
+			http.Error(writer, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":   idp.URL,
+			"aud":   testClientId,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": authorization.Get("nonce"),
+		}
+		for name, value := range idp.claims {
+			claims[name] = value
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "idp-key"
+		idToken, err := token.SignedString(idp.key)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJson(writer, map[string]string{"id_token": idToken, "token_type": "Bearer"})
+	})
+	mux.HandleFunc("/jwks", func(writer http.ResponseWriter, _ *http.Request) {
+		writeJson(writer, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "idp-key",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/logout", func(writer http.ResponseWriter, _ *http.Request) {
+		_, _ = writer.Write([]byte("logged out"))
+	})
+
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+
+	return idp
+}
+
+func newOidcApplication(t *testing.T, idp *fakeIdentityProvider, defaultRole string) (*httptest.Server, *storage.UserRepository) {
+	newLogger, err := logger.NewLogger(&logger.Config{LogLevel: "ERROR"})
+	assert.NoError(t, err, "error should be nil")
+
+	database, err := db.NewSqliteDatabase(logger.NewGormLogger(newLogger), &db.Config{
+		SqliteConnection: filepath.Join(t.TempDir(), "feed.db"),
+	})
+	assert.NoError(t, err, "error should be nil")
+
+	userRepository, err := storage.NewUserRepository(database)
+	assert.NoError(t, err, "error should be nil")
+	apiKeyRepository, err := storage.NewApiKeyRepository(database)
+	assert.NoError(t, err, "error should be nil")
+
+	oidcConfig := &auth.OidcConfig{
+		Issuer:       idp.URL,
+		ClientId:     testClientId,
+		ClientSecret: testClientSecret,
+		Scopes:       []string{"openid", "profile"},
+		UserClaim:    "preferred_username",
+		RolesClaim:   "groups",
+		RoleMapping:  map[string]string{"feed-admins": "admin", "feed-editors": "editor"},
+		DefaultRole:  defaultRole,
+		SessionTtl:   time.Hour,
+	}
+	sessions, err := auth.NewSessions(oidcConfig)
+	assert.NoError(t, err, "error should be nil")
+
+	authenticator, err := auth.NewAuthenticator(newLogger, &auth.Config{JwtUserClaim: "sub"}, userRepository, apiKeyRepository, sessions)
+	assert.NoError(t, err, "error should be nil")
+
+	oidcHandler := auth.NewOidcHandler(newLogger, oidcConfig, sessions, userRepository)
+
+	publicApi := api.NewPublicApi(&api.PublicApiConfig{ApplicationSlug: "feed"})
+	publicApi.Router.Use(authenticator.Middleware)
+	oidcHandler.RegisterRoutes(publicApi)
+	publicApi.Router.Handle("/feed/graphql/", oidcHandler.LoginRequired(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		principal := authenticator.Principal(request.Context())
+		_, _ = fmt.Fprintf(writer, "%s:%s", principal.Name, principal.Role)
+	})))
+
+	server := httptest.NewServer(publicApi.Router)
+	t.Cleanup(server.Close)
+
+	oidcConfig.RedirectUrl = server.URL + "/feed/auth/callback"
+
+	return server, userRepository
+}
+
+func TestOidcLogin(t *testing.T) {
+	testCases := []struct {
+		name         string
+		claims       jwt.MapClaims
+		defaultRole  string
+		expectStatus int
+		expectBody   string
+	}{
+		{
+			name:         "mapped highest role",
+			claims:       jwt.MapClaims{"sub": "1", "preferred_username": "alice", "groups": []string{"feed-editors", "feed-admins"}},
+			defaultRole:  "viewer",
+			expectStatus: http.StatusOK,
+			expectBody:   "alice:admin",
+		},
+		{
+			name:         "default role",
+			claims:       jwt.MapClaims{"sub": "2", "preferred_username": "bob", "groups": []string{"other"}},
+			defaultRole:  "viewer",
+			expectStatus: http.StatusOK,
+			expectBody:   "bob:viewer",
+		},
+		{
+			name:         "user claim fallback to subject",
+			claims:       jwt.MapClaims{"sub": "carol", "groups": "feed-editors"},
+			defaultRole:  "viewer",
+			expectStatus: http.StatusOK,
+			expectBody:   "carol:editor",
+		},
+		{
+			name:         "without role",
+			claims:       jwt.MapClaims{"sub": "3", "preferred_username": "dave"},
+			defaultRole:  "",
+			expectStatus: http.StatusForbidden,
+		},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			idp := newFakeIdentityProvider(t, testCase.claims)
+			server, _ := newOidcApplication(t, idp, testCase.defaultRole)
+
+			status, body := get(t, newBrowser(t), server.URL+"/feed/graphql/")
+
+			assert.Equal(t, testCase.expectStatus, status, "status should be equal")
+			if testCase.expectBody != "" {
+				assert.Equal(t, testCase.expectBody, body, "principal should be equal")
+			}
+		})
+	}
+}
+
+func TestOidcLoginUpdatesRoleAndLogout(t *testing.T) {
+	idp := newFakeIdentityProvider(t, jwt.MapClaims{"sub": "1", "preferred_username": "alice", "groups": []string{"feed-editors"}})
+	server, userRepository := newOidcApplication(t, idp, "viewer")
+	browser := newBrowser(t)
+
+	_, body := get(t, browser, server.URL+"/feed/graphql/")
+	assert.Equal(t, "alice:editor", body, "user should be provisioned with mapped role")
+
+	idp.claims["groups"] = []string{"feed-admins"}
+	browser.Jar, _ = cookiejar.New(nil)
+	_, body = get(t, browser, server.URL+"/feed/graphql/")
+	assert.Equal(t, "alice:admin", body, "role should be updated on next login")
+
+	users, err := userRepository.List(context.Background())
+	assert.NoError(t, err, "error should be nil")
+	assert.Len(t, users, 1, "user should be created once")
+
+	_, body = get(t, browser, server.URL+"/feed/auth/logout")
+	assert.Equal(t, "logged out", body, "logout should redirect to provider end session")
+
+	noRedirects := &http.Client{Jar: browser.Jar, CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	response, err := noRedirects.Get(server.URL + "/feed/graphql/")
+	assert.NoError(t, err, "error should be nil")
+	_ = response.Body.Close()
+	assert.Equal(t, http.StatusFound, response.StatusCode, "playground should require login after logout")
+	assert.Contains(t, response.Header.Get("Location"), "/feed/auth/login", "playground should redirect to login")
+}
+
+func TestOidcLoginKeepsLocalUsers(t *testing.T) {
+	idp := newFakeIdentityProvider(t, jwt.MapClaims{"sub": "1", "preferred_username": "root", "groups": []string{"feed-editors"}})
+	server, userRepository := newOidcApplication(t, idp, "viewer")
+	ctx := context.Background()
+
+	err := userRepository.Create(ctx, &storage.User{Name: "root", Role: "admin"})
+	assert.NoError(t, err, "error should be nil")
+
+	status, _ := get(t, newBrowser(t), server.URL+"/feed/graphql/")
+	assert.Equal(t, http.StatusForbidden, status, "identity should not take over local user with the same name")
+
+	user, err := userRepository.GetByName(ctx, "root")
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, "admin", user.Role, "role of local user should not be changed")
+}
+
+func TestOidcLoginFindsUserBySubject(t *testing.T) {
+	idp := newFakeIdentityProvider(t, jwt.MapClaims{"sub": "1", "preferred_username": "alice", "groups": []string{"feed-editors"}})
+	server, userRepository := newOidcApplication(t, idp, "viewer")
+
+	_, body := get(t, newBrowser(t), server.URL+"/feed/graphql/")
+	assert.Equal(t, "alice:editor", body, "user should be provisioned with mapped role")
+
+	idp.claims["preferred_username"] = "alice.smith"
+	_, body = get(t, newBrowser(t), server.URL+"/feed/graphql/")
+	assert.Equal(t, "alice:editor", body, "renamed identity should log in as the same user")
+
+	idp.claims["sub"] = "2"
+	idp.claims["preferred_username"] = "alice"
+	status, _ := get(t, newBrowser(t), server.URL+"/feed/graphql/")
+	assert.Equal(t, http.StatusForbidden, status, "other identity with the same name should be rejected")
+
+	users, err := userRepository.List(context.Background())
+	assert.NoError(t, err, "error should be nil")
+	assert.Len(t, users, 1, "user should be created once")
+}
+
+func TestOidcCallbackRejectsInvalidState(t *testing.T) {
+	idp := newFakeIdentityProvider(t, jwt.MapClaims{"sub": "1"})
+	server, _ := newOidcApplication(t, idp, "viewer")
+
+	status, _ := get(t, newBrowser(t), server.URL+"/feed/auth/callback?code=code-0&state=forged")
+
+	assert.Equal(t, http.StatusBadRequest, status, "callback without login state should be rejected")
+}
+
+func TestOidcLoginRejectsOpenRedirect(t *testing.T) {
+	idp := newFakeIdentityProvider(t, jwt.MapClaims{"sub": "alice"})
+	server, _ := newOidcApplication(t, idp, "viewer")
+
+	status, body := get(t, newBrowser(t), server.URL+"/feed/auth/login?redirect="+url.QueryEscape("//evil.test/"))
+
+	assert.Equal(t, http.StatusOK, status, "status should be equal")
+	assert.Equal(t, "alice:viewer", body, "external redirect should be replaced by playground")
+}
+
+func newBrowser(t *testing.T) *http.Client {
+	jar, err := cookiejar.New(nil)
+	assert.NoError(t, err, "error should be nil")
+
+	return &http.Client{Jar: jar, Timeout: 10 * time.Second}
+}
+
+func get(t *testing.T, client *http.Client, url string) (int, string) {
+	response, err := client.Get(url)
+	assert.NoError(t, err, "error should be nil")
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err, "error should be nil")
+
+	return response.StatusCode, string(body)
+}
+
+func writeJson(writer http.ResponseWriter, value any) {
+	writer.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(writer).Encode(value)
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrUnauthenticated = errors.New("authentication required")
+	ErrForbidden       = errors.New("permission denied")
+)
+
+type principalKey struct{}
+
+// Principal is the caller of the api, anonymous principal has zero UserId
+type Principal struct {
+	UserId uint64
+	Name   string
+	Role   Role
+}
+
+func (p *Principal) IsAnonymous() bool {
+	return p.UserId == 0
+}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFrom returns principal stored in context or nil
+func PrincipalFrom(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+var roleRanks = map[Role]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+// ParseRole returns role by case-insensitive name
+func ParseRole(name string) (Role, error) {
+	role := Role(strings.ToLower(strings.TrimSpace(name)))
+	if _, ok := roleRanks[role]; !ok {
+		return "", fmt.Errorf("unknown role: %q", name)
+	}
+
+	return role, nil
+}
+
+// Allows reports whether role has at least permissions of required role
+func (r Role) Allows(required Role) bool {
+	rank, ok := roleRanks[r]
+	return ok && rank >= roleRanks[required]
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const SessionCookie = "feed_session"
+
+var ErrInvalidCookie = errors.New("invalid cookie")
+
+type session struct {
+	UserId  uint64 `json:"uid"`
+	Expires int64  `json:"exp"`
+}
+
+// Sessions issues and verifies HMAC signed cookies, random secret invalidates sessions on restart
+type Sessions struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+func NewSessions(config *OidcConfig) (*Sessions, error) {
+	secret := []byte(config.SessionSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Sessions{secret: secret, ttl: config.SessionTtl}, nil
+}
+
+// Issue sets session cookie of user
+func (s *Sessions) Issue(writer http.ResponseWriter, request *http.Request, userId uint64) error {
+	expires := time.Now().Add(s.ttl)
+	return s.SetCookie(writer, request, SessionCookie, &session{UserId: userId, Expires: expires.Unix()}, expires)
+}
+
+// UserId returns user of valid session cookie
+func (s *Sessions) UserId(request *http.Request) (uint64, bool) {
+	value := &session{}
+	if err := s.ReadCookie(request, SessionCookie, value); err != nil {
+		return 0, false
+	}
+	if time.Now().Unix() > value.Expires {
+		return 0, false
+	}
+
+	return value.UserId, true
+}
+
+func (s *Sessions) Clear(writer http.ResponseWriter, request *http.Request) {
+	s.ClearCookie(writer, request, SessionCookie)
+}
+
+// SetCookie stores signed json value in cookie
+func (s *Sessions) SetCookie(writer http.ResponseWriter, request *http.Request, name string, value any, expires time.Time) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	http.SetCookie(writer, &http.Cookie{
+		Name:     name,
+		Value:    payload + "." + s.sign(name, payload),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   request.TLS != nil || request.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+// ReadCookie verifies signature and decodes json value of cookie
+func (s *Sessions) ReadCookie(request *http.Request, name string, value any) error {
+	cookie, err := request.Cookie(name)
+	if err != nil {
+		return err
+	}
+
+	payload, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(name, payload))) {
+		return ErrInvalidCookie
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return ErrInvalidCookie
+	}
+
+	return json.Unmarshal(data, value)
+}
+
+func (s *Sessions) ClearCookie(writer http.ResponseWriter, request *http.Request, name string) {
+	http.SetCookie(writer, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   request.TLS != nil || request.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// sign binds signature to cookie name, so value of one cookie can't be replayed as another
+func (s *Sessions) sign(name string, payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
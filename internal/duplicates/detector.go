@@ -0,0 +1,102 @@
+package duplicates
+
+import (
+	"context"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"time"
+)
+
+// Detector groups new articles with stored articles of the same story
+type Detector struct {
+	config     *Config
+	repository *storage.ArticleRepository
+}
+
+func NewDetector(config *Config, repository *storage.ArticleRepository) *Detector {
+	return &Detector{
+		config:     config,
+		repository: repository,
+	}
+}
+
+// Load returns index of articles stored within window
+func (d *Detector) Load(ctx context.Context, now time.Time) (*Index, error) {
+	index := &Index{maxDistance: d.config.MaxDistance}
+	if d.config.MaxDistance < 0 {
+		return index, nil
+	}
+
+	articles, err := d.repository.Fingerprints(ctx, now.Add(-d.config.Window))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, article := range articles {
+		index.Add(article)
+	}
+
+	return index, nil
+}
+
+type entry struct {
+	resourceId  string
+	link        string
+	fingerprint uint64
+	duplicateOf string
+}
+
+// Index fingerprints of articles compared with new articles
+type Index struct {
+	maxDistance int
+	entries     []entry
+}
+
+// NewIndex returns empty index, negative max distance disables detection
+func NewIndex(maxDistance int) *Index {
+	return &Index{maxDistance: maxDistance}
+}
+
+// Cluster returns link of the first article of story cluster which is the closest to fingerprint,
+// empty when article starts a new story. Articles of the same resource are never duplicates,
+// e.g. consecutive releases of one feed differ by a few words only
+func (i *Index) Cluster(resourceId string, link string, fingerprint uint64) string {
+	if i == nil || i.maxDistance < 0 || fingerprint == 0 {
+		return ""
+	}
+
+	cluster, closest := "", i.maxDistance+1
+	for _, entry := range i.entries {
+		if entry.link == link || entry.resourceId == resourceId {
+			continue
+		}
+
+		head := entry.link
+		if entry.duplicateOf != "" {
+			head = entry.duplicateOf
+		}
+		// article which is already the first one of story stays the first
+		if head == link {
+			continue
+		}
+
+		if distance := Distance(entry.fingerprint, fingerprint); distance < closest {
+			cluster, closest = head, distance
+		}
+	}
+
+	return cluster
+}
+
+// Add puts fingerprint of stored article into index
+func (i *Index) Add(article *storage.Article) {
+	if i == nil || article.Fingerprint == 0 {
+		return
+	}
+
+	i.entries = append(i.entries, entry{
+		resourceId:  article.ResourceId,
+		link:        article.Link,
+		fingerprint: uint64(article.Fingerprint),
+		duplicateOf: article.DuplicateOf,
+	})
+}
@@ -0,0 +1,10 @@
+package duplicates
+
+import "time"
+
+type Config struct {
+	// MaxDistance max number of different bits of fingerprints of the same story, negative disables detection
+	MaxDistance int `envconfig:"DUPLICATES_MAX_DISTANCE" default:"5"`
+	// Window how long stored articles are compared with new ones
+	Window time.Duration `envconfig:"DUPLICATES_WINDOW" default:"48h"`
+}
@@ -0,0 +1,230 @@
+package duplicates_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sealbro/go-feed-me/internal/db"
+	"github.com/sealbro/go-feed-me/internal/duplicates"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	releaseTitle   = "Go 1.23 is released"
+	releaseContent = "<p>Today the Go team is happy to release Go 1.23. You can get it from the download page. Go 1.23 comes with iterators in range over func, telemetry and many improvements.</p>"
+	maxDistance    = 5
+
+	goFeed       = "https://go.dev/blog/feed.atom"
+	newsFeed     = "https://news.example.com/feed"
+	rustFeed     = "https://blog.rust-lang.org/feed.xml"
+	releasesFeed = "https://github.com/golang/go/releases.atom"
+)
+
+func TestFingerprint(t *testing.T) {
+	release := duplicates.Fingerprint(releaseTitle, releaseContent)
+
+	testCases := []struct {
+		name      string
+		title     string
+		content   string
+		duplicate bool
+	}{
+		{
+			name:      "punctuation, case and html",
+			title:     "GO 1.23 is released!",
+			content:   "Today the Go team is happy to release Go 1.23. You can get it from the <a href=\"https://go.dev/dl\">download page</a>. Go 1.23 comes with iterators in range over func, telemetry and many improvements.",
+			duplicate: true,
+		},
+		{
+			name:      "summary of the same story",
+			title:     releaseTitle,
+			content:   "Today the Go team is happy to release Go 1.23. You can get it from the download page.",
+			duplicate: true,
+		},
+		{
+			name:      "previous release",
+			title:     "Go 1.22 is released",
+			content:   "<p>Today the Go team is happy to release Go 1.22. You can get it from the download page. Go 1.22 comes with range over integers and loop variable changes.</p>",
+			duplicate: false,
+		},
+		{
+			name:      "other story",
+			title:     "Rust 1.80 is released",
+			content:   "The Rust team is happy to announce a new version of Rust, 1.80.0. Rust is a programming language empowering everyone.",
+			duplicate: false,
+		},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			distance := duplicates.Distance(release, duplicates.Fingerprint(testCase.title, testCase.content))
+			assert.Equal(t, testCase.duplicate, distance <= maxDistance, "distance is %d", distance)
+		})
+	}
+
+	assert.Equal(t, uint64(0), duplicates.Fingerprint(" ", "<p>...</p>"), "text without words should have no fingerprint")
+}
+
+func TestIndex_Cluster(t *testing.T) {
+	release := duplicates.Fingerprint(releaseTitle, releaseContent)
+	other := duplicates.Fingerprint("Rust 1.80 is released", "The Rust team is happy to announce a new version of Rust")
+
+	testCases := []struct {
+		name        string
+		maxDistance int
+		stored      []*storage.Article
+		resource    string
+		link        string
+		fingerprint uint64
+		expect      string
+	}{
+		{
+			name:        "empty index",
+			maxDistance: maxDistance,
+			resource:    newsFeed,
+			link:        "https://news.example.com/go",
+			fingerprint: release,
+			expect:      "",
+		},
+		{
+			name:        "the first article of story",
+			maxDistance: maxDistance,
+			stored:      []*storage.Article{{ResourceId: goFeed, Link: "https://go.dev/blog/go1.23", Fingerprint: int64(release)}},
+			resource:    newsFeed,
+			link:        "https://news.example.com/go",
+			fingerprint: release,
+			expect:      "https://go.dev/blog/go1.23",
+		},
+		{
+			name:        "duplicate points to the first article",
+			maxDistance: maxDistance,
+			stored: []*storage.Article{
+				{ResourceId: rustFeed, Link: "https://rust.example.com", Fingerprint: int64(other)},
+				{ResourceId: newsFeed, Link: "https://news.example.com/go", Fingerprint: int64(release), DuplicateOf: "https://go.dev/blog/go1.23"},
+			},
+			resource:    "https://aggregator.example.com/feed",
+			link:        "https://aggregator.example.com/go",
+			fingerprint: release,
+			expect:      "https://go.dev/blog/go1.23",
+		},
+		{
+			name:        "refetched article is not duplicate of itself",
+			maxDistance: maxDistance,
+			stored: []*storage.Article{
+				{ResourceId: goFeed, Link: "https://go.dev/blog/go1.23", Fingerprint: int64(release)},
+				{ResourceId: newsFeed, Link: "https://news.example.com/go", Fingerprint: int64(release), DuplicateOf: "https://go.dev/blog/go1.23"},
+			},
+			resource:    goFeed,
+			link:        "https://go.dev/blog/go1.23",
+			fingerprint: release,
+			expect:      "",
+		},
+		{
+			name:        "next release of the same resource",
+			maxDistance: maxDistance,
+			stored: []*storage.Article{
+				{ResourceId: releasesFeed, Link: "https://github.com/opencv/opencv/releases/tag/4.9.0", Fingerprint: int64(duplicates.Fingerprint("opencv 4.9.0", ""))},
+				{ResourceId: releasesFeed, Link: "https://github.com/golang/go/releases/tag/1.2.3", Fingerprint: int64(duplicates.Fingerprint("Release 1.2.3", ""))},
+			},
+			resource:    releasesFeed,
+			link:        "https://github.com/golang/go/releases/tag/1.2.4",
+			fingerprint: duplicates.Fingerprint("Release 1.2.4", ""),
+			expect:      "",
+		},
+		{
+			name:        "other story",
+			maxDistance: maxDistance,
+			stored:      []*storage.Article{{ResourceId: rustFeed, Link: "https://rust.example.com", Fingerprint: int64(other)}},
+			resource:    newsFeed,
+			link:        "https://news.example.com/go",
+			fingerprint: release,
+			expect:      "",
+		},
+		{
+			name:        "disabled",
+			maxDistance: -1,
+			stored:      []*storage.Article{{ResourceId: goFeed, Link: "https://go.dev/blog/go1.23", Fingerprint: int64(release)}},
+			resource:    newsFeed,
+			link:        "https://news.example.com/go",
+			fingerprint: release,
+			expect:      "",
+		},
+		{
+			name:        "no fingerprint",
+			maxDistance: maxDistance,
+			stored:      []*storage.Article{{ResourceId: goFeed, Link: "https://go.dev/blog/go1.23"}},
+			resource:    newsFeed,
+			link:        "https://news.example.com/go",
+			expect:      "",
+		},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			index := duplicates.NewIndex(testCase.maxDistance)
+			for _, article := range testCase.stored {
+				index.Add(article)
+			}
+
+			assert.Equal(t, testCase.expect, index.Cluster(testCase.resource, testCase.link, testCase.fingerprint))
+		})
+	}
+
+	index := duplicates.NewIndex(maxDistance)
+	index.Add(&storage.Article{ResourceId: releasesFeed, Link: "https://github.com/golang/go/releases/tag/1.2.3", Fingerprint: int64(duplicates.Fingerprint("Release 1.2.3", ""))})
+	assert.Equal(t, "https://github.com/golang/go/releases/tag/1.2.3", index.Cluster(newsFeed, "https://news.example.com/1.2.4", duplicates.Fingerprint("Release 1.2.4", "")),
+		"close release of other resource should be duplicate")
+}
+
+func TestDetector_Load(t *testing.T) {
+	ctx := context.Background()
+	newLogger, err := logger.NewLogger(&logger.Config{LogLevel: "ERROR"})
+	assert.NoError(t, err, "error should be nil")
+
+	database, err := db.NewSqliteDatabase(logger.NewGormLogger(newLogger), &db.Config{
+		SqliteConnection: filepath.Join(t.TempDir(), "feed.db"),
+	})
+	assert.NoError(t, err, "error should be nil")
+	repository, err := storage.NewArticleRepository(database)
+	assert.NoError(t, err, "error should be nil")
+	_, err = storage.NewOutboxRepository(database)
+	assert.NoError(t, err, "error should be nil")
+
+	fingerprint := int64(duplicates.Fingerprint(releaseTitle, releaseContent))
+	articles := []*storage.Article{
+		{ResourceId: "https://old.example.com/feed", Link: "https://old.example.com/go", Title: releaseTitle, Created: time.Now().Add(-72 * time.Hour), Fingerprint: fingerprint},
+		{ResourceId: goFeed, Link: "https://go.dev/blog/go1.23", Title: releaseTitle, Created: time.Now(), Published: time.Now(), Fingerprint: fingerprint},
+		{ResourceId: newsFeed, Link: "https://news.example.com/go", Title: releaseTitle, Created: time.Now(), Published: time.Now(), Fingerprint: fingerprint, DuplicateOf: "https://go.dev/blog/go1.23"},
+	}
+	for _, article := range articles {
+		_, err = repository.UpsertAndPublish(ctx, article)
+		assert.NoError(t, err, "error should be nil")
+	}
+
+	detector := duplicates.NewDetector(&duplicates.Config{MaxDistance: maxDistance, Window: 48 * time.Hour}, repository)
+	index, err := detector.Load(ctx, time.Now())
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, "https://go.dev/blog/go1.23", index.Cluster("https://aggregator.example.com/feed", "https://aggregator.example.com/go", uint64(fingerprint)),
+		"articles older than window should be ignored")
+
+	listed, err := repository.List(ctx, &storage.ArticleFilter{After: time.Now().Add(-time.Hour)})
+	assert.NoError(t, err, "error should be nil")
+	assert.Len(t, listed, 1, "duplicates should not be listed")
+
+	stored, err := repository.Duplicates(ctx, []string{"https://go.dev/blog/go1.23"})
+	assert.NoError(t, err, "error should be nil")
+	assert.Len(t, stored["https://go.dev/blog/go1.23"], 1)
+	assert.Equal(t, "https://news.example.com/go", stored["https://go.dev/blog/go1.23"][0].Link)
+
+	pruned, err := repository.ListPruned(ctx, time.Now().Add(-time.Hour), "")
+	assert.NoError(t, err, "error should be nil")
+	assert.Empty(t, pruned, "duplicates should be saved without outbox event")
+}
@@ -0,0 +1,81 @@
+package duplicates
+
+import (
+	"hash/fnv"
+	"html"
+	"math/bits"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// contentWords words of content taken into fingerprint, so summary and full text of the same story stay close
+const contentWords = 50
+
+// titleWeight title defines story more than its content
+const titleWeight = 6
+
+var tagsRegex = regexp.MustCompile(`<[^>]*>`)
+
+// Fingerprint returns SimHash of normalized title and content, 0 when there are no words
+func Fingerprint(title string, content string) uint64 {
+	var weights [64]int
+	features := 0
+
+	add := func(feature string, weight int) {
+		hash := fnv.New64a()
+		_, _ = hash.Write([]byte(feature))
+		sum := hash.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit] += weight
+			} else {
+				weights[bit] -= weight
+			}
+		}
+		features++
+	}
+
+	for _, word := range words(title) {
+		add(word, titleWeight)
+	}
+
+	contentWordList := words(content)
+	if len(contentWordList) > contentWords {
+		contentWordList = contentWordList[:contentWords]
+	}
+	for i, word := range contentWordList {
+		add(word, 1)
+		// shingles keep order of words
+		if i > 0 {
+			add(contentWordList[i-1]+" "+word, 1)
+		}
+	}
+
+	if features == 0 {
+		return 0
+	}
+
+	var fingerprint uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+
+	return fingerprint
+}
+
+// Distance number of different bits of fingerprints
+func Distance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// words lower case words of text without html tags and punctuation
+func words(text string) []string {
+	text = html.UnescapeString(tagsRegex.ReplaceAllString(text, " "))
+
+	return strings.FieldsFunc(strings.ToLower(text), func(char rune) bool {
+		return !unicode.IsLetter(char) && !unicode.IsNumber(char)
+	})
+}
@@ -1,7 +1,9 @@
 package graphql_api
 
 import (
+	"context"
 	"fmt"
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/handler/extension"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
@@ -11,33 +13,55 @@ import (
 	"github.com/sealbro/go-feed-me/graph"
 	"github.com/sealbro/go-feed-me/graph/model"
 	"github.com/sealbro/go-feed-me/internal/api"
+	"github.com/sealbro/go-feed-me/internal/auth"
+	"github.com/sealbro/go-feed-me/internal/outbox"
 	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/internal/subscribers"
 	"github.com/sealbro/go-feed-me/internal/traces"
 	"github.com/sealbro/go-feed-me/pkg/logger"
 	"github.com/sealbro/go-feed-me/pkg/notifier"
 	"log/slog"
-	"net/http"
+	"strings"
 	"time"
 )
 
 type GraphqlServer struct {
-	resolvers *graph.Resolver
-	logger    *logger.Logger
+	resolvers   *graph.Resolver
+	oidcHandler *auth.OidcHandler
+	logger      *logger.Logger
 }
 
 func NewGraphqlServer(logger *logger.Logger,
 	articleRepository *storage.ArticleRepository,
 	resourceRepository *storage.ResourceRepository,
+	userRepository *storage.UserRepository,
+	apiKeyRepository *storage.ApiKeyRepository,
+	outboxRepository *storage.OutboxRepository,
+	webhookDeliveryRepository *storage.WebhookDeliveryRepository,
+	ruleRepository *storage.RuleRepository,
+	articleLoader *outbox.ArticleLoader,
+	registry *subscribers.Registry,
+	authenticator *auth.Authenticator,
+	oidcHandler *auth.OidcHandler,
 	tracerProvider traces.ShutdownTracerProvider,
 	subscriptionManager *notifier.SubscriptionManager[*model.FeedArticle]) *GraphqlServer {
 	graphqlApi := &GraphqlServer{
 		resolvers: &graph.Resolver{
-			ArticleRepository:   articleRepository,
-			ResourceRepository:  resourceRepository,
-			SubscriptionManager: subscriptionManager,
-			TracerProvider:      tracerProvider,
+			ArticleRepository:         articleRepository,
+			ResourceRepository:        resourceRepository,
+			UserRepository:            userRepository,
+			ApiKeyRepository:          apiKeyRepository,
+			OutboxRepository:          outboxRepository,
+			WebhookDeliveryRepository: webhookDeliveryRepository,
+			RuleRepository:            ruleRepository,
+			ArticleLoader:             articleLoader,
+			Subscribers:               registry,
+			Authenticator:             authenticator,
+			SubscriptionManager:       subscriptionManager,
+			TracerProvider:            tracerProvider,
 		},
-		logger: logger,
+		oidcHandler: oidcHandler,
+		logger:      logger,
 	}
 
 	return graphqlApi
@@ -46,17 +70,24 @@ func NewGraphqlServer(logger *logger.Logger,
 func (server *GraphqlServer) RegisterRoutes(registrar api.Registrar) {
 	urlPrefix := "graphql"
 
-	schema := graph.NewExecutableSchema(graph.Config{Resolvers: server.resolvers})
+	schema := graph.NewExecutableSchema(graph.Config{
+		Resolvers: server.resolvers,
+		Directives: graph.DirectiveRoot{
+			HasRole: server.resolvers.HasRole,
+		},
+	})
 	srv := handler.NewDefaultServer(schema)
 
 	srv.AddTransport(transport.POST{})
 	srv.AddTransport(transport.Websocket{
 		KeepAlivePingInterval: 10 * time.Second,
 		Upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				return true
-			},
+			CheckOrigin: server.resolvers.Authenticator.CheckOrigin,
 		},
+		InitFunc: server.websocketInit,
+	})
+	srv.AroundOperations(func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+		return next(server.resolvers.WithDuplicatesLoader(ctx))
 	})
 	srv.Use(extension.Introspection{})
 	srv.Use(PrometheusMetrics{})
@@ -66,7 +97,7 @@ func (server *GraphqlServer) RegisterRoutes(registrar api.Registrar) {
 	playgroundEndpoint := registrar.Prefix(urlPrefix, "/")
 
 	registrar.RegisterRoutesFunc(func(router *mux.Router) {
-		router.Handle(playgroundEndpoint, playground.Handler("GraphQL playground", endpoint))
+		router.Handle(playgroundEndpoint, server.oidcHandler.LoginRequired(playground.Handler("GraphQL playground", endpoint)))
 		router.Handle(endpoint, srv)
 	})
 
@@ -74,3 +105,24 @@ func (server *GraphqlServer) RegisterRoutes(registrar api.Registrar) {
 
 	server.logger.Info("GraphQl server", slog.String("url", graphqlUrl))
 }
+
+// websocketInit authenticates subscription by token from connection_init payload,
+// browsers can't set headers on websocket upgrade request. Session cookie of upgrade request is not trusted,
+// a page of other site can open websocket with cookies of user, so subscription without token stays anonymous
+func (server *GraphqlServer) websocketInit(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+	token := payload.GetString(auth.ApiKeyHeader)
+	if token == "" {
+		token = strings.TrimSpace(strings.TrimPrefix(payload.Authorization(), "Bearer "))
+	}
+	if token == "" {
+		return auth.WithPrincipal(ctx, nil), &payload, nil
+	}
+
+	principal, err := server.resolvers.Authenticator.AuthenticateToken(ctx, token)
+	if err != nil {
+		server.logger.WarnContext(ctx, "can't authenticate websocket", slog.Any("error", err))
+		return ctx, nil, auth.ErrUnauthenticated
+	}
+
+	return auth.WithPrincipal(ctx, principal), &payload, nil
+}
@@ -0,0 +1,120 @@
+package job
+
+import (
+	"cmp"
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"strconv"
+	"strings"
+)
+
+// itemEnclosures returns enclosures of item and media:content of media rss, like YouTube videos,
+// iTunes duration, episode, season and image or media thumbnail are added to every enclosure
+func itemEnclosures(item *gofeed.Item) []*storage.Enclosure {
+	duration, episode, season := 0, 0, 0
+	if item.ITunesExt != nil {
+		duration = parseDuration(item.ITunesExt.Duration)
+		episode = parseNumber(item.ITunesExt.Episode)
+		season = parseNumber(item.ITunesExt.Season)
+	}
+	thumbnail := itemThumbnail(item)
+
+	enclosures := make([]*storage.Enclosure, 0, len(item.Enclosures))
+	add := func(url string, mimeType string, length int64, mediaDuration int) {
+		url = strings.TrimSpace(url)
+		if url == "" {
+			return
+		}
+		// the same file of enclosure and media:content is merged, each of them may miss type, size or duration
+		for _, enclosure := range enclosures {
+			if enclosure.Url == url {
+				enclosure.Type = cmp.Or(enclosure.Type, strings.TrimSpace(mimeType))
+				enclosure.Length = max(enclosure.Length, length)
+				enclosure.Duration = max(enclosure.Duration, mediaDuration)
+				return
+			}
+		}
+
+		enclosures = append(enclosures, &storage.Enclosure{
+			Url:       url,
+			Type:      strings.TrimSpace(mimeType),
+			Length:    length,
+			Duration:  max(duration, mediaDuration),
+			Episode:   episode,
+			Season:    season,
+			Thumbnail: thumbnail,
+		})
+	}
+
+	for _, enclosure := range item.Enclosures {
+		length, _ := strconv.ParseInt(strings.TrimSpace(enclosure.Length), 10, 64)
+		add(enclosure.URL, enclosure.Type, max(length, 0), 0)
+	}
+	for _, content := range mediaElements(item, "content") {
+		length, _ := strconv.ParseInt(content.Attrs["fileSize"], 10, 64)
+		add(content.Attrs["url"], content.Attrs["type"], max(length, 0), parseNumber(content.Attrs["duration"]))
+	}
+
+	return enclosures
+}
+
+// itemImage returns image of item, iTunes image or media thumbnail
+func itemImage(item *gofeed.Item) string {
+	if item.Image != nil && item.Image.URL != "" {
+		return item.Image.URL
+	}
+
+	return itemThumbnail(item)
+}
+
+func itemThumbnail(item *gofeed.Item) string {
+	if item.ITunesExt != nil && item.ITunesExt.Image != "" {
+		return item.ITunesExt.Image
+	}
+	for _, thumbnail := range mediaElements(item, "thumbnail") {
+		if url := strings.TrimSpace(thumbnail.Attrs["url"]); url != "" {
+			return url
+		}
+	}
+
+	return ""
+}
+
+// mediaElements returns media rss elements of item including elements of media:group
+func mediaElements(item *gofeed.Item, name string) []ext.Extension {
+	media, ok := item.Extensions["media"]
+	if !ok {
+		return nil
+	}
+
+	elements := append([]ext.Extension{}, media[name]...)
+	for _, group := range media["group"] {
+		elements = append(elements, group.Children[name]...)
+	}
+
+	return elements
+}
+
+// parseDuration parses iTunes duration in seconds or HH:MM:SS, 0 when it is invalid
+func parseDuration(value string) int {
+	seconds := 0
+	for _, part := range strings.Split(strings.TrimSpace(value), ":") {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return 0
+		}
+		seconds = seconds*60 + number
+	}
+
+	return seconds
+}
+
+func parseNumber(value string) int {
+	number, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || number < 0 {
+		return 0
+	}
+
+	return number
+}
@@ -0,0 +1,95 @@
+package job_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// parseFixture returns articles of feed from testdata
+func parseFixture(t *testing.T, name string) []storage.Article {
+	feed, err := os.ReadFile(filepath.Join("testdata", name))
+	assert.NoError(t, err, "error should be nil")
+
+	parser, _ := newParserJob(t)
+	_, articles, _, err := parser.FromUrl(context.Background(), storage.Resource{Url: serveFeed(t, string(feed)), Active: true})
+	assert.NoError(t, err, "error should be nil")
+
+	return articles
+}
+
+func TestParserFeedJob_Enclosures(t *testing.T) {
+	testCases := []struct {
+		name       string
+		fixture    string
+		image      string
+		enclosures []*storage.Enclosure
+	}{
+		{
+			name:    "itunes podcast",
+			fixture: "podcast.xml",
+			image:   "https://cdn.changelog.com/gotime-325.png",
+			enclosures: []*storage.Enclosure{{
+				Url:       "https://op3.dev/e/cdn.changelog.com/gotime-325.mp3",
+				Type:      "audio/mpeg",
+				Length:    62312345,
+				Duration:  3723,
+				Episode:   325,
+				Season:    7,
+				Thumbnail: "https://cdn.changelog.com/gotime-325.png",
+			}},
+		},
+		{
+			name:    "youtube media group",
+			fixture: "youtube.xml",
+			image:   "https://i2.ytimg.com/vi/3fD8XT8WyA8/hqdefault.jpg",
+			enclosures: []*storage.Enclosure{{
+				Url:       "https://www.youtube.com/v/3fD8XT8WyA8?version=3",
+				Type:      "application/x-shockwave-flash",
+				Thumbnail: "https://i2.ytimg.com/vi/3fD8XT8WyA8/hqdefault.jpg",
+			}},
+		},
+		{
+			name:    "media rss merged with enclosure",
+			fixture: "media.xml",
+			image:   "https://videos.example.com/keynote.jpg",
+			enclosures: []*storage.Enclosure{
+				{
+					Url:       "https://videos.example.com/keynote.mp4",
+					Type:      "video/mp4",
+					Length:    734003200,
+					Duration:  2710,
+					Thumbnail: "https://videos.example.com/keynote.jpg",
+				},
+				{
+					Url:       "https://videos.example.com/keynote.webm",
+					Type:      "video/webm",
+					Duration:  2710,
+					Thumbnail: "https://videos.example.com/keynote.jpg",
+				},
+			},
+		},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			articles := parseFixture(t, testCase.fixture)
+			assert.NotEmpty(t, articles)
+			assert.Equal(t, testCase.image, articles[0].Image)
+			assert.Equal(t, testCase.enclosures, articles[0].Enclosures)
+		})
+	}
+}
+
+func TestParserFeedJob_NoEnclosures(t *testing.T) {
+	articles := parseFixture(t, "media.xml")
+	assert.Len(t, articles, 2)
+	assert.Empty(t, articles[1].Image, "item without media should have no image")
+	assert.Empty(t, articles[1].Enclosures, "item without media should have no enclosures")
+}
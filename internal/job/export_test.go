@@ -0,0 +1,11 @@
+package job
+
+import (
+	"context"
+	"github.com/sealbro/go-feed-me/internal/storage"
+)
+
+// FromUrl exposes parsing of resource to tests of job_test package
+func (p *ParserFeedJob) FromUrl(ctx context.Context, resource storage.Resource) (*storage.Resource, []storage.Article, int, error) {
+	return p.fromUrl(ctx, resource)
+}
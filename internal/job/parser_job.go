@@ -1,12 +1,17 @@
 package job
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"github.com/mmcdole/gofeed"
 	"github.com/reugn/go-quartz/quartz"
 	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/duplicates"
+	"github.com/sealbro/go-feed-me/internal/links"
 	"github.com/sealbro/go-feed-me/internal/metrics"
+	"github.com/sealbro/go-feed-me/internal/outbox"
+	"github.com/sealbro/go-feed-me/internal/rules"
 	"github.com/sealbro/go-feed-me/internal/storage"
 	"github.com/sealbro/go-feed-me/internal/traces"
 	"github.com/sealbro/go-feed-me/pkg/logger"
@@ -14,6 +19,8 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 	"log/slog"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -22,6 +29,10 @@ type ParserFeedJob struct {
 	logger             *logger.Logger
 	articleRepository  *storage.ArticleRepository
 	resourceRepository *storage.ResourceRepository
+	userRepository     *storage.UserRepository
+	rules              *rules.Engine
+	duplicates         *duplicates.Detector
+	links              *links.Canonicalizer
 	manager            *notifier.SubscriptionManager[*model.FeedArticle]
 	tracerProvider     traces.ShutdownTracerProvider
 }
@@ -29,6 +40,10 @@ type ParserFeedJob struct {
 func NewParserFeedJob(logger *logger.Logger,
 	articleRepository *storage.ArticleRepository,
 	resourceRepository *storage.ResourceRepository,
+	userRepository *storage.UserRepository,
+	rules *rules.Engine,
+	duplicates *duplicates.Detector,
+	links *links.Canonicalizer,
 	tracerProvider traces.ShutdownTracerProvider,
 	manager *notifier.SubscriptionManager[*model.FeedArticle],
 ) quartz.Job {
@@ -38,6 +53,10 @@ func NewParserFeedJob(logger *logger.Logger,
 		feedParser:         gofeed.NewParser(),
 		articleRepository:  articleRepository,
 		resourceRepository: resourceRepository,
+		userRepository:     userRepository,
+		rules:              rules,
+		duplicates:         duplicates,
+		links:              links,
 		tracerProvider:     tracerProvider,
 	}
 }
@@ -55,10 +74,20 @@ func (p *ParserFeedJob) Execute(ctx context.Context) error {
 
 	span.AddEvent("resources", trace.WithAttributes(attribute.Key("resources.count").Int(len(resources))))
 
+	ruleSet, err := p.rules.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("can't load rules: %w", err)
+	}
+
+	index, err := p.duplicates.Load(ctx, time.Now())
+	if err != nil {
+		return fmt.Errorf("can't load fingerprints: %w", err)
+	}
+
 	for _, resource := range resources {
 		time.Sleep(3 * time.Second)
 
-		if !p.processResource(ctx, tracer, resource) {
+		if !p.processResource(ctx, tracer, resource, ruleSet, index) {
 			return fmt.Errorf("can't process resource: %s", resource.Url)
 		}
 	}
@@ -66,34 +95,81 @@ func (p *ParserFeedJob) Execute(ctx context.Context) error {
 	return nil
 }
 
-func (p *ParserFeedJob) processResource(ctx context.Context, tracer trace.Tracer, resource *storage.Resource) bool {
+// processResource saves and publishes parsed articles after applying rules to them,
+// duplicates of stories from index are saved without notification
+func (p *ParserFeedJob) processResource(ctx context.Context, tracer trace.Tracer, resource *storage.Resource, ruleSet *rules.RuleSet, index *duplicates.Index) bool {
 	ctx, span := tracer.Start(ctx, resource.Url)
 	defer span.End()
 
-	updatedResource, articles, err := p.fromUrl(ctx, *resource)
+	updatedResource, articles, filtered, err := p.fromUrl(ctx, *resource)
 	if err != nil {
-		p.logger.WarnContext(ctx, "can't parse resource", slog.String("url", resource.Url))
+		p.logger.WarnContext(ctx, "can't parse resource", slog.String("url", resource.Url), slog.Any("error", err))
 		return true
 	}
 
-	if len(articles) == 0 {
+	if len(articles) == 0 && filtered == 0 {
 		return true
 	}
 
-	// TODO: notify only new articles
-	p.notify(articles, updatedResource)
+	p.logger.InfoContext(ctx, "resource fetched", slog.String("url", resource.Url),
+		slog.Int("articles", len(articles)), slog.Int("filtered", filtered))
+	span.SetAttributes(attribute.Key("articles.count").Int(len(articles)), attribute.Key("articles.filtered").Int(filtered))
 
+	feedArticles := make([]*model.FeedArticle, 0, len(articles))
+	readLinks := make([]string, 0)
+	now := time.Now()
 	for _, article := range articles {
-		err = p.articleRepository.Upsert(ctx, &article)
+		outcome := ruleSet.Apply(outbox.NewFeedArticle(&article, updatedResource), now)
+		if outcome.Drop {
+			p.logger.InfoContext(ctx, "article dropped by rule", slog.String("url", article.Link), slog.Any("rules", outcome.Rules))
+			continue
+		}
+		article.Starred = outcome.Star
+		article.Priority = outcome.Priority
+		article.Subscribers = outcome.Subscribers
+		article.Tags = outcome.Tags
+		if outcome.MarkRead {
+			readLinks = append(readLinks, article.Link)
+		}
+
+		fingerprint := duplicates.Fingerprint(article.Title, cmp.Or(article.Description, article.Content))
+		article.Fingerprint = int64(fingerprint)
+		article.DuplicateOf = index.Cluster(article.ResourceId, article.Link, fingerprint)
+
+		eventId, err := p.articleRepository.UpsertAndPublish(ctx, &article)
 		if err != nil {
 			p.logger.ErrorContext(ctx, "can't save article", slog.String("url", article.Link))
+			// saved articles are already in outbox, live subscribers get them too
+			p.manager.Notify(feedArticles...)
 			return false
-		} else {
-			metrics.AddedArticlesCounter.Inc()
-			p.logger.InfoContext(ctx, "article saved", slog.String("url", article.Link))
 		}
+
+		index.Add(&article)
+		// items without published date are parsed on every fetch, stored ones are updated without notification
+		if eventId == 0 && article.DuplicateOf == "" {
+			p.logger.DebugContext(ctx, "article updated", slog.String("url", article.Link))
+			continue
+		}
+
+		metrics.AddedArticlesCounter.Inc()
+		if article.DuplicateOf != "" {
+			p.logger.InfoContext(ctx, "duplicate article saved", slog.String("url", article.Link), slog.String("duplicate_of", article.DuplicateOf))
+			continue
+		}
+		p.logger.InfoContext(ctx, "article saved", slog.String("url", article.Link))
+
+		feedArticle := outbox.NewFeedArticle(&article, updatedResource)
+		feedArticle.EventId = eventId
+		feedArticles = append(feedArticles, feedArticle)
+	}
+
+	if err = p.userRepository.MarkReadForAll(ctx, readLinks); err != nil {
+		p.logger.WarnContext(ctx, "can't mark articles read", slog.String("url", resource.Url), slog.Any("error", err))
 	}
 
+	// notify after saving, so subscribers resuming from cursor never miss articles in between
+	p.manager.Notify(feedArticles...)
+
 	err = p.resourceRepository.Upsert(ctx, updatedResource)
 	if err != nil {
 		p.logger.ErrorContext(ctx, "can't save resource", slog.String("url", resource.Url))
@@ -105,25 +181,6 @@ func (p *ParserFeedJob) processResource(ctx context.Context, tracer trace.Tracer
 	return true
 }
 
-func (p *ParserFeedJob) notify(articles []storage.Article, resource *storage.Resource) {
-	feedArticles := make([]*model.FeedArticle, len(articles))
-	for i, article := range articles {
-		feedArticles[i] = &model.FeedArticle{
-			Created:       article.Created,
-			Published:     article.Published,
-			ResourceID:    article.ResourceId,
-			ResourceTitle: resource.Title,
-			Link:          article.Link,
-			Title:         article.Title,
-			Description:   article.Description,
-			Content:       article.Content,
-			Author:        article.Author,
-			Image:         article.Image,
-		}
-	}
-	p.manager.Notify(feedArticles...)
-}
-
 func (p *ParserFeedJob) Description() string {
 	return "Feed parser"
 }
@@ -132,18 +189,35 @@ func (p *ParserFeedJob) Key() int {
 	return FeedParser
 }
 
-func (p *ParserFeedJob) fromUrl(ctx context.Context, resource storage.Resource) (*storage.Resource, []storage.Article, error) {
+// fromUrl returns new articles of resource with canonical links which pass its include and exclude patterns
+// and count of filtered out items
+func (p *ParserFeedJob) fromUrl(ctx context.Context, resource storage.Resource) (*storage.Resource, []storage.Article, int, error) {
 	url := resource.Url
 
+	patterns, err := rules.NewPatterns(resource.IncludePatterns, resource.ExcludePatterns)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
 	feed, err := p.feedParser.ParseURLWithContext(url, ctx)
 	if err != nil || feed == nil || len(feed.Items) == 0 {
-		return nil, nil, err
+		return nil, nil, 0, err
 	}
 
 	dateTimeNow := time.Now()
 
+	itemLinks := make([]string, len(feed.Items))
+	for i, item := range feed.Items {
+		itemLinks[i] = item.Link
+	}
+	storedLinks, err := p.articleRepository.StoredLinks(ctx, itemLinks)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
 	var articles []storage.Article
 	var maxPublished time.Time
+	filtered := 0
 
 	for _, item := range feed.Items {
 		if item.PublishedParsed != nil && resource.Published.After(*item.PublishedParsed) {
@@ -155,38 +229,102 @@ func (p *ParserFeedJob) fromUrl(ctx context.Context, resource storage.Resource)
 			published = *item.PublishedParsed
 		}
 
+		// filtered items move published mark too, so they are not filtered again on next fetch
+		if published.After(maxPublished) {
+			maxPublished = published
+		}
+
+		if !patterns.Allow(item.Title, item.Link, item.Description) {
+			filtered++
+			continue
+		}
+
+		authors := itemAuthors(item)
 		author := ""
-		if len(item.Authors) > 0 {
-			author = item.Authors[0].Name
+		if len(authors) > 0 {
+			author = authors[0].Name
+		}
+
+		var updated time.Time
+		if item.UpdatedParsed != nil {
+			updated = *item.UpdatedParsed
 		}
 
-		image := ""
-		if item.Image != nil {
-			image = item.Image.URL
+		// stored article keeps its link, so changed canonical form does not save it again as new one
+		link, stored := storedLinks[item.Link]
+		if !stored {
+			link = p.links.Canonical(ctx, item.Link)
 		}
 
 		articles = append(articles, storage.Article{
-			ResourceId:  url,
-			Created:     dateTimeNow,
-			Link:        item.Link,
-			Title:       item.Title,
-			Description: item.Description,
-			Content:     item.Content,
-			Author:      author,
-			Image:       image,
-			Published:   published,
+			ResourceId:   url,
+			Created:      dateTimeNow,
+			Link:         link,
+			OriginalLink: item.Link,
+			Title:        item.Title,
+			Description:  item.Description,
+			Content:      item.Content,
+			Author:       author,
+			Image:        itemImage(item),
+			Enclosures:   itemEnclosures(item),
+			Authors:      authors,
+			Categories:   itemCategories(item),
+			Guid:         item.GUID,
+			Updated:      updated,
+			Published:    published,
 		})
+	}
 
-		if published.After(maxPublished) {
-			maxPublished = published
-		}
+	image := ""
+	if feed.Image != nil {
+		image = feed.Image.URL
+	}
+	if image == "" && feed.ITunesExt != nil {
+		image = feed.ITunesExt.Image
 	}
 
 	return &storage.Resource{
-		Modified:  dateTimeNow,
-		Published: maxPublished.Add(time.Second),
-		Url:       url,
-		Title:     feed.Title,
-		Active:    resource.Active,
-	}, articles, nil
+		Modified:    dateTimeNow,
+		Published:   maxPublished.Add(time.Second),
+		Url:         url,
+		Title:       feed.Title,
+		Active:      resource.Active,
+		Tags:        resource.Tags,
+		Description: feed.Description,
+		Link:        feed.Link,
+		Image:       image,
+		Language:    feed.Language,
+		Generator:   feed.Generator,
+	}, articles, filtered, nil
+}
+
+// itemAuthors returns authors with names or emails in feed order
+func itemAuthors(item *gofeed.Item) []*storage.ArticleAuthor {
+	authors := make([]*storage.ArticleAuthor, 0, len(item.Authors))
+	for _, person := range item.Authors {
+		if person == nil {
+			continue
+		}
+
+		name, email := strings.TrimSpace(person.Name), strings.TrimSpace(person.Email)
+		if name == "" && email == "" {
+			continue
+		}
+		authors = append(authors, &storage.ArticleAuthor{Name: cmp.Or(name, email), Email: email})
+	}
+
+	return authors
+}
+
+// itemCategories returns trimmed categories without repeats, compared case-insensitively
+func itemCategories(item *gofeed.Item) []string {
+	categories := make([]string, 0, len(item.Categories))
+	for _, category := range item.Categories {
+		category = strings.TrimSpace(category)
+		if category != "" && !slices.ContainsFunc(categories, func(existing string) bool { return strings.EqualFold(existing, category) }) {
+			categories = append(categories, category)
+		}
+	}
+
+	return categories
 }
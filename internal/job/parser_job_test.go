@@ -0,0 +1,122 @@
+package job_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sealbro/go-feed-me/internal/db"
+	"github.com/sealbro/go-feed-me/internal/job"
+	"github.com/sealbro/go-feed-me/internal/links"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+const releasesFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>Go releases</title>
+    <link>https://github.com/golang/go/releases</link>
+    <item>
+      <title>go1.23rc2</title>
+      <link>https://github.com/golang/go/releases/tag/go1.23rc2</link>
+      <pubDate>Wed, 03 Jul 2024 10:00:00 GMT</pubDate>
+    </item>
+    <item>
+      <title>go1.22.5</title>
+      <link>https://github.com/golang/go/releases/tag/go1.22.5?utm_source=rss</link>
+      <pubDate>Tue, 02 Jul 2024 10:00:00 GMT</pubDate>
+    </item>
+    <item>
+      <title>go1.22.4</title>
+      <link>https://github.com/golang/go/releases/tag/go1.22.4</link>
+      <pubDate>Mon, 01 Jul 2024 10:00:00 GMT</pubDate>
+    </item>
+  </channel>
+</rss>`
+
+// newParserJob creates job parsing feeds without rules, duplicates and notifications
+func newParserJob(t *testing.T) (*job.ParserFeedJob, *storage.ArticleRepository) {
+	newLogger, err := logger.NewLogger(&logger.Config{LogLevel: "ERROR"})
+	assert.NoError(t, err, "error should be nil")
+
+	database, err := db.NewSqliteDatabase(logger.NewGormLogger(newLogger), &db.Config{
+		SqliteConnection: filepath.Join(t.TempDir(), "feed.db"),
+	})
+	assert.NoError(t, err, "error should be nil")
+	articleRepository, err := storage.NewArticleRepository(database)
+	assert.NoError(t, err, "error should be nil")
+
+	canonicalizer := links.NewCanonicalizer(newLogger, &links.Config{TrackingParams: []string{"utm_*"}, ForceHttps: true})
+	parser := job.NewParserFeedJob(newLogger, articleRepository, nil, nil, nil, nil, canonicalizer, nil, nil)
+
+	return parser.(*job.ParserFeedJob), articleRepository
+}
+
+// serveFeed returns url of server responding with feed
+func serveFeed(t *testing.T, feed string) string {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Header().Set("Content-Type", "application/rss+xml")
+		_, _ = writer.Write([]byte(feed))
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL + "/feed.xml"
+}
+
+func TestParserFeedJob_Patterns(t *testing.T) {
+	parser, _ := newParserJob(t)
+	resource := storage.Resource{
+		Url:             serveFeed(t, releasesFeed),
+		Active:          true,
+		Published:       time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC),
+		ExcludePatterns: []string{"/rc\\d*$/"},
+	}
+
+	updated, articles, filtered, err := parser.FromUrl(context.Background(), resource)
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, 1, filtered, "release candidate should be filtered")
+	assert.Len(t, articles, 1, "article older than published mark should be skipped")
+	assert.Equal(t, "https://github.com/golang/go/releases/tag/go1.22.5", articles[0].Link)
+	assert.Equal(t, "https://github.com/golang/go/releases/tag/go1.22.5?utm_source=rss", articles[0].OriginalLink)
+	assert.True(t, updated.Published.Equal(time.Date(2024, 7, 3, 10, 0, 1, 0, time.UTC)), "published mark should move past filtered item, got %s", updated.Published)
+
+	resource.Published = updated.Published
+	_, articles, filtered, err = parser.FromUrl(context.Background(), resource)
+	assert.NoError(t, err, "error should be nil")
+	assert.Empty(t, articles, "parsed items should not be parsed again")
+	assert.Zero(t, filtered, "filtered item should not be counted again")
+}
+
+func TestParserFeedJob_StoredLinks(t *testing.T) {
+	parser, articleRepository := newParserJob(t)
+	stored := &storage.Article{
+		ResourceId:   "https://github.com/golang/go/releases.atom",
+		Link:         "https://github.com/golang/go/releases/tag/go1.22.5?utm_source=rss",
+		OriginalLink: "https://github.com/golang/go/releases/tag/go1.22.5?utm_source=rss",
+		Created:      time.Now(),
+	}
+	assert.NoError(t, articleRepository.Upsert(context.Background(), stored), "error should be nil")
+
+	_, articles, _, err := parser.FromUrl(context.Background(), storage.Resource{Url: serveFeed(t, releasesFeed), Active: true})
+	assert.NoError(t, err, "error should be nil")
+	assert.Len(t, articles, 3)
+	assert.Equal(t, stored.Link, articles[1].Link, "stored article should keep its link")
+	assert.Equal(t, "https://github.com/golang/go/releases/tag/go1.23rc2", articles[0].Link)
+}
+
+func TestParserFeedJob_AuthorsAndCategories(t *testing.T) {
+	articles := parseFixture(t, "authors.xml")
+	assert.Len(t, articles, 1)
+
+	assert.Equal(t, []*storage.ArticleAuthor{
+		{Name: "Ian Lance Taylor", Email: "iant@golang.org"},
+		{Name: "rsc@golang.org", Email: "rsc@golang.org"},
+	}, articles[0].Authors, "author with email only should be named by email, empty author should be skipped")
+	assert.Equal(t, "Ian Lance Taylor", articles[0].Author, "the first author should be main one")
+	assert.Equal(t, []string{"Iterators", "Go"}, articles[0].Categories, "categories should be trimmed and repeated ones compared ignoring case")
+}
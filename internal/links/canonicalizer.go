@@ -0,0 +1,244 @@
+package links
+
+import (
+	"context"
+	"fmt"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// maxPageSize bytes of article page searched for canonical link
+const maxPageSize = 1 << 20
+
+// Canonicalizer turns different links of the same article into one link
+type Canonicalizer struct {
+	logger    *logger.Logger
+	config    *Config
+	client    *http.Client
+	hostRules map[string][]string
+}
+
+func NewCanonicalizer(logger *logger.Logger, config *Config) *Canonicalizer {
+	hostRules := make(map[string][]string, len(config.HostRules))
+	for host, params := range config.HostRules {
+		kept := make([]string, 0)
+		for _, param := range strings.Split(params, "|") {
+			if param = strings.TrimSpace(param); param != "" {
+				kept = append(kept, param)
+			}
+		}
+		hostRules[strings.ToLower(strings.TrimPrefix(strings.TrimSpace(host), "www."))] = kept
+	}
+
+	return &Canonicalizer{
+		logger:    logger,
+		config:    config,
+		client:    &http.Client{Timeout: config.Timeout},
+		hostRules: hostRules,
+	}
+}
+
+// Canonical returns canonical link of article, with FollowCanonical rel=canonical link of article page wins,
+// page which can not be loaded keeps the link
+func (c *Canonicalizer) Canonical(ctx context.Context, link string) string {
+	canonical := c.Normalize(link)
+	if !c.config.FollowCanonical || canonical == "" {
+		return canonical
+	}
+
+	pageCanonical, err := c.pageCanonical(ctx, link)
+	if err != nil {
+		c.logger.DebugContext(ctx, "can't load canonical link", slog.String("url", link), slog.Any("error", err))
+		return canonical
+	}
+	if pageCanonical == "" {
+		return canonical
+	}
+
+	return c.Normalize(pageCanonical)
+}
+
+// Normalize removes fragment, default port, trailing slash and tracking parameters, sorts the rest parameters
+// and lower cases scheme and host, links which are not http urls are only trimmed
+func (c *Canonicalizer) Normalize(link string) string {
+	link = strings.TrimSpace(link)
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Host == "" {
+		return link
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return link
+	}
+
+	// default port is removed by scheme of link, before it is forced to https
+	host := strings.ToLower(parsed.Hostname())
+	port := parsed.Port()
+	if port == "" || (port == "80" && parsed.Scheme == "http") || (port == "443" && parsed.Scheme == "https") {
+		parsed.Host = host
+	} else {
+		parsed.Host = host + ":" + port
+	}
+	if c.config.ForceHttps {
+		parsed.Scheme = "https"
+	}
+
+	parsed.Fragment, parsed.RawFragment = "", ""
+	parsed.User = nil
+	parsed.Path = strings.TrimRight(parsed.Path, "/")
+	parsed.RawPath = ""
+	parsed.RawQuery = c.query(host, parsed.RawQuery)
+
+	return parsed.String()
+}
+
+// queryParam parameter of query, parameter without value like ?amp is kept without "="
+type queryParam struct {
+	name     string
+	value    string
+	hasValue bool
+}
+
+func (c *Canonicalizer) query(host string, rawQuery string) string {
+	kept, hasRule := c.hostRule(host)
+
+	params := make([]queryParam, 0)
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		rawName, rawValue, hasValue := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(rawName)
+		if err != nil {
+			name = rawName
+		}
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			value = rawValue
+		}
+
+		if hasRule && !slices.Contains(kept, name) || !hasRule && c.tracking(name) {
+			continue
+		}
+		params = append(params, queryParam{name: name, value: value, hasValue: hasValue})
+	}
+	sort.SliceStable(params, func(i, j int) bool {
+		return params[i].name < params[j].name
+	})
+
+	var query strings.Builder
+	for _, param := range params {
+		if query.Len() > 0 {
+			query.WriteByte('&')
+		}
+		query.WriteString(url.QueryEscape(param.name))
+		if param.hasValue {
+			query.WriteByte('=')
+			query.WriteString(url.QueryEscape(param.value))
+		}
+	}
+
+	return query.String()
+}
+
+// hostRule returns parameters kept for host or its parent domain
+func (c *Canonicalizer) hostRule(host string) ([]string, bool) {
+	host = strings.TrimPrefix(host, "www.")
+	for {
+		if kept, ok := c.hostRules[host]; ok {
+			return kept, true
+		}
+
+		dot := strings.IndexByte(host, '.')
+		if dot < 0 {
+			return nil, false
+		}
+		host = host[dot+1:]
+	}
+}
+
+func (c *Canonicalizer) tracking(name string) bool {
+	name = strings.ToLower(name)
+	for _, param := range c.config.TrackingParams {
+		param = strings.ToLower(strings.TrimSpace(param))
+		if prefix, ok := strings.CutSuffix(param, "*"); ok && strings.HasPrefix(name, prefix) || param == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pageCanonical returns absolute href of <link rel="canonical"> of page head
+func (c *Canonicalizer) pageCanonical(ctx context.Context, link string) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Accept", "text/html")
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+
+	href := findCanonical(io.LimitReader(response.Body, maxPageSize))
+	if href == "" {
+		return "", nil
+	}
+
+	canonical, err := response.Request.URL.Parse(href)
+	if err != nil {
+		return "", err
+	}
+	if canonical.Scheme != "http" && canonical.Scheme != "https" {
+		return "", nil
+	}
+
+	return canonical.String(), nil
+}
+
+func findCanonical(page io.Reader) string {
+	tokenizer := html.NewTokenizer(page)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if token.DataAtom == atom.Body {
+				return ""
+			}
+			if token.DataAtom != atom.Link {
+				continue
+			}
+
+			rel, href := "", ""
+			for _, attribute := range token.Attr {
+				switch attribute.Key {
+				case "rel":
+					rel = attribute.Val
+				case "href":
+					href = strings.TrimSpace(attribute.Val)
+				}
+			}
+			if slices.Contains(strings.Fields(strings.ToLower(rel)), "canonical") && href != "" {
+				return href
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package links_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sealbro/go-feed-me/internal/links"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func newConfig() *links.Config {
+	return &links.Config{
+		TrackingParams: []string{"utm_*", "fbclid", "gclid"},
+		HostRules:      map[string]string{"youtube.com": "v|t", "medium.com": ""},
+		ForceHttps:     true,
+		Timeout:        time.Second,
+	}
+}
+
+func newCanonicalizer(t *testing.T, config *links.Config) *links.Canonicalizer {
+	newLogger, err := logger.NewLogger(&logger.Config{LogLevel: "ERROR"})
+	assert.NoError(t, err, "error should be nil")
+
+	return links.NewCanonicalizer(newLogger, config)
+}
+
+func TestCanonicalizer_Normalize(t *testing.T) {
+	canonicalizer := newCanonicalizer(t, newConfig())
+
+	testCases := []struct {
+		name   string
+		link   string
+		expect string
+	}{
+		{name: "canonical", link: "https://go.dev/blog/go1.23", expect: "https://go.dev/blog/go1.23"},
+		{name: "tracking parameters", link: "https://go.dev/blog/go1.23?utm_source=rss&utm_medium=feed&fbclid=abc", expect: "https://go.dev/blog/go1.23"},
+		{name: "tracking parameters ignore case", link: "https://go.dev/blog/go1.23?UTM_Source=rss&GCLID=1", expect: "https://go.dev/blog/go1.23"},
+		{name: "other parameters are sorted", link: "https://example.com/search?q=go&page=2&utm_campaign=x", expect: "https://example.com/search?page=2&q=go"},
+		{name: "trailing slash", link: "https://go.dev/blog/go1.23/", expect: "https://go.dev/blog/go1.23"},
+		{name: "root", link: "https://go.dev/", expect: "https://go.dev"},
+		{name: "http", link: "http://go.dev/blog/go1.23", expect: "https://go.dev/blog/go1.23"},
+		{name: "fragment", link: "https://go.dev/blog/go1.23#iterators", expect: "https://go.dev/blog/go1.23"},
+		{name: "scheme and host case", link: "HTTPS://Go.Dev/blog/Go1.23", expect: "https://go.dev/blog/Go1.23"},
+		{name: "default port", link: "https://go.dev:443/blog", expect: "https://go.dev/blog"},
+		{name: "other port", link: "https://localhost:8080/feed/", expect: "https://localhost:8080/feed"},
+		{name: "default port of http", link: "http://go.dev:80/blog", expect: "https://go.dev/blog"},
+		{name: "https port of http", link: "http://go.dev:443/blog", expect: "https://go.dev:443/blog"},
+		{name: "parameters without value", link: "https://example.com/post?id=1&amp&empty=", expect: "https://example.com/post?amp&empty=&id=1"},
+		{name: "repeated parameters keep order", link: "https://example.com/search?tag=go&q=x&tag=db", expect: "https://example.com/search?q=x&tag=go&tag=db"},
+		{name: "host rule keeps parameters", link: "https://www.youtube.com/watch?v=abc&feature=share&t=10&utm_source=rss", expect: "https://www.youtube.com/watch?t=10&v=abc"},
+		{name: "host rule of parent domain", link: "https://engineering.medium.com/post-1?source=rss----1", expect: "https://engineering.medium.com/post-1"},
+		{name: "spaces", link: "  https://go.dev/blog/go1.23  ", expect: "https://go.dev/blog/go1.23"},
+		{name: "not http", link: "mailto:gopher@go.dev", expect: "mailto:gopher@go.dev"},
+		{name: "relative", link: "/blog/go1.23", expect: "/blog/go1.23"},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expect, canonicalizer.Normalize(testCase.link))
+		})
+	}
+
+	config := newConfig()
+	config.ForceHttps = false
+	assert.Equal(t, "http://go.dev/blog", newCanonicalizer(t, config).Normalize("http://go.dev:80/blog/"), "http should be kept")
+}
+
+func TestCanonicalizer_Canonical(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/amp/story":
+			_, _ = fmt.Fprint(w, `<html><head><title>Story</title><link rel="amphtml" href="/amp/story"><link rel="Canonical" href="/story/?utm_source=amp"></head><body></body></html>`)
+		case "/absolute":
+			_, _ = fmt.Fprint(w, `<html><head><link rel="canonical" href="https://go.dev/blog/go1.23/"></head></html>`)
+		case "/body":
+			_, _ = fmt.Fprint(w, `<html><head></head><body><link rel="canonical" href="/other"></body></html>`)
+		case "/none":
+			_, _ = fmt.Fprint(w, `<html><head><title>No canonical</title></head></html>`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := newConfig()
+	config.ForceHttps = false
+	config.FollowCanonical = true
+	canonicalizer := newCanonicalizer(t, config)
+
+	testCases := []struct {
+		name   string
+		link   string
+		expect string
+	}{
+		{name: "relative canonical", link: server.URL + "/amp/story?utm_source=rss", expect: server.URL + "/story"},
+		{name: "absolute canonical", link: server.URL + "/absolute", expect: "https://go.dev/blog/go1.23"},
+		{name: "canonical in body is ignored", link: server.URL + "/body", expect: server.URL + "/body"},
+		{name: "no canonical", link: server.URL + "/none#top", expect: server.URL + "/none"},
+		{name: "page is not found", link: server.URL + "/missing/?fbclid=1", expect: server.URL + "/missing"},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expect, canonicalizer.Canonical(context.Background(), testCase.link))
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package links
+
+import "time"
+
+type Config struct {
+	// TrackingParams query parameters removed from links, name ending with * removes parameters with prefix
+	TrackingParams []string `envconfig:"LINKS_TRACKING_PARAMS" default:"utm_*,fbclid,gclid,dclid,msclkid,yclid,mc_cid,mc_eid,igshid,_hsenc,_hsmi,mkt_tok,ref_src"`
+	// HostRules query parameters kept for host and its subdomains, others are removed, e.g. youtube.com:v|list,medium.com:
+	HostRules map[string]string `envconfig:"LINKS_HOST_RULES"`
+	// ForceHttps replaces http scheme by https
+	ForceHttps bool `envconfig:"LINKS_FORCE_HTTPS" default:"true"`
+	// FollowCanonical loads article pages and uses their rel=canonical link
+	FollowCanonical bool `envconfig:"LINKS_FOLLOW_CANONICAL" default:"false"`
+	// Timeout of loading article page
+	Timeout time.Duration `envconfig:"LINKS_TIMEOUT" default:"10s"`
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	prometheusclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/sealbro/go-feed-me/pkg/notifier"
+)
+
+type SubscriptionStats interface {
+	KindStats() []notifier.KindStats
+}
+
+var subscriptionCollector *SubscriptionCollector
+
+// SubscriptionCollector reports delivery counters of subscribers grouped by kind on scrape
+type SubscriptionCollector struct {
+	stats       SubscriptionStats
+	subscribers *prometheusclient.Desc
+	delivered   *prometheusclient.Desc
+	dropped     *prometheusclient.Desc
+	buffered    *prometheusclient.Desc
+	pending     *prometheusclient.Desc
+}
+
+func RegisterSubscriptionsOn(registerer prometheusclient.Registerer, stats SubscriptionStats) {
+	subscriptionCollector = &SubscriptionCollector{
+		stats: stats,
+		subscribers: prometheusclient.NewDesc(
+			"feed_subscribers",
+			"Number of current subscribers.",
+			[]string{"kind"}, nil,
+		),
+		delivered: prometheusclient.NewDesc(
+			"feed_subscriber_delivered_events_total",
+			"Total number of events delivered to subscriber buffer.",
+			[]string{"kind"}, nil,
+		),
+		dropped: prometheusclient.NewDesc(
+			"feed_subscriber_dropped_events_total",
+			"Total number of events dropped by subscriber overflow policy.",
+			[]string{"kind"}, nil,
+		),
+		buffered: prometheusclient.NewDesc(
+			"feed_subscriber_buffered_batches",
+			"Number of batches waiting in subscriber buffer.",
+			[]string{"kind"}, nil,
+		),
+		pending: prometheusclient.NewDesc(
+			"feed_subscriber_pending_events",
+			"Number of events collected for the next subscriber batch.",
+			[]string{"kind"}, nil,
+		),
+	}
+
+	registerer.MustRegister(subscriptionCollector)
+}
+
+func UnRegisterSubscriptionsFrom(registerer prometheusclient.Registerer) {
+	if subscriptionCollector != nil {
+		registerer.Unregister(subscriptionCollector)
+	}
+}
+
+func (c *SubscriptionCollector) Describe(descs chan<- *prometheusclient.Desc) {
+	descs <- c.subscribers
+	descs <- c.delivered
+	descs <- c.dropped
+	descs <- c.buffered
+	descs <- c.pending
+}
+
+func (c *SubscriptionCollector) Collect(metrics chan<- prometheusclient.Metric) {
+	for _, stats := range c.stats.KindStats() {
+		metrics <- prometheusclient.MustNewConstMetric(c.subscribers, prometheusclient.GaugeValue, float64(stats.Subscribers), stats.Kind)
+		metrics <- prometheusclient.MustNewConstMetric(c.delivered, prometheusclient.CounterValue, float64(stats.Delivered), stats.Kind)
+		metrics <- prometheusclient.MustNewConstMetric(c.dropped, prometheusclient.CounterValue, float64(stats.Dropped), stats.Kind)
+		metrics <- prometheusclient.MustNewConstMetric(c.buffered, prometheusclient.GaugeValue, float64(stats.Buffered), stats.Kind)
+		metrics <- prometheusclient.MustNewConstMetric(c.pending, prometheusclient.GaugeValue, float64(stats.Pending), stats.Kind)
+	}
+}
@@ -0,0 +1,202 @@
+package outbox
+
+import (
+	"context"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/storage"
+)
+
+// ArticleLoader turns outbox events into feed articles
+type ArticleLoader struct {
+	articleRepository  *storage.ArticleRepository
+	resourceRepository *storage.ResourceRepository
+}
+
+func NewArticleLoader(articleRepository *storage.ArticleRepository, resourceRepository *storage.ResourceRepository) *ArticleLoader {
+	return &ArticleLoader{
+		articleRepository:  articleRepository,
+		resourceRepository: resourceRepository,
+	}
+}
+
+// Load returns articles in the order of events, articles removed since publishing are skipped
+func (l *ArticleLoader) Load(ctx context.Context, events []*storage.OutboxEvent) ([]*model.FeedArticle, error) {
+	links := make([]string, len(events))
+	for i, event := range events {
+		links[i] = event.ArticleLink
+	}
+
+	articles, err := l.articleRepository.GetByLinks(ctx, links)
+	if err != nil {
+		return nil, err
+	}
+
+	articlesByLink := make(map[string]*storage.Article, len(articles))
+	for _, article := range articles {
+		articlesByLink[article.Link] = article
+	}
+
+	resourcesByUrl, err := l.resources(ctx, articles)
+	if err != nil {
+		return nil, err
+	}
+	if err = l.details(ctx, articles); err != nil {
+		return nil, err
+	}
+
+	feedArticles := make([]*model.FeedArticle, 0, len(events))
+	for _, event := range events {
+		article, ok := articlesByLink[event.ArticleLink]
+		if !ok {
+			continue
+		}
+
+		feedArticle := NewFeedArticle(article, resourcesByUrl[article.ResourceId])
+		feedArticle.EventId = event.Id
+		feedArticles = append(feedArticles, feedArticle)
+	}
+
+	return feedArticles, nil
+}
+
+// LoadArticles returns feed articles of stored articles keeping their order
+func (l *ArticleLoader) LoadArticles(ctx context.Context, articles []*storage.Article) ([]*model.FeedArticle, error) {
+	resourcesByUrl, err := l.resources(ctx, articles)
+	if err != nil {
+		return nil, err
+	}
+	if err = l.details(ctx, articles); err != nil {
+		return nil, err
+	}
+
+	feedArticles := make([]*model.FeedArticle, len(articles))
+	for i, article := range articles {
+		feedArticles[i] = NewFeedArticle(article, resourcesByUrl[article.ResourceId])
+	}
+
+	return feedArticles, nil
+}
+
+func (l *ArticleLoader) resources(ctx context.Context, articles []*storage.Article) (map[string]*storage.Resource, error) {
+	urls := make([]string, len(articles))
+	for i, article := range articles {
+		urls[i] = article.ResourceId
+	}
+
+	resources, err := l.resourceRepository.GetByUrls(ctx, urls)
+	if err != nil {
+		return nil, err
+	}
+
+	resourcesByUrl := make(map[string]*storage.Resource, len(resources))
+	for _, resource := range resources {
+		resourcesByUrl[resource.Url] = resource
+	}
+
+	return resourcesByUrl, nil
+}
+
+// details sets tags which rules added to articles, their enclosures, authors and categories
+func (l *ArticleLoader) details(ctx context.Context, articles []*storage.Article) error {
+	links := make([]string, len(articles))
+	for i, article := range articles {
+		links[i] = article.Link
+	}
+
+	tags, err := l.articleRepository.TagsByArticles(ctx, links)
+	if err != nil {
+		return err
+	}
+	enclosures, err := l.articleRepository.EnclosuresByArticles(ctx, links)
+	if err != nil {
+		return err
+	}
+	authors, err := l.articleRepository.AuthorsByArticles(ctx, links)
+	if err != nil {
+		return err
+	}
+	categories, err := l.articleRepository.CategoriesByArticles(ctx, links)
+	if err != nil {
+		return err
+	}
+
+	for _, article := range articles {
+		article.Tags = tags[article.Link]
+		article.Enclosures = enclosures[article.Link]
+		article.Authors = authors[article.Link]
+		article.Categories = categories[article.Link]
+	}
+
+	return nil
+}
+
+// NewFeedArticle converts stored article, resource may be nil when it was removed,
+// tags of article are tags of resource and tags added by rules
+func NewFeedArticle(article *storage.Article, resource *storage.Resource) *model.FeedArticle {
+	feedArticle := &model.FeedArticle{
+		Created:      article.Created,
+		Published:    article.Published,
+		ResourceID:   article.ResourceId,
+		Link:         article.Link,
+		OriginalLink: article.OriginalLink,
+		Guid:         article.Guid,
+		Title:        article.Title,
+		Description:  article.Description,
+		Content:      article.Content,
+		Author:       article.Author,
+		Image:        article.Image,
+		Starred:      article.Starred,
+		Priority:     article.Priority,
+		Subscribers:  article.Subscribers,
+		Enclosures:   newFeedEnclosures(article.Enclosures),
+		Authors:      newFeedAuthors(article.Authors),
+		Categories:   article.Categories,
+	}
+	if !article.Updated.IsZero() {
+		feedArticle.Updated = &article.Updated
+	}
+	if resource != nil {
+		feedArticle.ResourceTitle = resource.Title
+		feedArticle.Tags = resource.TagNames()
+	}
+	if len(article.Tags) > 0 {
+		feedArticle.Tags = storage.NormalizeTags(append(feedArticle.Tags, article.Tags...))
+	}
+
+	return feedArticle
+}
+
+// newFeedEnclosures converts stored enclosures, unknown duration, episode and season are nil
+func newFeedEnclosures(enclosures []*storage.Enclosure) []*model.Enclosure {
+	feedEnclosures := make([]*model.Enclosure, len(enclosures))
+	for i, enclosure := range enclosures {
+		feedEnclosures[i] = &model.Enclosure{
+			URL:       enclosure.Url,
+			Type:      enclosure.Type,
+			Length:    int(enclosure.Length),
+			Duration:  positive(enclosure.Duration),
+			Episode:   positive(enclosure.Episode),
+			Season:    positive(enclosure.Season),
+			Thumbnail: enclosure.Thumbnail,
+		}
+	}
+
+	return feedEnclosures
+}
+
+func newFeedAuthors(authors []*storage.ArticleAuthor) []*model.Author {
+	feedAuthors := make([]*model.Author, len(authors))
+	for i, author := range authors {
+		feedAuthors[i] = &model.Author{Name: author.Name, Email: author.Email}
+	}
+
+	return feedAuthors
+}
+
+func positive(value int) *int {
+	if value <= 0 {
+		return nil
+	}
+
+	return &value
+}
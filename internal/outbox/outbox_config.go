@@ -0,0 +1,18 @@
+package outbox
+
+import "time"
+
+type Config struct {
+	// PollInterval how often subscribers check outbox for new events
+	PollInterval time.Duration `envconfig:"OUTBOX_POLL_INTERVAL" default:"15s"`
+	// BatchSize max number of events delivered at once
+	BatchSize int `envconfig:"OUTBOX_BATCH_SIZE" default:"10"`
+	// MaxAttempts number of failed deliveries before events move to dead letters
+	MaxAttempts int `envconfig:"OUTBOX_MAX_ATTEMPTS" default:"5"`
+	// RetryBase first retry delay, every next attempt doubles it
+	RetryBase time.Duration `envconfig:"OUTBOX_RETRY_BASE" default:"30s"`
+	// RetryMax upper bound of retry delay
+	RetryMax time.Duration `envconfig:"OUTBOX_RETRY_MAX" default:"30m"`
+	// Retention how long events delivered to all subscribers are kept
+	Retention time.Duration `envconfig:"OUTBOX_RETENTION" default:"168h"`
+}
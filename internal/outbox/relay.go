@@ -0,0 +1,270 @@
+package outbox
+
+import (
+	"context"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/pkg/graceful"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"github.com/sealbro/go-feed-me/pkg/notifier"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const pruneInterval = time.Hour
+
+// Deliverer durable subscriber, returned error means batch was not delivered and will be retried
+type Deliverer interface {
+	Name() string
+	Deliver(ctx context.Context, articles []*model.FeedArticle) error
+}
+
+// BatchingDeliverer deliverer which groups articles by its own batching,
+// other deliverers get events as soon as they are polled
+type BatchingDeliverer interface {
+	Deliverer
+	Batching() notifier.Batching
+}
+
+// TaggedDeliverer deliverer which gets only articles having any of its tags, no tags means all articles
+type TaggedDeliverer interface {
+	Deliverer
+	Tags() []string
+}
+
+// Relay delivers outbox events to every deliverer at least once, each deliverer has its own cursor
+type Relay struct {
+	logger           *logger.Logger
+	config           *Config
+	outboxRepository *storage.OutboxRepository
+	articleLoader    *ArticleLoader
+	deliverers       []Deliverer
+
+	cancelFunc context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+func NewRelay(logger *logger.Logger,
+	config *Config,
+	outboxRepository *storage.OutboxRepository,
+	articleLoader *ArticleLoader,
+	deliverers []Deliverer,
+	closer *graceful.ShutdownCloser,
+) *Relay {
+	r := &Relay{
+		logger:           logger,
+		config:           config,
+		outboxRepository: outboxRepository,
+		articleLoader:    articleLoader,
+		deliverers:       deliverers,
+	}
+
+	closer.Register(r)
+
+	return r
+}
+
+func (r *Relay) Start(ctx context.Context) error {
+	cancelCtx, cancelFunc := context.WithCancel(ctx)
+	r.cancelFunc = cancelFunc
+
+	for _, deliverer := range r.deliverers {
+		r.logger.InfoContext(ctx, "Outbox - Start delivery", slog.String("subscriber", deliverer.Name()))
+		r.loop(cancelCtx, r.config.PollInterval, func(ctx context.Context) {
+			if err := r.Flush(ctx, deliverer); err != nil && ctx.Err() == nil {
+				r.logger.ErrorContext(ctx, "Outbox - Delivery failed", slog.String("subscriber", deliverer.Name()), slog.Any("error", err))
+			}
+		})
+	}
+
+	r.loop(cancelCtx, pruneInterval, func(ctx context.Context) {
+		pruned, err := r.Prune(ctx, time.Now())
+		if err != nil && ctx.Err() == nil {
+			r.logger.ErrorContext(ctx, "Outbox - Prune failed", slog.Any("error", err))
+		} else if pruned > 0 {
+			r.logger.InfoContext(ctx, "Outbox - Pruned delivered events", slog.Int64("events", pruned))
+		}
+	})
+
+	return nil
+}
+
+// Prune removes events older than retention which every registered deliverer got
+func (r *Relay) Prune(ctx context.Context, now time.Time) (int64, error) {
+	names := make([]string, len(r.deliverers))
+	for i, deliverer := range r.deliverers {
+		names[i] = deliverer.Name()
+	}
+
+	return r.outboxRepository.Prune(ctx, names, now.Add(-r.config.Retention))
+}
+
+// Close stops delivery and waits for batches in flight, undelivered events stay in outbox
+func (r *Relay) Close() error {
+	if r.cancelFunc != nil {
+		r.cancelFunc()
+		r.cancelFunc = nil
+	}
+	r.wg.Wait()
+
+	return nil
+}
+
+// Flush delivers replayed dead letters and pending events until outbox is drained,
+// deliverer failure postpones next attempt by backoff
+func (r *Relay) Flush(ctx context.Context, deliverer Deliverer) error {
+	name := deliverer.Name()
+
+	if err := r.replay(ctx, deliverer); err != nil {
+		return err
+	}
+
+	cursor, err := r.outboxRepository.Cursor(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	batching := r.batching(deliverer)
+	limit := r.config.BatchSize
+	if batching.Size > 0 {
+		limit = batching.Size
+	}
+
+	for time.Now().After(cursor.NextAttempt) {
+		events, err := r.outboxRepository.Pending(ctx, cursor.EventId, limit)
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		if !batching.Ready(events[0].Created, len(events), time.Now()) {
+			return nil
+		}
+
+		deliverErr := r.deliver(ctx, deliverer, events)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if deliverErr == nil {
+			cursor.EventId = events[len(events)-1].Id
+			cursor.Attempts = 0
+			cursor.NextAttempt = time.Time{}
+			cursor.LastError = ""
+			if err = r.outboxRepository.SaveCursor(ctx, cursor); err != nil {
+				return err
+			}
+			continue
+		}
+
+		cursor.Attempts++
+		cursor.LastError = deliverErr.Error()
+
+		if cursor.Attempts >= r.config.MaxAttempts {
+			r.logger.WarnContext(ctx, "Outbox - Moved events to dead letters", slog.String("subscriber", name),
+				slog.Int("events", len(events)), slog.Any("error", deliverErr))
+			if err = r.outboxRepository.DeadLetter(ctx, cursor, events); err != nil {
+				return err
+			}
+			continue
+		}
+
+		cursor.NextAttempt = time.Now().Add(r.backoff(cursor.Attempts))
+		r.logger.WarnContext(ctx, "Outbox - Delivery will be retried", slog.String("subscriber", name),
+			slog.Int("attempts", cursor.Attempts), slog.Time("next_attempt", cursor.NextAttempt), slog.Any("error", deliverErr))
+
+		return r.outboxRepository.SaveCursor(ctx, cursor)
+	}
+
+	return nil
+}
+
+func (r *Relay) replay(ctx context.Context, deliverer Deliverer) error {
+	for {
+		deadLetters, err := r.outboxRepository.ReplayQueue(ctx, deliverer.Name(), r.config.BatchSize)
+		if err != nil || len(deadLetters) == 0 {
+			return err
+		}
+
+		ids := make([]uint64, len(deadLetters))
+		events := make([]*storage.OutboxEvent, len(deadLetters))
+		for i, deadLetter := range deadLetters {
+			ids[i] = deadLetter.Id
+			events[i] = &storage.OutboxEvent{Id: deadLetter.EventId, ArticleLink: deadLetter.ArticleLink}
+		}
+
+		deliverErr := r.deliver(ctx, deliverer, events)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if deliverErr != nil {
+			r.logger.WarnContext(ctx, "Outbox - Replay failed", slog.String("subscriber", deliverer.Name()), slog.Any("error", deliverErr))
+			return r.outboxRepository.ReplayFailed(ctx, ids, deliverErr.Error())
+		}
+
+		if err = r.outboxRepository.DeleteDeadLetters(ctx, ids); err != nil {
+			return err
+		}
+	}
+}
+
+// deliver sends articles of events routed to deliverer and having its tags, batch without such articles is skipped
+func (r *Relay) deliver(ctx context.Context, deliverer Deliverer, events []*storage.OutboxEvent) error {
+	articles, err := r.articleLoader.Load(ctx, events)
+	if err != nil {
+		return err
+	}
+
+	routed := make([]*model.FeedArticle, 0, len(articles))
+	for _, article := range articles {
+		if article.RoutedTo(deliverer.Name()) {
+			routed = append(routed, article)
+		}
+	}
+	if taggedDeliverer, ok := deliverer.(TaggedDeliverer); ok {
+		routed = model.FilterByTags(routed, taggedDeliverer.Tags())
+	}
+	if len(routed) == 0 {
+		return nil
+	}
+
+	return deliverer.Deliver(ctx, routed)
+}
+
+func (r *Relay) batching(deliverer Deliverer) notifier.Batching {
+	if batchingDeliverer, ok := deliverer.(BatchingDeliverer); ok {
+		return batchingDeliverer.Batching()
+	}
+
+	return notifier.Batching{Size: r.config.BatchSize}
+}
+
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.config.RetryBase
+	for i := 1; i < attempts && delay < r.config.RetryMax; i++ {
+		delay *= 2
+	}
+
+	return min(delay, r.config.RetryMax)
+}
+
+func (r *Relay) loop(ctx context.Context, interval time.Duration, tick func(ctx context.Context)) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			tick(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,443 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/db"
+	"github.com/sealbro/go-feed-me/internal/outbox"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/pkg/graceful"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"github.com/sealbro/go-feed-me/pkg/notifier"
+	"github.com/stretchr/testify/assert"
+)
+
+const testResource = "https://example.com/feed"
+
+// fakeDeliverer records delivered links and fails while failures counter is positive
+type fakeDeliverer struct {
+	name      string
+	failures  int
+	attempts  int
+	delivered []string
+}
+
+func (d *fakeDeliverer) Name() string {
+	return d.name
+}
+
+func (d *fakeDeliverer) Deliver(_ context.Context, articles []*model.FeedArticle) error {
+	d.attempts++
+	if d.failures > 0 {
+		d.failures--
+		return errors.New("webhook unavailable")
+	}
+
+	for _, article := range articles {
+		d.delivered = append(d.delivered, article.Link)
+	}
+
+	return nil
+}
+
+// batchingDeliverer collects articles by its own batching
+type batchingDeliverer struct {
+	fakeDeliverer
+	batching notifier.Batching
+}
+
+func (d *batchingDeliverer) Batching() notifier.Batching {
+	return d.batching
+}
+
+// taggedDeliverer gets only articles having any of its tags
+type taggedDeliverer struct {
+	fakeDeliverer
+	tags []string
+}
+
+func (d *taggedDeliverer) Tags() []string {
+	return d.tags
+}
+
+type testOutbox struct {
+	relay              *outbox.Relay
+	outboxRepository   *storage.OutboxRepository
+	articleRepository  *storage.ArticleRepository
+	resourceRepository *storage.ResourceRepository
+}
+
+func newTestOutbox(t *testing.T, config *outbox.Config) *testOutbox {
+	newLogger, err := logger.NewLogger(&logger.Config{LogLevel: "ERROR"})
+	assert.NoError(t, err, "error should be nil")
+
+	database, err := db.NewSqliteDatabase(logger.NewGormLogger(newLogger), &db.Config{
+		SqliteConnection: filepath.Join(t.TempDir(), "feed.db"),
+	})
+	assert.NoError(t, err, "error should be nil")
+
+	resourceRepository, err := storage.NewResourceRepository(database)
+	assert.NoError(t, err, "error should be nil")
+	articleRepository, err := storage.NewArticleRepository(database)
+	assert.NoError(t, err, "error should be nil")
+	outboxRepository, err := storage.NewOutboxRepository(database)
+	assert.NoError(t, err, "error should be nil")
+
+	err = resourceRepository.Upsert(context.Background(), &storage.Resource{Url: testResource, Title: "Example", Active: true})
+	assert.NoError(t, err, "error should be nil")
+
+	relay := outbox.NewRelay(newLogger, config, outboxRepository,
+		outbox.NewArticleLoader(articleRepository, resourceRepository), nil, graceful.NewShutdownCloser())
+
+	return &testOutbox{
+		relay:              relay,
+		outboxRepository:   outboxRepository,
+		articleRepository:  articleRepository,
+		resourceRepository: resourceRepository,
+	}
+}
+
+func newConfig() *outbox.Config {
+	return &outbox.Config{
+		PollInterval: time.Second,
+		BatchSize:    2,
+		MaxAttempts:  3,
+		Retention:    time.Hour,
+	}
+}
+
+func (o *testOutbox) publish(t *testing.T, links ...string) {
+	for _, link := range links {
+		_, err := o.articleRepository.UpsertAndPublish(context.Background(), &storage.Article{
+			ResourceId: testResource,
+			Link:       link,
+			Title:      fmt.Sprintf("title of %s", link),
+			Created:    time.Now(),
+			Published:  time.Now(),
+		})
+		assert.NoError(t, err, "error should be nil")
+	}
+}
+
+func TestRelay_Flush_DeliversInOrderOnce(t *testing.T) {
+	ctx := context.Background()
+	o := newTestOutbox(t, newConfig())
+	deliverer := &fakeDeliverer{name: "first"}
+
+	assert.NoError(t, o.relay.Flush(ctx, deliverer), "error should be nil")
+	o.publish(t, "a", "b", "c")
+
+	assert.NoError(t, o.relay.Flush(ctx, deliverer), "error should be nil")
+	assert.NoError(t, o.relay.Flush(ctx, deliverer), "error should be nil")
+
+	assert.Equal(t, []string{"a", "b", "c"}, deliverer.delivered)
+	assert.Equal(t, 2, deliverer.attempts, "events should be delivered in batches")
+}
+
+func TestRelay_Flush_NewSubscriberStartsAfterLatestEvent(t *testing.T) {
+	ctx := context.Background()
+	o := newTestOutbox(t, newConfig())
+	deliverer := &fakeDeliverer{name: "late"}
+
+	o.publish(t, "old")
+	assert.NoError(t, o.relay.Flush(ctx, deliverer), "error should be nil")
+	o.publish(t, "new")
+	assert.NoError(t, o.relay.Flush(ctx, deliverer), "error should be nil")
+
+	assert.Equal(t, []string{"new"}, deliverer.delivered)
+}
+
+func TestRelay_Flush_RetriesWithBackoff(t *testing.T) {
+	ctx := context.Background()
+	config := newConfig()
+	config.RetryBase = time.Hour
+	config.RetryMax = time.Hour
+	o := newTestOutbox(t, config)
+	deliverer := &fakeDeliverer{name: "slow", failures: 1}
+
+	assert.NoError(t, o.relay.Flush(ctx, deliverer), "error should be nil")
+	o.publish(t, "a")
+
+	assert.NoError(t, o.relay.Flush(ctx, deliverer), "error should be nil")
+	assert.NoError(t, o.relay.Flush(ctx, deliverer), "error should be nil")
+	assert.Equal(t, 1, deliverer.attempts, "retry should wait for backoff")
+	assert.Empty(t, deliverer.delivered)
+
+	cursor, err := o.outboxRepository.Cursor(ctx, "slow")
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, 1, cursor.Attempts)
+	assert.Equal(t, "webhook unavailable", cursor.LastError)
+	assert.True(t, cursor.NextAttempt.After(time.Now().Add(59*time.Minute)), "next attempt should be delayed by backoff")
+
+	// restart keeps undelivered events in outbox
+	cursor.NextAttempt = time.Time{}
+	assert.NoError(t, o.outboxRepository.SaveCursor(ctx, cursor), "error should be nil")
+
+	assert.NoError(t, o.relay.Flush(ctx, deliverer), "error should be nil")
+	assert.Equal(t, []string{"a"}, deliverer.delivered)
+}
+
+func TestRelay_Flush_DeadLettersAndReplay(t *testing.T) {
+	ctx := context.Background()
+	o := newTestOutbox(t, newConfig())
+	broken := &fakeDeliverer{name: "broken", failures: 3}
+	healthy := &fakeDeliverer{name: "healthy"}
+
+	assert.NoError(t, o.relay.Flush(ctx, broken), "error should be nil")
+	assert.NoError(t, o.relay.Flush(ctx, healthy), "error should be nil")
+	o.publish(t, "a", "b", "c")
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, o.relay.Flush(ctx, broken), "error should be nil")
+	}
+	assert.NoError(t, o.relay.Flush(ctx, healthy), "error should be nil")
+
+	assert.Equal(t, []string{"c"}, broken.delivered, "events after dead letters should be delivered")
+	assert.Equal(t, []string{"a", "b", "c"}, healthy.delivered, "failing subscriber should not block others")
+
+	deadLetters, err := o.outboxRepository.DeadLetters(ctx, "broken")
+	assert.NoError(t, err, "error should be nil")
+	assert.Len(t, deadLetters, 2)
+	assert.Equal(t, 3, deadLetters[0].Attempts)
+	assert.Equal(t, "webhook unavailable", deadLetters[0].LastError)
+
+	ids := []uint64{deadLetters[0].Id, deadLetters[1].Id}
+	assert.NoError(t, o.outboxRepository.Replay(ctx, ids), "error should be nil")
+	assert.NoError(t, o.relay.Flush(ctx, broken), "error should be nil")
+
+	assert.Equal(t, []string{"c", "a", "b"}, broken.delivered)
+	deadLetters, err = o.outboxRepository.DeadLetters(ctx, "")
+	assert.NoError(t, err, "error should be nil")
+	assert.Empty(t, deadLetters, "replayed dead letters should be removed")
+}
+
+func TestRelay_Flush_DelivererBatching(t *testing.T) {
+	ctx := context.Background()
+	o := newTestOutbox(t, newConfig())
+	deliverer := &batchingDeliverer{
+		fakeDeliverer: fakeDeliverer{name: "digest"},
+		batching:      notifier.Batching{Size: 3, MaxWait: time.Hour},
+	}
+
+	assert.NoError(t, o.relay.Flush(ctx, deliverer), "error should be nil")
+	o.publish(t, "a", "b")
+	assert.NoError(t, o.relay.Flush(ctx, deliverer), "error should be nil")
+	assert.Equal(t, 0, deliverer.attempts, "batch should wait for max wait or size")
+
+	o.publish(t, "c", "d")
+	assert.NoError(t, o.relay.Flush(ctx, deliverer), "error should be nil")
+	assert.Equal(t, []string{"a", "b", "c"}, deliverer.delivered, "full batch should be delivered")
+	assert.Equal(t, 1, deliverer.attempts, "rest should wait for the next batch")
+}
+
+func TestRelay_Flush_RoutesArticlesByRules(t *testing.T) {
+	ctx := context.Background()
+	o := newTestOutbox(t, newConfig())
+	chat := &fakeDeliverer{name: "chat"}
+	push := &fakeDeliverer{name: "push"}
+
+	assert.NoError(t, o.relay.Flush(ctx, chat), "error should be nil")
+	assert.NoError(t, o.relay.Flush(ctx, push), "error should be nil")
+
+	_, err := o.articleRepository.UpsertAndPublish(ctx, &storage.Article{
+		ResourceId:  testResource,
+		Link:        "a",
+		Title:       "routed",
+		Created:     time.Now(),
+		Published:   time.Now(),
+		Subscribers: []string{"push"},
+		Tags:        []string{"urgent"},
+	})
+	assert.NoError(t, err, "error should be nil")
+	o.publish(t, "b")
+
+	assert.NoError(t, o.relay.Flush(ctx, chat), "error should be nil")
+	assert.NoError(t, o.relay.Flush(ctx, push), "error should be nil")
+
+	assert.Equal(t, []string{"b"}, chat.delivered, "routed article should be skipped")
+	assert.Equal(t, []string{"a", "b"}, push.delivered)
+
+	stored, err := o.articleRepository.GetByLinks(ctx, []string{"a"})
+	assert.NoError(t, err, "error should be nil")
+	articles, err := outbox.NewArticleLoader(o.articleRepository, o.resourceRepository).LoadArticles(ctx, stored)
+	assert.NoError(t, err, "error should be nil")
+	assert.Len(t, articles, 1)
+	assert.Equal(t, []string{"urgent"}, articles[0].Tags, "tags added by rules should be loaded")
+}
+
+func TestRelay_Flush_FiltersArticlesByTags(t *testing.T) {
+	ctx := context.Background()
+	o := newTestOutbox(t, newConfig())
+	deliverer := &taggedDeliverer{fakeDeliverer: fakeDeliverer{name: "releases"}, tags: []string{"release"}}
+
+	assert.NoError(t, o.relay.Flush(ctx, deliverer), "error should be nil")
+	_, err := o.articleRepository.UpsertAndPublish(ctx, &storage.Article{
+		ResourceId: testResource,
+		Link:       "a",
+		Title:      "release",
+		Created:    time.Now(),
+		Published:  time.Now(),
+		Tags:       []string{"release"},
+	})
+	assert.NoError(t, err, "error should be nil")
+	o.publish(t, "b", "c")
+
+	assert.NoError(t, o.relay.Flush(ctx, deliverer), "error should be nil")
+	assert.Equal(t, []string{"a"}, deliverer.delivered, "only articles with tags should be delivered")
+	assert.Equal(t, 1, deliverer.attempts, "batch without tagged articles should be skipped")
+
+	cursor, err := o.outboxRepository.Cursor(ctx, "releases")
+	assert.NoError(t, err, "error should be nil")
+	pending, err := o.outboxRepository.Pending(ctx, cursor.EventId, 10)
+	assert.NoError(t, err, "error should be nil")
+	assert.Empty(t, pending, "cursor should move past skipped articles")
+}
+
+func TestArticleLoader_Enclosures(t *testing.T) {
+	ctx := context.Background()
+	o := newTestOutbox(t, newConfig())
+
+	article := &storage.Article{
+		ResourceId: testResource,
+		Link:       "episode",
+		Title:      "Episode 12",
+		Created:    time.Now(),
+		Published:  time.Now(),
+		Enclosures: []*storage.Enclosure{
+			{Url: "https://cdn.example.com/12.mp3", Type: "audio/mpeg", Length: 123456, Duration: 3723, Episode: 12, Season: 2},
+			{Url: "https://cdn.example.com/12.jpg", Type: "image/jpeg"},
+		},
+	}
+	_, err := o.articleRepository.UpsertAndPublish(ctx, article)
+	assert.NoError(t, err, "error should be nil")
+
+	article.Enclosures = article.Enclosures[:1]
+	_, err = o.articleRepository.UpsertAndPublish(ctx, article)
+	assert.NoError(t, err, "error should be nil")
+
+	stored, err := o.articleRepository.GetByLinks(ctx, []string{"episode"})
+	assert.NoError(t, err, "error should be nil")
+	articles, err := outbox.NewArticleLoader(o.articleRepository, o.resourceRepository).LoadArticles(ctx, stored)
+	assert.NoError(t, err, "error should be nil")
+	assert.Len(t, articles, 1)
+	assert.Len(t, articles[0].Enclosures, 1, "enclosures should be replaced on update")
+
+	enclosure := articles[0].Enclosures[0]
+	assert.Equal(t, "https://cdn.example.com/12.mp3", enclosure.URL)
+	assert.Equal(t, "audio/mpeg", enclosure.Type)
+	assert.Equal(t, 123456, enclosure.Length)
+	assert.Equal(t, 3723, *enclosure.Duration)
+	assert.Equal(t, 12, *enclosure.Episode)
+	assert.Equal(t, 2, *enclosure.Season)
+	assert.Empty(t, enclosure.Thumbnail)
+}
+
+func TestArticleLoader_AuthorsAndCategories(t *testing.T) {
+	ctx := context.Background()
+	o := newTestOutbox(t, newConfig())
+
+	updated := time.Date(2024, 8, 13, 10, 0, 0, 0, time.UTC)
+	article := &storage.Article{
+		ResourceId: testResource,
+		Link:       "release",
+		Guid:       "tag:example.com,2024:release",
+		Title:      "Release",
+		Created:    time.Now(),
+		Published:  time.Now(),
+		Updated:    updated,
+		Authors:    []*storage.ArticleAuthor{{Name: "asmorkalov"}, {Email: "alalek@example.com"}},
+		Categories: []string{"Computer Vision", "C++"},
+	}
+	_, err := o.articleRepository.UpsertAndPublish(ctx, article)
+	assert.NoError(t, err, "error should be nil")
+	o.publish(t, "other")
+
+	err = o.resourceRepository.Upsert(ctx, &storage.Resource{Url: testResource, Title: "Example", Active: true,
+		Description: "Example releases", Link: "https://example.com", Language: "en"})
+	assert.NoError(t, err, "error should be nil")
+
+	stored, err := o.articleRepository.List(ctx, &storage.ArticleFilter{Categories: []string{"computer vision"}})
+	assert.NoError(t, err, "error should be nil")
+	assert.Len(t, stored, 1, "categories should be compared case-insensitively")
+
+	articles, err := outbox.NewArticleLoader(o.articleRepository, o.resourceRepository).LoadArticles(ctx, stored)
+	assert.NoError(t, err, "error should be nil")
+	assert.Len(t, articles, 1)
+	assert.Equal(t, "tag:example.com,2024:release", articles[0].Guid)
+	assert.True(t, updated.Equal(*articles[0].Updated))
+	assert.Equal(t, []*model.Author{{Name: "asmorkalov"}, {Email: "alalek@example.com"}}, articles[0].Authors, "authors should keep feed order")
+	assert.ElementsMatch(t, []string{"Computer Vision", "C++"}, articles[0].Categories)
+
+	resource, err := o.resourceRepository.Get(ctx, testResource)
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, "Example releases", resource.Description)
+	assert.Equal(t, "en", resource.Language)
+}
+
+func TestOutboxRepository_Prune(t *testing.T) {
+	ctx := context.Background()
+	o := newTestOutbox(t, newConfig())
+	fast := &fakeDeliverer{name: "fast"}
+	lagging := &fakeDeliverer{name: "lagging", failures: 1}
+
+	assert.NoError(t, o.relay.Flush(ctx, fast), "error should be nil")
+	assert.NoError(t, o.relay.Flush(ctx, lagging), "error should be nil")
+	o.publish(t, "a", "b")
+	assert.NoError(t, o.relay.Flush(ctx, fast), "error should be nil")
+	assert.NoError(t, o.relay.Flush(ctx, lagging), "error should be nil")
+
+	pruned, err := o.outboxRepository.Prune(ctx, []string{"fast", "lagging"}, time.Now().Add(time.Minute))
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, int64(0), pruned, "events not delivered to all subscribers should be kept")
+
+	cursor, err := o.outboxRepository.Cursor(ctx, "lagging")
+	assert.NoError(t, err, "error should be nil")
+	cursor.NextAttempt = time.Time{}
+	assert.NoError(t, o.outboxRepository.SaveCursor(ctx, cursor), "error should be nil")
+	assert.NoError(t, o.relay.Flush(ctx, lagging), "error should be nil")
+
+	pruned, err = o.outboxRepository.Prune(ctx, []string{"fast", "lagging"}, time.Now().Add(time.Minute))
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, int64(2), pruned)
+}
+
+func TestOutboxRepository_Prune_RemovedSubscriber(t *testing.T) {
+	ctx := context.Background()
+	o := newTestOutbox(t, newConfig())
+	fast := &fakeDeliverer{name: "fast"}
+	removed := &fakeDeliverer{name: "removed"}
+
+	assert.NoError(t, o.relay.Flush(ctx, fast), "error should be nil")
+	assert.NoError(t, o.relay.Flush(ctx, removed), "error should be nil")
+	o.publish(t, "a", "b")
+	assert.NoError(t, o.relay.Flush(ctx, fast), "error should be nil")
+
+	pruned, err := o.outboxRepository.Prune(ctx, []string{"fast", "removed"}, time.Now().Add(time.Minute))
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, int64(0), pruned, "events not delivered to registered subscriber should be kept")
+
+	pruned, err = o.outboxRepository.Prune(ctx, []string{"fast"}, time.Now().Add(time.Minute))
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, int64(2), pruned, "cursor of removed subscriber should not hold events")
+}
+
+func TestRelay_Prune_WithoutCursors(t *testing.T) {
+	ctx := context.Background()
+	config := newConfig()
+	o := newTestOutbox(t, config)
+	o.publish(t, "a", "b")
+
+	pruned, err := o.relay.Prune(ctx, time.Now())
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, int64(0), pruned, "events within retention should be kept")
+
+	pruned, err = o.relay.Prune(ctx, time.Now().Add(config.Retention+time.Minute))
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, int64(2), pruned, "events older than retention should be removed without cursors")
+}
@@ -0,0 +1,97 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Patterns include and exclude filters of resource articles, pattern wrapped in slashes like /-rc\d*$/ is regular expression,
+// other patterns are case-insensitive globs with * and ? like *nightly*
+type Patterns struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// NewPatterns compiles include and exclude patterns, empty patterns are ignored
+func NewPatterns(include []string, exclude []string) (*Patterns, error) {
+	var err error
+	patterns := &Patterns{}
+	if patterns.include, err = compilePatterns("include", include); err != nil {
+		return nil, err
+	}
+	if patterns.exclude, err = compilePatterns("exclude", exclude); err != nil {
+		return nil, err
+	}
+
+	return patterns, nil
+}
+
+// Allow checks that any of texts matches any include pattern and none of texts matches exclude patterns,
+// without include patterns every text is included
+func (p *Patterns) Allow(texts ...string) bool {
+	if p == nil {
+		return true
+	}
+
+	if len(p.include) > 0 && !matchAny(p.include, texts) {
+		return false
+	}
+
+	return !matchAny(p.exclude, texts)
+}
+
+func matchAny(patterns []*regexp.Regexp, texts []string) bool {
+	for _, pattern := range patterns {
+		for _, text := range texts {
+			if pattern.MatchString(text) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// compilePatterns compiles patterns of kind, error names the pattern which can not be compiled
+func compilePatterns(kind string, patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+
+		expression := globExpression(pattern)
+		if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			expression = pattern[1 : len(pattern)-1]
+		}
+
+		regex, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s pattern %q: %w", kind, pattern, err)
+		}
+		compiled = append(compiled, regex)
+	}
+
+	return compiled, nil
+}
+
+// globExpression converts glob to regular expression matching the whole text
+func globExpression(glob string) string {
+	var expression strings.Builder
+	expression.WriteString(`(?is)^`)
+	for _, char := range glob {
+		switch char {
+		case '*':
+			expression.WriteString(`.*`)
+		case '?':
+			expression.WriteString(`.`)
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(char)))
+		}
+	}
+	expression.WriteString(`$`)
+
+	return expression.String()
+}
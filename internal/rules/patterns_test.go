@@ -0,0 +1,57 @@
+package rules_test
+
+import (
+	"testing"
+
+	"github.com/sealbro/go-feed-me/internal/rules"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatterns_Allow(t *testing.T) {
+	releases := []string{"-rc", "*nightly*", "/(?i)beta\\d*$/"}
+	keywords := []string{"*kubernetes*", "/\\bGo\\b/"}
+
+	testCases := []struct {
+		name    string
+		include []string
+		exclude []string
+		texts   []string
+		allowed bool
+	}{
+		{name: "no patterns", texts: []string{"v1.2.0"}, allowed: true},
+		{name: "stable release", exclude: releases, texts: []string{"v1.2.0", "https://github.com/org/repo/releases/tag/v1.2.0"}, allowed: true},
+		{name: "glob should match whole text", exclude: releases, texts: []string{"v1.2.0-rc1"}, allowed: true},
+		{name: "glob ignores case", exclude: releases, texts: []string{"Nightly build 2024-08-13"}, allowed: false},
+		{name: "regex", exclude: releases, texts: []string{"v1.3.0-BETA2"}, allowed: false},
+		{name: "regex matches link", exclude: releases, texts: []string{"Release", "https://github.com/org/repo/releases/tag/v1.3.0-beta"}, allowed: false},
+		{name: "include glob", include: keywords, texts: []string{"News", "<p>Kubernetes 1.31 released</p>"}, allowed: true},
+		{name: "include regex", include: keywords, texts: []string{"Go 1.23 is released"}, allowed: true},
+		{name: "include regex keeps case", include: keywords, texts: []string{"Forgo the rest"}, allowed: false},
+		{name: "no keyword", include: keywords, texts: []string{"Rust 1.80", "<p>New release</p>"}, allowed: false},
+		{name: "exclude wins over include", include: keywords, exclude: []string{"*nightly*"}, texts: []string{"Go nightly"}, allowed: false},
+		{name: "empty patterns are ignored", include: []string{" ", ""}, texts: []string{"anything"}, allowed: true},
+		{name: "glob single character", exclude: []string{"v?.0"}, texts: []string{"v2.0"}, allowed: false},
+		{name: "glob escapes regex", exclude: []string{"v1.0"}, texts: []string{"v1x0"}, allowed: true},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			patterns, err := rules.NewPatterns(testCase.include, testCase.exclude)
+			assert.NoError(t, err, "error should be nil")
+			assert.Equal(t, testCase.allowed, patterns.Allow(testCase.texts...))
+		})
+	}
+}
+
+func TestNewPatterns_Invalid(t *testing.T) {
+	_, err := rules.NewPatterns([]string{"/go(/"}, nil)
+	assert.ErrorContains(t, err, "invalid include pattern")
+
+	_, err = rules.NewPatterns(nil, []string{"/[a-/"})
+	assert.ErrorContains(t, err, "invalid exclude pattern")
+
+	var patterns *rules.Patterns
+	assert.True(t, patterns.Allow("anything"), "nil patterns should allow everything")
+}
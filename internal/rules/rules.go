@@ -0,0 +1,203 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Outcome actions of rules matched by article
+type Outcome struct {
+	Drop     bool
+	MarkRead bool
+	Star     bool
+	// Tags added to article
+	Tags []string
+	// Subscribers names of subscribers which get article, empty means all subscribers
+	Subscribers []string
+	Priority    int
+	// Rules names of matched rules in order of applying
+	Rules []string
+}
+
+// rule compiled stored rule
+type rule struct {
+	*storage.Rule
+	tags         []string
+	author       string
+	titleRegex   *regexp.Regexp
+	contentRegex *regexp.Regexp
+}
+
+// Validate checks that regular expressions and age bounds of rule are valid
+func Validate(stored *storage.Rule) error {
+	_, err := compile(stored)
+	return err
+}
+
+func compile(stored *storage.Rule) (*rule, error) {
+	compiled := &rule{
+		Rule:   stored,
+		tags:   storage.NormalizeTags(stored.Tags),
+		author: strings.TrimSpace(stored.Author),
+	}
+
+	var err error
+	if stored.TitleRegex != "" {
+		if compiled.titleRegex, err = regexp.Compile(stored.TitleRegex); err != nil {
+			return nil, fmt.Errorf("invalid title regex: %w", err)
+		}
+	}
+	if stored.ContentRegex != "" {
+		if compiled.contentRegex, err = regexp.Compile(stored.ContentRegex); err != nil {
+			return nil, fmt.Errorf("invalid content regex: %w", err)
+		}
+	}
+	if stored.MinAge < 0 || stored.MaxAge < 0 {
+		return nil, fmt.Errorf("age should not be negative")
+	}
+	if stored.MaxAge > 0 && stored.MinAge > stored.MaxAge {
+		return nil, fmt.Errorf("min age should not be greater than max age")
+	}
+
+	return compiled, nil
+}
+
+// match checks conditions, tags of article include tags added by rules applied before
+func (r *rule) match(article *model.FeedArticle, tags []string, now time.Time) bool {
+	if len(r.Resources) > 0 && !slices.Contains(r.Resources, article.ResourceID) {
+		return false
+	}
+	if len(r.tags) > 0 && !slices.ContainsFunc(r.tags, func(tag string) bool { return slices.Contains(tags, tag) }) {
+		return false
+	}
+	if r.author != "" && !strings.EqualFold(strings.TrimSpace(article.Author), r.author) {
+		return false
+	}
+	if r.titleRegex != nil && !r.titleRegex.MatchString(article.Title) {
+		return false
+	}
+	if r.contentRegex != nil && !r.contentRegex.MatchString(article.Description) && !r.contentRegex.MatchString(article.Content) {
+		return false
+	}
+	if r.HasImage != nil && *r.HasImage != (article.Image != "") {
+		return false
+	}
+
+	age := now.Sub(article.Published)
+	if r.MinAge > 0 && age < r.MinAge {
+		return false
+	}
+	if r.MaxAge > 0 && age > r.MaxAge {
+		return false
+	}
+
+	return true
+}
+
+// RuleSet enabled rules in order of applying
+type RuleSet struct {
+	rules []*rule
+}
+
+// Apply evaluates rules in order, actions of all matched rules are combined till a rule which drops article or stops processing
+func (s *RuleSet) Apply(article *model.FeedArticle, now time.Time) *Outcome {
+	outcome := &Outcome{}
+	if s == nil {
+		return outcome
+	}
+
+	tags := article.Tags
+	for _, rule := range s.rules {
+		if !rule.match(article, tags, now) {
+			continue
+		}
+
+		outcome.Rules = append(outcome.Rules, rule.Name)
+		if rule.Drop {
+			outcome.Drop = true
+			return outcome
+		}
+
+		outcome.MarkRead = outcome.MarkRead || rule.MarkRead
+		outcome.Star = outcome.Star || rule.Star
+		outcome.Priority += rule.Priority
+		if addTags := storage.NormalizeTags(rule.AddTags); len(addTags) > 0 {
+			outcome.Tags = storage.NormalizeTags(append(outcome.Tags, addTags...))
+			tags = storage.NormalizeTags(append(slices.Clone(tags), addTags...))
+		}
+		for _, subscriber := range rule.Subscribers {
+			if subscriber = strings.TrimSpace(subscriber); subscriber != "" && !slices.Contains(outcome.Subscribers, subscriber) {
+				outcome.Subscribers = append(outcome.Subscribers, subscriber)
+			}
+		}
+
+		if rule.Stop {
+			break
+		}
+	}
+
+	return outcome
+}
+
+// Engine loads rules stored in database
+type Engine struct {
+	logger     *logger.Logger
+	repository *storage.RuleRepository
+}
+
+func NewEngine(logger *logger.Logger, repository *storage.RuleRepository) *Engine {
+	return &Engine{
+		logger:     logger,
+		repository: repository,
+	}
+}
+
+// Load returns enabled rules in order, rules which can not be compiled are skipped
+func (e *Engine) Load(ctx context.Context) (*RuleSet, error) {
+	stored, err := e.repository.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &RuleSet{rules: make([]*rule, 0, len(stored))}
+	for _, storedRule := range stored {
+		if !storedRule.Enabled {
+			continue
+		}
+
+		compiled, err := compile(storedRule)
+		if err != nil {
+			e.logger.WarnContext(ctx, "Rule is skipped", slog.String("rule", storedRule.Name), slog.Any("error", err))
+			continue
+		}
+		set.rules = append(set.rules, compiled)
+	}
+
+	return set, nil
+}
+
+// NewRuleSet compiles rules in given order, disabled rules are skipped
+func NewRuleSet(stored ...*storage.Rule) (*RuleSet, error) {
+	set := &RuleSet{rules: make([]*rule, 0, len(stored))}
+	for _, storedRule := range stored {
+		if !storedRule.Enabled {
+			continue
+		}
+
+		compiled, err := compile(storedRule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", storedRule.Name, err)
+		}
+		set.rules = append(set.rules, compiled)
+	}
+
+	return set, nil
+}
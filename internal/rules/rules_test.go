@@ -0,0 +1,189 @@
+package rules_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/rules"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2024, 8, 13, 12, 0, 0, 0, time.UTC)
+
+func newArticle() *model.FeedArticle {
+	return &model.FeedArticle{
+		ResourceID:  "https://github.com/golang/go/releases.atom",
+		Link:        "https://github.com/golang/go/releases/tag/go1.23.0",
+		Title:       "go1.23.0",
+		Author:      "Gopher",
+		Description: "<p>Release notes with security fixes</p>",
+		Image:       "https://example.com/image.png",
+		Published:   now.Add(-2 * time.Hour),
+		Tags:        []string{"release"},
+	}
+}
+
+func boolPtr(value bool) *bool {
+	return &value
+}
+
+func TestRuleSet_Apply_Conditions(t *testing.T) {
+	testCases := []struct {
+		name    string
+		rule    storage.Rule
+		matched bool
+	}{
+		{name: "empty conditions", rule: storage.Rule{}, matched: true},
+		{name: "resource", rule: storage.Rule{Resources: []string{"https://github.com/golang/go/releases.atom"}}, matched: true},
+		{name: "other resource", rule: storage.Rule{Resources: []string{"https://go.dev/blog/feed.atom"}}, matched: false},
+		{name: "any of tags", rule: storage.Rule{Tags: []string{"blog", "Release"}}, matched: true},
+		{name: "missing tag", rule: storage.Rule{Tags: []string{"blog"}}, matched: false},
+		{name: "author ignores case", rule: storage.Rule{Author: " gopher "}, matched: true},
+		{name: "other author", rule: storage.Rule{Author: "rob"}, matched: false},
+		{name: "title regex", rule: storage.Rule{TitleRegex: `^go1\.\d+\.0$`}, matched: true},
+		{name: "title regex mismatch", rule: storage.Rule{TitleRegex: `rc\d`}, matched: false},
+		{name: "content regex", rule: storage.Rule{ContentRegex: `(?i)SECURITY`}, matched: true},
+		{name: "content regex mismatch", rule: storage.Rule{ContentRegex: `beta`}, matched: false},
+		{name: "has image", rule: storage.Rule{HasImage: boolPtr(true)}, matched: true},
+		{name: "has no image", rule: storage.Rule{HasImage: boolPtr(false)}, matched: false},
+		{name: "younger than max age", rule: storage.Rule{MaxAge: 3 * time.Hour}, matched: true},
+		{name: "older than max age", rule: storage.Rule{MaxAge: time.Hour}, matched: false},
+		{name: "older than min age", rule: storage.Rule{MinAge: time.Hour}, matched: true},
+		{name: "younger than min age", rule: storage.Rule{MinAge: 3 * time.Hour}, matched: false},
+		{name: "all conditions", rule: storage.Rule{Tags: []string{"release"}, Author: "gopher", TitleRegex: `go1`, MaxAge: 3 * time.Hour}, matched: true},
+		{name: "one of conditions fails", rule: storage.Rule{Tags: []string{"release"}, Author: "rob", TitleRegex: `go1`}, matched: false},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.rule.Name = testCase.name
+			testCase.rule.Enabled = true
+			testCase.rule.Star = true
+
+			ruleSet, err := rules.NewRuleSet(&testCase.rule)
+			assert.NoError(t, err, "error should be nil")
+
+			outcome := ruleSet.Apply(newArticle(), now)
+			assert.Equal(t, testCase.matched, outcome.Star)
+			assert.Equal(t, testCase.matched, len(outcome.Rules) == 1)
+		})
+	}
+}
+
+func TestRuleSet_Apply_Actions(t *testing.T) {
+	testCases := []struct {
+		name   string
+		rules  []*storage.Rule
+		expect rules.Outcome
+	}{
+		{
+			name:   "no rules",
+			expect: rules.Outcome{},
+		},
+		{
+			name: "actions are combined",
+			rules: []*storage.Rule{
+				{Name: "star", Enabled: true, Star: true, Priority: 1, AddTags: []string{"Go"}},
+				{Name: "route", Enabled: true, MarkRead: true, Priority: 2, AddTags: []string{"important", "go"}, Subscribers: []string{"ntfy", " discord "}},
+				{Name: "route again", Enabled: true, Subscribers: []string{"ntfy"}},
+			},
+			expect: rules.Outcome{
+				MarkRead:    true,
+				Star:        true,
+				Tags:        []string{"go", "important"},
+				Subscribers: []string{"ntfy", "discord"},
+				Priority:    3,
+				Rules:       []string{"star", "route", "route again"},
+			},
+		},
+		{
+			name: "drop wins over previous actions",
+			rules: []*storage.Rule{
+				{Name: "star", Enabled: true, Star: true},
+				{Name: "drop", Enabled: true, Drop: true},
+				{Name: "after drop", Enabled: true, MarkRead: true},
+			},
+			expect: rules.Outcome{Drop: true, Star: true, Rules: []string{"star", "drop"}},
+		},
+		{
+			name: "stop skips next rules",
+			rules: []*storage.Rule{
+				{Name: "stop", Enabled: true, Priority: 1, Stop: true},
+				{Name: "drop", Enabled: true, Drop: true},
+			},
+			expect: rules.Outcome{Priority: 1, Rules: []string{"stop"}},
+		},
+		{
+			name: "stop of not matched rule is ignored",
+			rules: []*storage.Rule{
+				{Name: "stop", Enabled: true, Author: "rob", Stop: true},
+				{Name: "star", Enabled: true, Star: true},
+			},
+			expect: rules.Outcome{Star: true, Rules: []string{"star"}},
+		},
+		{
+			name: "tags added before are matched",
+			rules: []*storage.Rule{
+				{Name: "tag", Enabled: true, TitleRegex: `^go`, AddTags: []string{"golang"}},
+				{Name: "by tag", Enabled: true, Tags: []string{"golang"}, Priority: -1},
+			},
+			expect: rules.Outcome{Tags: []string{"golang"}, Priority: -1, Rules: []string{"tag", "by tag"}},
+		},
+		{
+			name: "disabled rule is skipped",
+			rules: []*storage.Rule{
+				{Name: "disabled", Enabled: false, Drop: true},
+				{Name: "star", Enabled: true, Star: true},
+			},
+			expect: rules.Outcome{Star: true, Rules: []string{"star"}},
+		},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			ruleSet, err := rules.NewRuleSet(testCase.rules...)
+			assert.NoError(t, err, "error should be nil")
+
+			article := newArticle()
+			outcome := ruleSet.Apply(article, now)
+			assert.Equal(t, testCase.expect, *outcome)
+			assert.Equal(t, []string{"release"}, article.Tags, "article should not be changed")
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		rule   storage.Rule
+		expect string
+	}{
+		{name: "valid", rule: storage.Rule{TitleRegex: `go\d`, MinAge: time.Hour, MaxAge: 2 * time.Hour}},
+		{name: "title regex", rule: storage.Rule{TitleRegex: `go(`}, expect: "invalid title regex"},
+		{name: "content regex", rule: storage.Rule{ContentRegex: `[a-`}, expect: "invalid content regex"},
+		{name: "negative age", rule: storage.Rule{MinAge: -time.Hour}, expect: "age should not be negative"},
+		{name: "age bounds", rule: storage.Rule{MinAge: 2 * time.Hour, MaxAge: time.Hour}, expect: "min age should not be greater than max age"},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			err := rules.Validate(&testCase.rule)
+			if testCase.expect == "" {
+				assert.NoError(t, err, "error should be nil")
+				return
+			}
+			assert.ErrorContains(t, err, testCase.expect)
+		})
+	}
+
+	var ruleSet *rules.RuleSet
+	assert.Equal(t, &rules.Outcome{}, ruleSet.Apply(newArticle(), now), "nil rule set should not match")
+}
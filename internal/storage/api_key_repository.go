@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/sealbro/go-feed-me/internal/db"
+	"gorm.io/gorm"
+	"strconv"
+	"time"
+)
+
+// ApiKey only hash of the key is stored, plain key is shown once on creation
+type ApiKey struct {
+	Id       uint64     `json:"id" gorm:"primaryKey"`
+	Created  time.Time  `json:"created"`
+	LastUsed *time.Time `json:"last_used"`
+	UserId   uint64     `json:"user_id" gorm:"index"`
+	Name     string     `json:"name"`
+	Hash     string     `json:"hash" gorm:"uniqueIndex"`
+}
+
+type ApiKeyRepository struct {
+	db *db.DB
+}
+
+func NewApiKeyRepository(db *db.DB) (*ApiKeyRepository, error) {
+	err := db.AutoMigrate(&ApiKey{})
+	if err != nil {
+		return nil, err
+	}
+	return &ApiKeyRepository{db: db}, nil
+}
+
+func (r *ApiKeyRepository) Create(ctx context.Context, apiKey *ApiKey) error {
+	apiKey.Created = time.Now()
+
+	return r.db.WithContext(ctx).Create(apiKey).Error
+}
+
+func (r *ApiKeyRepository) GetByHash(ctx context.Context, hash string) (*ApiKey, error) {
+	dbModel := &ApiKey{}
+	last := r.db.WithContext(ctx).Last(dbModel, "hash = ?", hash)
+	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return dbModel, last.Error
+}
+
+func (r *ApiKeyRepository) List(ctx context.Context, userId uint64) ([]*ApiKey, error) {
+	apiKeys := make([]*ApiKey, 0)
+	last := r.db.WithContext(ctx).Order("created").Find(&apiKeys, "user_id = ?", userId)
+	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return apiKeys, last.Error
+}
+
+func (r *ApiKeyRepository) Touch(ctx context.Context, id uint64) error {
+	tx := r.db.WithContext(ctx).Model(&ApiKey{}).Where("id = ?", id).Update("last_used", time.Now())
+
+	return tx.Error
+}
+
+// Delete removes keys of user, userId zero removes keys of any user
+func (r *ApiKeyRepository) Delete(ctx context.Context, userId uint64, ids []uint64) error {
+	query := r.db.WithContext(ctx).Where("id IN ?", ids)
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+
+	return query.Delete(&ApiKey{}).Error
+}
+
+func (k *ApiKey) IdString() string {
+	return strconv.FormatUint(k.Id, 10)
+}
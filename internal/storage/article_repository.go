@@ -6,6 +6,7 @@ import (
 	"github.com/sealbro/go-feed-me/internal/db"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"strings"
 	"time"
 )
 
@@ -19,6 +20,67 @@ type Article struct {
 	Content     string    `json:"content"`
 	Author      string    `json:"author"`
 	Image       string    `json:"image"`
+	// OriginalLink link of article in feed, Link is its canonical form
+	OriginalLink string `json:"original_link" gorm:"index"`
+	// Guid id of item in feed
+	Guid string `json:"guid" gorm:"index"`
+	// Updated time of last item change, zero when feed has no such time
+	Updated time.Time `json:"updated"`
+	// Starred, Priority and Subscribers are set by rules when article is parsed
+	Starred  bool `json:"starred"`
+	Priority int  `json:"priority"`
+	// Subscribers names of subscribers which get article, empty means all subscribers
+	Subscribers []string `json:"subscribers" gorm:"serializer:json"`
+	// Tags added by rules, saved with article in addition to tags of its resource
+	Tags []string `json:"tags" gorm:"-"`
+	// Enclosures media files of article, saved with article
+	Enclosures []*Enclosure `json:"enclosures" gorm:"-"`
+	// Authors all authors of article, Author is name of the first one
+	Authors []*ArticleAuthor `json:"authors" gorm:"-"`
+	// Categories categories of item in feed
+	Categories []string `json:"categories" gorm:"-"`
+	// Fingerprint SimHash of title and content, 0 when article has no words
+	Fingerprint int64 `json:"fingerprint"`
+	// DuplicateOf link of the first article of the same story, empty for the first one
+	DuplicateOf string `json:"duplicate_of" gorm:"index;default:''"`
+}
+
+// ArticleTag tag added to article by rules
+type ArticleTag struct {
+	ArticleLink string `json:"article_link" gorm:"primaryKey"`
+	TagName     string `json:"tag_name" gorm:"primaryKey"`
+}
+
+// ArticleAuthor author of article in feed order
+type ArticleAuthor struct {
+	ArticleLink string `json:"article_link" gorm:"primaryKey"`
+	Position    int    `json:"position" gorm:"primaryKey"`
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+}
+
+// ArticleCategory category of item in feed
+type ArticleCategory struct {
+	ArticleLink string `json:"article_link" gorm:"primaryKey"`
+	Name        string `json:"name" gorm:"primaryKey"`
+}
+
+// Enclosure media file of article, e.g. podcast episode or video
+type Enclosure struct {
+	ArticleLink string `json:"article_link" gorm:"primaryKey"`
+	Url         string `json:"url" gorm:"primaryKey"`
+	// Position order of enclosure in article
+	Position int    `json:"position"`
+	Type     string `json:"type"`
+	// Length size in bytes, 0 when unknown
+	Length int64 `json:"length"`
+	// Duration in seconds, 0 when unknown
+	Duration int `json:"duration"`
+	// Episode and Season numbers of podcast episode, 0 when unknown
+	Episode int `json:"episode"`
+	Season  int `json:"season"`
+	// Thumbnail link of preview image
+	Thumbnail string `json:"thumbnail"`
 }
 
 type ArticleRepository struct {
@@ -26,7 +88,14 @@ type ArticleRepository struct {
 }
 
 func NewArticleRepository(db *db.DB) (*ArticleRepository, error) {
-	err := db.AutoMigrate(&Article{})
+	err := db.AutoMigrate(&Article{}, &Tag{}, &ArticleTag{}, &Enclosure{}, &ArticleAuthor{}, &ArticleCategory{})
+	if err != nil {
+		return nil, err
+	}
+
+	// articles stored before canonical links are keyed by link of feed
+	err = db.Model(&Article{}).Where("original_link = '' OR original_link IS NULL").
+		Update("original_link", gorm.Expr("link")).Error
 	if err != nil {
 		return nil, err
 	}
@@ -34,7 +103,7 @@ func NewArticleRepository(db *db.DB) (*ArticleRepository, error) {
 }
 
 func (r *ArticleRepository) Upsert(ctx context.Context, article *Article) error {
-	columns := []string{"title", "published", "description", "content", "author", "image"}
+	columns := []string{"original_link", "guid", "updated", "title", "published", "description", "content", "author", "image", "starred", "priority", "subscribers", "fingerprint", "duplicate_of"}
 
 	tx := r.db.WithContext(ctx).Clauses(clause.OnConflict{
 		Columns:   []clause.Column{{Name: "link"}},
@@ -44,12 +113,281 @@ func (r *ArticleRepository) Upsert(ctx context.Context, article *Article) error
 	return tx.Error
 }
 
-func (r *ArticleRepository) List(ctx context.Context, after time.Time) ([]*Article, error) {
+// UpsertAndPublish saves article with its tags, enclosures, authors and categories and writes outbox event in the same transaction,
+// so new article is never stored without notification, returns id of the event.
+// Already stored articles and duplicates of stories are saved without event and 0 is returned
+func (r *ArticleRepository) UpsertAndPublish(ctx context.Context, article *Article) (uint64, error) {
+	event := &OutboxEvent{Created: time.Now(), ArticleLink: article.Link}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stored int64
+		if err := tx.Model(&Article{}).Where("link = ?", article.Link).Count(&stored).Error; err != nil {
+			return err
+		}
+
+		err := (&ArticleRepository{db: &db.DB{DB: tx}}).Upsert(ctx, article)
+		if err != nil {
+			return err
+		}
+
+		if err = saveDetails(tx, article); err != nil {
+			return err
+		}
+
+		if stored > 0 || article.DuplicateOf != "" {
+			return nil
+		}
+
+		return tx.Create(event).Error
+	})
+
+	return event.Id, err
+}
+
+// saveDetails adds tags of article and replaces its enclosures, authors and categories
+func saveDetails(tx *gorm.DB, article *Article) error {
+	if tags := NormalizeTags(article.Tags); len(tags) > 0 {
+		articleTags := make([]*ArticleTag, len(tags))
+		for i, tag := range tags {
+			articleTags[i] = &ArticleTag{ArticleLink: article.Link, TagName: tag}
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(toTags(tags)).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(articleTags).Error; err != nil {
+			return err
+		}
+	}
+
+	for i, enclosure := range article.Enclosures {
+		enclosure.ArticleLink, enclosure.Position = article.Link, i
+	}
+	for i, author := range article.Authors {
+		author.ArticleLink, author.Position = article.Link, i
+	}
+	categories := make([]*ArticleCategory, 0, len(article.Categories))
+	for _, category := range article.Categories {
+		categories = append(categories, &ArticleCategory{ArticleLink: article.Link, Name: category})
+	}
+
+	if err := replaceRows(tx, article.Link, article.Enclosures); err != nil {
+		return err
+	}
+	if err := replaceRows(tx, article.Link, article.Authors); err != nil {
+		return err
+	}
+
+	return replaceRows(tx, article.Link, categories)
+}
+
+// replaceRows replaces rows of article in child table
+func replaceRows[T any](tx *gorm.DB, link string, rows []*T) error {
+	if err := tx.Delete(new(T), "article_link = ?", link).Error; err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(rows).Error
+}
+
+// GetByLinks returns articles by links in any order
+func (r *ArticleRepository) GetByLinks(ctx context.Context, links []string) ([]*Article, error) {
+	articles := make([]*Article, 0)
+	last := r.db.WithContext(ctx).Find(&articles, "link IN ?", links)
+	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return articles, last.Error
+}
+
+// StoredLinks returns links of stored articles by their original links, so article keeps link it was stored with
+// when canonical form of its link changes
+func (r *ArticleRepository) StoredLinks(ctx context.Context, originalLinks []string) (map[string]string, error) {
+	articles := make([]*Article, 0)
+	tx := r.db.WithContext(ctx).Select("link", "original_link").Find(&articles, "original_link IN ?", originalLinks)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	links := make(map[string]string, len(articles))
+	for _, article := range articles {
+		links[article.OriginalLink] = article.Link
+	}
+
+	return links, nil
+}
+
+// Fingerprints returns resources, links, fingerprints and stories of articles created after time
+func (r *ArticleRepository) Fingerprints(ctx context.Context, after time.Time) ([]*Article, error) {
+	articles := make([]*Article, 0)
+	tx := r.db.WithContext(ctx).Select("resource_id", "link", "fingerprint", "duplicate_of").
+		Order("created").
+		Find(&articles, "created > ? AND fingerprint <> 0", after)
+
+	return articles, tx.Error
+}
+
+// Duplicates returns articles of the same stories as articles with links grouped by link, oldest first
+func (r *ArticleRepository) Duplicates(ctx context.Context, links []string) (map[string][]*Article, error) {
 	articles := make([]*Article, 0)
-	last := r.db.WithContext(ctx).Order("published desc").Find(&articles, "published > ?", after)
+	tx := r.db.WithContext(ctx).Order("created").Find(&articles, "duplicate_of IN ?", links)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	duplicates := make(map[string][]*Article)
+	for _, article := range articles {
+		duplicates[article.DuplicateOf] = append(duplicates[article.DuplicateOf], article)
+	}
+
+	return duplicates, nil
+}
+
+// TagsByArticles returns tag names added by rules grouped by article link
+func (r *ArticleRepository) TagsByArticles(ctx context.Context, links []string) (map[string][]string, error) {
+	articleTags := make([]*ArticleTag, 0)
+	tx := r.db.WithContext(ctx).Order("tag_name").Find(&articleTags, "article_link IN ?", links)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	tags := make(map[string][]string, len(links))
+	for _, articleTag := range articleTags {
+		tags[articleTag.ArticleLink] = append(tags[articleTag.ArticleLink], articleTag.TagName)
+	}
+
+	return tags, nil
+}
+
+// EnclosuresByArticles returns enclosures grouped by article link in order of position
+func (r *ArticleRepository) EnclosuresByArticles(ctx context.Context, links []string) (map[string][]*Enclosure, error) {
+	enclosures := make([]*Enclosure, 0)
+	tx := r.db.WithContext(ctx).Order("position").Find(&enclosures, "article_link IN ?", links)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	grouped := make(map[string][]*Enclosure, len(links))
+	for _, enclosure := range enclosures {
+		grouped[enclosure.ArticleLink] = append(grouped[enclosure.ArticleLink], enclosure)
+	}
+
+	return grouped, nil
+}
+
+// AuthorsByArticles returns authors grouped by article link in feed order
+func (r *ArticleRepository) AuthorsByArticles(ctx context.Context, links []string) (map[string][]*ArticleAuthor, error) {
+	authors := make([]*ArticleAuthor, 0)
+	tx := r.db.WithContext(ctx).Order("position").Find(&authors, "article_link IN ?", links)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	grouped := make(map[string][]*ArticleAuthor, len(links))
+	for _, author := range authors {
+		grouped[author.ArticleLink] = append(grouped[author.ArticleLink], author)
+	}
+
+	return grouped, nil
+}
+
+// CategoriesByArticles returns category names grouped by article link
+func (r *ArticleRepository) CategoriesByArticles(ctx context.Context, links []string) (map[string][]string, error) {
+	categories := make([]*ArticleCategory, 0)
+	tx := r.db.WithContext(ctx).Order("name").Find(&categories, "article_link IN ?", links)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	grouped := make(map[string][]string, len(links))
+	for _, category := range categories {
+		grouped[category.ArticleLink] = append(grouped[category.ArticleLink], category.Name)
+	}
+
+	return grouped, nil
+}
+
+// ListPruned returns articles created after time whose outbox events are already pruned ordered by created time and link,
+// with afterLink articles created at the time with greater link are returned too
+func (r *ArticleRepository) ListPruned(ctx context.Context, after time.Time, afterLink string) ([]*Article, error) {
+	articles := make([]*Article, 0)
+	publishedLinks := r.db.WithContext(ctx).Model(&OutboxEvent{}).Select("article_link")
+	query := r.db.WithContext(ctx).Order("created, link").
+		Where("duplicate_of = '' AND link NOT IN (?)", publishedLinks)
+	if afterLink != "" {
+		query = query.Where("created > ? OR (created = ? AND link > ?)", after, after, afterLink)
+	} else {
+		query = query.Where("created > ?", after)
+	}
+
+	last := query.Find(&articles)
 	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
 		return nil, nil
 	}
 
 	return articles, last.Error
 }
+
+// ArticleFilter narrows articles list, zero values mean no filtering
+type ArticleFilter struct {
+	After time.Time
+	// Tags only articles having any of tags, directly or by their resource
+	Tags []string
+	// UserId owner of Subscribed and Unread filters
+	UserId uint64
+	// Subscribed only articles of resources which user subscribed to
+	Subscribed bool
+	// Unread only articles which user has not read yet
+	Unread bool
+	// Starred only articles starred by rules
+	Starred bool
+	// Categories only articles of any of categories, compared case-insensitively
+	Categories []string
+}
+
+func (r *ArticleRepository) List(ctx context.Context, filter *ArticleFilter) ([]*Article, error) {
+	articles := make([]*Article, 0)
+	// duplicates are reachable through the first article of their story
+	query := r.db.WithContext(ctx).Order("published desc").Where("published > ? AND duplicate_of = ''", filter.After)
+	if tags := NormalizeTags(filter.Tags); len(tags) > 0 {
+		taggedUrls := r.db.WithContext(ctx).Model(&ResourceTag{}).Select("resource_url").Where("tag_name IN ?", tags)
+		taggedLinks := r.db.WithContext(ctx).Model(&ArticleTag{}).Select("article_link").Where("tag_name IN ?", tags)
+		query = query.Where("resource_id IN (?) OR link IN (?)", taggedUrls, taggedLinks)
+	}
+	if filter.Subscribed {
+		subscribedUrls := r.db.WithContext(ctx).Model(&UserSubscription{}).Select("resource_url").Where("user_id = ?", filter.UserId)
+		query = query.Where("resource_id IN (?)", subscribedUrls)
+	}
+	if filter.Unread {
+		readLinks := r.db.WithContext(ctx).Model(&ArticleRead{}).Select("article_link").Where("user_id = ?", filter.UserId)
+		query = query.Where("link NOT IN (?)", readLinks)
+	}
+
+	if filter.Starred {
+		query = query.Where("starred = ?", true)
+	}
+	if categories := lowerAll(filter.Categories); len(categories) > 0 {
+		categorizedLinks := r.db.WithContext(ctx).Model(&ArticleCategory{}).Select("article_link").Where("LOWER(name) IN ?", categories)
+		query = query.Where("link IN (?)", categorizedLinks)
+	}
+
+	last := query.Find(&articles)
+	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return articles, last.Error
+}
+
+func lowerAll(values []string) []string {
+	lowered := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			lowered = append(lowered, value)
+		}
+	}
+
+	return lowered
+}
@@ -0,0 +1,140 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArticleRepository_StoredLinks(t *testing.T) {
+	ctx := context.Background()
+	database := newDatabase(t)
+	repository, err := storage.NewArticleRepository(database)
+	assert.NoError(t, err, "error should be nil")
+
+	legacy := &storage.Article{ResourceId: "https://go.dev/blog/feed.atom", Link: "http://go.dev/blog/go1.22?utm_source=rss", Created: time.Now()}
+	assert.NoError(t, database.Create(legacy).Error, "error should be nil")
+	assert.NoError(t, repository.Upsert(ctx, &storage.Article{
+		ResourceId:   "https://go.dev/blog/feed.atom",
+		Link:         "https://go.dev/blog/go1.23",
+		OriginalLink: "https://go.dev/blog/go1.23/?utm_source=rss",
+		Created:      time.Now(),
+	}), "error should be nil")
+
+	repository, err = storage.NewArticleRepository(database)
+	assert.NoError(t, err, "error should be nil")
+
+	links, err := repository.StoredLinks(ctx, []string{
+		"http://go.dev/blog/go1.22?utm_source=rss",
+		"https://go.dev/blog/go1.23/?utm_source=rss",
+		"https://go.dev/blog/go1.24",
+	})
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, map[string]string{
+		"http://go.dev/blog/go1.22?utm_source=rss":   "http://go.dev/blog/go1.22?utm_source=rss",
+		"https://go.dev/blog/go1.23/?utm_source=rss": "https://go.dev/blog/go1.23",
+	}, links, "article stored without original link should be found by its link")
+}
+
+func TestArticleRepository_UpsertAndPublishOnlyNew(t *testing.T) {
+	ctx := context.Background()
+	database := newDatabase(t)
+	repository, err := storage.NewArticleRepository(database)
+	assert.NoError(t, err, "error should be nil")
+	outboxRepository, err := storage.NewOutboxRepository(database)
+	assert.NoError(t, err, "error should be nil")
+
+	article := &storage.Article{ResourceId: goBlog, Link: "https://go.dev/blog/go1.23", Title: "Go 1.23", Created: time.Now()}
+	eventId, err := repository.UpsertAndPublish(ctx, article)
+	assert.NoError(t, err, "error should be nil")
+	assert.NotZero(t, eventId, "new article should be published")
+
+	article.Title = "Go 1.23 is released"
+	eventId, err = repository.UpsertAndPublish(ctx, article)
+	assert.NoError(t, err, "error should be nil")
+	assert.Zero(t, eventId, "stored article should not be published again")
+
+	events, err := outboxRepository.Pending(ctx, 0, 10)
+	assert.NoError(t, err, "error should be nil")
+	assert.Len(t, events, 1)
+
+	stored, err := repository.GetByLinks(ctx, []string{article.Link})
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, "Go 1.23 is released", stored[0].Title, "stored article should be updated")
+}
+
+func TestArticleRepository_Tags(t *testing.T) {
+	ctx := context.Background()
+	database := newDatabase(t)
+	repository, err := storage.NewArticleRepository(database)
+	assert.NoError(t, err, "error should be nil")
+	_, err = storage.NewOutboxRepository(database)
+	assert.NoError(t, err, "error should be nil")
+
+	article := &storage.Article{ResourceId: goBlog, Link: "https://go.dev/blog/go1.23", Tags: []string{" Release ", "go"}, Created: time.Now()}
+	_, err = repository.UpsertAndPublish(ctx, article)
+	assert.NoError(t, err, "error should be nil")
+	article.Tags = []string{"release", "security"}
+	_, err = repository.UpsertAndPublish(ctx, article)
+	assert.NoError(t, err, "error should be nil")
+
+	tags, err := repository.TagsByArticles(ctx, []string{article.Link})
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, []string{"go", "release", "security"}, tags[article.Link], "tags added by rules should be kept and normalized")
+}
+
+func TestArticleRepository_ListByTags(t *testing.T) {
+	ctx := context.Background()
+	database := newDatabase(t)
+	resourceRepository, err := storage.NewResourceRepository(database)
+	assert.NoError(t, err, "error should be nil")
+	repository, err := storage.NewArticleRepository(database)
+	assert.NoError(t, err, "error should be nil")
+	_, err = storage.NewOutboxRepository(database)
+	assert.NoError(t, err, "error should be nil")
+
+	newResources(t, resourceRepository, goBlog, rustBlog, newsFeed)
+	assert.NoError(t, resourceRepository.AddTags(ctx, []string{goBlog}, []string{"go"}), "error should be nil")
+	assert.NoError(t, resourceRepository.AddTags(ctx, []string{rustBlog}, []string{"rust"}), "error should be nil")
+
+	published := time.Now()
+	for _, article := range []*storage.Article{
+		{ResourceId: goBlog, Link: "https://go.dev/blog/go1.23"},
+		{ResourceId: rustBlog, Link: "https://blog.rust-lang.org/1.80"},
+		{ResourceId: newsFeed, Link: "https://news.example.com/go-security", Tags: []string{"security"}},
+		{ResourceId: newsFeed, Link: "https://news.example.com/weather"},
+	} {
+		article.Created, article.Published = published, published
+		_, err = repository.UpsertAndPublish(ctx, article)
+		assert.NoError(t, err, "error should be nil")
+	}
+
+	testCases := []struct {
+		name   string
+		tags   []string
+		expect []string
+	}{
+		{name: "no tags", expect: []string{"https://go.dev/blog/go1.23", "https://blog.rust-lang.org/1.80", "https://news.example.com/go-security", "https://news.example.com/weather"}},
+		{name: "resource tag", tags: []string{"GO"}, expect: []string{"https://go.dev/blog/go1.23"}},
+		{name: "article tag", tags: []string{"security"}, expect: []string{"https://news.example.com/go-security"}},
+		{name: "any of tags", tags: []string{"rust", "security"}, expect: []string{"https://blog.rust-lang.org/1.80", "https://news.example.com/go-security"}},
+		{name: "unknown tag", tags: []string{"python"}, expect: []string{}},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+		t.Run(testCase.name, func(t *testing.T) {
+			articles, err := repository.List(ctx, &storage.ArticleFilter{After: published.Add(-time.Hour), Tags: testCase.tags})
+			assert.NoError(t, err, "error should be nil")
+
+			links := make([]string, 0, len(articles))
+			for _, article := range articles {
+				links = append(links, article.Link)
+			}
+			assert.ElementsMatch(t, testCase.expect, links)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/sealbro/go-feed-me/internal/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// MatrixThread root event of matrix thread which collects articles of a resource in a room
+type MatrixThread struct {
+	Subscriber string    `json:"subscriber" gorm:"primaryKey"`
+	RoomId     string    `json:"room_id" gorm:"primaryKey"`
+	ResourceId string    `json:"resource_id" gorm:"primaryKey"`
+	EventId    string    `json:"event_id"`
+	Created    time.Time `json:"created"`
+}
+
+type MatrixThreadRepository struct {
+	db *db.DB
+}
+
+func NewMatrixThreadRepository(db *db.DB) (*MatrixThreadRepository, error) {
+	err := db.AutoMigrate(&MatrixThread{})
+	if err != nil {
+		return nil, err
+	}
+	return &MatrixThreadRepository{db: db}, nil
+}
+
+// MatrixThread returns root event id of thread, empty when thread is not started yet
+func (r *MatrixThreadRepository) MatrixThread(ctx context.Context, subscriber string, roomId string, resourceId string) (string, error) {
+	thread := &MatrixThread{}
+	last := r.db.WithContext(ctx).Take(thread, "subscriber = ? AND room_id = ? AND resource_id = ?", subscriber, roomId, resourceId)
+	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+
+	return thread.EventId, last.Error
+}
+
+// AddMatrixThread keeps root event of thread, the existing root is replaced
+func (r *MatrixThreadRepository) AddMatrixThread(ctx context.Context, thread *MatrixThread) error {
+	if thread.Created.IsZero() {
+		thread.Created = time.Now()
+	}
+
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(thread).Error
+}
@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/sealbro/go-feed-me/internal/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strconv"
+	"time"
+)
+
+// OutboxEvent new article waiting for delivery, written in the same transaction as the article
+type OutboxEvent struct {
+	Id          uint64    `json:"id" gorm:"primaryKey"`
+	Created     time.Time `json:"created" gorm:"index"`
+	ArticleLink string    `json:"article_link"`
+}
+
+// OutboxCursor delivery position and retry state of a subscriber
+type OutboxCursor struct {
+	Subscriber  string    `json:"subscriber" gorm:"primaryKey"`
+	EventId     uint64    `json:"event_id"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error"`
+	Updated     time.Time `json:"updated"`
+}
+
+// OutboxDeadLetter event which subscriber failed to deliver after all attempts
+type OutboxDeadLetter struct {
+	Id          uint64    `json:"id" gorm:"primaryKey"`
+	Created     time.Time `json:"created"`
+	Subscriber  string    `json:"subscriber" gorm:"index"`
+	EventId     uint64    `json:"event_id"`
+	ArticleLink string    `json:"article_link"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error"`
+	Replay      bool      `json:"replay"`
+}
+
+type OutboxRepository struct {
+	db *db.DB
+}
+
+func NewOutboxRepository(db *db.DB) (*OutboxRepository, error) {
+	err := db.AutoMigrate(&OutboxEvent{}, &OutboxCursor{}, &OutboxDeadLetter{})
+	if err != nil {
+		return nil, err
+	}
+	return &OutboxRepository{db: db}, nil
+}
+
+// Cursor returns cursor of subscriber, new subscriber starts after the latest event
+func (r *OutboxRepository) Cursor(ctx context.Context, subscriber string) (*OutboxCursor, error) {
+	cursor := &OutboxCursor{}
+	last := r.db.WithContext(ctx).Last(cursor, "subscriber = ?", subscriber)
+	if last.Error == nil {
+		return cursor, nil
+	}
+	if !errors.Is(last.Error, gorm.ErrRecordNotFound) {
+		return nil, last.Error
+	}
+
+	latest, err := r.LatestId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor = &OutboxCursor{Subscriber: subscriber, EventId: latest, Updated: time.Now()}
+	tx := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(cursor)
+
+	return cursor, tx.Error
+}
+
+func (r *OutboxRepository) Cursors(ctx context.Context) ([]*OutboxCursor, error) {
+	cursors := make([]*OutboxCursor, 0)
+	last := r.db.WithContext(ctx).Order("subscriber").Find(&cursors)
+	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return cursors, last.Error
+}
+
+func (r *OutboxRepository) SaveCursor(ctx context.Context, cursor *OutboxCursor) error {
+	cursor.Updated = time.Now()
+
+	return r.db.WithContext(ctx).Save(cursor).Error
+}
+
+// Pending returns events after cursor in order of writing
+func (r *OutboxRepository) Pending(ctx context.Context, afterId uint64, limit int) ([]*OutboxEvent, error) {
+	events := make([]*OutboxEvent, 0)
+	last := r.db.WithContext(ctx).Order("id").Limit(limit).Find(&events, "id > ?", afterId)
+	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return events, last.Error
+}
+
+// Between returns events with afterId < id <= toId in order of writing
+func (r *OutboxRepository) Between(ctx context.Context, afterId uint64, toId uint64, limit int) ([]*OutboxEvent, error) {
+	events := make([]*OutboxEvent, 0)
+	last := r.db.WithContext(ctx).Order("id").Limit(limit).Find(&events, "id > ? AND id <= ?", afterId, toId)
+	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return events, last.Error
+}
+
+// LatestId returns id of the last written event, 0 if outbox is empty
+func (r *OutboxRepository) LatestId(ctx context.Context) (uint64, error) {
+	var latest uint64
+	tx := r.db.WithContext(ctx).Model(&OutboxEvent{}).Select("COALESCE(MAX(id), 0)").Scan(&latest)
+
+	return latest, tx.Error
+}
+
+// LatestIdBefore returns id of the last event written not after time, 0 if there is no such event
+func (r *OutboxRepository) LatestIdBefore(ctx context.Context, before time.Time) (uint64, error) {
+	var latest uint64
+	tx := r.db.WithContext(ctx).Model(&OutboxEvent{}).Select("COALESCE(MAX(id), 0)").Where("created <= ?", before).Scan(&latest)
+
+	return latest, tx.Error
+}
+
+// Exists reports whether event is still kept in outbox
+func (r *OutboxRepository) Exists(ctx context.Context, id uint64) (bool, error) {
+	var count int64
+	tx := r.db.WithContext(ctx).Model(&OutboxEvent{}).Where("id = ?", id).Count(&count)
+
+	return count > 0, tx.Error
+}
+
+// DeadLetter moves failed events to dead letters and advances cursor past them in one transaction
+func (r *OutboxRepository) DeadLetter(ctx context.Context, cursor *OutboxCursor, events []*OutboxEvent) error {
+	created := time.Now()
+	deadLetters := make([]*OutboxDeadLetter, len(events))
+	for i, event := range events {
+		deadLetters[i] = &OutboxDeadLetter{
+			Created:     created,
+			Subscriber:  cursor.Subscriber,
+			EventId:     event.Id,
+			ArticleLink: event.ArticleLink,
+			Attempts:    cursor.Attempts,
+			LastError:   cursor.LastError,
+		}
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(deadLetters) > 0 {
+			if err := tx.Create(deadLetters).Error; err != nil {
+				return err
+			}
+		}
+
+		cursor.EventId = events[len(events)-1].Id
+		cursor.Attempts = 0
+		cursor.NextAttempt = time.Time{}
+		cursor.LastError = ""
+		cursor.Updated = created
+
+		return tx.Save(cursor).Error
+	})
+}
+
+// DeadLetters returns dead letters of subscriber, empty subscriber returns dead letters of all subscribers
+func (r *OutboxRepository) DeadLetters(ctx context.Context, subscriber string) ([]*OutboxDeadLetter, error) {
+	deadLetters := make([]*OutboxDeadLetter, 0)
+	query := r.db.WithContext(ctx).Order("id")
+	if subscriber != "" {
+		query = query.Where("subscriber = ?", subscriber)
+	}
+
+	last := query.Find(&deadLetters)
+	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return deadLetters, last.Error
+}
+
+// ReplayQueue returns dead letters of subscriber requested for replay
+func (r *OutboxRepository) ReplayQueue(ctx context.Context, subscriber string, limit int) ([]*OutboxDeadLetter, error) {
+	deadLetters := make([]*OutboxDeadLetter, 0)
+	last := r.db.WithContext(ctx).Order("id").Limit(limit).Find(&deadLetters, "subscriber = ? AND replay = ?", subscriber, true)
+	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return deadLetters, last.Error
+}
+
+// Replay requests redelivery of dead letters
+func (r *OutboxRepository) Replay(ctx context.Context, ids []uint64) error {
+	tx := r.db.WithContext(ctx).Model(&OutboxDeadLetter{}).Where("id IN ?", ids).Update("replay", true)
+
+	return tx.Error
+}
+
+// ReplayFailed returns dead letters back to inspection with the new error
+func (r *OutboxRepository) ReplayFailed(ctx context.Context, ids []uint64, lastError string) error {
+	tx := r.db.WithContext(ctx).Model(&OutboxDeadLetter{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+		"replay":     false,
+		"last_error": lastError,
+		"attempts":   gorm.Expr("attempts + 1"),
+	})
+
+	return tx.Error
+}
+
+func (r *OutboxRepository) DeleteDeadLetters(ctx context.Context, ids []uint64) error {
+	tx := r.db.WithContext(ctx).Delete(&OutboxDeadLetter{}, "id IN ?", ids)
+
+	return tx.Error
+}
+
+// Prune removes old events delivered to all subscribers and not referenced by their dead letters,
+// only the oldest events are removed, so every event after a kept one is kept too.
+// Cursors and dead letters of subscribers removed from config do not hold events,
+// without cursors of subscribers events are removed by time only
+func (r *OutboxRepository) Prune(ctx context.Context, subscribers []string, before time.Time) (int64, error) {
+	var cursors struct {
+		Count   int64
+		EventId uint64
+	}
+	tx := r.db.WithContext(ctx).Model(&OutboxCursor{}).
+		Select("COUNT(*) AS count, COALESCE(MIN(event_id), 0) AS event_id").
+		Where("subscriber IN ?", subscribers).
+		Scan(&cursors)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+
+	var deadLetter uint64
+	tx = r.db.WithContext(ctx).Model(&OutboxDeadLetter{}).
+		Select("COALESCE(MIN(event_id), 0)").
+		Where("subscriber IN ?", subscribers).
+		Scan(&deadLetter)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+
+	query := r.db.WithContext(ctx).Where("created < ?", before)
+	if cursors.Count > 0 {
+		query = query.Where("id <= ?", cursors.EventId)
+	}
+	if deadLetter > 0 {
+		query = query.Where("id < ?", deadLetter)
+	}
+	tx = query.Delete(&OutboxEvent{})
+
+	return tx.RowsAffected, tx.Error
+}
+
+func (l *OutboxDeadLetter) IdString() string {
+	return strconv.FormatUint(l.Id, 10)
+}
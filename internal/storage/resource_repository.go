@@ -16,6 +16,17 @@ type Resource struct {
 	Published time.Time `json:"published"`
 	Title     string    `json:"title"`
 	Url       string    `json:"url" gorm:"primaryKey"`
+	Tags      []*Tag    `json:"tags" gorm:"many2many:resource_tags"`
+	// Description, Link of homepage, Image, Language and Generator are metadata of feed
+	Description string `json:"description"`
+	Link        string `json:"link"`
+	Image       string `json:"image"`
+	Language    string `json:"language"`
+	Generator   string `json:"generator"`
+	// IncludePatterns only items matching any of patterns are stored
+	IncludePatterns []string `json:"include_patterns" gorm:"serializer:json"`
+	// ExcludePatterns items matching any of patterns are not stored
+	ExcludePatterns []string `json:"exclude_patterns" gorm:"serializer:json"`
 }
 
 type ResourceRepository struct {
@@ -23,7 +34,12 @@ type ResourceRepository struct {
 }
 
 func NewResourceRepository(db *db.DB) (*ResourceRepository, error) {
-	err := db.AutoMigrate(&Resource{})
+	err := db.SetupJoinTable(&Resource{}, "Tags", &ResourceTag{})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.AutoMigrate(&Resource{}, &Tag{}, &ResourceTag{})
 	if err != nil {
 		return nil, err
 	}
@@ -32,7 +48,7 @@ func NewResourceRepository(db *db.DB) (*ResourceRepository, error) {
 
 func (r *ResourceRepository) Get(ctx context.Context, url string) (*Resource, error) {
 	dbModel := &Resource{}
-	last := r.db.WithContext(ctx).Last(dbModel, "url = ?", url)
+	last := r.db.WithContext(ctx).Preload("Tags").Last(dbModel, "url = ?", url)
 	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
 		return nil, nil
 	}
@@ -40,9 +56,26 @@ func (r *ResourceRepository) Get(ctx context.Context, url string) (*Resource, er
 	return dbModel, last.Error
 }
 
-func (r *ResourceRepository) List(ctx context.Context, active bool) ([]*Resource, error) {
+// GetByUrls returns resources with tags by urls in any order
+func (r *ResourceRepository) GetByUrls(ctx context.Context, urls []string) ([]*Resource, error) {
+	resources := make([]*Resource, 0)
+	last := r.db.WithContext(ctx).Preload("Tags").Find(&resources, "url IN ?", urls)
+	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return resources, last.Error
+}
+
+// List returns resources by active flag, if tags passed only resources with any of them
+func (r *ResourceRepository) List(ctx context.Context, active bool, tags ...string) ([]*Resource, error) {
 	resources := make([]*Resource, 0)
-	last := r.db.WithContext(ctx).Find(&resources, "active = ?", active)
+	query := r.db.WithContext(ctx).Preload("Tags").Where("active = ?", active)
+	if tags = NormalizeTags(tags); len(tags) > 0 {
+		query = query.Where("url IN (?)", r.taggedUrls(ctx, tags))
+	}
+
+	last := query.Find(&resources)
 	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
 		return nil, nil
 	}
@@ -50,12 +83,28 @@ func (r *ResourceRepository) List(ctx context.Context, active bool) ([]*Resource
 	return resources, last.Error
 }
 
+// TagsByResources returns tag names grouped by resource url
+func (r *ResourceRepository) TagsByResources(ctx context.Context, urls []string) (map[string][]string, error) {
+	resourceTags := make([]*ResourceTag, 0)
+	tx := r.db.WithContext(ctx).Order("tag_name").Find(&resourceTags, "resource_url IN ?", urls)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	tags := make(map[string][]string, len(urls))
+	for _, resourceTag := range resourceTags {
+		tags[resourceTag.ResourceUrl] = append(tags[resourceTag.ResourceUrl], resourceTag.TagName)
+	}
+
+	return tags, nil
+}
+
 func (r *ResourceRepository) Upsert(ctx context.Context, repoInfo *Resource) error {
 	repoInfo.Modified = time.Now()
 
-	columns := []string{"title", "published", "modified", "active"}
+	columns := []string{"title", "published", "modified", "active", "description", "link", "image", "language", "generator"}
 
-	tx := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+	tx := r.db.WithContext(ctx).Omit(clause.Associations).Clauses(clause.OnConflict{
 		Columns:   []clause.Column{{Name: "url"}},
 		DoUpdates: clause.AssignmentColumns(columns),
 	}).Create(repoInfo)
@@ -64,9 +113,19 @@ func (r *ResourceRepository) Upsert(ctx context.Context, repoInfo *Resource) err
 }
 
 func (r *ResourceRepository) Delete(ctx context.Context, urls []string) error {
-	tx := r.db.WithContext(ctx).Delete(&Resource{}, "url IN ?", urls)
-
-	return tx.Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Delete(&ResourceTag{}, "resource_url IN ?", urls).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Delete(&UserSubscription{}, "resource_url IN ?", urls).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Delete(&Resource{}, "url IN ?", urls).Error
+	})
 }
 
 func (r *ResourceRepository) Activate(ctx context.Context, urls []string, active bool) error {
@@ -79,3 +138,52 @@ func (r *ResourceRepository) Activate(ctx context.Context, urls []string, active
 
 	return tx.Error
 }
+
+// SetPatterns replaces include and exclude patterns of resources
+func (r *ResourceRepository) SetPatterns(ctx context.Context, urls []string, include []string, exclude []string) error {
+	tx := r.db.WithContext(ctx).Model(&Resource{}).Where("url IN ?", urls).
+		Select("include_patterns", "exclude_patterns", "modified").
+		Updates(&Resource{IncludePatterns: include, ExcludePatterns: exclude, Modified: time.Now()})
+
+	return tx.Error
+}
+
+// AddTags attaches tags to resources, tags that do not exist yet will be created
+func (r *ResourceRepository) AddTags(ctx context.Context, urls []string, tags []string) error {
+	tags = NormalizeTags(tags)
+	if len(urls) == 0 || len(tags) == 0 {
+		return nil
+	}
+
+	resourceTags := make([]*ResourceTag, 0, len(urls)*len(tags))
+	for _, url := range urls {
+		for _, tag := range tags {
+			resourceTags = append(resourceTags, &ResourceTag{ResourceUrl: url, TagName: tag})
+		}
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(toTags(tags)).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(resourceTags).Error
+	})
+}
+
+// RemoveTags detaches tags from resources
+func (r *ResourceRepository) RemoveTags(ctx context.Context, urls []string, tags []string) error {
+	tags = NormalizeTags(tags)
+	if len(urls) == 0 || len(tags) == 0 {
+		return nil
+	}
+
+	tx := r.db.WithContext(ctx).Delete(&ResourceTag{}, "resource_url IN ? AND tag_name IN ?", urls, tags)
+
+	return tx.Error
+}
+
+func (r *ResourceRepository) taggedUrls(ctx context.Context, tags []string) *gorm.DB {
+	return r.db.WithContext(ctx).Model(&ResourceTag{}).Select("resource_url").Where("tag_name IN ?", tags)
+}
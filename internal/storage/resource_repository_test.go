@@ -0,0 +1,82 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	goBlog   = "https://go.dev/blog/feed.atom"
+	rustBlog = "https://blog.rust-lang.org/feed.xml"
+	newsFeed = "https://news.example.com/feed"
+)
+
+func newResources(t *testing.T, repository *storage.ResourceRepository, urls ...string) {
+	for _, url := range urls {
+		assert.NoError(t, repository.Upsert(context.Background(), &storage.Resource{Url: url, Title: url, Active: true}), "error should be nil")
+	}
+}
+
+func TestResourceRepository_Tags(t *testing.T) {
+	ctx := context.Background()
+	repository, err := storage.NewResourceRepository(newDatabase(t))
+	assert.NoError(t, err, "error should be nil")
+	newResources(t, repository, goBlog, rustBlog)
+
+	assert.NoError(t, repository.AddTags(ctx, []string{goBlog, rustBlog}, []string{" Lang ", "release"}), "error should be nil")
+	assert.NoError(t, repository.AddTags(ctx, []string{goBlog}, []string{"lang", "Go"}), "adding existing tag should be ignored")
+
+	tags, err := repository.TagsByResources(ctx, []string{goBlog, rustBlog})
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, map[string][]string{
+		goBlog:   {"go", "lang", "release"},
+		rustBlog: {"lang", "release"},
+	}, tags, "tags should be normalized")
+
+	assert.NoError(t, repository.RemoveTags(ctx, []string{goBlog}, []string{"RELEASE", "unknown"}), "error should be nil")
+
+	resource, err := repository.Get(ctx, goBlog)
+	assert.NoError(t, err, "error should be nil")
+	assert.ElementsMatch(t, []string{"go", "lang"}, resource.TagNames())
+
+	tags, err = repository.TagsByResources(ctx, []string{rustBlog})
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, []string{"lang", "release"}, tags[rustBlog], "tags of other resources should be kept")
+}
+
+func TestResourceRepository_ListByTags(t *testing.T) {
+	ctx := context.Background()
+	repository, err := storage.NewResourceRepository(newDatabase(t))
+	assert.NoError(t, err, "error should be nil")
+	newResources(t, repository, goBlog, rustBlog, newsFeed)
+	assert.NoError(t, repository.AddTags(ctx, []string{goBlog}, []string{"go"}), "error should be nil")
+	assert.NoError(t, repository.AddTags(ctx, []string{rustBlog}, []string{"rust"}), "error should be nil")
+
+	testCases := []struct {
+		name   string
+		tags   []string
+		expect []string
+	}{
+		{name: "no tags", expect: []string{goBlog, rustBlog, newsFeed}},
+		{name: "one tag", tags: []string{"Go"}, expect: []string{goBlog}},
+		{name: "any of tags", tags: []string{"go", "rust"}, expect: []string{goBlog, rustBlog}},
+		{name: "unknown tag", tags: []string{"python"}, expect: []string{}},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+		t.Run(testCase.name, func(t *testing.T) {
+			resources, err := repository.List(ctx, true, testCase.tags...)
+			assert.NoError(t, err, "error should be nil")
+
+			urls := make([]string, 0, len(resources))
+			for _, resource := range resources {
+				urls = append(urls, resource.Url)
+			}
+			assert.ElementsMatch(t, testCase.expect, urls)
+		})
+	}
+}
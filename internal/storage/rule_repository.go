@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/sealbro/go-feed-me/internal/db"
+	"gorm.io/gorm"
+	"strconv"
+	"time"
+)
+
+// Rule conditions and actions applied to parsed articles in position order, empty conditions match any article
+type Rule struct {
+	Id       uint64    `json:"id" gorm:"primaryKey"`
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+	Name     string    `json:"name"`
+	Position int       `json:"position" gorm:"index"`
+	Enabled  bool      `json:"enabled"`
+
+	// Resources urls of resources
+	Resources []string `json:"resources" gorm:"serializer:json"`
+	// Tags article has any of tags
+	Tags []string `json:"tags" gorm:"serializer:json"`
+	// TitleRegex regular expression on title
+	TitleRegex string `json:"title_regex"`
+	// ContentRegex regular expression on description or content
+	ContentRegex string `json:"content_regex"`
+	// Author author name, compared case-insensitively
+	Author string `json:"author"`
+	// HasImage nil matches articles with and without image
+	HasImage *bool `json:"has_image"`
+	// MinAge and MaxAge bounds of time since article was published, zero means no bound
+	MinAge time.Duration `json:"min_age"`
+	MaxAge time.Duration `json:"max_age"`
+
+	Drop     bool     `json:"drop"`
+	MarkRead bool     `json:"mark_read"`
+	Star     bool     `json:"star"`
+	AddTags  []string `json:"add_tags" gorm:"serializer:json"`
+	// Subscribers route article only to subscribers with names
+	Subscribers []string `json:"subscribers" gorm:"serializer:json"`
+	// Priority added to priority of push notifications
+	Priority int `json:"priority"`
+	// Stop rules after this one are not applied when it matches
+	Stop bool `json:"stop"`
+}
+
+type RuleRepository struct {
+	db *db.DB
+}
+
+func NewRuleRepository(db *db.DB) (*RuleRepository, error) {
+	err := db.AutoMigrate(&Rule{})
+	if err != nil {
+		return nil, err
+	}
+	return &RuleRepository{db: db}, nil
+}
+
+// List returns rules in order of applying
+func (r *RuleRepository) List(ctx context.Context) ([]*Rule, error) {
+	rules := make([]*Rule, 0)
+	last := r.db.WithContext(ctx).Order("position, id").Find(&rules)
+	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return rules, last.Error
+}
+
+func (r *RuleRepository) Get(ctx context.Context, id uint64) (*Rule, error) {
+	rule := &Rule{}
+	last := r.db.WithContext(ctx).Last(rule, "id = ?", id)
+	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return rule, last.Error
+}
+
+// NextPosition returns position of rule applied after all existing rules
+func (r *RuleRepository) NextPosition(ctx context.Context) (int, error) {
+	var position *int
+	if tx := r.db.WithContext(ctx).Model(&Rule{}).Select("MAX(position)").Scan(&position); tx.Error != nil || position == nil {
+		return 0, tx.Error
+	}
+
+	return *position + 1, nil
+}
+
+func (r *RuleRepository) Create(ctx context.Context, rule *Rule) error {
+	rule.Created = time.Now()
+	rule.Modified = rule.Created
+
+	return r.db.WithContext(ctx).Create(rule).Error
+}
+
+func (r *RuleRepository) Update(ctx context.Context, rule *Rule) error {
+	rule.Modified = time.Now()
+
+	return r.db.WithContext(ctx).Save(rule).Error
+}
+
+func (r *RuleRepository) Delete(ctx context.Context, ids []uint64) error {
+	return r.db.WithContext(ctx).Delete(&Rule{}, "id IN ?", ids).Error
+}
+
+func (r *Rule) IdString() string {
+	return strconv.FormatUint(r.Id, 10)
+}
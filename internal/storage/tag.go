@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"strings"
+)
+
+type Tag struct {
+	Name string `json:"name" gorm:"primaryKey"`
+}
+
+// ResourceTag join table between resources and tags, articles inherit tags of their resource
+type ResourceTag struct {
+	ResourceUrl string `json:"resource_url" gorm:"primaryKey"`
+	TagName     string `json:"tag_name" gorm:"primaryKey"`
+}
+
+// NormalizeTags trims, lowercases and removes empty and duplicated tag names
+func NormalizeTags(names []string) []string {
+	normalized := make([]string, 0, len(names))
+	seen := map[string]struct{}{}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		normalized = append(normalized, name)
+	}
+
+	return normalized
+}
+
+func toTags(names []string) []*Tag {
+	tags := make([]*Tag, len(names))
+	for i, name := range names {
+		tags[i] = &Tag{Name: name}
+	}
+
+	return tags
+}
+
+// TagNames returns names of resource tags
+func (r *Resource) TagNames() []string {
+	names := make([]string, len(r.Tags))
+	for i, tag := range r.Tags {
+		names[i] = tag.Name
+	}
+
+	return names
+}
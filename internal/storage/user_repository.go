@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/sealbro/go-feed-me/internal/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strconv"
+	"time"
+)
+
+type User struct {
+	Id      uint64    `json:"id" gorm:"primaryKey"`
+	Created time.Time `json:"created"`
+	Name    string    `json:"name" gorm:"uniqueIndex"`
+	Role    string    `json:"role"`
+	// OidcIssuer and OidcSubject identity of user created by OpenID Connect login, empty for local users
+	OidcIssuer  string `json:"oidc_issuer" gorm:"index:idx_users_oidc"`
+	OidcSubject string `json:"oidc_subject" gorm:"index:idx_users_oidc"`
+}
+
+// UserSubscription resource which user subscribed to
+type UserSubscription struct {
+	UserId      uint64    `json:"user_id" gorm:"primaryKey"`
+	ResourceUrl string    `json:"resource_url" gorm:"primaryKey"`
+	Created     time.Time `json:"created"`
+}
+
+// ArticleRead article which user has read
+type ArticleRead struct {
+	UserId      uint64    `json:"user_id" gorm:"primaryKey"`
+	ArticleLink string    `json:"article_link" gorm:"primaryKey"`
+	Read        time.Time `json:"read"`
+}
+
+type UserRepository struct {
+	db *db.DB
+}
+
+func NewUserRepository(db *db.DB) (*UserRepository, error) {
+	err := db.AutoMigrate(&User{}, &UserSubscription{}, &ArticleRead{})
+	if err != nil {
+		return nil, err
+	}
+	return &UserRepository{db: db}, nil
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *User) error {
+	user.Created = time.Now()
+
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+func (r *UserRepository) Get(ctx context.Context, id uint64) (*User, error) {
+	dbModel := &User{}
+	last := r.db.WithContext(ctx).Last(dbModel, "id = ?", id)
+	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return dbModel, last.Error
+}
+
+func (r *UserRepository) GetByName(ctx context.Context, name string) (*User, error) {
+	dbModel := &User{}
+	last := r.db.WithContext(ctx).Last(dbModel, "name = ?", name)
+	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return dbModel, last.Error
+}
+
+// GetByOidcSubject returns user created by OpenID Connect login of identity
+func (r *UserRepository) GetByOidcSubject(ctx context.Context, issuer string, subject string) (*User, error) {
+	dbModel := &User{}
+	last := r.db.WithContext(ctx).Last(dbModel, "oidc_issuer = ? AND oidc_subject = ?", issuer, subject)
+	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return dbModel, last.Error
+}
+
+func (r *UserRepository) List(ctx context.Context) ([]*User, error) {
+	users := make([]*User, 0)
+	last := r.db.WithContext(ctx).Order("name").Find(&users)
+	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return users, last.Error
+}
+
+func (r *UserRepository) SetRole(ctx context.Context, name string, role string) error {
+	tx := r.db.WithContext(ctx).Model(&User{}).Where("name = ?", name).Update("role", role)
+	if tx.Error == nil && tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return tx.Error
+}
+
+// Delete removes users with their subscriptions, read state and api keys
+func (r *UserRepository) Delete(ctx context.Context, names []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		userIds := tx.Model(&User{}).Select("id").Where("name IN ?", names)
+
+		err := tx.Delete(&UserSubscription{}, "user_id IN (?)", userIds).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Delete(&ArticleRead{}, "user_id IN (?)", userIds).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Delete(&ApiKey{}, "user_id IN (?)", userIds).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Delete(&User{}, "name IN ?", names).Error
+	})
+}
+
+func (r *UserRepository) Subscribe(ctx context.Context, userId uint64, urls []string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	created := time.Now()
+	subscriptions := make([]*UserSubscription, len(urls))
+	for i, url := range urls {
+		subscriptions[i] = &UserSubscription{UserId: userId, ResourceUrl: url, Created: created}
+	}
+
+	tx := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(subscriptions)
+
+	return tx.Error
+}
+
+func (r *UserRepository) Unsubscribe(ctx context.Context, userId uint64, urls []string) error {
+	tx := r.db.WithContext(ctx).Delete(&UserSubscription{}, "user_id = ? AND resource_url IN ?", userId, urls)
+
+	return tx.Error
+}
+
+// SubscribedUrls returns urls of resources which user subscribed to
+func (r *UserRepository) SubscribedUrls(ctx context.Context, userId uint64) ([]string, error) {
+	urls := make([]string, 0)
+	tx := r.db.WithContext(ctx).Model(&UserSubscription{}).Where("user_id = ?", userId).Order("resource_url").Pluck("resource_url", &urls)
+
+	return urls, tx.Error
+}
+
+// MarkRead sets or clears read state of articles for user
+func (r *UserRepository) MarkRead(ctx context.Context, userId uint64, links []string, read bool) error {
+	if len(links) == 0 {
+		return nil
+	}
+
+	if !read {
+		tx := r.db.WithContext(ctx).Delete(&ArticleRead{}, "user_id = ? AND article_link IN ?", userId, links)
+		return tx.Error
+	}
+
+	readTime := time.Now()
+	reads := make([]*ArticleRead, len(links))
+	for i, link := range links {
+		reads[i] = &ArticleRead{UserId: userId, ArticleLink: link, Read: readTime}
+	}
+
+	tx := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(reads)
+
+	return tx.Error
+}
+
+// MarkReadForAll sets read state of articles for every user
+func (r *UserRepository) MarkReadForAll(ctx context.Context, links []string) error {
+	if len(links) == 0 {
+		return nil
+	}
+
+	userIds := make([]uint64, 0)
+	if tx := r.db.WithContext(ctx).Model(&User{}).Pluck("id", &userIds); tx.Error != nil || len(userIds) == 0 {
+		return tx.Error
+	}
+
+	readTime := time.Now()
+	reads := make([]*ArticleRead, 0, len(userIds)*len(links))
+	for _, userId := range userIds {
+		for _, link := range links {
+			reads = append(reads, &ArticleRead{UserId: userId, ArticleLink: link, Read: readTime})
+		}
+	}
+
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(reads).Error
+}
+
+// ReadLinks returns which of links user has read
+func (r *UserRepository) ReadLinks(ctx context.Context, userId uint64, links []string) (map[string]bool, error) {
+	readLinks := make([]string, 0)
+	tx := r.db.WithContext(ctx).Model(&ArticleRead{}).
+		Where("user_id = ? AND article_link IN ?", userId, links).
+		Pluck("article_link", &readLinks)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	read := make(map[string]bool, len(readLinks))
+	for _, link := range readLinks {
+		read[link] = true
+	}
+
+	return read, nil
+}
+
+func (u *User) IdString() string {
+	return strconv.FormatUint(u.Id, 10)
+}
@@ -0,0 +1,92 @@
+package storage_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/sealbro/go-feed-me/internal/db"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func newDatabase(t *testing.T) *db.DB {
+	newLogger, err := logger.NewLogger(&logger.Config{LogLevel: "ERROR"})
+	assert.NoError(t, err, "error should be nil")
+
+	database, err := db.NewSqliteDatabase(logger.NewGormLogger(newLogger), &db.Config{
+		SqliteConnection: filepath.Join(t.TempDir(), "feed.db"),
+	})
+	assert.NoError(t, err, "error should be nil")
+
+	return database
+}
+
+func newUsers(t *testing.T, repository *storage.UserRepository, names ...string) []*storage.User {
+	users := make([]*storage.User, len(names))
+	for i, name := range names {
+		users[i] = &storage.User{Name: name, Role: "viewer"}
+		assert.NoError(t, repository.Create(context.Background(), users[i]), "error should be nil")
+	}
+
+	return users
+}
+
+func TestUserRepository_Subscriptions(t *testing.T) {
+	ctx := context.Background()
+	database := newDatabase(t)
+	repository, err := storage.NewUserRepository(database)
+	assert.NoError(t, err, "error should be nil")
+	_, err = storage.NewApiKeyRepository(database)
+	assert.NoError(t, err, "error should be nil")
+	users := newUsers(t, repository, "alice", "bob")
+	alice, bob := users[0], users[1]
+
+	assert.NoError(t, repository.Subscribe(ctx, alice.Id, []string{"https://go.dev/blog/feed.atom", "https://blog.rust-lang.org/feed.xml"}), "error should be nil")
+	assert.NoError(t, repository.Subscribe(ctx, alice.Id, []string{"https://go.dev/blog/feed.atom"}), "subscribing twice should be ignored")
+	assert.NoError(t, repository.Subscribe(ctx, bob.Id, []string{"https://go.dev/blog/feed.atom"}), "error should be nil")
+	assert.NoError(t, repository.Unsubscribe(ctx, alice.Id, []string{"https://blog.rust-lang.org/feed.xml"}), "error should be nil")
+
+	urls, err := repository.SubscribedUrls(ctx, alice.Id)
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, []string{"https://go.dev/blog/feed.atom"}, urls)
+
+	assert.NoError(t, repository.Unsubscribe(ctx, bob.Id, []string{"https://go.dev/blog/feed.atom"}), "error should be nil")
+	urls, err = repository.SubscribedUrls(ctx, alice.Id)
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, []string{"https://go.dev/blog/feed.atom"}, urls, "subscriptions of other users should be kept")
+
+	assert.NoError(t, repository.Delete(ctx, []string{"alice"}), "error should be nil")
+	urls, err = repository.SubscribedUrls(ctx, alice.Id)
+	assert.NoError(t, err, "error should be nil")
+	assert.Empty(t, urls, "subscriptions of removed user should be removed")
+}
+
+func TestUserRepository_ReadState(t *testing.T) {
+	ctx := context.Background()
+	repository, err := storage.NewUserRepository(newDatabase(t))
+	assert.NoError(t, err, "error should be nil")
+	users := newUsers(t, repository, "alice", "bob")
+	alice, bob := users[0], users[1]
+
+	links := []string{"https://go.dev/blog/go1.22", "https://go.dev/blog/go1.23"}
+	assert.NoError(t, repository.MarkRead(ctx, alice.Id, links, true), "error should be nil")
+	assert.NoError(t, repository.MarkRead(ctx, alice.Id, links[:1], true), "marking read twice should be ignored")
+	assert.NoError(t, repository.MarkRead(ctx, alice.Id, links[:1], false), "error should be nil")
+
+	read, err := repository.ReadLinks(ctx, alice.Id, links)
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, map[string]bool{"https://go.dev/blog/go1.23": true}, read)
+
+	read, err = repository.ReadLinks(ctx, bob.Id, links)
+	assert.NoError(t, err, "error should be nil")
+	assert.Empty(t, read, "read state should be per user")
+
+	assert.NoError(t, repository.MarkReadForAll(ctx, []string{"https://go.dev/blog/go1.22"}), "error should be nil")
+	for _, user := range users {
+		read, err = repository.ReadLinks(ctx, user.Id, links)
+		assert.NoError(t, err, "error should be nil")
+		assert.True(t, read["https://go.dev/blog/go1.22"], "article should be read by %s", user.Name)
+	}
+}
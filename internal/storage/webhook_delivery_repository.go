@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/sealbro/go-feed-me/internal/db"
+	"gorm.io/gorm"
+	"time"
+)
+
+// WebhookDelivery one attempt of webhook subscriber to post batch of articles
+type WebhookDelivery struct {
+	Id         uint64    `json:"id" gorm:"primaryKey"`
+	Created    time.Time `json:"created" gorm:"index"`
+	Subscriber string    `json:"subscriber" gorm:"index"`
+	// Url target without query and credentials
+	Url        string `json:"url"`
+	DeliveryId string `json:"delivery_id" gorm:"index"`
+	Attempt    int    `json:"attempt"`
+	Articles   int    `json:"articles"`
+	// StatusCode response status, 0 when request failed before response
+	StatusCode int    `json:"status_code"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error"`
+}
+
+type WebhookDeliveryRepository struct {
+	db *db.DB
+}
+
+func NewWebhookDeliveryRepository(db *db.DB) (*WebhookDeliveryRepository, error) {
+	err := db.AutoMigrate(&WebhookDelivery{})
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookDeliveryRepository{db: db}, nil
+}
+
+func (r *WebhookDeliveryRepository) Add(ctx context.Context, delivery *WebhookDelivery) error {
+	if delivery.Created.IsZero() {
+		delivery.Created = time.Now()
+	}
+
+	return r.db.WithContext(ctx).Create(delivery).Error
+}
+
+// WebhookDeliveries returns the latest attempts first, empty subscriber returns attempts of all subscribers
+func (r *WebhookDeliveryRepository) WebhookDeliveries(ctx context.Context, subscriber string, limit int) ([]*WebhookDelivery, error) {
+	deliveries := make([]*WebhookDelivery, 0)
+	query := r.db.WithContext(ctx).Order("id desc").Limit(limit)
+	if subscriber != "" {
+		query = query.Where("subscriber = ?", subscriber)
+	}
+
+	last := query.Find(&deliveries)
+	if errors.Is(last.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return deliveries, last.Error
+}
+
+// PruneWebhookDeliveries removes attempts created before time
+func (r *WebhookDeliveryRepository) PruneWebhookDeliveries(ctx context.Context, before time.Time) error {
+	return r.db.WithContext(ctx).Where("created < ?", before).Delete(&WebhookDelivery{}).Error
+}
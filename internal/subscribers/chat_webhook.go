@@ -0,0 +1,108 @@
+package subscribers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// chatWebhook posts JSON messages to incoming webhook of chat or push platform,
+// 429 responses are retried in place after Retry-After delay
+type chatWebhook struct {
+	platform   string
+	subscriber string
+	url        string
+	// headers authenticate requests to platforms which do not keep secret in url
+	headers       map[string]string
+	maxRetries    int
+	maxRetryAfter time.Duration
+	logger        *logger.Logger
+	client        *http.Client
+}
+
+func newChatWebhook(logger *logger.Logger, platform string, subscriber string, url string, maxRetries int, maxRetryAfter time.Duration) *chatWebhook {
+	return &chatWebhook{
+		platform:      platform,
+		subscriber:    subscriber,
+		url:           url,
+		maxRetries:    maxRetries,
+		maxRetryAfter: maxRetryAfter,
+		logger:        logger,
+		client:        &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (w *chatWebhook) send(ctx context.Context, message any) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := w.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if retryAfter < 0 || attempt >= w.maxRetries {
+			return err
+		}
+
+		w.logger.WarnContext(ctx, "Chat webhook is rate limited", slog.String("platform", w.platform),
+			slog.String("subscriber", w.subscriber), slog.Duration("retry_after", retryAfter))
+
+		if err := sleep(ctx, retryAfter); err != nil {
+			return err
+		}
+	}
+}
+
+// post returns delay before retry when webhook is rate limited and -1 when request should not be retried in place
+func (w *chatWebhook) post(ctx context.Context, body []byte) (time.Duration, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return -1, fmt.Errorf("invalid %s webhook url", w.platform)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range w.headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := w.client.Do(request)
+	if err != nil {
+		// url error contains webhook secret
+		return -1, fmt.Errorf("%s webhook request failed: %w", w.platform, errors.Unwrap(err))
+	}
+	defer response.Body.Close()
+
+	responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+	if response.StatusCode == http.StatusTooManyRequests {
+		return w.retryAfter(response.Header.Get("Retry-After")), fmt.Errorf("%s webhook rate limited: %s", w.platform, strings.TrimSpace(string(responseBody)))
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return -1, fmt.Errorf("%s webhook responded %d: %s", w.platform, response.StatusCode, strings.TrimSpace(string(responseBody)))
+	}
+
+	return 0, nil
+}
+
+func (w *chatWebhook) retryAfter(header string) time.Duration {
+	retryAfter := time.Second
+	if seconds, err := strconv.Atoi(strings.TrimSpace(header)); err == nil && seconds >= 0 {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+
+	return min(retryAfter, w.maxRetryAfter)
+}
+
+func (w *chatWebhook) close() {
+	w.client.CloseIdleConnections()
+}
@@ -0,0 +1,58 @@
+package subscribers
+
+import (
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/pkg/notifier"
+	"time"
+)
+
+// defaultBatching batching of chat and push subscribers when batch size and time are not set
+var defaultBatching = notifier.Batching{Size: 10, MaxWait: time.Minute}
+
+// DeliveryConfig selects and batches articles of subscriber, every subscriber type reads it
+// with own prefix, e.g. DISCORD_TAGS, DISCORD_BATCH_SIZE, DISCORD_BATCH_TIME and DISCORD_DIGEST
+type DeliveryConfig struct {
+	Tags []string `split_words:"true"`
+	// BatchSize and BatchTime override batching of subscriber type when set, 0 batch time sends articles immediately
+	BatchSize *int           `split_words:"true"`
+	BatchTime *time.Duration `split_words:"true"`
+	Digest    []string       `split_words:"true"`
+}
+
+func (c DeliveryConfig) Validate() error {
+	return c.batching(notifier.Batching{}).Validate()
+}
+
+// batching returns batching of config, size and wait which are not set are taken from defaults
+func (c DeliveryConfig) batching(defaults notifier.Batching) notifier.Batching {
+	batching := notifier.Batching{Size: defaults.Size, MaxWait: defaults.MaxWait, Digest: c.Digest}
+	if c.BatchSize != nil {
+		batching.Size = *c.BatchSize
+	}
+	if c.BatchTime != nil {
+		batching.MaxWait = *c.BatchTime
+	}
+
+	return batching
+}
+
+// delivery tags and batching of subscriber, the outbox relay reads them to select and group articles
+type delivery struct {
+	tags     []string
+	batching notifier.Batching
+}
+
+func newDelivery(config DeliveryConfig, defaults notifier.Batching) delivery {
+	return delivery{
+		tags:     storage.NormalizeTags(config.Tags),
+		batching: config.batching(defaults),
+	}
+}
+
+func (d delivery) Tags() []string {
+	return d.tags
+}
+
+func (d delivery) Batching() notifier.Batching {
+	return d.batching
+}
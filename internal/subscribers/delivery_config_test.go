@@ -0,0 +1,62 @@
+package subscribers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/sealbro/go-feed-me/internal/subscribers"
+	"github.com/sealbro/go-feed-me/pkg/notifier"
+	"github.com/stretchr/testify/assert"
+)
+
+// delivery tags and batching which outbox relay reads from subscriber
+type delivery interface {
+	Tags() []string
+	Batching() notifier.Batching
+}
+
+func TestDeliveryConfig_Instances(t *testing.T) {
+	t.Setenv("SUBSCRIBER_RELEASES_DISCORD_WEBHOOK_ID", "1")
+	t.Setenv("SUBSCRIBER_RELEASES_DISCORD_WEBHOOK_TOKEN", "releases-token")
+	t.Setenv("SUBSCRIBER_RELEASES_DISCORD_TAGS", "Release,security")
+	t.Setenv("SUBSCRIBER_RELEASES_DISCORD_BATCH_TIME", "0s")
+	t.Setenv("SUBSCRIBER_RELEASES_DISCORD_DIGEST", "09:00")
+
+	instances, err := subscribers.NewDiscordSubscribers(newLogger(t),
+		&subscribers.DiscordConfig{WebhookId: 3, WebhookToken: "default-token"},
+		&subscribers.Config{Instances: map[string]string{"releases": "discord"}})
+	assert.NoError(t, err, "error should be nil")
+	assert.Len(t, instances, 2)
+
+	releases := instances[0].(delivery)
+	assert.Equal(t, []string{"release", "security"}, releases.Tags(), "tags should be normalized")
+	assert.Equal(t, notifier.Batching{Size: 10, Digest: []string{"09:00"}}, releases.Batching(), "zero batch time should override default one")
+
+	fallback := instances[1].(delivery)
+	assert.Empty(t, fallback.Tags())
+	assert.Equal(t, notifier.Batching{Size: 10, MaxWait: time.Minute}, fallback.Batching())
+}
+
+func TestDeliveryConfig_EmailDefaults(t *testing.T) {
+	t.Setenv("EMAIL_SMTP_HOST", "smtp.example.com")
+	t.Setenv("EMAIL_FROM", "feed@example.com")
+	t.Setenv("EMAIL_TO", "alice@example.com")
+	t.Setenv("EMAIL_BATCH_SIZE", "50")
+
+	config := &subscribers.EmailConfig{}
+	assert.NoError(t, envconfig.Process("", config), "error should be nil")
+
+	subscriber, err := subscribers.NewEmailSubscriber(newLogger(t), "email", config)
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, notifier.Batching{Size: 50, MaxWait: time.Hour}, subscriber.Batching(), "email should wait for digest of an hour")
+}
+
+func TestDeliveryConfig_Validate(t *testing.T) {
+	size, wait := -1, -time.Minute
+
+	assert.NoError(t, subscribers.DeliveryConfig{Digest: []string{"Mon 09:00"}}.Validate(), "error should be nil")
+	assert.Error(t, subscribers.DeliveryConfig{BatchSize: &size}.Validate(), "negative batch size should be rejected")
+	assert.Error(t, subscribers.DeliveryConfig{BatchTime: &wait}.Validate(), "negative batch time should be rejected")
+	assert.Error(t, subscribers.DeliveryConfig{Digest: []string{"25:00"}}.Validate(), "invalid digest should be rejected")
+}
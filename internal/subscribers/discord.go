@@ -2,84 +2,327 @@ package subscribers
 
 import (
 	"context"
-	md "github.com/JohannesKaufmann/html-to-markdown"
+	"fmt"
 	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/rest"
 	"github.com/disgoorg/disgo/webhook"
 	"github.com/disgoorg/snowflake/v2"
 	"github.com/sealbro/go-feed-me/graph/model"
-	"github.com/sealbro/go-feed-me/pkg/graceful"
 	"github.com/sealbro/go-feed-me/pkg/logger"
-	"github.com/sealbro/go-feed-me/pkg/notifier"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
 )
 
+const DiscordType = "discord"
+
+// Discord limits, see https://discord.com/developers/docs/resources/message#embed-object-embed-limits
+const (
+	discordMaxEmbeds      = 10
+	discordMaxMessageText = 6000
+	discordMaxTitle       = 256
+	discordMaxFooter      = 2048
+	discordMaxAuthor      = 256
+	discordMaxThreadName  = 100
+	// discordDescriptionLength keeps ten embeds of a batch in one message
+	discordDescriptionLength = 500
+	// discordColor embed color when DISCORD_COLOR is not set
+	discordColor = "87CEEB"
+	// discordDefaultWebhook name of webhook configured by DISCORD_WEBHOOK_ID and DISCORD_WEBHOOK_TOKEN
+	discordDefaultWebhook = "default"
+)
+
+// discordTarget webhook with articles routed to it
+type discordTarget struct {
+	name     string
+	client   webhook.Client
+	threadId snowflake.ID
+	// route nil routes all articles
+	route func(article *model.FeedArticle) bool
+}
+
+// discordRuleColor embed color of articles matching rule
+type discordRuleColor struct {
+	articleRule
+	color int
+}
+
+// DiscordSubscriber delivers outbox events to discord webhooks
 type DiscordSubscriber struct {
-	subscriptionManager *notifier.SubscriptionManager[*model.FeedArticle]
-	cancelFunc          context.CancelFunc
-	config              *DiscordConfig
-	logger              *logger.Logger
-	converter           *md.Converter
+	delivery
+	name      string
+	config    *DiscordConfig
+	logger    *logger.Logger
+	targets   []*discordTarget
+	color     int
+	colors    []discordRuleColor
+	templates *messageTemplates
 }
 
-func NewDiscordSubscriber(logger *logger.Logger, config *DiscordConfig,
-	subscriptionManager *notifier.SubscriptionManager[*model.FeedArticle], closer *graceful.ShutdownCloser) *DiscordSubscriber {
-	d := &DiscordSubscriber{
-		logger:              logger,
-		subscriptionManager: subscriptionManager,
-		config:              config,
-		converter:           md.NewConverter("", true, nil),
+// NewDiscordSubscribers creates instances declared in SUBSCRIBERS and the default one configured by DISCORD_* variables
+func NewDiscordSubscribers(logger *logger.Logger, defaultConfig *DiscordConfig, config *Config) ([]Subscriber, error) {
+	instances, err := newInstances(config, DiscordType, func(name string, settings *DiscordConfig) (Subscriber, error) {
+		return NewDiscordSubscriber(logger, name, settings)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	closer.Register(d)
+	if defaultConfig.Enabled() {
+		instance, err := NewDiscordSubscriber(logger, DiscordType, defaultConfig)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
 
-	return d
+	return instances, nil
 }
 
-func (s *DiscordSubscriber) Subscribe(ctx context.Context) error {
-	cancelCtx, cancelFunc := context.WithCancel(ctx)
-	s.cancelFunc = cancelFunc
-	events, err := s.subscriptionManager.AddSubscriber(cancelCtx, "discord")
+// NewDiscordSubscriber creates subscriber, invalid webhooks, routes and colors fail creation
+func NewDiscordSubscriber(logger *logger.Logger, name string, config *DiscordConfig) (*DiscordSubscriber, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	templates, err := newMessageTemplates(config.Template, config.Templates)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	subscriber := &DiscordSubscriber{
+		name:      name,
+		logger:    logger,
+		config:    config,
+		templates: templates,
+		delivery:  newDelivery(config.Delivery, defaultBatching),
+	}
+	subscriber.color, _ = parseDiscordColor(firstNonEmpty(config.Color, discordColor))
+
+	colors := make([]string, 0, len(config.Colors))
+	for color := range config.Colors {
+		colors = append(colors, color)
+	}
+	// order of colors keeps choice stable when article matches several tag rules
+	sort.Strings(colors)
+	for _, color := range colors {
+		value, err := parseDiscordColor(color)
+		if err != nil {
+			continue
+		}
+		subscriber.colors = append(subscriber.colors, discordRuleColor{articleRule: newArticleRule(config.Colors[color]), color: value})
 	}
 
-	go s.processEvents(events)
+	routes := make([]articleRule, 0, len(config.Routes))
+	names := make([]string, 0, len(config.Webhooks))
+	for webhookName := range config.Webhooks {
+		names = append(names, webhookName)
+	}
+	sort.Strings(names)
+	for _, webhookName := range names {
+		parsed, err := parseDiscordWebhook(config.Webhooks[webhookName])
+		if err != nil {
+			continue
+		}
 
+		target := &discordTarget{name: webhookName, client: subscriber.client(parsed.id, parsed.token), threadId: parsed.threadId}
+		if values, ok := config.Routes[webhookName]; ok {
+			route := newArticleRule(values)
+			routes = append(routes, route)
+			target.route = route.match
+		}
+		subscriber.targets = append(subscriber.targets, target)
+	}
+
+	if config.WebhookId != 0 && config.WebhookToken != "" {
+		target := &discordTarget{
+			name:     discordDefaultWebhook,
+			client:   subscriber.client(snowflake.ID(config.WebhookId), config.WebhookToken),
+			threadId: snowflake.ID(config.ThreadId),
+		}
+		if len(routes) > 0 {
+			target.route = func(article *model.FeedArticle) bool {
+				return !slices.ContainsFunc(routes, func(route articleRule) bool { return route.match(article) })
+			}
+		}
+		subscriber.targets = append(subscriber.targets, target)
+	}
+
+	return subscriber, nil
+}
+
+func (s *DiscordSubscriber) Name() string {
+	return s.name
+}
+
+func (s *DiscordSubscriber) Subscribe(_ context.Context) error {
 	return nil
 }
 
-func (s *DiscordSubscriber) processEvents(fireEvents <-chan []*model.FeedArticle) {
-	client := webhook.New(snowflake.ID(s.config.WebhookId), s.config.WebhookToken)
-
-	for events := range fireEvents {
-
-		embeds := make([]discord.Embed, len(events))
-		for i, event := range events {
-			revertIndex := len(events) - 1 - i
-			embeds[revertIndex] = discord.Embed{
-				Title:       event.Title,
-				Type:        discord.EmbedTypeRich,
-				Description: event.Description,
-				URL:         event.Link,
-				Timestamp:   &event.Published,
-				Color:       0x87CEEB,
-				Footer: &discord.EmbedFooter{
-					Text: event.ResourceTitle,
-				},
-				Author: &discord.EmbedAuthor{Name: event.Author},
+// Deliver sends articles routed to every webhook as messages within embed limits
+func (s *DiscordSubscriber) Deliver(ctx context.Context, events []*model.FeedArticle) error {
+	if len(events) == 0 {
+		return nil
+	}
+	events = oldestFirst(events)
+
+	for _, target := range s.targets {
+		routed := events
+		if target.route != nil {
+			routed = make([]*model.FeedArticle, 0, len(events))
+			for _, event := range events {
+				if target.route(event) {
+					routed = append(routed, event)
+				}
 			}
 		}
 
-		_, err := client.CreateEmbeds(embeds)
+		messages, err := s.messages(routed)
 		if err != nil {
-			s.logger.Error("Failed to send message to discord", err)
+			return err
+		}
+
+		for _, message := range messages {
+			if err := s.send(ctx, target, message); err != nil {
+				return fmt.Errorf("discord webhook %s: %w", target.name, err)
+			}
 		}
 	}
+
+	return nil
+}
+
+// Render returns messages of article as they are sent
+func (s *DiscordSubscriber) Render(_ context.Context, event *model.FeedArticle) (string, error) {
+	messages, err := s.messages([]*model.FeedArticle{event})
+	if err != nil {
+		return "", err
+	}
+
+	return renderPayload(messages)
 }
 
 func (s *DiscordSubscriber) Close() error {
-	if s.cancelFunc != nil {
-		s.cancelFunc()
-		s.cancelFunc = nil
+	for _, target := range s.targets {
+		target.client.Close(context.Background())
 	}
 	return nil
 }
+
+func (s *DiscordSubscriber) client(id snowflake.ID, token string) webhook.Client {
+	// rest client counts the first request as a try
+	opts := []rest.ConfigOpt{rest.WithRateLimiterConfigOpts(rest.WithMaxRetries(s.config.MaxRetries + 1))}
+	if s.config.ApiUrl != "" {
+		opts = append(opts, rest.WithURL(strings.TrimRight(s.config.ApiUrl, "/")))
+	}
+
+	return webhook.New(id, token, webhook.WithLogger(s.logger.Logger), webhook.WithRestClientConfigOpts(opts...))
+}
+
+func (s *DiscordSubscriber) send(ctx context.Context, target *discordTarget, message discord.WebhookMessageCreate) error {
+	if target.threadId != 0 {
+		_, err := target.client.CreateMessageInThread(message, target.threadId, rest.WithCtx(ctx))
+		return err
+	}
+
+	_, err := target.client.CreateMessage(message, rest.WithCtx(ctx))
+	return err
+}
+
+// messages renders embeds and splits them by embed count and text length of a message,
+// in forum every article starts own post named by article title
+func (s *DiscordSubscriber) messages(events []*model.FeedArticle) ([]discord.WebhookMessageCreate, error) {
+	embeds := make([]discord.Embed, len(events))
+	for i, event := range events {
+		article, err := s.templates.notification(event)
+		if err != nil {
+			return nil, err
+		}
+		embeds[i] = s.embed(event, article)
+	}
+
+	if s.config.Forum {
+		messages := make([]discord.WebhookMessageCreate, len(embeds))
+		for i, embed := range embeds {
+			messages[i] = discord.WebhookMessageCreate{
+				Embeds:     []discord.Embed{embed},
+				ThreadName: truncate(firstNonEmpty(embed.Title, embed.URL), discordMaxThreadName),
+			}
+		}
+		return messages, nil
+	}
+
+	chunks := splitByLength(embeds, discordEmbedLength, discordMaxMessageText, discordMaxEmbeds)
+	messages := make([]discord.WebhookMessageCreate, len(chunks))
+	for i, chunk := range chunks {
+		messages[i] = discord.WebhookMessageCreate{Embeds: chunk}
+	}
+
+	return messages, nil
+}
+
+// embed renders article, footer rendered by template replaces resource and author
+func (s *DiscordSubscriber) embed(event *model.FeedArticle, article *notification) discord.Embed {
+	embed := discord.Embed{
+		Title:       truncate(article.Title, discordMaxTitle),
+		Type:        discord.EmbedTypeRich,
+		Description: article.Markdown(discordDescriptionLength),
+		URL:         article.Link,
+	}
+	if !article.Published.IsZero() {
+		embed.Timestamp = &article.Published
+	}
+	footer := article.Resource
+	if article.hasFooter {
+		footer = article.Footer
+	}
+	if footer != "" {
+		embed.Footer = &discord.EmbedFooter{Text: truncate(footer, discordMaxFooter)}
+	}
+	if article.Author != "" && !article.hasFooter {
+		embed.Author = &discord.EmbedAuthor{Name: truncate(article.Author, discordMaxAuthor)}
+	}
+
+	if article.Image != "" {
+		switch s.config.Image {
+		case DiscordImage, "":
+			embed.Image = &discord.EmbedResource{URL: article.Image}
+		case DiscordThumbnail:
+			embed.Thumbnail = &discord.EmbedResource{URL: article.Image}
+		}
+	}
+
+	embed.Color = s.embedColor(event)
+
+	return embed
+}
+
+// embedColor returns color of article resource, then color of article tags, then default color
+func (s *DiscordSubscriber) embedColor(event *model.FeedArticle) int {
+	for _, color := range s.colors {
+		if slices.Contains(color.resources, event.ResourceID) {
+			return color.color
+		}
+	}
+	for _, color := range s.colors {
+		if color.match(event) {
+			return color.color
+		}
+	}
+
+	return s.color
+}
+
+// discordEmbedLength characters of embed counted by discord against limit of a message
+func discordEmbedLength(embed discord.Embed) int {
+	length := utf8.RuneCountInString(embed.Title) + utf8.RuneCountInString(embed.Description)
+	if embed.Footer != nil {
+		length += utf8.RuneCountInString(embed.Footer.Text)
+	}
+	if embed.Author != nil {
+		length += utf8.RuneCountInString(embed.Author.Name)
+	}
+
+	return length
+}
@@ -1,6 +1,131 @@
 package subscribers
 
+import (
+	"fmt"
+	"github.com/disgoorg/snowflake/v2"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DiscordImage renders article image below description
+	DiscordImage = "image"
+	// DiscordThumbnail renders article image at the right of embed
+	DiscordThumbnail = "thumbnail"
+	// DiscordNoImage renders embeds without article image
+	DiscordNoImage = "none"
+)
+
 type DiscordConfig struct {
 	WebhookId    uint64 `envconfig:"DISCORD_WEBHOOK_ID"`
 	WebhookToken string `envconfig:"DISCORD_WEBHOOK_TOKEN"`
+	// ThreadId thread of default webhook channel to post in
+	ThreadId uint64 `envconfig:"DISCORD_THREAD_ID"`
+	// Webhooks named webhook urls, thread_id query parameter of url selects thread to post in
+	Webhooks map[string]string `envconfig:"DISCORD_WEBHOOKS"`
+	// Routes articles for named webhooks by tags or resource urls, e.g. releases:release|https://go.dev/blog/feed.atom,
+	// named webhook without route gets all articles and default webhook gets articles matching no route
+	Routes map[string]string `envconfig:"DISCORD_ROUTES"`
+	Color  string            `envconfig:"DISCORD_COLOR" default:"87CEEB"`
+	// Colors embed colors by tags or resource urls, e.g. ff0000:security,00add8:https://go.dev/blog/feed.atom
+	Colors map[string]string `envconfig:"DISCORD_COLORS"`
+	Image  string            `envconfig:"DISCORD_IMAGE" default:"image"`
+	// Forum webhooks post to forum channels, every article starts own post
+	Forum    bool           `envconfig:"DISCORD_FORUM" default:"false"`
+	ApiUrl   string         `envconfig:"DISCORD_API_URL" default:"https://discord.com/api/v10"`
+	Delivery DeliveryConfig `envconfig:"DISCORD"`
+	// Template path of text/template rendering markdown of embed description, optional "title" and "footer" templates replace title and source
+	Template string `envconfig:"DISCORD_TEMPLATE"`
+	// Templates paths of templates by tags or resource urls, e.g. /etc/feed/releases.tmpl:release|https://go.dev/blog/feed.atom
+	Templates map[string]string `envconfig:"DISCORD_TEMPLATES"`
+	// MaxRetries how many times rate limited requests are retried in place before batch goes back to outbox
+	MaxRetries int `envconfig:"DISCORD_MAX_RETRIES" default:"3"`
+}
+
+func (c *DiscordConfig) Enabled() bool {
+	return c.WebhookId != 0 && c.WebhookToken != "" || len(c.Webhooks) > 0
+}
+
+func (c *DiscordConfig) Validate() error {
+	if !c.Enabled() {
+		return fmt.Errorf("discord webhook id and token or webhooks are required")
+	}
+	if c.Forum && c.ThreadId != 0 {
+		return fmt.Errorf("discord forum webhook can not post to thread")
+	}
+	for name, webhook := range c.Webhooks {
+		parsed, err := parseDiscordWebhook(webhook)
+		if err != nil {
+			return fmt.Errorf("invalid discord webhook %q: %w", name, err)
+		}
+		if c.Forum && parsed.threadId != 0 {
+			return fmt.Errorf("discord forum webhook %q can not post to thread", name)
+		}
+	}
+	for name := range c.Routes {
+		if _, ok := c.Webhooks[name]; !ok {
+			return fmt.Errorf("discord route for unknown webhook %q", name)
+		}
+	}
+	if c.Color != "" {
+		if _, err := parseDiscordColor(c.Color); err != nil {
+			return err
+		}
+	}
+	for color := range c.Colors {
+		if _, err := parseDiscordColor(color); err != nil {
+			return err
+		}
+	}
+	if c.Image != "" && c.Image != DiscordImage && c.Image != DiscordThumbnail && c.Image != DiscordNoImage {
+		return fmt.Errorf("unknown discord image %q, expected %s, %s or %s", c.Image, DiscordImage, DiscordThumbnail, DiscordNoImage)
+	}
+	if c.ApiUrl != "" && !httpUrl(c.ApiUrl) {
+		return fmt.Errorf("discord api url should be http url")
+	}
+
+	return c.Delivery.Validate()
+}
+
+// discordWebhook id, token and optional thread of webhook url
+type discordWebhook struct {
+	id       snowflake.ID
+	token    string
+	threadId snowflake.ID
+}
+
+// parseDiscordWebhook parses urls like https://discord.com/api/webhooks/<id>/<token>?thread_id=<id>
+func parseDiscordWebhook(webhook string) (*discordWebhook, error) {
+	parsed, err := url.Parse(webhook)
+	if err != nil || !httpUrl(webhook) {
+		return nil, fmt.Errorf("webhook should be http url")
+	}
+
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(parts) < 3 || parts[len(parts)-3] != "webhooks" {
+		return nil, fmt.Errorf("webhook url should end with /webhooks/<id>/<token>")
+	}
+
+	result := &discordWebhook{token: parts[len(parts)-1]}
+	if result.id, err = snowflake.Parse(parts[len(parts)-2]); err != nil {
+		return nil, fmt.Errorf("invalid webhook id: %w", err)
+	}
+	if thread := parsed.Query().Get("thread_id"); thread != "" {
+		if result.threadId, err = snowflake.Parse(thread); err != nil {
+			return nil, fmt.Errorf("invalid thread id: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// parseDiscordColor parses hex color like 87CEEB or #87ceeb
+func parseDiscordColor(color string) (int, error) {
+	value, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(color), "#"), 16, 24)
+	if err != nil {
+		return 0, fmt.Errorf("invalid discord color %q, expected hex like 87CEEB", color)
+	}
+
+	return int(value), nil
 }
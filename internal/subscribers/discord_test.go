@@ -0,0 +1,259 @@
+package subscribers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/subscribers"
+	"github.com/stretchr/testify/assert"
+)
+
+type discordEmbed struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Url         string `json:"url"`
+	Color       int    `json:"color"`
+	Footer      *struct {
+		Text string `json:"text"`
+	} `json:"footer"`
+	Image *struct {
+		Url string `json:"url"`
+	} `json:"image"`
+	Thumbnail *struct {
+		Url string `json:"url"`
+	} `json:"thumbnail"`
+}
+
+type discordMessage struct {
+	Webhook    string
+	ThreadId   string
+	ThreadName string         `json:"thread_name"`
+	Embeds     []discordEmbed `json:"embeds"`
+}
+
+// discordMessages returns messages of successful requests to webhook endpoints
+func discordMessages(t *testing.T, receiver *webhookReceiver) []discordMessage {
+	var messages []discordMessage
+	for _, request := range receiver.requests {
+		if request.Status >= http.StatusMultipleChoices {
+			continue
+		}
+		message := discordMessage{
+			Webhook:  strings.TrimPrefix(request.Path, "/webhooks/"),
+			ThreadId: request.Query.Get("thread_id"),
+		}
+		assert.NoError(t, json.Unmarshal(request.Body, &message), "error should be nil")
+		messages = append(messages, message)
+	}
+
+	return messages
+}
+
+func newDiscordConfig(url string) *subscribers.DiscordConfig {
+	return &subscribers.DiscordConfig{
+		WebhookId:    1,
+		WebhookToken: "default-token",
+		Color:        "87CEEB",
+		Image:        subscribers.DiscordImage,
+		ApiUrl:       url,
+		MaxRetries:   2,
+	}
+}
+
+func newDiscordSubscriber(t *testing.T, receiver *webhookReceiver, configure func(config *subscribers.DiscordConfig)) *subscribers.DiscordSubscriber {
+	// executed webhook responds with message
+	receiver.response = `{"id": "1", "channel_id": "2"}`
+	config := newDiscordConfig(newServer(t, receiver))
+	if configure != nil {
+		configure(config)
+	}
+
+	subscriber, err := subscribers.NewDiscordSubscriber(newLogger(t), "discord", config)
+	assert.NoError(t, err, "error should be nil")
+	assert.NoError(t, subscriber.Subscribe(context.Background()), "error should be nil")
+	t.Cleanup(func() { _ = subscriber.Close() })
+
+	return subscriber
+}
+
+func TestDiscordSubscriber_Deliver(t *testing.T) {
+	receiver := &webhookReceiver{}
+	subscriber := newDiscordSubscriber(t, receiver, func(config *subscribers.DiscordConfig) {
+		config.Colors = map[string]string{"#ff0000": "security", "00add8": "https://go.dev/blog/feed.atom"}
+		config.Image = subscribers.DiscordThumbnail
+	})
+
+	articles := testArticles()
+	articles[0].ResourceID = "https://go.dev/blog/feed.atom"
+	articles[0].Tags = []string{"security"}
+	err := subscriber.Deliver(context.Background(), articles)
+	assert.NoError(t, err, "error should be nil")
+
+	messages := discordMessages(t, receiver)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "1/default-token", messages[0].Webhook)
+
+	embeds := messages[0].Embeds
+	assert.Len(t, embeds, 2)
+	assert.Equal(t, "First", embeds[0].Title, "articles should be sent oldest first")
+	assert.Equal(t, "Read **more** at [docs](https://example.com/docs)", embeds[0].Description, "description should be markdown")
+	assert.Equal(t, "Example blog", embeds[0].Footer.Text)
+	assert.Equal(t, 0x87CEEB, embeds[0].Color, "default color should be used without matching rules")
+	assert.Equal(t, "https://example.com/1.png", embeds[0].Thumbnail.Url)
+	assert.Nil(t, embeds[0].Image)
+	assert.Equal(t, 0x00ADD8, embeds[1].Color, "resource color should win over tag color")
+}
+
+func TestDiscordSubscriber_Limits(t *testing.T) {
+	receiver := &webhookReceiver{}
+	subscriber := newDiscordSubscriber(t, receiver, nil)
+
+	articles := longArticles(25)
+	for _, article := range articles {
+		article.Title += " " + strings.Repeat("title ", 100)
+	}
+	assert.NoError(t, subscriber.Deliver(context.Background(), articles), "error should be nil")
+
+	messages := discordMessages(t, receiver)
+	count := 0
+	for _, message := range messages {
+		assert.LessOrEqual(t, len(message.Embeds), 10, "message should respect embed limit")
+		length := 0
+		for _, embed := range message.Embeds {
+			assert.LessOrEqual(t, utf8.RuneCountInString(embed.Title), 256, "title should be truncated")
+			length += utf8.RuneCountInString(embed.Title) + utf8.RuneCountInString(embed.Description) + utf8.RuneCountInString(embed.Footer.Text)
+		}
+		assert.LessOrEqual(t, length, 6000, "message should respect text limit")
+		count += len(message.Embeds)
+	}
+	assert.Greater(t, len(messages), 3, "articles should be split by text limit")
+	assert.Equal(t, 25, count, "all articles should be sent")
+}
+
+func TestDiscordSubscriber_Routes(t *testing.T) {
+	receiver := &webhookReceiver{}
+	subscriber := newDiscordSubscriber(t, receiver, func(config *subscribers.DiscordConfig) {
+		config.Webhooks = map[string]string{
+			"releases": "https://discord.com/api/webhooks/2/releases-token?thread_id=20",
+			"all":      "https://discord.com/api/webhooks/3/all-token",
+		}
+		config.Routes = map[string]string{"releases": "release|https://go.dev/blog/feed.atom"}
+	})
+
+	err := subscriber.Deliver(context.Background(), []*model.FeedArticle{
+		{Title: "Notes", Link: "https://example.com/notes", ResourceID: "https://example.com/feed"},
+		{Title: "Blog", Link: "https://go.dev/blog/1", ResourceID: "https://go.dev/blog/feed.atom"},
+		{Title: "v1.2.0", Link: "https://example.com/v1.2.0", ResourceID: "https://example.com/releases", Tags: []string{"release"}},
+	})
+	assert.NoError(t, err, "error should be nil")
+
+	titles := map[string][]string{}
+	for _, message := range discordMessages(t, receiver) {
+		for _, embed := range message.Embeds {
+			titles[message.Webhook+"#"+message.ThreadId] = append(titles[message.Webhook+"#"+message.ThreadId], embed.Title)
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		"2/releases-token#20": {"v1.2.0", "Blog"},
+		"3/all-token#":        {"v1.2.0", "Blog", "Notes"},
+		"1/default-token#":    {"Notes"},
+	}, titles, "default webhook should get articles matching no route")
+}
+
+func TestDiscordSubscriber_Forum(t *testing.T) {
+	receiver := &webhookReceiver{}
+	subscriber := newDiscordSubscriber(t, receiver, func(config *subscribers.DiscordConfig) {
+		config.Forum = true
+	})
+
+	articles := testArticles()
+	articles[0].Title += " " + strings.Repeat("long ", 50)
+	err := subscriber.Deliver(context.Background(), articles)
+	assert.NoError(t, err, "error should be nil")
+
+	messages := discordMessages(t, receiver)
+	assert.Len(t, messages, 2, "every article should start own post")
+	assert.Equal(t, "First", messages[0].ThreadName)
+	assert.LessOrEqual(t, utf8.RuneCountInString(messages[1].ThreadName), 100, "post name should be truncated")
+}
+
+func TestDiscordSubscriber_RateLimit(t *testing.T) {
+	articles := testArticles()[1:]
+
+	receiver := &webhookReceiver{statuses: []int{http.StatusTooManyRequests, http.StatusTooManyRequests}}
+	subscriber := newDiscordSubscriber(t, receiver, nil)
+	assert.NoError(t, subscriber.Deliver(context.Background(), articles), "rate limited request should be retried")
+	assert.Len(t, discordMessages(t, receiver), 1)
+
+	receiver = &webhookReceiver{statuses: []int{http.StatusBadRequest}}
+	subscriber = newDiscordSubscriber(t, receiver, nil)
+	assert.Error(t, subscriber.Deliver(context.Background(), articles), "batch should go back to outbox")
+	assert.Len(t, receiver.requests, 1, "other errors should not be retried in place")
+}
+
+func TestNewDiscordSubscribers_InstanceSettings(t *testing.T) {
+	t.Setenv("DISCORD_WEBHOOK_ID", "1")
+	t.Setenv("DISCORD_WEBHOOK_TOKEN", "default-token")
+	t.Setenv("SUBSCRIBER_RELEASES_DISCORD_COLOR", "ff0000")
+
+	_, err := subscribers.NewDiscordSubscribers(newLogger(t), &subscribers.DiscordConfig{},
+		&subscribers.Config{Instances: map[string]string{"releases": "discord"}})
+	assert.ErrorContains(t, err, "webhook id and token", "named instance should not use webhook of default instance")
+
+	t.Setenv("SUBSCRIBER_RELEASES_DISCORD_WEBHOOK_ID", "2")
+	t.Setenv("SUBSCRIBER_RELEASES_DISCORD_WEBHOOK_TOKEN", "releases-token")
+	instances, err := subscribers.NewDiscordSubscribers(newLogger(t), &subscribers.DiscordConfig{},
+		&subscribers.Config{Instances: map[string]string{"releases": "discord"}})
+	assert.NoError(t, err, "error should be nil")
+	assert.Len(t, instances, 1)
+}
+
+func TestDiscordConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		configure func(config *subscribers.DiscordConfig)
+		expect    string
+	}{
+		{name: "valid", configure: func(config *subscribers.DiscordConfig) {}},
+		{name: "webhooks only", configure: func(config *subscribers.DiscordConfig) {
+			config.WebhookId, config.WebhookToken = 0, ""
+			config.Webhooks = map[string]string{"news": "https://discord.com/api/webhooks/2/token"}
+		}},
+		{name: "invalid webhook", expect: "webhooks/<id>/<token>", configure: func(config *subscribers.DiscordConfig) {
+			config.Webhooks = map[string]string{"news": "https://discord.com/api/2/token"}
+		}},
+		{name: "unknown route", expect: "unknown webhook", configure: func(config *subscribers.DiscordConfig) {
+			config.Routes = map[string]string{"news": "release"}
+		}},
+		{name: "invalid color", expect: "color", configure: func(config *subscribers.DiscordConfig) {
+			config.Colors = map[string]string{"blue": "release"}
+		}},
+		{name: "forum thread", expect: "forum", configure: func(config *subscribers.DiscordConfig) {
+			config.Forum, config.ThreadId = true, 10
+		}},
+		{name: "unknown image", expect: "image", configure: func(config *subscribers.DiscordConfig) {
+			config.Image = "banner"
+		}},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			config := newDiscordConfig("https://discord.com/api/v10")
+			testCase.configure(config)
+
+			err := config.Validate()
+			if testCase.expect == "" {
+				assert.NoError(t, err, "error should be nil")
+				return
+			}
+			assert.ErrorContains(t, err, testCase.expect)
+		})
+	}
+}
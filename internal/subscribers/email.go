@@ -0,0 +1,459 @@
+package subscribers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"golang.org/x/net/html"
+	htmltemplate "html/template"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+const (
+	EmailType = "email"
+
+	emailSummaryLength  = 300
+	defaultEmailSubject = `{{if .Digest}}Feed digest{{else}}Feed{{end}}: {{.Count}} new {{if eq .Count 1}}article{{else}}articles{{end}}`
+)
+
+// hiddenTags html tags without visible text
+var hiddenTags = map[string]bool{"script": true, "style": true, "noscript": true, "head": true, "title": true}
+
+//go:embed templates/email.txt.tmpl templates/email.html.tmpl
+var emailTemplates embed.FS
+
+// emailData is passed to subject and body templates
+type emailData struct {
+	Subject   string
+	Recipient string
+	Digest    bool
+	Count     int
+	Groups    []emailGroup
+}
+
+// emailGroup articles of one resource
+type emailGroup struct {
+	ResourceId    string
+	ResourceTitle string
+	Articles      []emailArticle
+}
+
+type emailArticle struct {
+	Title     string
+	Link      string
+	Author    string
+	Image     string
+	Summary   string
+	Published time.Time
+	Tags      []string
+}
+
+// EmailSubscriber sends batches or digests of articles by SMTP, every recipient gets own email with own filters
+type EmailSubscriber struct {
+	delivery
+	name     string
+	config   *EmailConfig
+	logger   *logger.Logger
+	subject  *texttemplate.Template
+	text     *texttemplate.Template
+	html     *htmltemplate.Template
+	matchers map[string]*model.ArticleMatcher
+}
+
+// NewEmailSubscribers creates instances declared in SUBSCRIBERS and the default one configured by EMAIL_* variables
+func NewEmailSubscribers(logger *logger.Logger, defaultConfig *EmailConfig, config *Config) ([]Subscriber, error) {
+	instances, err := newInstances(config, EmailType, func(name string, settings *EmailConfig) (Subscriber, error) {
+		return NewEmailSubscriber(logger, name, settings)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if defaultConfig.Enabled() {
+		instance, err := NewEmailSubscriber(logger, EmailType, defaultConfig)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+func NewEmailSubscriber(logger *logger.Logger, name string, config *EmailConfig) (*EmailSubscriber, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	subject, err := texttemplate.New("subject").Parse(firstNonEmpty(config.Subject, defaultEmailSubject))
+	if err != nil {
+		return nil, fmt.Errorf("can not parse email subject template: %w", err)
+	}
+
+	textSource, err := readTemplate(config.TextTemplate, "templates/email.txt.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	text, err := texttemplate.New("text").Parse(textSource)
+	if err != nil {
+		return nil, fmt.Errorf("can not parse email text template: %w", err)
+	}
+
+	htmlSource, err := readTemplate(config.HtmlTemplate, "templates/email.html.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	htmlTemplate, err := htmltemplate.New("html").Parse(htmlSource)
+	if err != nil {
+		return nil, fmt.Errorf("can not parse email html template: %w", err)
+	}
+
+	matchers := map[string]*model.ArticleMatcher{}
+	for recipient, tags := range config.RecipientTags {
+		matcher := recipientMatcher(matchers, recipient)
+		matcher.Tags = storage.NormalizeTags(strings.Split(tags, "|"))
+	}
+	for recipient, keyword := range config.RecipientKeywords {
+		matcher := recipientMatcher(matchers, recipient)
+		matcher.Keyword = strings.ToLower(strings.TrimSpace(keyword))
+	}
+
+	return &EmailSubscriber{
+		name:     name,
+		logger:   logger,
+		config:   config,
+		subject:  subject,
+		text:     text,
+		html:     htmlTemplate,
+		delivery: newDelivery(config.Delivery, emailBatching),
+		matchers: matchers,
+	}, nil
+}
+
+func (s *EmailSubscriber) Name() string {
+	return s.name
+}
+
+func (s *EmailSubscriber) Subscribe(_ context.Context) error {
+	return nil
+}
+
+// Deliver sends one email to every recipient having matching articles
+func (s *EmailSubscriber) Deliver(ctx context.Context, events []*model.FeedArticle) error {
+	if len(events) == 0 {
+		return nil
+	}
+	events = oldestFirst(events)
+
+	for _, recipient := range s.config.To {
+		articles := events
+		if matcher, ok := s.matchers[recipientKey(recipient)]; ok {
+			articles = matcher.Filter(events)
+		}
+		if len(articles) == 0 {
+			continue
+		}
+
+		message, err := s.message(recipient, articles)
+		if err != nil {
+			return err
+		}
+		if err = s.send(ctx, recipient, message); err != nil {
+			return fmt.Errorf("can not send email to %s: %w", recipient, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *EmailSubscriber) Close() error {
+	return nil
+}
+
+// message renders multipart text and html email
+func (s *EmailSubscriber) message(recipient string, articles []*model.FeedArticle) ([]byte, error) {
+	data := &emailData{
+		Recipient: recipient,
+		Digest:    len(s.config.Delivery.Digest) > 0,
+		Count:     len(articles),
+		Groups:    groupByResource(articles),
+	}
+
+	var subject, text, body bytes.Buffer
+	if err := s.subject.Execute(&subject, data); err != nil {
+		return nil, fmt.Errorf("can not render email subject: %w", err)
+	}
+	data.Subject = strings.Join(strings.Fields(subject.String()), " ")
+	if err := s.text.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("can not render email text: %w", err)
+	}
+	if err := s.html.Execute(&body, data); err != nil {
+		return nil, fmt.Errorf("can not render email html: %w", err)
+	}
+
+	from, err := mail.ParseAddress(s.config.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := mail.ParseAddress(recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildEmail(from, to, data.Subject, text.String(), body.String())
+}
+
+// send delivers message by SMTP with configured security and auth
+func (s *EmailSubscriber) send(ctx context.Context, recipient string, message []byte) error {
+	from, err := mail.ParseAddress(s.config.From)
+	if err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(recipient)
+	if err != nil {
+		return err
+	}
+
+	address := net.JoinHostPort(s.config.SmtpHost, strconv.Itoa(s.config.SmtpPort))
+	tlsConfig := &tls.Config{ServerName: s.config.SmtpHost, InsecureSkipVerify: s.config.SmtpInsecureSkipVerify}
+	dialer := &net.Dialer{Timeout: s.config.SmtpTimeout}
+
+	var conn net.Conn
+	if s.config.SmtpSecurity == EmailTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	if s.config.SmtpTimeout > 0 {
+		if err = conn.SetDeadline(time.Now().Add(s.config.SmtpTimeout)); err != nil {
+			return err
+		}
+	}
+
+	client, err := smtp.NewClient(conn, s.config.SmtpHost)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if s.config.SmtpSecurity == EmailStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("smtp server %s does not support STARTTLS", address)
+		}
+		if err = client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	if s.config.SmtpUsername != "" {
+		if err = client.Auth(smtp.PlainAuth("", s.config.SmtpUsername, s.config.SmtpPassword, s.config.SmtpHost)); err != nil {
+			return err
+		}
+	}
+
+	if err = client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err = client.Rcpt(to.Address); err != nil {
+		return err
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = writer.Write(message); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+
+	if err = client.Quit(); err != nil {
+		s.logger.WarnContext(ctx, "Email sent but smtp session was not closed", slog.String("subscriber", s.name), slog.Any("error", err))
+	}
+
+	return nil
+}
+
+// buildEmail writes headers and quoted-printable text and html alternatives
+func buildEmail(from, to *mail.Address, subject, text, body string) ([]byte, error) {
+	var message bytes.Buffer
+	parts := multipart.NewWriter(&message)
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	headers := []string{
+		"From: " + from.String(),
+		"To: " + to.String(),
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Message-ID: <" + hex.EncodeToString(id) + "@" + domain + ">",
+		"MIME-Version: 1.0",
+		`Content-Type: multipart/alternative; boundary="` + parts.Boundary() + `"`,
+	}
+	var header bytes.Buffer
+	header.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{contentType: "text/plain; charset=utf-8", content: text},
+		{contentType: "text/html; charset=utf-8", content: body},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := quotedprintable.NewWriter(writer)
+		if _, err = encoder.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err = encoder.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	return append(header.Bytes(), message.Bytes()...), nil
+}
+
+// groupByResource keeps order of the first article of every resource
+func groupByResource(articles []*model.FeedArticle) []emailGroup {
+	groups := make([]emailGroup, 0)
+	indexes := map[string]int{}
+	for _, article := range articles {
+		index, ok := indexes[article.ResourceID]
+		if !ok {
+			index = len(groups)
+			indexes[article.ResourceID] = index
+			groups = append(groups, emailGroup{
+				ResourceId:    article.ResourceID,
+				ResourceTitle: firstNonEmpty(article.ResourceTitle, article.ResourceID),
+			})
+		}
+
+		groups[index].Articles = append(groups[index].Articles, emailArticle{
+			Title:     firstNonEmpty(article.Title, article.Link),
+			Link:      article.Link,
+			Author:    article.Author,
+			Image:     article.Image,
+			Summary:   plainText(article.Description, emailSummaryLength),
+			Published: article.Published,
+			Tags:      article.Tags,
+		})
+	}
+
+	return groups
+}
+
+// plainText returns visible text of html truncated to limit runes
+func plainText(description string, limit int) string {
+	var builder strings.Builder
+	skip := 0
+
+	tokenizer := html.NewTokenizer(strings.NewReader(description))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return cutText(strings.Join(strings.Fields(builder.String()), " "), limit)
+		case html.StartTagToken:
+			if name, _ := tokenizer.TagName(); hiddenTags[string(name)] {
+				skip++
+			}
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); hiddenTags[string(name)] && skip > 0 {
+				skip--
+			}
+		case html.TextToken:
+			if skip == 0 {
+				builder.Write(tokenizer.Text())
+				builder.WriteString(" ")
+			}
+		}
+	}
+}
+
+func cutText(text string, limit int) string {
+	cut := cutWords(text, limit)
+	if cut != text {
+		cut += "…"
+	}
+
+	return cut
+}
+
+func recipientMatcher(matchers map[string]*model.ArticleMatcher, recipient string) *model.ArticleMatcher {
+	key := recipientKey(recipient)
+	if matcher, ok := matchers[key]; ok {
+		return matcher
+	}
+
+	matcher := &model.ArticleMatcher{}
+	matchers[key] = matcher
+
+	return matcher
+}
+
+// recipientKey email address of recipient without display name
+func recipientKey(recipient string) string {
+	if address, err := mail.ParseAddress(recipient); err == nil {
+		recipient = address.Address
+	}
+
+	return strings.ToLower(strings.TrimSpace(recipient))
+}
+
+func readTemplate(path string, builtin string) (string, error) {
+	var (
+		source []byte
+		err    error
+	)
+	if path != "" {
+		source, err = os.ReadFile(path)
+	} else {
+		source, err = emailTemplates.ReadFile(builtin)
+	}
+	if err != nil {
+		return "", fmt.Errorf("can not read email template: %w", err)
+	}
+
+	return string(source), nil
+}
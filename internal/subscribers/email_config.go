@@ -0,0 +1,66 @@
+package subscribers
+
+import (
+	"fmt"
+	"github.com/sealbro/go-feed-me/pkg/notifier"
+	"net/mail"
+	"time"
+)
+
+const (
+	// EmailStartTLS upgrades plain connection by STARTTLS, usually port 587
+	EmailStartTLS = "starttls"
+	// EmailTLS connects with implicit TLS, usually port 465
+	EmailTLS = "tls"
+	// EmailPlain sends without encryption, only for local relays
+	EmailPlain = "none"
+)
+
+// emailBatching batching of email digests when batch size and time are not set
+var emailBatching = notifier.Batching{Size: 100, MaxWait: time.Hour}
+
+type EmailConfig struct {
+	SmtpHost     string `envconfig:"EMAIL_SMTP_HOST"`
+	SmtpPort     int    `envconfig:"EMAIL_SMTP_PORT" default:"587"`
+	SmtpSecurity string `envconfig:"EMAIL_SMTP_SECURITY" default:"starttls"`
+	SmtpUsername string `envconfig:"EMAIL_SMTP_USERNAME"`
+	SmtpPassword string `envconfig:"EMAIL_SMTP_PASSWORD"`
+	// SmtpInsecureSkipVerify accepts any server certificate, e.g. self-signed one of a local relay
+	SmtpInsecureSkipVerify bool          `envconfig:"EMAIL_SMTP_INSECURE_SKIP_VERIFY" default:"false"`
+	SmtpTimeout            time.Duration `envconfig:"EMAIL_SMTP_TIMEOUT" default:"30s"`
+	From                   string        `envconfig:"EMAIL_FROM"`
+	To                     []string      `envconfig:"EMAIL_TO"`
+	// Subject text/template of subject, empty uses default one
+	Subject string `envconfig:"EMAIL_SUBJECT"`
+	// TextTemplate and HtmlTemplate paths of text/template and html/template files overriding built-in bodies
+	TextTemplate string `envconfig:"EMAIL_TEXT_TEMPLATE"`
+	HtmlTemplate string `envconfig:"EMAIL_HTML_TEMPLATE"`
+	// RecipientTags sends recipient only articles having any of tags, e.g. bob@example.com:release|security
+	RecipientTags map[string]string `envconfig:"EMAIL_RECIPIENT_TAGS"`
+	// RecipientKeywords sends recipient only articles containing keyword, e.g. bob@example.com:kubernetes
+	RecipientKeywords map[string]string `envconfig:"EMAIL_RECIPIENT_KEYWORDS"`
+	Delivery          DeliveryConfig    `envconfig:"EMAIL"`
+}
+
+func (c *EmailConfig) Enabled() bool {
+	return c.SmtpHost != "" && c.From != "" && len(c.To) > 0
+}
+
+func (c *EmailConfig) Validate() error {
+	if !c.Enabled() {
+		return fmt.Errorf("email smtp host, sender and recipients are required")
+	}
+	if c.SmtpSecurity != EmailStartTLS && c.SmtpSecurity != EmailTLS && c.SmtpSecurity != EmailPlain {
+		return fmt.Errorf("unknown smtp security %q, expected %s, %s or %s", c.SmtpSecurity, EmailStartTLS, EmailTLS, EmailPlain)
+	}
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		return fmt.Errorf("invalid email sender %q: %w", c.From, err)
+	}
+	for _, to := range c.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid email recipient %q: %w", to, err)
+		}
+	}
+
+	return c.Delivery.Validate()
+}
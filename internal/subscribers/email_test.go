@@ -0,0 +1,352 @@
+package subscribers_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/subscribers"
+	"github.com/stretchr/testify/assert"
+)
+
+type smtpMessage struct {
+	From string
+	To   []string
+	Auth string
+	TLS  bool
+	Data []byte
+}
+
+// smtpServer in-process SMTP stand-in supporting STARTTLS, implicit TLS and AUTH PLAIN
+type smtpServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	startTLS  bool
+	implicit  bool
+
+	m        sync.Mutex
+	messages []smtpMessage
+}
+
+func newSmtpServer(t *testing.T, startTLS bool, implicit bool) *smtpServer {
+	server := &smtpServer{tlsConfig: newTlsConfig(t), startTLS: startTLS, implicit: implicit}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err, "error should be nil")
+	if implicit {
+		listener = tls.NewListener(listener, server.tlsConfig)
+	}
+	server.listener = listener
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	return server
+}
+
+func (s *smtpServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpServer) received() []smtpMessage {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return append([]smtpMessage(nil), s.messages...)
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	message := smtpMessage{TLS: s.implicit}
+	text := textproto.NewConn(conn)
+	_ = text.PrintfLine("220 localhost ESMTP stand-in")
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command, argument, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(command) {
+		case "EHLO", "HELO":
+			extensions := []string{"250-localhost", "250-AUTH PLAIN"}
+			if s.startTLS && !message.TLS {
+				extensions = append(extensions, "250-STARTTLS")
+			}
+			extensions = append(extensions, "250 8BITMIME")
+			for _, extension := range extensions {
+				_ = text.PrintfLine("%s", extension)
+			}
+		case "STARTTLS":
+			_ = text.PrintfLine("220 ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, text, message.TLS = tlsConn, textproto.NewConn(tlsConn), true
+		case "AUTH":
+			_, credentials, _ := strings.Cut(argument, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(credentials)
+			message.Auth = string(decoded)
+			_ = text.PrintfLine("235 authenticated")
+		case "MAIL":
+			from, _, _ := strings.Cut(strings.TrimPrefix(argument, "FROM:"), " ")
+			message.From = strings.Trim(from, "<>")
+			_ = text.PrintfLine("250 ok")
+		case "RCPT":
+			message.To = append(message.To, strings.Trim(strings.TrimPrefix(argument, "TO:"), "<>"))
+			_ = text.PrintfLine("250 ok")
+		case "DATA":
+			_ = text.PrintfLine("354 send data")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			message.Data = data
+			s.m.Lock()
+			s.messages = append(s.messages, message)
+			s.m.Unlock()
+			message = smtpMessage{TLS: message.TLS, Auth: message.Auth}
+			_ = text.PrintfLine("250 queued")
+		case "QUIT":
+			_ = text.PrintfLine("221 bye")
+			return
+		default:
+			_ = text.PrintfLine("250 ok")
+		}
+	}
+}
+
+func newTlsConfig(t *testing.T) *tls.Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err, "error should be nil")
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err, "error should be nil")
+
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{certificate}, PrivateKey: key}}}
+}
+
+func newEmailConfig(server *smtpServer, security string) *subscribers.EmailConfig {
+	return &subscribers.EmailConfig{
+		SmtpHost:               "127.0.0.1",
+		SmtpPort:               server.port(),
+		SmtpSecurity:           security,
+		SmtpUsername:           "feed",
+		SmtpPassword:           "secret",
+		SmtpInsecureSkipVerify: true,
+		SmtpTimeout:            5 * time.Second,
+		From:                   "Feed <feed@example.com>",
+		To:                     []string{"alice@example.com", "Bob <bob@example.com>"},
+	}
+}
+
+// emailParts returns decoded subject and bodies by content type
+func emailParts(t *testing.T, data []byte) (string, map[string]string) {
+	message, err := mail.ReadMessage(strings.NewReader(string(data)))
+	assert.NoError(t, err, "error should be nil")
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	assert.NoError(t, err, "error should be nil")
+
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	parts := map[string]string{}
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err, "error should be nil")
+
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		body, err := io.ReadAll(part)
+		assert.NoError(t, err, "error should be nil")
+		parts[contentType] = string(body)
+	}
+
+	return subject, parts
+}
+
+func emailArticles() []*model.FeedArticle {
+	return []*model.FeedArticle{
+		{
+			ResourceID:    "https://go.dev/blog/feed.atom",
+			ResourceTitle: "The Go Blog",
+			Title:         "Go 1.23 is released",
+			Link:          "https://go.dev/blog/go1.23",
+			Tags:          []string{"release"},
+		},
+		{
+			ResourceID:    "https://example.com/feed",
+			ResourceTitle: "Example & Co",
+			Title:         "Weekly notes",
+			Link:          "https://example.com/notes",
+			Author:        "Jane",
+			Description:   `<p>Kubernetes <b>tips</b></p><script>alert(1)</script>`,
+		},
+		{
+			ResourceID:    "https://go.dev/blog/feed.atom",
+			ResourceTitle: "The Go Blog",
+			Title:         "Range over func",
+			Link:          "https://go.dev/blog/range-functions",
+		},
+	}
+}
+
+func TestEmailSubscriber_Deliver(t *testing.T) {
+	server := newSmtpServer(t, false, false)
+	config := newEmailConfig(server, subscribers.EmailPlain)
+	config.RecipientTags = map[string]string{"bob@example.com": "release|security"}
+
+	subscriber, err := subscribers.NewEmailSubscriber(newLogger(t), "email", config)
+	assert.NoError(t, err, "error should be nil")
+	assert.NoError(t, subscriber.Subscribe(context.Background()), "error should be nil")
+
+	err = subscriber.Deliver(context.Background(), emailArticles())
+	assert.NoError(t, err, "error should be nil")
+
+	messages := server.received()
+	assert.Len(t, messages, 2, "every recipient should get own email")
+	assert.Equal(t, "feed@example.com", messages[0].From)
+	assert.Equal(t, []string{"alice@example.com"}, messages[0].To)
+	assert.Equal(t, "\x00feed\x00secret", messages[0].Auth)
+
+	subject, parts := emailParts(t, messages[0].Data)
+	assert.Equal(t, "Feed: 3 new articles", subject)
+
+	text := parts["text/plain"]
+	assert.Equal(t, 1, strings.Count(text, "The Go Blog"), "articles should be grouped by resource")
+	assert.Less(t, strings.Index(text, "Range over func"), strings.Index(text, "Example & Co"), "groups should keep order of the first article")
+	assert.Contains(t, text, "Kubernetes tips")
+	assert.NotContains(t, text, "alert")
+
+	body := parts["text/html"]
+	assert.Contains(t, body, `<a href="https://go.dev/blog/go1.23"`)
+	assert.Contains(t, body, "Example &amp; Co", "html should be escaped")
+
+	assert.Equal(t, []string{"bob@example.com"}, messages[1].To)
+	subject, parts = emailParts(t, messages[1].Data)
+	assert.Equal(t, "Feed: 1 new article", subject, "recipient filter should be applied")
+	assert.Contains(t, parts["text/plain"], "Go 1.23 is released")
+	assert.NotContains(t, parts["text/plain"], "Weekly notes")
+}
+
+func TestEmailSubscriber_Security(t *testing.T) {
+	testCases := []struct {
+		name     string
+		security string
+		startTLS bool
+		implicit bool
+		expect   string
+	}{
+		{name: "starttls", security: subscribers.EmailStartTLS, startTLS: true},
+		{name: "implicit tls", security: subscribers.EmailTLS, implicit: true},
+		{name: "starttls not supported", security: subscribers.EmailStartTLS, expect: "STARTTLS"},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			server := newSmtpServer(t, testCase.startTLS, testCase.implicit)
+			config := newEmailConfig(server, testCase.security)
+			config.To = []string{"alice@example.com"}
+			config.Delivery.Digest = []string{"Mon 09:00"}
+
+			subscriber, err := subscribers.NewEmailSubscriber(newLogger(t), "email", config)
+			assert.NoError(t, err, "error should be nil")
+
+			err = subscriber.Deliver(context.Background(), emailArticles())
+			if testCase.expect != "" {
+				assert.ErrorContains(t, err, testCase.expect)
+				assert.Empty(t, server.received(), "email should not be sent without encryption")
+				return
+			}
+
+			assert.NoError(t, err, "error should be nil")
+			messages := server.received()
+			assert.Len(t, messages, 1)
+			assert.True(t, messages[0].TLS, "email should be sent over TLS")
+
+			subject, _ := emailParts(t, messages[0].Data)
+			assert.Equal(t, "Feed digest: 3 new articles", subject)
+		})
+	}
+}
+
+func TestEmailConfig_Validate(t *testing.T) {
+	config := &subscribers.EmailConfig{
+		SmtpHost:     "smtp.example.com",
+		SmtpPort:     587,
+		SmtpSecurity: subscribers.EmailStartTLS,
+		From:         "feed@example.com",
+		To:           []string{"alice@example.com"},
+	}
+	assert.NoError(t, config.Validate(), "error should be nil")
+
+	config.SmtpSecurity = "ssl"
+	assert.Error(t, config.Validate(), "unknown security should be rejected")
+
+	config.SmtpSecurity = subscribers.EmailTLS
+	config.To = []string{"not an address"}
+	assert.Error(t, config.Validate(), "invalid recipient should be rejected")
+
+	config.To = []string{"alice@example.com"}
+	config.Delivery.Digest = []string{"Someday 09:00"}
+	assert.Error(t, config.Validate(), "invalid digest should be rejected")
+
+	config.Delivery.Digest = nil
+	config.Subject = "{{.Count"
+	_, err := subscribers.NewEmailSubscriber(newLogger(t), "email", config)
+	assert.ErrorContains(t, err, "subject", "invalid subject template should be rejected")
+}
+
+func TestNewEmailSubscribers_InvalidConfig(t *testing.T) {
+	t.Setenv("SUBSCRIBER_OPS_EMAIL_SMTP_HOST", "smtp.example.com")
+	t.Setenv("SUBSCRIBER_OPS_EMAIL_FROM", "feed@example.com")
+	t.Setenv("SUBSCRIBER_OPS_EMAIL_TO", "alice@example.com")
+	t.Setenv("SUBSCRIBER_OPS_EMAIL_SMTP_SECURITY", "ssl")
+
+	_, err := subscribers.NewEmailSubscribers(newLogger(t), &subscribers.EmailConfig{}, &subscribers.Config{Instances: map[string]string{"ops": "email"}})
+	assert.ErrorContains(t, err, "unknown smtp security", "invalid config should fail on startup")
+}
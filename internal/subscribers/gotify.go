@@ -0,0 +1,108 @@
+package subscribers
+
+import (
+	"context"
+	"fmt"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"strings"
+)
+
+const GotifyType = "gotify"
+
+const (
+	gotifyMinPriority = 0
+	gotifyMaxPriority = 10
+)
+
+// gotifyMessage message of application, see https://gotify.net/api-docs#/message/createMessage
+type gotifyMessage struct {
+	Title    string         `json:"title"`
+	Message  string         `json:"message"`
+	Priority int            `json:"priority"`
+	Extras   map[string]any `json:"extras,omitempty"`
+}
+
+// GotifySubscriber sends every article as message of gotify application
+type GotifySubscriber struct {
+	delivery
+	name       string
+	config     *GotifyConfig
+	webhook    *chatWebhook
+	priorities *pushPriorities
+}
+
+// NewGotifySubscribers creates instances declared in SUBSCRIBERS and the default one configured by GOTIFY_* variables
+func NewGotifySubscribers(logger *logger.Logger, defaultConfig *GotifyConfig, config *Config) ([]Subscriber, error) {
+	instances, err := newInstances(config, GotifyType, func(name string, settings *GotifyConfig) (Subscriber, error) {
+		return NewGotifySubscriber(logger, name, settings)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if defaultConfig.Enabled() {
+		instance, err := NewGotifySubscriber(logger, GotifyType, defaultConfig)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+func NewGotifySubscriber(logger *logger.Logger, name string, config *GotifyConfig) (*GotifySubscriber, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	priorities, err := newPushPriorities(config.Priority, config.Priorities, gotifyMinPriority, gotifyMaxPriority)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gotify priority: %w", err)
+	}
+
+	webhook := newChatWebhook(logger, GotifyType, name, strings.TrimRight(config.Url, "/")+"/message", config.MaxRetries, config.MaxRetryAfter)
+	webhook.headers = map[string]string{"X-Gotify-Key": config.AppToken}
+
+	return &GotifySubscriber{
+		name:       name,
+		config:     config,
+		delivery:   newDelivery(config.Delivery, defaultBatching),
+		webhook:    webhook,
+		priorities: priorities,
+	}, nil
+}
+
+func (s *GotifySubscriber) Name() string {
+	return s.name
+}
+
+func (s *GotifySubscriber) Subscribe(_ context.Context) error {
+	return nil
+}
+
+func (s *GotifySubscriber) Deliver(ctx context.Context, events []*model.FeedArticle) error {
+	return sendPushes(ctx, s.webhook, events, s.message)
+}
+
+// message sends article with priority by its rules, click of notification opens article
+func (s *GotifySubscriber) message(event *model.FeedArticle, article *notification) any {
+	message := gotifyMessage{
+		Title:    article.Title,
+		Message:  pushMessage(article, pushMessageLength),
+		Priority: s.priorities.priority(event),
+	}
+	if article.Link != "" {
+		message.Extras = map[string]any{
+			"client::notification": map[string]any{"click": map[string]string{"url": article.Link}},
+		}
+	}
+
+	return message
+}
+
+func (s *GotifySubscriber) Close() error {
+	s.webhook.close()
+	return nil
+}
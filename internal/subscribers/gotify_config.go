@@ -0,0 +1,38 @@
+package subscribers
+
+import (
+	"fmt"
+	"time"
+)
+
+type GotifyConfig struct {
+	// Url gotify server url
+	Url      string `envconfig:"GOTIFY_URL"`
+	AppToken string `envconfig:"GOTIFY_APP_TOKEN"`
+	Priority int    `envconfig:"GOTIFY_PRIORITY" default:"5"`
+	// Priorities priority by tags or resource urls, e.g. 8:release|security,6:https://go.dev/blog/feed.atom
+	Priorities map[string]string `envconfig:"GOTIFY_PRIORITIES"`
+	Delivery   DeliveryConfig    `envconfig:"GOTIFY"`
+	// MaxRetries how many times 429 responses are retried in place before batch goes back to outbox
+	MaxRetries int `envconfig:"GOTIFY_MAX_RETRIES" default:"3"`
+	// MaxRetryAfter upper bound of waiting requested by Retry-After header
+	MaxRetryAfter time.Duration `envconfig:"GOTIFY_MAX_RETRY_AFTER" default:"1m"`
+}
+
+func (c *GotifyConfig) Enabled() bool {
+	return c.Url != "" && c.AppToken != ""
+}
+
+func (c *GotifyConfig) Validate() error {
+	if !c.Enabled() {
+		return fmt.Errorf("gotify url and app token are required")
+	}
+	if !httpUrl(c.Url) {
+		return fmt.Errorf("gotify url should be http url")
+	}
+	if _, err := newPushPriorities(c.Priority, c.Priorities, gotifyMinPriority, gotifyMaxPriority); err != nil {
+		return fmt.Errorf("invalid gotify priority: %w", err)
+	}
+
+	return c.Delivery.Validate()
+}
@@ -0,0 +1,64 @@
+package subscribers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sealbro/go-feed-me/internal/subscribers"
+	"github.com/stretchr/testify/assert"
+)
+
+type gotifyMessage struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+	Extras   struct {
+		Notification struct {
+			Click struct {
+				Url string `json:"url"`
+			} `json:"click"`
+		} `json:"client::notification"`
+	} `json:"extras"`
+}
+
+func TestGotifySubscriber_Deliver(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{http.StatusTooManyRequests}}
+	serverUrl := newServer(t, receiver)
+
+	subscriber, err := subscribers.NewGotifySubscriber(newLogger(t), "gotify", &subscribers.GotifyConfig{
+		Url:           serverUrl + "/",
+		AppToken:      "app-token",
+		Priority:      4,
+		Priorities:    map[string]string{"8": "https://github.com/golang/go/releases.atom"},
+		MaxRetries:    1,
+		MaxRetryAfter: 10 * time.Millisecond,
+	})
+	assert.NoError(t, err, "error should be nil")
+	assert.NoError(t, subscriber.Subscribe(context.Background()), "error should be nil")
+
+	assert.NoError(t, subscriber.Deliver(context.Background(), pushArticles()[1:]), "rate limited request should be retried")
+	assert.Len(t, receiver.requests, 2, "rate limited request should be repeated")
+
+	request := receiver.requests[1]
+	assert.Equal(t, "/message", request.Path)
+	assert.Equal(t, "app-token", request.Header.Get("X-Gotify-Key"))
+
+	message := gotifyMessage{}
+	assert.NoError(t, json.Unmarshal(request.Body, &message), "error should be nil")
+	assert.Equal(t, "go1.23.1", message.Title)
+	assert.Equal(t, 8, message.Priority, "priority of resource should be used")
+	assert.Equal(t, "https://github.com/golang/go/releases/tag/go1.23.1", message.Extras.Notification.Click.Url)
+}
+
+func TestNewGotifySubscriber(t *testing.T) {
+	_, err := subscribers.NewGotifySubscriber(newLogger(t), "gotify", &subscribers.GotifyConfig{
+		Url:        "https://gotify.example.com",
+		AppToken:   "app-token",
+		Priority:   5,
+		Priorities: map[string]string{"11": "release"},
+	})
+	assert.Error(t, err, "priority out of range should be rejected")
+}
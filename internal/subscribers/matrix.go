@@ -0,0 +1,345 @@
+package subscribers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MatrixType = "matrix"
+
+	matrixHtmlFormat        = "org.matrix.custom.html"
+	matrixDescriptionLength = 1000
+)
+
+type matrixMessage struct {
+	MsgType       string          `json:"msgtype"`
+	Body          string          `json:"body"`
+	Format        string          `json:"format"`
+	FormattedBody string          `json:"formatted_body"`
+	RelatesTo     *matrixRelation `json:"m.relates_to,omitempty"`
+}
+
+// matrixRelation thread relation with reply fallback for clients without threads
+type matrixRelation struct {
+	RelType       string             `json:"rel_type"`
+	EventId       string             `json:"event_id"`
+	IsFallingBack bool               `json:"is_falling_back"`
+	InReplyTo     *matrixReplyTarget `json:"m.in_reply_to,omitempty"`
+}
+
+type matrixReplyTarget struct {
+	EventId string `json:"event_id"`
+}
+
+type matrixResponse struct {
+	EventId      string `json:"event_id"`
+	ErrCode      string `json:"errcode"`
+	Error        string `json:"error"`
+	RetryAfterMs int64  `json:"retry_after_ms"`
+}
+
+// MatrixError error returned by client-server API
+type MatrixError struct {
+	Code        int
+	ErrCode     string
+	Description string
+	RetryAfter  time.Duration
+}
+
+func (e *MatrixError) Error() string {
+	return fmt.Sprintf("matrix responded %d %s: %s", e.Code, e.ErrCode, e.Description)
+}
+
+// MatrixSubscriber delivers outbox events to matrix rooms by client-server API
+type MatrixSubscriber struct {
+	delivery
+	name      string
+	config    *MatrixConfig
+	logger    *logger.Logger
+	client    *http.Client
+	templates *messageTemplates
+	threads   *storage.MatrixThreadRepository
+}
+
+// NewMatrixSubscribers creates instances declared in SUBSCRIBERS and the default one configured by MATRIX_* variables
+func NewMatrixSubscribers(logger *logger.Logger, defaultConfig *MatrixConfig, config *Config, threads *storage.MatrixThreadRepository) ([]Subscriber, error) {
+	instances, err := newInstances(config, MatrixType, func(name string, settings *MatrixConfig) (Subscriber, error) {
+		return NewMatrixSubscriber(logger, name, settings, threads)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if defaultConfig.Enabled() {
+		instance, err := NewMatrixSubscriber(logger, MatrixType, defaultConfig, threads)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+// NewMatrixSubscriber creates subscriber, threads repository keeps root events of threads when MATRIX_THREADS is set
+func NewMatrixSubscriber(logger *logger.Logger, name string, config *MatrixConfig, threads *storage.MatrixThreadRepository) (*MatrixSubscriber, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.Threads && threads == nil {
+		return nil, fmt.Errorf("matrix threads require thread repository")
+	}
+
+	templates, err := newMessageTemplates(config.Template, config.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MatrixSubscriber{
+		name:      name,
+		logger:    logger,
+		config:    config,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		templates: templates,
+		delivery:  newDelivery(config.Delivery, defaultBatching),
+		threads:   threads,
+	}, nil
+}
+
+func (s *MatrixSubscriber) Name() string {
+	return s.name
+}
+
+func (s *MatrixSubscriber) Subscribe(_ context.Context) error {
+	return nil
+}
+
+// Deliver sends every article as message to every room,
+// transaction ids are derived from articles, so homeserver drops messages repeated by retry
+func (s *MatrixSubscriber) Deliver(ctx context.Context, events []*model.FeedArticle) error {
+	if len(events) == 0 {
+		return nil
+	}
+	articles, err := s.templates.notifications(events)
+	if err != nil {
+		return err
+	}
+
+	for _, roomId := range s.config.RoomIds {
+		for _, article := range articles {
+			message := s.message(article)
+			if s.config.Threads {
+				root, err := s.thread(ctx, roomId, article)
+				if err != nil {
+					return err
+				}
+				message.RelatesTo = &matrixRelation{
+					RelType:       "m.thread",
+					EventId:       root,
+					IsFallingBack: true,
+					InReplyTo:     &matrixReplyTarget{EventId: root},
+				}
+			}
+
+			if _, err := s.send(ctx, roomId, s.transactionId(roomId, "article", firstNonEmpty(article.Link, article.Title)), message); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Render returns message of article as it is sent
+func (s *MatrixSubscriber) Render(_ context.Context, event *model.FeedArticle) (string, error) {
+	article, err := s.templates.notification(event)
+	if err != nil {
+		return "", err
+	}
+
+	return renderPayload(s.message(article))
+}
+
+func (s *MatrixSubscriber) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// thread returns root event of resource thread in room, root is posted when thread is not started yet
+func (s *MatrixSubscriber) thread(ctx context.Context, roomId string, article *notification) (string, error) {
+	resourceId := firstNonEmpty(article.ResourceId, article.Resource)
+	root, err := s.threads.MatrixThread(ctx, s.name, roomId, resourceId)
+	if err != nil || root != "" {
+		return root, err
+	}
+
+	title := telegramEscaper.Replace(article.Resource)
+	if httpUrl(article.ResourceId) {
+		title = `<a href="` + telegramAttributeEscaper.Replace(article.ResourceId) + `">` + title + "</a>"
+	}
+	message := matrixMessage{
+		MsgType:       s.config.MsgType,
+		Body:          article.Resource,
+		Format:        matrixHtmlFormat,
+		FormattedBody: "<b>" + title + "</b>",
+	}
+
+	root, err = s.send(ctx, roomId, s.transactionId(roomId, "thread", resourceId), message)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.threads.AddMatrixThread(ctx, &storage.MatrixThread{
+		Subscriber: s.name,
+		RoomId:     roomId,
+		ResourceId: resourceId,
+		EventId:    root,
+	})
+
+	return root, err
+}
+
+// message renders title link, resource with author and description, plain body is markdown
+func (s *MatrixSubscriber) message(article *notification) matrixMessage {
+	title := "<b>" + telegramEscaper.Replace(article.Title) + "</b>"
+	body := article.Title
+	if article.Link != "" {
+		title = `<b><a href="` + telegramAttributeEscaper.Replace(article.Link) + `">` + telegramEscaper.Replace(article.Title) + "</a></b>"
+		body = "[" + article.Title + "](" + article.Link + ")"
+	}
+
+	formatted := []string{title}
+	plain := []string{body}
+
+	if source := article.Source(); source != "" {
+		formatted = append(formatted, "<i>"+telegramEscaper.Replace(source)+"</i>")
+		plain = append(plain, source)
+	}
+
+	if description := article.HTML(matrixDescriptionLength); description != "" {
+		formatted = append(formatted, "", matrixLineBreaks(description))
+		plain = append(plain, "", s.plainDescription(article))
+	}
+
+	return matrixMessage{
+		MsgType:       s.config.MsgType,
+		Body:          strings.Join(plain, "\n"),
+		Format:        matrixHtmlFormat,
+		FormattedBody: strings.Join(formatted, "<br>"),
+	}
+}
+
+// plainDescription markdown of description, text rendered by template is html and becomes plain text
+func (s *MatrixSubscriber) plainDescription(article *notification) string {
+	if article.hasText {
+		return plainText(article.Text, matrixDescriptionLength)
+	}
+
+	return article.Markdown(matrixDescriptionLength)
+}
+
+// transactionId stable id of message, homeserver returns the same event for repeated transaction
+func (s *MatrixSubscriber) transactionId(roomId string, kind string, key string) string {
+	hash := sha256.Sum256([]byte(strings.Join([]string{s.name, roomId, kind, key}, "\x00")))
+
+	return "gfm" + hex.EncodeToString(hash[:16])
+}
+
+// send puts message to room and returns event id, rate limited requests are retried after retry_after_ms delay
+func (s *MatrixSubscriber) send(ctx context.Context, roomId string, transactionId string, message matrixMessage) (string, error) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+
+	for attempt := 0; ; attempt++ {
+		eventId, err := s.put(ctx, roomId, transactionId, body)
+
+		var matrixError *MatrixError
+		if !errors.As(err, &matrixError) || matrixError.Code != http.StatusTooManyRequests || attempt >= s.config.MaxRetries {
+			return eventId, err
+		}
+
+		retryAfter := min(matrixError.RetryAfter, s.config.MaxRetryAfter)
+		s.logger.WarnContext(ctx, "Matrix rate limited bot", slog.String("subscriber", s.name), slog.Duration("retry_after", retryAfter))
+
+		if err := sleep(ctx, retryAfter); err != nil {
+			return "", err
+		}
+	}
+}
+
+func (s *MatrixSubscriber) put(ctx context.Context, roomId string, transactionId string, body []byte) (string, error) {
+	target := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		strings.TrimRight(s.config.HomeserverUrl, "/"), url.PathEscape(roomId), url.PathEscape(transactionId))
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer "+s.config.AccessToken)
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("matrix send to %s failed: %w", roomId, errors.Unwrap(err))
+	}
+	defer response.Body.Close()
+
+	result := matrixResponse{}
+	responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 64*1024))
+	_ = json.Unmarshal(responseBody, &result)
+	if response.StatusCode >= 200 && response.StatusCode < 300 && result.EventId != "" {
+		return result.EventId, nil
+	}
+
+	matrixError := &MatrixError{Code: response.StatusCode, ErrCode: result.ErrCode, Description: result.Error, RetryAfter: time.Second}
+	if matrixError.Description == "" {
+		matrixError.Description = strings.TrimSpace(string(responseBody))
+	}
+	if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		matrixError.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	if result.RetryAfterMs > 0 {
+		matrixError.RetryAfter = time.Duration(result.RetryAfterMs) * time.Millisecond
+	}
+
+	return "", matrixError
+}
+
+// matrixLineBreaks replaces new lines by br tags outside of pre blocks, matrix clients collapse them in html
+func matrixLineBreaks(text string) string {
+	var builder strings.Builder
+	for {
+		start := strings.Index(text, "<pre>")
+		if start < 0 {
+			builder.WriteString(strings.ReplaceAll(text, "\n", "<br>"))
+			return builder.String()
+		}
+		end := strings.Index(text[start:], "</pre>")
+		if end < 0 {
+			end = len(text) - start
+		} else {
+			end += len("</pre>")
+		}
+
+		builder.WriteString(strings.ReplaceAll(text[:start], "\n", "<br>"))
+		builder.WriteString(text[start : start+end])
+		text = text[start+end:]
+	}
+}
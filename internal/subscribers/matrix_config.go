@@ -0,0 +1,50 @@
+package subscribers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type MatrixConfig struct {
+	HomeserverUrl string `envconfig:"MATRIX_HOMESERVER_URL" default:"https://matrix.org"`
+	AccessToken   string `envconfig:"MATRIX_ACCESS_TOKEN"`
+	// RoomIds internal ids of rooms like !abc:example.org, the bot should be joined to them
+	RoomIds []string `envconfig:"MATRIX_ROOM_IDS"`
+	// MsgType m.notice marks messages as sent by bot, m.text as regular ones
+	MsgType string `envconfig:"MATRIX_MSGTYPE" default:"m.notice"`
+	// Threads posts articles of every resource as replies to thread started by resource title
+	Threads  bool           `envconfig:"MATRIX_THREADS" default:"false"`
+	Delivery DeliveryConfig `envconfig:"MATRIX"`
+	// Template path of text/template rendering html of message, optional "title" and "footer" templates replace title and source
+	Template string `envconfig:"MATRIX_TEMPLATE"`
+	// Templates paths of templates by tags or resource urls, e.g. /etc/feed/releases.tmpl:release|https://go.dev/blog/feed.atom
+	Templates map[string]string `envconfig:"MATRIX_TEMPLATES"`
+	// MaxRetries how many times rate limited requests are retried in place before batch goes back to outbox
+	MaxRetries int `envconfig:"MATRIX_MAX_RETRIES" default:"3"`
+	// MaxRetryAfter upper bound of waiting requested by retry_after_ms
+	MaxRetryAfter time.Duration `envconfig:"MATRIX_MAX_RETRY_AFTER" default:"1m"`
+}
+
+func (c *MatrixConfig) Enabled() bool {
+	return c.AccessToken != "" && len(c.RoomIds) > 0
+}
+
+func (c *MatrixConfig) Validate() error {
+	if !c.Enabled() {
+		return fmt.Errorf("matrix access token and room ids are required")
+	}
+	if !httpUrl(c.HomeserverUrl) {
+		return fmt.Errorf("matrix homeserver url should be http url")
+	}
+	for _, roomId := range c.RoomIds {
+		if !strings.HasPrefix(roomId, "!") || !strings.Contains(roomId, ":") {
+			return fmt.Errorf("matrix room id %q should look like !id:server", roomId)
+		}
+	}
+	if c.MsgType != "m.notice" && c.MsgType != "m.text" {
+		return fmt.Errorf("unknown matrix msgtype %q, expected m.notice or m.text", c.MsgType)
+	}
+
+	return c.Delivery.Validate()
+}
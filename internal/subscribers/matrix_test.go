@@ -0,0 +1,221 @@
+package subscribers_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/internal/subscribers"
+	"github.com/stretchr/testify/assert"
+)
+
+type matrixEvent struct {
+	RoomId        string
+	EventId       string
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format"`
+	FormattedBody string `json:"formatted_body"`
+	RelatesTo     *struct {
+		RelType string `json:"rel_type"`
+		EventId string `json:"event_id"`
+	} `json:"m.relates_to"`
+}
+
+// matrixHomeserver stand-in of client-server API, repeated transactions return the same event like homeserver does
+type matrixHomeserver struct {
+	m            sync.Mutex
+	rateLimited  int
+	requests     int
+	transactions map[string]string
+	events       []matrixEvent
+}
+
+func (h *matrixHomeserver) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.requests++
+
+	if request.Method != http.MethodPut || request.Header.Get("Authorization") != "Bearer secret" {
+		writer.WriteHeader(http.StatusUnauthorized)
+		_, _ = writer.Write([]byte(`{"errcode":"M_UNKNOWN_TOKEN","error":"Invalid access token"}`))
+		return
+	}
+	if h.rateLimited > 0 {
+		h.rateLimited--
+		writer.WriteHeader(http.StatusTooManyRequests)
+		_, _ = writer.Write([]byte(`{"errcode":"M_LIMIT_EXCEEDED","error":"Too many requests","retry_after_ms":1}`))
+		return
+	}
+
+	var roomId, transactionId string
+	if _, err := fmt.Sscanf(strings.ReplaceAll(request.URL.Path, "/", " "), " _matrix client v3 rooms %s send m.room.message %s", &roomId, &transactionId); err != nil {
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	key := roomId + "/" + transactionId
+	if h.transactions == nil {
+		h.transactions = map[string]string{}
+	}
+	eventId, ok := h.transactions[key]
+	if !ok {
+		event := matrixEvent{RoomId: roomId, EventId: fmt.Sprintf("$event%d", len(h.events))}
+		_ = json.NewDecoder(request.Body).Decode(&event)
+		h.events = append(h.events, event)
+		h.transactions[key] = event.EventId
+		eventId = event.EventId
+	}
+
+	_, _ = writer.Write([]byte(`{"event_id":"` + eventId + `"}`))
+}
+
+func newMatrixThreadRepository(t *testing.T) *storage.MatrixThreadRepository {
+	repository, err := storage.NewMatrixThreadRepository(newDatabase(t))
+	assert.NoError(t, err, "error should be nil")
+
+	return repository
+}
+
+func newMatrixConfig(url string) *subscribers.MatrixConfig {
+	return &subscribers.MatrixConfig{
+		HomeserverUrl: url,
+		AccessToken:   "secret",
+		RoomIds:       []string{"!news:example.org"},
+		MsgType:       "m.notice",
+		MaxRetries:    2,
+		MaxRetryAfter: time.Second,
+	}
+}
+
+func matrixArticles() []*model.FeedArticle {
+	return []*model.FeedArticle{
+		{
+			ResourceID:    "https://example.com/feed",
+			ResourceTitle: "Example blog",
+			Title:         "Second",
+			Link:          "https://example.com/2",
+		},
+		{
+			ResourceID:    "https://go.dev/blog/feed.atom",
+			ResourceTitle: "The Go Blog",
+			Title:         "First <release>",
+			Link:          "https://go.dev/blog/1",
+			Author:        "Jane",
+			Description:   "<p>Line one<br>line <b>two</b></p><pre>code\nblock</pre>",
+		},
+	}
+}
+
+func TestMatrixSubscriber_Deliver(t *testing.T) {
+	homeserver := &matrixHomeserver{}
+	serverUrl := newServer(t, homeserver)
+
+	subscriber, err := subscribers.NewMatrixSubscriber(newLogger(t), "matrix", newMatrixConfig(serverUrl), nil)
+	assert.NoError(t, err, "error should be nil")
+	assert.NoError(t, subscriber.Subscribe(context.Background()), "error should be nil")
+
+	assert.NoError(t, subscriber.Deliver(context.Background(), matrixArticles()), "error should be nil")
+	assert.Len(t, homeserver.events, 2)
+
+	first := homeserver.events[0]
+	assert.Equal(t, "!news:example.org", first.RoomId)
+	assert.Equal(t, "m.notice", first.MsgType)
+	assert.Equal(t, "org.matrix.custom.html", first.Format)
+	assert.Equal(t, `<b><a href="https://go.dev/blog/1">First &lt;release&gt;</a></b><br><i>The Go Blog · by Jane</i><br><br>Line one<br>line <b>two</b><br><br><pre>code
+block</pre>`, first.FormattedBody)
+	assert.True(t, strings.HasPrefix(first.Body, "[First <release>](https://go.dev/blog/1)\nThe Go Blog · by Jane\n\nLine one"), "plain body should be markdown")
+	assert.Nil(t, first.RelatesTo, "articles should not be threaded by default")
+
+	assert.NoError(t, subscriber.Deliver(context.Background(), matrixArticles()), "error should be nil")
+	assert.Len(t, homeserver.events, 2, "retried batch should reuse transaction ids")
+}
+
+func TestMatrixSubscriber_Threads(t *testing.T) {
+	homeserver := &matrixHomeserver{}
+	serverUrl := newServer(t, homeserver)
+
+	config := newMatrixConfig(serverUrl)
+	config.Threads = true
+	subscriber, err := subscribers.NewMatrixSubscriber(newLogger(t), "matrix", config, newMatrixThreadRepository(t))
+	assert.NoError(t, err, "error should be nil")
+	assert.NoError(t, subscriber.Subscribe(context.Background()), "error should be nil")
+
+	articles := matrixArticles()
+	articles = append(articles, &model.FeedArticle{
+		ResourceID:    "https://go.dev/blog/feed.atom",
+		ResourceTitle: "The Go Blog",
+		Title:         "Third",
+		Link:          "https://go.dev/blog/3",
+	})
+	assert.NoError(t, subscriber.Deliver(context.Background(), articles), "error should be nil")
+
+	roots := map[string]string{}
+	for _, event := range homeserver.events {
+		if event.RelatesTo == nil {
+			roots[event.Body] = event.EventId
+		}
+	}
+	assert.Len(t, roots, 2, "every resource should have own thread")
+	assert.Len(t, homeserver.events, 5)
+
+	threads := map[string]string{}
+	for _, event := range homeserver.events {
+		if event.RelatesTo != nil {
+			assert.Equal(t, "m.thread", event.RelatesTo.RelType)
+			threads[strings.SplitN(event.Body, "\n", 2)[0]] = event.RelatesTo.EventId
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"[First <release>](https://go.dev/blog/1)": roots["The Go Blog"],
+		"[Third](https://go.dev/blog/3)":           roots["The Go Blog"],
+		"[Second](https://example.com/2)":          roots["Example blog"],
+	}, threads)
+}
+
+func TestMatrixSubscriber_Errors(t *testing.T) {
+	homeserver := &matrixHomeserver{rateLimited: 2}
+	serverUrl := newServer(t, homeserver)
+
+	articles := matrixArticles()[:1]
+	subscriber, err := subscribers.NewMatrixSubscriber(newLogger(t), "matrix", newMatrixConfig(serverUrl), nil)
+	assert.NoError(t, err, "error should be nil")
+	assert.NoError(t, subscriber.Deliver(context.Background(), articles), "rate limited request should be retried")
+	assert.Equal(t, 3, homeserver.requests)
+
+	homeserver.rateLimited = 3
+	subscriber, err = subscribers.NewMatrixSubscriber(newLogger(t), "other", newMatrixConfig(serverUrl), nil)
+	assert.NoError(t, err, "error should be nil")
+	assert.ErrorContains(t, subscriber.Deliver(context.Background(), articles), "M_LIMIT_EXCEEDED", "batch should go back to outbox after retries")
+
+	config := newMatrixConfig(serverUrl)
+	config.AccessToken = "wrong"
+	subscriber, err = subscribers.NewMatrixSubscriber(newLogger(t), "matrix", config, nil)
+	assert.NoError(t, err, "error should be nil")
+	err = subscriber.Deliver(context.Background(), articles)
+	assert.ErrorContains(t, err, "M_UNKNOWN_TOKEN")
+	assert.NotContains(t, err.Error(), "wrong", "error should not contain token")
+}
+
+func TestMatrixConfig_Validate(t *testing.T) {
+	config := newMatrixConfig("https://matrix.example.org")
+	assert.NoError(t, config.Validate(), "error should be nil")
+
+	config.RoomIds = []string{"#news:example.org"}
+	assert.Error(t, config.Validate(), "room alias should be rejected")
+
+	config.RoomIds = []string{"!news:example.org"}
+	config.MsgType = "m.image"
+	assert.Error(t, config.Validate(), "unknown msgtype should be rejected")
+
+	config.MsgType = "m.text"
+	config.AccessToken = ""
+	assert.Error(t, config.Validate(), "access token should be required")
+}
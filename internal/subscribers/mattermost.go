@@ -0,0 +1,181 @@
+package subscribers
+
+import (
+	"context"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"unicode/utf8"
+)
+
+const MattermostType = "mattermost"
+
+// Mattermost limits, see https://developers.mattermost.com/integrate/reference/message-attachments/
+const (
+	// mattermostMaxText keeps text of attachments below 16383 characters limit of a post
+	mattermostMaxText           = 16000
+	mattermostMaxAttachments    = 20
+	mattermostMaxTitle          = 300
+	mattermostDescriptionLength = 500
+	mattermostColor             = "#87CEEB"
+)
+
+type mattermostMessage struct {
+	Username    string                 `json:"username,omitempty"`
+	IconUrl     string                 `json:"icon_url,omitempty"`
+	Channel     string                 `json:"channel,omitempty"`
+	Attachments []mattermostAttachment `json:"attachments"`
+}
+
+// mattermostAttachment slack compatible message attachment
+type mattermostAttachment struct {
+	Fallback   string `json:"fallback"`
+	Color      string `json:"color"`
+	Title      string `json:"title"`
+	TitleLink  string `json:"title_link,omitempty"`
+	AuthorName string `json:"author_name,omitempty"`
+	Text       string `json:"text,omitempty"`
+	ImageUrl   string `json:"image_url,omitempty"`
+	Footer     string `json:"footer,omitempty"`
+	Ts         int64  `json:"ts,omitempty"`
+}
+
+// MattermostSubscriber delivers outbox events to Mattermost incoming webhook as message attachments
+type MattermostSubscriber struct {
+	delivery
+	name      string
+	config    *MattermostConfig
+	webhook   *chatWebhook
+	templates *messageTemplates
+}
+
+// NewMattermostSubscribers creates instances declared in SUBSCRIBERS and the default one configured by MATTERMOST_* variables
+func NewMattermostSubscribers(logger *logger.Logger, defaultConfig *MattermostConfig, config *Config) ([]Subscriber, error) {
+	instances, err := newInstances(config, MattermostType, func(name string, settings *MattermostConfig) (Subscriber, error) {
+		return NewMattermostSubscriber(logger, name, settings)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if defaultConfig.Enabled() {
+		instance, err := NewMattermostSubscriber(logger, MattermostType, defaultConfig)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+func NewMattermostSubscriber(logger *logger.Logger, name string, config *MattermostConfig) (*MattermostSubscriber, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	templates, err := newMessageTemplates(config.Template, config.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MattermostSubscriber{
+		name:      name,
+		config:    config,
+		webhook:   newChatWebhook(logger, MattermostType, name, config.WebhookUrl, config.MaxRetries, config.MaxRetryAfter),
+		templates: templates,
+		delivery:  newDelivery(config.Delivery, defaultBatching),
+	}, nil
+}
+
+func (s *MattermostSubscriber) Name() string {
+	return s.name
+}
+
+func (s *MattermostSubscriber) Subscribe(_ context.Context) error {
+	return nil
+}
+
+// Deliver sends articles as one or more messages
+func (s *MattermostSubscriber) Deliver(ctx context.Context, events []*model.FeedArticle) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	articles, err := s.templates.notifications(events)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range s.messages(articles) {
+		if err := s.webhook.send(ctx, message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Render returns messages of article as they are sent
+func (s *MattermostSubscriber) Render(_ context.Context, event *model.FeedArticle) (string, error) {
+	articles, err := s.templates.notifications([]*model.FeedArticle{event})
+	if err != nil {
+		return "", err
+	}
+
+	return renderPayload(s.messages(articles))
+}
+
+func (s *MattermostSubscriber) Close() error {
+	s.webhook.close()
+	return nil
+}
+
+// messages renders attachments and splits them by count and text length of a post
+func (s *MattermostSubscriber) messages(articles []*notification) []mattermostMessage {
+	attachments := make([]mattermostAttachment, len(articles))
+	for i, article := range articles {
+		attachments[i] = mattermostArticle(article)
+	}
+
+	chunks := splitByLength(attachments, func(attachment mattermostAttachment) int {
+		return utf8.RuneCountInString(attachment.Title) + utf8.RuneCountInString(attachment.Text)
+	}, mattermostMaxText, mattermostMaxAttachments)
+
+	messages := make([]mattermostMessage, 0, len(chunks))
+	for _, chunk := range chunks {
+		messages = append(messages, mattermostMessage{
+			Username:    s.config.Username,
+			IconUrl:     s.config.IconUrl,
+			Channel:     s.config.Channel,
+			Attachments: chunk,
+		})
+	}
+
+	return messages
+}
+
+func mattermostArticle(article *notification) mattermostAttachment {
+	title := truncate(article.Title, mattermostMaxTitle)
+	attachment := mattermostAttachment{
+		Fallback:   title,
+		Color:      mattermostColor,
+		Title:      title,
+		TitleLink:  article.Link,
+		AuthorName: article.Author,
+		Text:       article.Markdown(mattermostDescriptionLength),
+		ImageUrl:   article.Image,
+		Footer:     article.Resource,
+	}
+	// footer rendered by template replaces resource and author
+	if article.hasFooter {
+		attachment.AuthorName, attachment.Footer = "", article.Footer
+	}
+	if article.Link != "" {
+		attachment.Fallback += " " + article.Link
+	}
+	if !article.Published.IsZero() {
+		attachment.Ts = article.Published.Unix()
+	}
+
+	return attachment
+}
@@ -0,0 +1,41 @@
+package subscribers
+
+import (
+	"fmt"
+	"time"
+)
+
+type MattermostConfig struct {
+	WebhookUrl string `envconfig:"MATTERMOST_WEBHOOK_URL"`
+	// Username, IconUrl and Channel override webhook defaults when the server allows it
+	Username string         `envconfig:"MATTERMOST_USERNAME"`
+	IconUrl  string         `envconfig:"MATTERMOST_ICON_URL"`
+	Channel  string         `envconfig:"MATTERMOST_CHANNEL"`
+	Delivery DeliveryConfig `envconfig:"MATTERMOST"`
+	// Template path of text/template rendering markdown of attachment, optional "title" and "footer" templates replace title and source
+	Template string `envconfig:"MATTERMOST_TEMPLATE"`
+	// Templates paths of templates by tags or resource urls, e.g. /etc/feed/releases.tmpl:release|https://go.dev/blog/feed.atom
+	Templates map[string]string `envconfig:"MATTERMOST_TEMPLATES"`
+	// MaxRetries how many times 429 responses are retried in place before batch goes back to outbox
+	MaxRetries int `envconfig:"MATTERMOST_MAX_RETRIES" default:"3"`
+	// MaxRetryAfter upper bound of waiting requested by Retry-After header
+	MaxRetryAfter time.Duration `envconfig:"MATTERMOST_MAX_RETRY_AFTER" default:"1m"`
+}
+
+func (c *MattermostConfig) Enabled() bool {
+	return c.WebhookUrl != ""
+}
+
+func (c *MattermostConfig) Validate() error {
+	if !c.Enabled() {
+		return fmt.Errorf("mattermost webhook url is required")
+	}
+	if !httpUrl(c.WebhookUrl) {
+		return fmt.Errorf("mattermost webhook url should be http url")
+	}
+	if c.IconUrl != "" && !httpUrl(c.IconUrl) {
+		return fmt.Errorf("mattermost icon url should be http url")
+	}
+
+	return c.Delivery.Validate()
+}
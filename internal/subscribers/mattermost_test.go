@@ -0,0 +1,116 @@
+package subscribers_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sealbro/go-feed-me/internal/subscribers"
+	"github.com/stretchr/testify/assert"
+)
+
+type mattermostAttachment struct {
+	Fallback   string `json:"fallback"`
+	Title      string `json:"title"`
+	TitleLink  string `json:"title_link"`
+	AuthorName string `json:"author_name"`
+	Text       string `json:"text"`
+	ImageUrl   string `json:"image_url"`
+	Footer     string `json:"footer"`
+	Ts         int64  `json:"ts"`
+}
+
+type mattermostMessage struct {
+	Username    string                 `json:"username"`
+	Channel     string                 `json:"channel"`
+	Attachments []mattermostAttachment `json:"attachments"`
+}
+
+func newMattermostSubscriber(t *testing.T, receiver *webhookReceiver) *subscribers.MattermostSubscriber {
+	subscriber, err := subscribers.NewMattermostSubscriber(newLogger(t), "mattermost", &subscribers.MattermostConfig{
+		WebhookUrl:    newServer(t, receiver),
+		Username:      "feed",
+		Channel:       "town-square",
+		MaxRetries:    2,
+		MaxRetryAfter: 10 * time.Millisecond,
+	})
+	assert.NoError(t, err, "error should be nil")
+
+	return subscriber
+}
+
+func TestMattermostSubscriber_Deliver(t *testing.T) {
+	receiver := &webhookReceiver{}
+	subscriber := newMattermostSubscriber(t, receiver)
+	assert.NoError(t, subscriber.Subscribe(context.Background()), "error should be nil")
+
+	articles := testArticles()
+	articles[0].ResourceTitle = ""
+	err := subscriber.Deliver(context.Background(), articles)
+	assert.NoError(t, err, "error should be nil")
+
+	messages := decodeRequests[mattermostMessage](t, receiver)
+	assert.Len(t, messages, 1, "articles should be sent in one message")
+	assert.Equal(t, "feed", messages[0].Username)
+	assert.Equal(t, "town-square", messages[0].Channel)
+
+	attachments := messages[0].Attachments
+	assert.Len(t, attachments, 2)
+	assert.Equal(t, mattermostAttachment{
+		Fallback:   "First https://example.com/1",
+		Title:      "First",
+		TitleLink:  "https://example.com/1",
+		AuthorName: "Jane",
+		Text:       "Read **more** at [docs](https://example.com/docs)",
+		ImageUrl:   "https://example.com/1.png",
+		Footer:     "Example blog",
+		Ts:         publishedAt.Unix(),
+	}, attachments[0], "articles should be sent oldest first")
+	assert.Equal(t, "https://example.com/feed", attachments[1].Footer, "resource id should be used without resource title")
+	assert.Zero(t, attachments[1].Ts)
+}
+
+func TestMattermostSubscriber_Limits(t *testing.T) {
+	receiver := &webhookReceiver{}
+	subscriber := newMattermostSubscriber(t, receiver)
+
+	articles := longArticles(50)
+	assert.NoError(t, subscriber.Deliver(context.Background(), articles), "error should be nil")
+
+	assert.Len(t, receiver.requests, 3, "articles should be split by attachment limit")
+	count := 0
+	for _, message := range decodeRequests[mattermostMessage](t, receiver) {
+		text := 0
+		for _, attachment := range message.Attachments {
+			text += utf8.RuneCountInString(attachment.Title) + utf8.RuneCountInString(attachment.Text)
+			assert.True(t, strings.HasSuffix(attachment.Text, "…"), "description should be truncated")
+		}
+		assert.LessOrEqual(t, text, 16383, "message should respect post limit")
+		count += len(message.Attachments)
+	}
+	assert.Equal(t, 50, count, "all articles should be sent")
+}
+
+func TestMattermostSubscriber_RateLimit(t *testing.T) {
+	articles := testArticles()[1:]
+
+	receiver := &webhookReceiver{statuses: []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests}}
+	subscriber := newMattermostSubscriber(t, receiver)
+	assert.ErrorContains(t, subscriber.Deliver(context.Background(), articles), "mattermost webhook rate limited", "batch should go back to outbox after retries")
+	assert.Len(t, receiver.requests, 3)
+}
+
+func TestMattermostConfig_Validate(t *testing.T) {
+	config := &subscribers.MattermostConfig{WebhookUrl: "https://chat.example.com/hooks/id"}
+	assert.NoError(t, config.Validate(), "error should be nil")
+
+	config.IconUrl = "feed.png"
+	assert.Error(t, config.Validate(), "icon url should be http url")
+
+	config.IconUrl = ""
+	config.WebhookUrl = ""
+	assert.Error(t, config.Validate(), "url should be required")
+}
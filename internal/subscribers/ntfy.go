@@ -0,0 +1,115 @@
+package subscribers
+
+import (
+	"context"
+	"fmt"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+)
+
+const NtfyType = "ntfy"
+
+// ntfy limits, see https://docs.ntfy.sh/publish/
+const (
+	ntfyMinPriority = 1
+	ntfyMaxPriority = 5
+	ntfyMaxTitle    = 250
+)
+
+// ntfyMessage JSON message published to server url
+type ntfyMessage struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority"`
+	Tags     []string `json:"tags,omitempty"`
+	Click    string   `json:"click,omitempty"`
+	Attach   string   `json:"attach,omitempty"`
+}
+
+// NtfySubscriber publishes every article as push notification to ntfy topic
+type NtfySubscriber struct {
+	delivery
+	name       string
+	config     *NtfyConfig
+	topic      string
+	webhook    *chatWebhook
+	priorities *pushPriorities
+}
+
+// NewNtfySubscribers creates instances declared in SUBSCRIBERS and the default one configured by NTFY_* variables
+func NewNtfySubscribers(logger *logger.Logger, defaultConfig *NtfyConfig, config *Config) ([]Subscriber, error) {
+	instances, err := newInstances(config, NtfyType, func(name string, settings *NtfyConfig) (Subscriber, error) {
+		return NewNtfySubscriber(logger, name, settings)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if defaultConfig.Enabled() {
+		instance, err := NewNtfySubscriber(logger, NtfyType, defaultConfig)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+func NewNtfySubscriber(logger *logger.Logger, name string, config *NtfyConfig) (*NtfySubscriber, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	priorities, err := newPushPriorities(config.Priority, config.Priorities, ntfyMinPriority, ntfyMaxPriority)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ntfy priority: %w", err)
+	}
+
+	subscriber := &NtfySubscriber{
+		name:       name,
+		config:     config,
+		delivery:   newDelivery(config.Delivery, defaultBatching),
+		priorities: priorities,
+	}
+
+	server, topic, _ := config.topic()
+	subscriber.topic = topic
+	subscriber.webhook = newChatWebhook(logger, NtfyType, name, server, config.MaxRetries, config.MaxRetryAfter)
+	if config.Token != "" {
+		subscriber.webhook.headers = map[string]string{"Authorization": "Bearer " + config.Token}
+	}
+
+	return subscriber, nil
+}
+
+func (s *NtfySubscriber) Name() string {
+	return s.name
+}
+
+func (s *NtfySubscriber) Subscribe(_ context.Context) error {
+	return nil
+}
+
+func (s *NtfySubscriber) Deliver(ctx context.Context, events []*model.FeedArticle) error {
+	return sendPushes(ctx, s.webhook, events, s.message)
+}
+
+// message publishes article to topic with priority by its rules, click opens article
+func (s *NtfySubscriber) message(event *model.FeedArticle, article *notification) any {
+	return ntfyMessage{
+		Topic:    s.topic,
+		Title:    truncate(article.Title, ntfyMaxTitle),
+		Message:  pushMessage(article, pushMessageLength),
+		Priority: s.priorities.priority(event),
+		Tags:     article.Tags,
+		Click:    article.Link,
+		Attach:   article.Image,
+	}
+}
+
+func (s *NtfySubscriber) Close() error {
+	s.webhook.close()
+	return nil
+}
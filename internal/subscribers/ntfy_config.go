@@ -0,0 +1,60 @@
+package subscribers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type NtfyConfig struct {
+	// TopicUrl server url with topic, e.g. https://ntfy.sh/releases
+	TopicUrl string `envconfig:"NTFY_TOPIC_URL"`
+	// Token access token of protected topic
+	Token    string `envconfig:"NTFY_TOKEN"`
+	Priority int    `envconfig:"NTFY_PRIORITY" default:"3"`
+	// Priorities priority by tags or resource urls, e.g. 5:release|security,4:https://go.dev/blog/feed.atom
+	Priorities map[string]string `envconfig:"NTFY_PRIORITIES"`
+	Delivery   DeliveryConfig    `envconfig:"NTFY"`
+	// MaxRetries how many times 429 responses are retried in place before batch goes back to outbox
+	MaxRetries int `envconfig:"NTFY_MAX_RETRIES" default:"3"`
+	// MaxRetryAfter upper bound of waiting requested by Retry-After header
+	MaxRetryAfter time.Duration `envconfig:"NTFY_MAX_RETRY_AFTER" default:"1m"`
+}
+
+func (c *NtfyConfig) Enabled() bool {
+	return c.TopicUrl != ""
+}
+
+func (c *NtfyConfig) Validate() error {
+	if !c.Enabled() {
+		return fmt.Errorf("ntfy topic url is required")
+	}
+	if _, _, err := c.topic(); err != nil {
+		return err
+	}
+	if _, err := newPushPriorities(c.Priority, c.Priorities, ntfyMinPriority, ntfyMaxPriority); err != nil {
+		return fmt.Errorf("invalid ntfy priority: %w", err)
+	}
+
+	return c.Delivery.Validate()
+}
+
+// topic splits topic url to server url accepting JSON messages and topic name
+func (c *NtfyConfig) topic() (string, string, error) {
+	parsed, err := url.Parse(c.TopicUrl)
+	if err != nil || !httpUrl(c.TopicUrl) {
+		return "", "", fmt.Errorf("ntfy topic url should be http url")
+	}
+
+	path := strings.Trim(parsed.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	topic := path[slash+1:]
+	if topic == "" {
+		return "", "", fmt.Errorf("ntfy topic url should end with topic")
+	}
+	parsed.Path = "/" + path[:max(slash, 0)]
+	parsed.RawQuery = ""
+
+	return strings.TrimRight(parsed.String(), "/"), topic, nil
+}
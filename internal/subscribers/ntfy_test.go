@@ -0,0 +1,86 @@
+package subscribers_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sealbro/go-feed-me/internal/subscribers"
+	"github.com/stretchr/testify/assert"
+)
+
+type ntfyMessage struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority"`
+	Tags     []string `json:"tags"`
+	Click    string   `json:"click"`
+	Attach   string   `json:"attach"`
+}
+
+func TestNtfySubscriber_Deliver(t *testing.T) {
+	receiver := &webhookReceiver{}
+	serverUrl := newServer(t, receiver)
+
+	subscriber, err := subscribers.NewNtfySubscriber(newLogger(t), "ntfy", &subscribers.NtfyConfig{
+		TopicUrl:      serverUrl + "/ntfy/releases",
+		Token:         "tk_secret",
+		Priority:      2,
+		Priorities:    map[string]string{"5": "security", "4": "release|https://example.com/other"},
+		MaxRetryAfter: time.Second,
+	})
+	assert.NoError(t, err, "error should be nil")
+	assert.NoError(t, subscriber.Subscribe(context.Background()), "error should be nil")
+
+	assert.NoError(t, subscriber.Deliver(context.Background(), pushArticles()), "error should be nil")
+	assert.Len(t, receiver.requests, 2, "every article should be separate notification")
+
+	request := receiver.requests[0]
+	assert.Equal(t, "/ntfy", request.Path, "message should be published to server url")
+	assert.Equal(t, "Bearer tk_secret", request.Header.Get("Authorization"))
+
+	message := ntfyMessage{}
+	assert.NoError(t, json.Unmarshal(request.Body, &message), "error should be nil")
+	assert.Equal(t, ntfyMessage{
+		Topic:    "releases",
+		Title:    "go1.23.1",
+		Message:  "Go releases\nSecurity fixes",
+		Priority: 5,
+		Tags:     []string{"release", "security"},
+		Click:    "https://github.com/golang/go/releases/tag/go1.23.1",
+		Attach:   "https://github.com/golang.png",
+	}, message, "the highest priority of matching rules should be used")
+
+	message = ntfyMessage{}
+	assert.NoError(t, json.Unmarshal(receiver.requests[1].Body, &message), "error should be nil")
+	assert.Equal(t, 2, message.Priority, "default priority should be used without matching rules")
+	assert.Equal(t, "Example blog", message.Message)
+}
+
+func TestNtfyConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config subscribers.NtfyConfig
+		expect string
+	}{
+		{name: "valid", config: subscribers.NtfyConfig{TopicUrl: "https://ntfy.sh/releases", Priority: 3}},
+		{name: "no topic", config: subscribers.NtfyConfig{TopicUrl: "https://ntfy.sh/", Priority: 3}, expect: "topic"},
+		{name: "priority out of range", config: subscribers.NtfyConfig{TopicUrl: "https://ntfy.sh/releases", Priority: 6}, expect: "priority"},
+		{name: "invalid rule", config: subscribers.NtfyConfig{TopicUrl: "https://ntfy.sh/releases", Priority: 3, Priorities: map[string]string{"high": "release"}}, expect: "priority"},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.config.Validate()
+			if testCase.expect == "" {
+				assert.NoError(t, err, "error should be nil")
+				return
+			}
+			assert.ErrorContains(t, err, testCase.expect)
+		})
+	}
+}
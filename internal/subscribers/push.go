@@ -0,0 +1,89 @@
+package subscribers
+
+import (
+	"context"
+	"fmt"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"strconv"
+	"strings"
+)
+
+// pushMessageLength keeps push notifications short enough for lock screen
+const pushMessageLength = 500
+
+// pushPriority priority of articles matching rule
+type pushPriority struct {
+	articleRule
+	priority int
+}
+
+// pushPriorities picks priority of push notification by resource or tag of article
+type pushPriorities struct {
+	fallback int
+	minimum  int
+	maximum  int
+	rules    []pushPriority
+}
+
+// newPushPriorities parses rules like 5:release|security or 4:https://go.dev/blog/feed.atom,
+// every value is a tag or resource url
+func newPushPriorities(fallback int, rules map[string]string, minimum int, maximum int) (*pushPriorities, error) {
+	if fallback < minimum || fallback > maximum {
+		return nil, fmt.Errorf("priority %d should be from %d to %d", fallback, minimum, maximum)
+	}
+
+	priorities := &pushPriorities{fallback: fallback, minimum: minimum, maximum: maximum}
+	for key, values := range rules {
+		priority, err := strconv.Atoi(strings.TrimSpace(key))
+		if err != nil || priority < minimum || priority > maximum {
+			return nil, fmt.Errorf("priority %q should be from %d to %d", key, minimum, maximum)
+		}
+
+		priorities.rules = append(priorities.rules, pushPriority{articleRule: newArticleRule(values), priority: priority})
+	}
+
+	return priorities, nil
+}
+
+// priority returns the highest priority of matching rules, fallback one when no rule matches,
+// bumped by priority which article rules set and kept in range
+func (p *pushPriorities) priority(article *model.FeedArticle) int {
+	priority, matched := p.fallback, false
+	for _, rule := range p.rules {
+		if !rule.match(article) {
+			continue
+		}
+		if !matched || rule.priority > priority {
+			priority, matched = rule.priority, true
+		}
+	}
+
+	return max(p.minimum, min(p.maximum, priority+article.Priority))
+}
+
+// pushMessage renders source line and plain text description within limit of characters
+func pushMessage(article *notification, limit int) string {
+	lines := make([]string, 0, 2)
+	if source := article.Source(); source != "" {
+		lines = append(lines, source)
+	}
+	if description := plainText(article.Description, limit); description != "" {
+		lines = append(lines, description)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, firstNonEmpty(article.Link, article.Title))
+	}
+
+	return cutText(strings.Join(lines, "\n"), limit-1)
+}
+
+// sendPushes sends push notification per article oldest first, message builds request of article
+func sendPushes(ctx context.Context, webhook *chatWebhook, events []*model.FeedArticle, message func(event *model.FeedArticle, article *notification) any) error {
+	for _, event := range oldestFirst(events) {
+		if err := webhook.send(ctx, message(event, newNotification(event))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
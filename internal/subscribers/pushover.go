@@ -0,0 +1,127 @@
+package subscribers
+
+import (
+	"context"
+	"fmt"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"strings"
+)
+
+const PushoverType = "pushover"
+
+// Pushover limits, see https://pushover.net/api
+const (
+	pushoverMinPriority = -2
+	pushoverMaxPriority = 2
+	// pushoverEmergency priority repeated till user acknowledges notification
+	pushoverEmergency   = 2
+	pushoverMaxMessage  = 1024
+	pushoverMaxTitle    = 250
+	pushoverMaxUrl      = 512
+	pushoverMaxUrlTitle = 100
+)
+
+type pushoverMessage struct {
+	Token     string `json:"token"`
+	User      string `json:"user"`
+	Device    string `json:"device,omitempty"`
+	Title     string `json:"title"`
+	Message   string `json:"message"`
+	Url       string `json:"url,omitempty"`
+	UrlTitle  string `json:"url_title,omitempty"`
+	Priority  int    `json:"priority"`
+	Retry     int    `json:"retry,omitempty"`
+	Expire    int    `json:"expire,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+}
+
+// PushoverSubscriber sends every article as pushover notification
+type PushoverSubscriber struct {
+	delivery
+	name       string
+	config     *PushoverConfig
+	webhook    *chatWebhook
+	priorities *pushPriorities
+}
+
+// NewPushoverSubscribers creates instances declared in SUBSCRIBERS and the default one configured by PUSHOVER_* variables
+func NewPushoverSubscribers(logger *logger.Logger, defaultConfig *PushoverConfig, config *Config) ([]Subscriber, error) {
+	instances, err := newInstances(config, PushoverType, func(name string, settings *PushoverConfig) (Subscriber, error) {
+		return NewPushoverSubscriber(logger, name, settings)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if defaultConfig.Enabled() {
+		instance, err := NewPushoverSubscriber(logger, PushoverType, defaultConfig)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+func NewPushoverSubscriber(logger *logger.Logger, name string, config *PushoverConfig) (*PushoverSubscriber, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	priorities, err := newPushPriorities(config.Priority, config.Priorities, pushoverMinPriority, pushoverMaxPriority)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pushover priority: %w", err)
+	}
+
+	return &PushoverSubscriber{
+		name:       name,
+		config:     config,
+		delivery:   newDelivery(config.Delivery, defaultBatching),
+		webhook:    newChatWebhook(logger, PushoverType, name, config.ApiUrl, config.MaxRetries, config.MaxRetryAfter),
+		priorities: priorities,
+	}, nil
+}
+
+func (s *PushoverSubscriber) Name() string {
+	return s.name
+}
+
+func (s *PushoverSubscriber) Subscribe(_ context.Context) error {
+	return nil
+}
+
+func (s *PushoverSubscriber) Deliver(ctx context.Context, events []*model.FeedArticle) error {
+	return sendPushes(ctx, s.webhook, events, s.message)
+}
+
+// message sends article to devices of user, emergency one is repeated till user acknowledges it
+func (s *PushoverSubscriber) message(event *model.FeedArticle, article *notification) any {
+	message := pushoverMessage{
+		Token:    s.config.AppToken,
+		User:     s.config.UserKey,
+		Device:   strings.Join(s.config.Devices, ","),
+		Title:    truncate(article.Title, pushoverMaxTitle),
+		Message:  pushMessage(article, pushoverMaxMessage),
+		Priority: s.priorities.priority(event),
+	}
+	if article.Link != "" && len(article.Link) <= pushoverMaxUrl {
+		message.Url = article.Link
+		message.UrlTitle = truncate(article.Resource, pushoverMaxUrlTitle)
+	}
+	if message.Priority == pushoverEmergency {
+		message.Retry = int(s.config.Retry.Seconds())
+		message.Expire = int(s.config.Expire.Seconds())
+	}
+	if !article.Published.IsZero() {
+		message.Timestamp = article.Published.Unix()
+	}
+
+	return message
+}
+
+func (s *PushoverSubscriber) Close() error {
+	s.webhook.close()
+	return nil
+}
@@ -0,0 +1,47 @@
+package subscribers
+
+import (
+	"fmt"
+	"time"
+)
+
+type PushoverConfig struct {
+	ApiUrl   string `envconfig:"PUSHOVER_API_URL" default:"https://api.pushover.net/1/messages.json"`
+	AppToken string `envconfig:"PUSHOVER_APP_TOKEN"`
+	// UserKey user or group key
+	UserKey string `envconfig:"PUSHOVER_USER_KEY"`
+	// Devices only these devices of user, empty sends to all devices
+	Devices  []string `envconfig:"PUSHOVER_DEVICES"`
+	Priority int      `envconfig:"PUSHOVER_PRIORITY" default:"0"`
+	// Priorities priority by tags or resource urls, e.g. 1:release|security,-1:https://go.dev/blog/feed.atom
+	Priorities map[string]string `envconfig:"PUSHOVER_PRIORITIES"`
+	// Retry and Expire how often and how long emergency notifications are repeated till acknowledged
+	Retry    time.Duration  `envconfig:"PUSHOVER_RETRY" default:"1m"`
+	Expire   time.Duration  `envconfig:"PUSHOVER_EXPIRE" default:"1h"`
+	Delivery DeliveryConfig `envconfig:"PUSHOVER"`
+	// MaxRetries how many times 429 responses are retried in place before batch goes back to outbox
+	MaxRetries int `envconfig:"PUSHOVER_MAX_RETRIES" default:"3"`
+	// MaxRetryAfter upper bound of waiting requested by Retry-After header
+	MaxRetryAfter time.Duration `envconfig:"PUSHOVER_MAX_RETRY_AFTER" default:"1m"`
+}
+
+func (c *PushoverConfig) Enabled() bool {
+	return c.AppToken != "" && c.UserKey != ""
+}
+
+func (c *PushoverConfig) Validate() error {
+	if !c.Enabled() {
+		return fmt.Errorf("pushover app token and user key are required")
+	}
+	if !httpUrl(c.ApiUrl) {
+		return fmt.Errorf("pushover api url should be http url")
+	}
+	if _, err := newPushPriorities(c.Priority, c.Priorities, pushoverMinPriority, pushoverMaxPriority); err != nil {
+		return fmt.Errorf("invalid pushover priority: %w", err)
+	}
+	if c.Retry < 30*time.Second || c.Expire <= 0 || c.Expire > 3*time.Hour {
+		return fmt.Errorf("pushover retry should be at least 30s and expire at most 3h")
+	}
+
+	return c.Delivery.Validate()
+}
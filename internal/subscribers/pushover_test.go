@@ -0,0 +1,104 @@
+package subscribers_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/subscribers"
+	"github.com/stretchr/testify/assert"
+)
+
+type pushoverMessage struct {
+	Token    string `json:"token"`
+	User     string `json:"user"`
+	Device   string `json:"device"`
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Url      string `json:"url"`
+	UrlTitle string `json:"url_title"`
+	Priority int    `json:"priority"`
+	Retry    int    `json:"retry"`
+	Expire   int    `json:"expire"`
+}
+
+func newPushoverConfig(url string) *subscribers.PushoverConfig {
+	return &subscribers.PushoverConfig{
+		ApiUrl:        url,
+		AppToken:      "app-token",
+		UserKey:       "user-key",
+		Priority:      -1,
+		Priorities:    map[string]string{"2": "security"},
+		Retry:         time.Minute,
+		Expire:        time.Hour,
+		MaxRetryAfter: time.Second,
+	}
+}
+
+func TestPushoverSubscriber_Deliver(t *testing.T) {
+	receiver := &webhookReceiver{}
+	serverUrl := newServer(t, receiver)
+
+	config := newPushoverConfig(serverUrl)
+	config.Devices = []string{"phone", "watch"}
+	subscriber, err := subscribers.NewPushoverSubscriber(newLogger(t), "pushover", config)
+	assert.NoError(t, err, "error should be nil")
+	assert.NoError(t, subscriber.Subscribe(context.Background()), "error should be nil")
+
+	articles := append([]*model.FeedArticle{{
+		Title:       "Long read",
+		Link:        "https://example.com/" + strings.Repeat("a", 600),
+		Description: "<p>" + strings.Repeat("word ", 500) + "</p>",
+	}}, pushArticles()...)
+	assert.NoError(t, subscriber.Deliver(context.Background(), articles), "error should be nil")
+	assert.Len(t, receiver.requests, 3)
+
+	messages := decodeRequests[pushoverMessage](t, receiver)
+	assert.Equal(t, pushoverMessage{
+		Token:    "app-token",
+		User:     "user-key",
+		Device:   "phone,watch",
+		Title:    "go1.23.1",
+		Message:  "Go releases\nSecurity fixes",
+		Url:      "https://github.com/golang/go/releases/tag/go1.23.1",
+		UrlTitle: "Go releases",
+		Priority: 2,
+		Retry:    60,
+		Expire:   3600,
+	}, messages[0], "emergency notification should have retry and expire")
+
+	assert.Equal(t, -1, messages[1].Priority)
+	assert.Zero(t, messages[1].Retry)
+
+	assert.Empty(t, messages[2].Url, "too long url should be skipped")
+	assert.LessOrEqual(t, utf8.RuneCountInString(messages[2].Message), 1024, "message should respect limit")
+}
+
+func TestPushoverSubscriber_Errors(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{http.StatusBadRequest}}
+	serverUrl := newServer(t, receiver)
+
+	subscriber, err := subscribers.NewPushoverSubscriber(newLogger(t), "pushover", newPushoverConfig(serverUrl))
+	assert.NoError(t, err, "error should be nil")
+
+	err = subscriber.Deliver(context.Background(), pushArticles())
+	assert.ErrorContains(t, err, "400")
+	assert.NotContains(t, err.Error(), "app-token", "error should not contain token")
+	assert.Len(t, receiver.requests, 1, "batch should go back to outbox")
+}
+
+func TestPushoverConfig_Validate(t *testing.T) {
+	config := newPushoverConfig("https://api.pushover.net/1/messages.json")
+	assert.NoError(t, config.Validate(), "error should be nil")
+
+	config.Retry = time.Second
+	assert.Error(t, config.Validate(), "retry below 30 seconds should be rejected")
+
+	config.Retry = time.Minute
+	config.Priority = 3
+	assert.Error(t, config.Validate(), "priority out of range should be rejected")
+}
@@ -0,0 +1,92 @@
+package subscribers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/pkg/graceful"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"log/slog"
+	"sort"
+)
+
+// Registry keeps all subscriber instances, starts and closes them together
+type Registry struct {
+	logger      *logger.Logger
+	subscribers []Subscriber
+	byName      map[string]Subscriber
+}
+
+func NewRegistry(logger *logger.Logger, subscribers []Subscriber, closer *graceful.ShutdownCloser) (*Registry, error) {
+	byName := make(map[string]Subscriber, len(subscribers))
+	for _, subscriber := range subscribers {
+		if _, ok := byName[subscriber.Name()]; ok {
+			return nil, fmt.Errorf("subscriber %q is declared twice", subscriber.Name())
+		}
+		byName[subscriber.Name()] = subscriber
+	}
+
+	sorted := append([]Subscriber(nil), subscribers...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name() < sorted[j].Name()
+	})
+
+	r := &Registry{
+		logger:      logger,
+		subscribers: sorted,
+		byName:      byName,
+	}
+
+	closer.Register(r)
+
+	return r, nil
+}
+
+// Start subscribes every instance, registry fails if any subscriber can not start
+func (r *Registry) Start(ctx context.Context) error {
+	for _, subscriber := range r.subscribers {
+		if err := subscriber.Subscribe(ctx); err != nil {
+			return fmt.Errorf("can not start subscriber %q: %w", subscriber.Name(), err)
+		}
+		r.logger.InfoContext(ctx, "Subscriber started", slog.String("subscriber", subscriber.Name()))
+	}
+
+	return nil
+}
+
+// List returns subscribers ordered by name
+func (r *Registry) List() []Subscriber {
+	return r.subscribers
+}
+
+func (r *Registry) Get(name string) (Subscriber, bool) {
+	subscriber, ok := r.byName[name]
+	return subscriber, ok
+}
+
+// Render previews messages of article by subscriber
+func (r *Registry) Render(ctx context.Context, name string, article *model.FeedArticle) (string, error) {
+	subscriber, ok := r.byName[name]
+	if !ok {
+		return "", fmt.Errorf("subscriber %q is not declared", name)
+	}
+
+	renderer, ok := subscriber.(Renderer)
+	if !ok {
+		return "", fmt.Errorf("subscriber %q can not render messages", name)
+	}
+
+	return renderer.Render(ctx, article)
+}
+
+func (r *Registry) Close() error {
+	var errs []error
+	for _, subscriber := range r.subscribers {
+		if err := subscriber.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("subscriber %q: %w", subscriber.Name(), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,94 @@
+package subscribers_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/subscribers"
+	"github.com/sealbro/go-feed-me/pkg/graceful"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSubscriber struct {
+	name       string
+	subscribed bool
+	closed     bool
+	err        error
+}
+
+func (s *fakeSubscriber) Name() string { return s.name }
+
+func (s *fakeSubscriber) Subscribe(_ context.Context) error {
+	s.subscribed = s.err == nil
+	return s.err
+}
+
+func (s *fakeSubscriber) Deliver(_ context.Context, _ []*model.FeedArticle) error { return nil }
+
+func (s *fakeSubscriber) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestRegistry_StartAndClose(t *testing.T) {
+	closer := graceful.NewShutdownCloser()
+	first := &fakeSubscriber{name: "ops"}
+	second := &fakeSubscriber{name: "releases"}
+
+	registry, err := subscribers.NewRegistry(newLogger(t), []subscribers.Subscriber{second, first}, closer)
+	assert.NoError(t, err, "error should be nil")
+
+	assert.NoError(t, registry.Start(context.Background()), "error should be nil")
+	assert.True(t, first.subscribed && second.subscribed, "all subscribers should be started")
+	assert.Equal(t, []subscribers.Subscriber{first, second}, registry.List(), "subscribers should be ordered by name")
+
+	found, ok := registry.Get("releases")
+	assert.True(t, ok)
+	assert.Equal(t, second, found)
+
+	assert.NoError(t, closer.Close(), "error should be nil")
+	assert.True(t, first.closed && second.closed, "graceful closer should close all subscribers")
+}
+
+func TestRegistry_Errors(t *testing.T) {
+	_, err := subscribers.NewRegistry(newLogger(t), []subscribers.Subscriber{
+		&fakeSubscriber{name: "ops"}, &fakeSubscriber{name: "ops"},
+	}, graceful.NewShutdownCloser())
+	assert.Error(t, err, "duplicate names should be rejected")
+
+	registry, err := subscribers.NewRegistry(newLogger(t), []subscribers.Subscriber{
+		&fakeSubscriber{name: "broken", err: errors.New("bad token")},
+	}, graceful.NewShutdownCloser())
+	assert.NoError(t, err, "error should be nil")
+	assert.ErrorContains(t, registry.Start(context.Background()), "broken")
+}
+
+func TestNewDiscordSubscribers(t *testing.T) {
+	t.Setenv("SUBSCRIBER_RELEASES_DISCORD_WEBHOOK_ID", "1")
+	t.Setenv("SUBSCRIBER_RELEASES_DISCORD_WEBHOOK_TOKEN", "releases-token")
+	t.Setenv("SUBSCRIBER_RELEASES_DISCORD_TAGS", "release")
+	t.Setenv("SUBSCRIBER_OPS_TEAM_DISCORD_WEBHOOK_ID", "2")
+	t.Setenv("SUBSCRIBER_OPS_TEAM_DISCORD_WEBHOOK_TOKEN", "ops-token")
+
+	config := &subscribers.Config{Instances: map[string]string{
+		"releases": "discord",
+		"ops-team": "Discord",
+		"team":     "slack",
+	}}
+	defaultConfig := &subscribers.DiscordConfig{WebhookId: 3, WebhookToken: "default-token"}
+
+	instances, err := subscribers.NewDiscordSubscribers(newLogger(t), defaultConfig, config)
+	assert.NoError(t, err, "error should be nil")
+
+	names := make([]string, len(instances))
+	for i, instance := range instances {
+		names[i] = instance.Name()
+		assert.NoError(t, instance.Subscribe(context.Background()), "configured instance should start")
+	}
+	assert.Equal(t, []string{"ops-team", "releases", "discord"}, names, "only discord instances and default one should be created")
+
+	_, err = subscribers.NewDiscordSubscribers(newLogger(t), &subscribers.DiscordConfig{}, &subscribers.Config{})
+	assert.NoError(t, err, "no instances should not be an error")
+}
@@ -0,0 +1,274 @@
+package subscribers
+
+import (
+	"fmt"
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"html"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	markdownConverter  = md.NewConverter("", true, nil)
+	markdownImage      = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	markdownHeader     = regexp.MustCompile(`(?m)^#{1,6}\s+(.+)$`)
+	markdownEmptyLinks = regexp.MustCompile(`\[\s*\]\([^)]*\)`)
+	markdownNewlines   = regexp.MustCompile(`\n{3,}`)
+)
+
+// notification article mapped for chat subscribers, so every platform shows the same title, link,
+// author, resource and image
+type notification struct {
+	// Title article title, link when article has no title
+	Title string
+	// Link http link of article, empty when article link is not http
+	Link       string
+	Author     string
+	Resource   string
+	ResourceId string
+	// Image http link of article image, empty when there is no such image
+	Image     string
+	Published time.Time
+	// Description original html description
+	Description string
+	Tags        []string
+	// Enclosures media files of article with http links
+	Enclosures []*model.Enclosure
+	// Text rendered by user template, replaces description
+	Text string
+	// Footer rendered by user template, replaces resource and author line
+	Footer    string
+	hasText   bool
+	hasFooter bool
+}
+
+func newNotification(article *model.FeedArticle) *notification {
+	link := ""
+	if httpUrl(article.Link) {
+		link = article.Link
+	}
+	image := ""
+	if httpUrl(article.Image) {
+		image = article.Image
+	}
+	enclosures := make([]*model.Enclosure, 0, len(article.Enclosures))
+	for _, enclosure := range article.Enclosures {
+		if httpUrl(enclosure.URL) {
+			enclosures = append(enclosures, enclosure)
+		}
+	}
+
+	return &notification{
+		Title:       strings.TrimSpace(firstNonEmpty(article.Title, article.Link)),
+		Link:        link,
+		Author:      strings.TrimSpace(article.Author),
+		Resource:    strings.TrimSpace(firstNonEmpty(article.ResourceTitle, article.ResourceID)),
+		ResourceId:  article.ResourceID,
+		Image:       image,
+		Published:   article.Published,
+		Description: article.Description,
+		Tags:        article.Tags,
+		Enclosures:  enclosures,
+	}
+}
+
+// newNotifications maps articles oldest first
+func newNotifications(articles []*model.FeedArticle) []*notification {
+	notifications := make([]*notification, 0, len(articles))
+	for _, article := range oldestFirst(articles) {
+		notifications = append(notifications, newNotification(article))
+	}
+
+	return notifications
+}
+
+// Source resource and author in one line, e.g. "Go Blog · by Jane"
+func (n *notification) Source() string {
+	if n.hasFooter {
+		return n.Footer
+	}
+
+	parts := make([]string, 0, 2)
+	if n.Resource != "" {
+		parts = append(parts, n.Resource)
+	}
+	if n.Author != "" {
+		parts = append(parts, "by "+n.Author)
+	}
+
+	return strings.Join(parts, " · ")
+}
+
+// Markdown returns description as common markdown truncated to limit runes followed by media links,
+// text rendered by user template is taken as markdown
+func (n *notification) Markdown(limit int) string {
+	if n.hasText {
+		return truncateMarkdown(n.Text, limit)
+	}
+
+	media := n.media(func(url string, label string) string {
+		return "[" + label + "](" + url + ")"
+	})
+
+	return withMedia(media, limit, func(limit int) string {
+		return truncateMarkdown(markdown(n.Description), limit)
+	})
+}
+
+// HTML returns description sanitized to tags supported by telegram and truncated to limit runes followed by media links,
+// text rendered by user template is taken as html
+func (n *notification) HTML(limit int) string {
+	if n.hasText {
+		return sanitizeTelegramHTML(n.Text, limit)
+	}
+
+	media := n.media(func(url string, label string) string {
+		return `<a href="` + html.EscapeString(url) + `">` + label + `</a>`
+	})
+
+	return withMedia(media, limit, func(limit int) string {
+		return sanitizeTelegramHTML(n.Description, limit)
+	})
+}
+
+// media returns link per audio, video or other media file, e.g. "🎧 Listen · S2E12 · 42:10",
+// images are skipped because article image is shown separately
+func (n *notification) media(link func(url string, label string) string) string {
+	lines := make([]string, 0, len(n.Enclosures))
+	for _, enclosure := range n.Enclosures {
+		kind := strings.ToLower(enclosure.Type)
+		if strings.HasPrefix(kind, "image/") {
+			continue
+		}
+
+		parts := []string{"📎 Download"}
+		switch {
+		case strings.HasPrefix(kind, "audio/"):
+			parts[0] = "🎧 Listen"
+		case strings.HasPrefix(kind, "video/") || kind == "application/x-shockwave-flash":
+			parts[0] = "🎬 Watch"
+		}
+		if enclosure.Episode != nil && enclosure.Season != nil {
+			parts = append(parts, fmt.Sprintf("S%dE%d", *enclosure.Season, *enclosure.Episode))
+		} else if enclosure.Episode != nil {
+			parts = append(parts, fmt.Sprintf("Episode %d", *enclosure.Episode))
+		}
+		if enclosure.Duration != nil {
+			parts = append(parts, formatDuration(*enclosure.Duration))
+		}
+
+		lines = append(lines, link(enclosure.URL, strings.Join(parts, " · ")))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// withMedia appends media links to description truncated to the rest of limit
+func withMedia(media string, limit int, description func(limit int) string) string {
+	if media == "" {
+		return description(limit)
+	}
+
+	text := ""
+	if rest := limit - utf8.RuneCountInString(media) - 2; rest > 0 {
+		text = description(rest)
+	}
+	if text == "" {
+		return media
+	}
+
+	return text + "\n\n" + media
+}
+
+// formatDuration formats seconds like 1:02:03 or 42:10
+func formatDuration(seconds int) string {
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds%3600/60, seconds%60)
+	}
+
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// markdown converts html to common markdown, images are dropped because every platform shows
+// article image separately and headers become bold text
+func markdown(description string) string {
+	if description == "" {
+		return ""
+	}
+
+	text, err := markdownConverter.ConvertString(description)
+	if err != nil {
+		text = plainText(description, math.MaxInt)
+	}
+
+	text = markdownImage.ReplaceAllString(text, "")
+	text = markdownEmptyLinks.ReplaceAllString(text, "")
+	text = markdownHeader.ReplaceAllString(text, "**$1**")
+
+	return markdownNewlines.ReplaceAllString(strings.TrimSpace(text), "\n\n")
+}
+
+// truncateMarkdown truncates text without leaving broken link at the end
+func truncateMarkdown(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	if limit <= 1 {
+		return "…"
+	}
+
+	cut := cutWords(text, limit-1)
+	if open := strings.LastIndex(cut, "["); open > strings.LastIndex(cut, ")") {
+		cut = cut[:open]
+	}
+
+	return strings.TrimSpace(cut) + "…"
+}
+
+func httpUrl(link string) bool {
+	return strings.HasPrefix(link, "https://") || strings.HasPrefix(link, "http://")
+}
+
+// splitByLength groups items in order, so every group has at most count items and total length within limit,
+// an item longer than limit gets own group
+func splitByLength[T any](items []T, length func(T) int, limit int, count int) [][]T {
+	groups := make([][]T, 0, 1)
+	var group []T
+	total := 0
+	for _, item := range items {
+		itemLength := length(item)
+		if len(group) > 0 && (len(group) >= count || total+itemLength > limit) {
+			groups = append(groups, group)
+			group, total = nil, 0
+		}
+		group = append(group, item)
+		total += itemLength
+	}
+	if len(group) > 0 {
+		groups = append(groups, group)
+	}
+
+	return groups
+}
+
+func truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+
+	return string([]rune(text)[:limit-1]) + "…"
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
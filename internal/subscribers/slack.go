@@ -0,0 +1,271 @@
+package subscribers
+
+import (
+	"context"
+	"fmt"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"github.com/sealbro/go-feed-me/pkg/notifier"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const SlackType = "slack"
+
+// Block Kit limits, see https://api.slack.com/reference/block-kit/blocks
+const (
+	slackMaxBlocks          = 50
+	slackMaxSectionText     = 3000
+	slackMaxContextText     = 2000
+	slackMaxContextElements = 10
+	slackMaxImageUrl        = 3000
+	slackMaxAltText         = 2000
+	slackMaxFallbackText    = 4000
+	slackDescriptionLength  = 500
+	// slackBlocksPerArticle section, context and divider
+	slackBlocksPerArticle = 3
+)
+
+var (
+	slackLinkRegexp = regexp.MustCompile(`\[([^\]]*)\]\(([^)\s]+)[^)]*\)`)
+	slackBoldRegexp = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	slackEscaper    = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+type slackMessage struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type      string         `json:"type"`
+	Text      *slackElement  `json:"text,omitempty"`
+	Accessory *slackElement  `json:"accessory,omitempty"`
+	Elements  []slackElement `json:"elements,omitempty"`
+}
+
+// slackElement text object or image element
+type slackElement struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageUrl string `json:"image_url,omitempty"`
+	AltText  string `json:"alt_text,omitempty"`
+}
+
+// SlackSubscriber delivers outbox events to slack incoming webhook as Block Kit messages
+type SlackSubscriber struct {
+	delivery
+	name      string
+	config    *SlackConfig
+	webhook   *chatWebhook
+	templates *messageTemplates
+}
+
+// NewSlackSubscribers creates instances declared in SUBSCRIBERS and the default one configured by SLACK_* variables
+func NewSlackSubscribers(logger *logger.Logger, defaultConfig *SlackConfig, config *Config) ([]Subscriber, error) {
+	instances, err := newInstances(config, SlackType, func(name string, settings *SlackConfig) (Subscriber, error) {
+		return NewSlackSubscriber(logger, name, settings)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if defaultConfig.Enabled() {
+		instance, err := NewSlackSubscriber(logger, SlackType, defaultConfig)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+func NewSlackSubscriber(logger *logger.Logger, name string, config *SlackConfig) (*SlackSubscriber, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	templates, err := newMessageTemplates(config.Template, config.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SlackSubscriber{
+		name:      name,
+		config:    config,
+		webhook:   newChatWebhook(logger, SlackType, name, config.WebhookUrl, config.MaxRetries, config.MaxRetryAfter),
+		templates: templates,
+		delivery:  newDelivery(config.Delivery, defaultBatching),
+	}, nil
+}
+
+func (s *SlackSubscriber) Name() string {
+	return s.name
+}
+
+func (s *SlackSubscriber) Subscribe(_ context.Context) error {
+	return nil
+}
+
+// Deliver sends articles as one or more Block Kit messages
+func (s *SlackSubscriber) Deliver(ctx context.Context, events []*model.FeedArticle) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	messages, err := s.messages(events)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		if err := s.webhook.send(ctx, message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Render returns messages of article as they are sent
+func (s *SlackSubscriber) Render(_ context.Context, event *model.FeedArticle) (string, error) {
+	messages, err := s.messages([]*model.FeedArticle{event})
+	if err != nil {
+		return "", err
+	}
+
+	return renderPayload(messages)
+}
+
+func (s *SlackSubscriber) Close() error {
+	s.webhook.close()
+	return nil
+}
+
+// messages renders articles oldest first and splits them by block limit of a message
+func (s *SlackSubscriber) messages(events []*model.FeedArticle) ([]slackMessage, error) {
+	articles, err := s.templates.notifications(events)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := notifier.SplitBySize(articles, slackMaxBlocks/slackBlocksPerArticle)
+	messages := make([]slackMessage, 0, len(chunks))
+	for _, chunk := range chunks {
+		message := slackMessage{
+			Text:   slackFallbackText(chunk),
+			Blocks: make([]slackBlock, 0, len(chunk)*slackBlocksPerArticle),
+		}
+		for i, article := range chunk {
+			message.Blocks = append(message.Blocks, s.section(article), slackContext(article))
+			if i < len(chunk)-1 {
+				message.Blocks = append(message.Blocks, slackBlock{Type: "divider"})
+			}
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// section renders title link with description and article image as accessory
+func (s *SlackSubscriber) section(article *notification) slackBlock {
+	title := slackEscaper.Replace(strings.ReplaceAll(article.Title, "|", "¦"))
+	heading := "*" + title + "*"
+	if article.Link != "" {
+		heading = fmt.Sprintf("*<%s|%s>*", article.Link, title)
+	}
+	if utf8.RuneCountInString(heading) > slackMaxSectionText {
+		heading = "*" + truncateMrkdwn(title, slackMaxSectionText-2) + "*"
+	}
+
+	text := heading
+	description := slackMrkdwn(article.Markdown(slackDescriptionLength))
+	if remain := slackMaxSectionText - utf8.RuneCountInString(heading) - 1; description != "" && remain > 0 {
+		text += "\n" + truncateMrkdwn(description, remain)
+	}
+
+	block := slackBlock{
+		Type: "section",
+		Text: &slackElement{Type: "mrkdwn", Text: text},
+	}
+	if article.Image != "" && len(article.Image) <= slackMaxImageUrl {
+		block.Accessory = &slackElement{
+			Type:     "image",
+			ImageUrl: article.Image,
+			AltText:  truncate(firstNonEmpty(article.Title, article.Resource, "image"), slackMaxAltText),
+		}
+	}
+
+	return block
+}
+
+// slackMrkdwn converts common markdown to slack flavour of markdown
+func slackMrkdwn(markdown string) string {
+	markdown = slackEscaper.Replace(markdown)
+	markdown = slackLinkRegexp.ReplaceAllStringFunc(markdown, func(link string) string {
+		groups := slackLinkRegexp.FindStringSubmatch(link)
+		text := strings.ReplaceAll(groups[1], "|", "¦")
+		if text == "" {
+			return "<" + groups[2] + ">"
+		}
+		return "<" + groups[2] + "|" + text + ">"
+	})
+
+	return slackBoldRegexp.ReplaceAllString(markdown, "*$1*")
+}
+
+// slackContext renders resource title, author and published date
+func slackContext(article *notification) slackBlock {
+	elements := make([]slackElement, 0, slackMaxContextElements)
+	if article.hasFooter {
+		if article.Footer != "" {
+			elements = append(elements, slackElement{Type: "mrkdwn", Text: truncateMrkdwn(slackMrkdwn(article.Footer), slackMaxContextText)})
+		}
+	} else if article.Resource != "" {
+		elements = append(elements, slackElement{Type: "mrkdwn", Text: truncateMrkdwn(slackEscaper.Replace(article.Resource), slackMaxContextText)})
+	}
+	if article.Author != "" && !article.hasFooter {
+		elements = append(elements, slackElement{Type: "mrkdwn", Text: truncateMrkdwn("by "+slackEscaper.Replace(article.Author), slackMaxContextText)})
+	}
+	if !article.Published.IsZero() {
+		published := fmt.Sprintf("<!date^%d^{date_short_pretty} {time}|%s>", article.Published.Unix(), article.Published.UTC().Format(time.RFC1123))
+		elements = append(elements, slackElement{Type: "mrkdwn", Text: published})
+	}
+	if len(elements) == 0 {
+		elements = append(elements, slackElement{Type: "mrkdwn", Text: slackEscaper.Replace(truncate(firstNonEmpty(article.Link, article.Title), slackMaxContextText))})
+	}
+
+	return slackBlock{Type: "context", Elements: elements}
+}
+
+// slackFallbackText used by notifications and clients which can not render blocks
+func slackFallbackText(articles []*notification) string {
+	titles := make([]string, len(articles))
+	for i, article := range articles {
+		titles[i] = article.Title
+	}
+
+	return truncate(slackEscaper.Replace(strings.Join(titles, "\n")), slackMaxFallbackText)
+}
+
+// truncateMrkdwn truncates text without leaving broken link or escaped entity at the end
+func truncateMrkdwn(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+
+	truncated := []rune(text)[:limit-1]
+	cut := string(truncated)
+	if open := strings.LastIndex(cut, "<"); open > strings.LastIndex(cut, ">") {
+		cut = cut[:open]
+	}
+	if amp := strings.LastIndex(cut, "&"); amp >= 0 && !strings.Contains(cut[amp:], ";") {
+		cut = cut[:amp]
+	}
+
+	return strings.TrimSpace(cut) + "…"
+}
@@ -0,0 +1,31 @@
+package subscribers
+
+import (
+	"fmt"
+	"time"
+)
+
+type SlackConfig struct {
+	WebhookUrl string         `envconfig:"SLACK_WEBHOOK_URL"`
+	Delivery   DeliveryConfig `envconfig:"SLACK"`
+	// Template path of text/template rendering markdown of section, optional "title" and "footer" templates replace title and source
+	Template string `envconfig:"SLACK_TEMPLATE"`
+	// Templates paths of templates by tags or resource urls, e.g. /etc/feed/releases.tmpl:release|https://go.dev/blog/feed.atom
+	Templates map[string]string `envconfig:"SLACK_TEMPLATES"`
+	// MaxRetries how many times 429 responses are retried in place before batch goes back to outbox
+	MaxRetries int `envconfig:"SLACK_MAX_RETRIES" default:"3"`
+	// MaxRetryAfter upper bound of waiting requested by Retry-After header
+	MaxRetryAfter time.Duration `envconfig:"SLACK_MAX_RETRY_AFTER" default:"1m"`
+}
+
+func (c *SlackConfig) Enabled() bool {
+	return c.WebhookUrl != ""
+}
+
+func (c *SlackConfig) Validate() error {
+	if !c.Enabled() {
+		return fmt.Errorf("slack webhook url is required")
+	}
+
+	return c.Delivery.Validate()
+}
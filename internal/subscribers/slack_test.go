@@ -0,0 +1,150 @@
+package subscribers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sealbro/go-feed-me/internal/subscribers"
+	"github.com/stretchr/testify/assert"
+)
+
+type slackElement struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ImageUrl string `json:"image_url"`
+	AltText  string `json:"alt_text"`
+}
+
+type slackBlock struct {
+	Type      string         `json:"type"`
+	Text      *slackElement  `json:"text"`
+	Accessory *slackElement  `json:"accessory"`
+	Elements  []slackElement `json:"elements"`
+}
+
+type slackMessage struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+func newSlackSubscriber(t *testing.T, receiver *webhookReceiver) *subscribers.SlackSubscriber {
+	subscriber, err := subscribers.NewSlackSubscriber(newLogger(t), "slack", &subscribers.SlackConfig{
+		WebhookUrl:    newServer(t, receiver),
+		MaxRetries:    2,
+		MaxRetryAfter: time.Second,
+	})
+	assert.NoError(t, err, "error should be nil")
+
+	return subscriber
+}
+
+func TestSlackSubscriber_Deliver(t *testing.T) {
+	receiver := &webhookReceiver{response: "ok"}
+	subscriber := newSlackSubscriber(t, receiver)
+
+	articles := testArticles()
+	articles[0].Title = "Second <release>"
+	err := subscriber.Deliver(context.Background(), articles)
+	assert.NoError(t, err, "error should be nil")
+
+	messages := decodeRequests[slackMessage](t, receiver)
+	assert.Len(t, messages, 1, "articles should be sent in one message")
+	blocks := messages[0].Blocks
+	assert.Equal(t, []string{"section", "context", "divider", "section", "context"}, blockTypes(blocks))
+
+	first := blocks[0]
+	assert.Equal(t, "*<https://example.com/1|First>*\nRead *more* at <https://example.com/docs|docs>", first.Text.Text)
+	assert.Equal(t, "image", first.Accessory.Type)
+	assert.Equal(t, "https://example.com/1.png", first.Accessory.ImageUrl)
+	assert.Equal(t, "First", first.Accessory.AltText)
+
+	elements := blocks[1].Elements
+	assert.Len(t, elements, 3, "resource, author and date should be in context")
+	assert.Equal(t, "Example blog", elements[0].Text)
+	assert.Equal(t, "by Jane", elements[1].Text)
+	assert.Contains(t, elements[2].Text, fmt.Sprintf("<!date^%d^", publishedAt.Unix()))
+
+	second := blocks[3]
+	assert.Equal(t, "*<https://example.com/2|Second &lt;release&gt;>*", second.Text.Text, "title should be escaped")
+	assert.Nil(t, second.Accessory, "article without image should have no accessory")
+}
+
+func TestSlackSubscriber_Limits(t *testing.T) {
+	receiver := &webhookReceiver{response: "ok"}
+	subscriber := newSlackSubscriber(t, receiver)
+
+	articles := longArticles(40)
+	for _, article := range articles {
+		article.Image = "ftp://example.com/image.png"
+	}
+
+	err := subscriber.Deliver(context.Background(), articles)
+	assert.NoError(t, err, "error should be nil")
+
+	messages := decodeRequests[slackMessage](t, receiver)
+	assert.Len(t, messages, 3, "articles should be split by block limit")
+	count := 0
+	for _, message := range messages {
+		assert.LessOrEqual(t, len(message.Blocks), 50, "message should respect block limit")
+		for _, block := range message.Blocks {
+			if block.Type != "section" {
+				continue
+			}
+			count++
+			assert.LessOrEqual(t, utf8.RuneCountInString(block.Text.Text), 3000, "section should respect text limit")
+			assert.True(t, strings.HasSuffix(block.Text.Text, "…"), "description should be truncated")
+			assert.Nil(t, block.Accessory, "only http images should be attached")
+		}
+	}
+	assert.Equal(t, 40, count, "all articles should be sent")
+}
+
+func TestSlackSubscriber_RateLimit(t *testing.T) {
+	articles := testArticles()[1:]
+
+	receiver := &webhookReceiver{statuses: []int{http.StatusTooManyRequests, http.StatusTooManyRequests}, response: "ok"}
+	subscriber := newSlackSubscriber(t, receiver)
+	assert.NoError(t, subscriber.Deliver(context.Background(), articles), "rate limited request should be retried")
+	assert.Len(t, receiver.requests, 3)
+	assert.Len(t, decodeRequests[slackMessage](t, receiver), 1)
+
+	receiver = &webhookReceiver{statuses: []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests}}
+	subscriber = newSlackSubscriber(t, receiver)
+	assert.ErrorContains(t, subscriber.Deliver(context.Background(), articles), "rate limited", "batch should go back to outbox after retries")
+	assert.Len(t, receiver.requests, 3)
+
+	receiver = &webhookReceiver{statuses: []int{http.StatusBadRequest}}
+	subscriber = newSlackSubscriber(t, receiver)
+	assert.ErrorContains(t, subscriber.Deliver(context.Background(), articles), "400")
+	assert.Len(t, receiver.requests, 1, "other errors should not be retried in place")
+}
+
+func TestNewSlackSubscribers(t *testing.T) {
+	t.Setenv("SUBSCRIBER_TEAM_SLACK_WEBHOOK_URL", "https://hooks.slack.com/services/team")
+
+	instances, err := subscribers.NewSlackSubscribers(newLogger(t),
+		&subscribers.SlackConfig{WebhookUrl: "https://hooks.slack.com/services/default"},
+		&subscribers.Config{Instances: map[string]string{"team": "slack", "ops": "discord"}})
+	assert.NoError(t, err, "error should be nil")
+
+	names := make([]string, len(instances))
+	for i, instance := range instances {
+		names[i] = instance.Name()
+		assert.NoError(t, instance.Subscribe(context.Background()), "error should be nil")
+	}
+	assert.Equal(t, []string{"team", "slack"}, names)
+}
+
+func blockTypes(blocks []slackBlock) []string {
+	types := make([]string, len(blocks))
+	for i, block := range blocks {
+		types[i] = block.Type
+	}
+
+	return types
+}
@@ -0,0 +1,148 @@
+package subscribers
+
+import (
+	"context"
+	"fmt"
+	"github.com/kelseyhightower/envconfig"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Subscriber notifies external target about new articles, every instance has unique name
+type Subscriber interface {
+	// Name unique name of instance, outbox keeps delivery cursor by it
+	Name() string
+	// Subscribe prepares subscriber before the first delivery
+	Subscribe(ctx context.Context) error
+	// Deliver sends batch of articles, error keeps them in outbox for retry,
+	// so messages sent before the failed one are repeated on retry
+	Deliver(ctx context.Context, articles []*model.FeedArticle) error
+	// Close releases subscriber resources
+	Close() error
+}
+
+// Renderer subscriber which previews its messages
+type Renderer interface {
+	// Render returns messages of article as subscriber sends them
+	Render(ctx context.Context, article *model.FeedArticle) (string, error)
+}
+
+var envconfigTag = regexp.MustCompile(`envconfig:"[^"]*"\s*`)
+
+// Config declares subscriber instances as name:type pairs, e.g. SUBSCRIBERS=releases:discord,ops:discord
+type Config struct {
+	Instances map[string]string `envconfig:"SUBSCRIBERS"`
+}
+
+// InstancePrefix returns environment prefix of instance settings, e.g. SUBSCRIBER_RELEASES for releases
+func InstancePrefix(name string) string {
+	replacer := strings.NewReplacer("-", "_", ".", "_", " ", "_")
+	return "SUBSCRIBER_" + strings.ToUpper(replacer.Replace(name))
+}
+
+// newInstances creates all configured instances of subscriber type ordered by name,
+// settings of every instance are read from environment with instance prefix
+func newInstances[TConfig any](config *Config, kind string, create func(name string, settings *TConfig) (Subscriber, error)) ([]Subscriber, error) {
+	names := make([]string, 0)
+	for name, instanceKind := range config.Instances {
+		if strings.EqualFold(strings.TrimSpace(instanceKind), kind) {
+			names = append(names, strings.TrimSpace(name))
+		}
+	}
+	sort.Strings(names)
+
+	instances := make([]Subscriber, 0, len(names))
+	for _, name := range names {
+		settings := new(TConfig)
+		if err := processInstance(InstancePrefix(name), settings); err != nil {
+			return nil, fmt.Errorf("can not load settings of %s subscriber %q: %w", kind, name, err)
+		}
+
+		instance, err := create(name, settings)
+		if err != nil {
+			return nil, fmt.Errorf("can not create %s subscriber %q: %w", kind, name, err)
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+// processInstance reads settings of instance only from environment keys with prefix. Envconfig falls back
+// to keys of envconfig tags without prefix, so instance would silently inherit webhooks and tokens of the default one,
+// settings are read into struct type whose fields are named by their keys and have no envconfig tags instead
+func processInstance[TConfig any](prefix string, settings *TConfig) error {
+	value := reflect.ValueOf(settings).Elem()
+	fields := make([]reflect.StructField, value.NumField())
+	for i := range fields {
+		field := value.Type().Field(i)
+		if key, ok := field.Tag.Lookup("envconfig"); ok {
+			field.Name = key
+			field.Tag = reflect.StructTag(envconfigTag.ReplaceAllString(string(field.Tag), ""))
+		}
+		fields[i] = field
+	}
+
+	prefixed := reflect.New(reflect.StructOf(fields)).Elem()
+	if err := envconfig.Process(prefix, prefixed.Addr().Interface()); err != nil {
+		return err
+	}
+	for i := range fields {
+		value.Field(i).Set(prefixed.Field(i))
+	}
+
+	return nil
+}
+
+// oldestFirst reverses batch of articles, feeds and outbox keep the newest article first
+func oldestFirst(events []*model.FeedArticle) []*model.FeedArticle {
+	ordered := make([]*model.FeedArticle, len(events))
+	for i, event := range events {
+		ordered[len(events)-1-i] = event
+	}
+
+	return ordered
+}
+
+// sleep waits before retry of rate limited request, returns ctx error when ctx is done earlier
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// articleRule matches articles of resources or with any of tags, configured like release|https://go.dev/blog/feed.atom
+type articleRule struct {
+	resources []string
+	tags      []string
+}
+
+func newArticleRule(values string) articleRule {
+	rule := articleRule{}
+	for _, value := range strings.Split(values, "|") {
+		if value = strings.TrimSpace(value); httpUrl(value) {
+			rule.resources = append(rule.resources, value)
+		} else if value != "" {
+			rule.tags = append(rule.tags, value)
+		}
+	}
+	rule.tags = storage.NormalizeTags(rule.tags)
+
+	return rule
+}
+
+func (r articleRule) match(article *model.FeedArticle) bool {
+	return slices.Contains(r.resources, article.ResourceID) || len(r.tags) > 0 && article.HasAnyTag(r.tags)
+}
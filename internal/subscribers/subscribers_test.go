@@ -0,0 +1,174 @@
+package subscribers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/db"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+var publishedAt = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+func newLogger(t *testing.T) *logger.Logger {
+	newLogger, err := logger.NewLogger(&logger.Config{LogLevel: "ERROR"})
+	assert.NoError(t, err, "error should be nil")
+
+	return newLogger
+}
+
+func newDatabase(t *testing.T) *db.DB {
+	gormLogger := logger.NewGormLogger(newLogger(t))
+	database, err := db.NewSqliteDatabase(gormLogger, &db.Config{SqliteConnection: filepath.Join(t.TempDir(), "feed.db")})
+	assert.NoError(t, err, "error should be nil")
+
+	return database
+}
+
+// newServer returns url of server closed with test
+func newServer(t *testing.T, handler http.Handler) string {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+type webhookRequest struct {
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+	Status int
+}
+
+// webhookReceiver responds with given statuses before success, response is sent with every status
+type webhookReceiver struct {
+	m        sync.Mutex
+	statuses []int
+	delay    time.Duration
+	response string
+	requests []webhookRequest
+}
+
+func (r *webhookReceiver) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	body, _ := io.ReadAll(request.Body)
+
+	r.m.Lock()
+	status := http.StatusNoContent
+	if r.response != "" {
+		status = http.StatusOK
+	}
+	if len(r.statuses) > 0 {
+		status = r.statuses[0]
+		r.statuses = r.statuses[1:]
+		writer.Header().Set("Retry-After", "0")
+	}
+	r.requests = append(r.requests, webhookRequest{
+		Path:   request.URL.Path,
+		Query:  request.URL.Query(),
+		Header: request.Header.Clone(),
+		Body:   body,
+		Status: status,
+	})
+	delay, response := r.delay, r.response
+	r.m.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-request.Context().Done():
+			return
+		}
+	}
+	if response == "" {
+		writer.WriteHeader(status)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	_, _ = writer.Write([]byte(response))
+}
+
+// decodeRequests returns JSON bodies of successful requests
+func decodeRequests[T any](t *testing.T, receiver *webhookReceiver) []T {
+	var messages []T
+	for _, request := range receiver.requests {
+		if request.Status >= http.StatusMultipleChoices {
+			continue
+		}
+		var message T
+		assert.NoError(t, json.Unmarshal(request.Body, &message), "error should be nil")
+		messages = append(messages, message)
+	}
+
+	return messages
+}
+
+// testArticles returns articles newest first like outbox delivers them
+func testArticles() []*model.FeedArticle {
+	return []*model.FeedArticle{
+		{
+			ResourceID:    "https://example.com/feed",
+			ResourceTitle: "Example blog",
+			Title:         "Second",
+			Link:          "https://example.com/2",
+		},
+		{
+			ResourceID:    "https://example.com/feed",
+			ResourceTitle: "Example blog",
+			Title:         "First",
+			Link:          "https://example.com/1",
+			Author:        "Jane",
+			Image:         "https://example.com/1.png",
+			Published:     publishedAt,
+			Description:   `<p>Read <b>more</b> at <a href="https://example.com/docs">docs</a></p>`,
+		},
+	}
+}
+
+// longArticles returns articles with descriptions over limits of every platform
+func longArticles(count int) []*model.FeedArticle {
+	articles := make([]*model.FeedArticle, count)
+	for i := range articles {
+		articles[i] = &model.FeedArticle{
+			ResourceTitle: "Example blog",
+			Title:         fmt.Sprintf("Article %d", i),
+			Link:          fmt.Sprintf("https://example.com/%d", i),
+			Description:   "<p>" + strings.Repeat("long description ", 1000) + "</p>",
+		}
+	}
+
+	return articles
+}
+
+// pushArticles returns articles matching priority rules of push notifications
+func pushArticles() []*model.FeedArticle {
+	return []*model.FeedArticle{
+		{
+			ResourceID:    "https://example.com/feed",
+			ResourceTitle: "Example blog",
+			Title:         "Weekly notes",
+			Link:          "https://example.com/notes",
+		},
+		{
+			ResourceID:    "https://github.com/golang/go/releases.atom",
+			ResourceTitle: "Go releases",
+			Title:         "go1.23.1",
+			Link:          "https://github.com/golang/go/releases/tag/go1.23.1",
+			Image:         "https://github.com/golang.png",
+			Description:   "<p>Security <b>fixes</b></p>",
+			Tags:          []string{"release", "security"},
+		},
+	}
+}
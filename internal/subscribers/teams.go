@@ -0,0 +1,233 @@
+package subscribers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"strings"
+)
+
+const TeamsType = "teams"
+
+// Teams limits, see https://learn.microsoft.com/en-us/microsoftteams/platform/webhooks-and-connectors/how-to/connectors-using
+const (
+	// teamsMaxPayload keeps messages below 28 KB limit of webhook request with room for envelope
+	teamsMaxPayload         = 25 * 1024
+	teamsMaxTitle           = 300
+	teamsMaxUrl             = 2048
+	teamsDescriptionLength  = 500
+	teamsCardVersion        = "1.4"
+	teamsAdaptiveCardSchema = "http://adaptivecards.io/schemas/adaptive-card.json"
+	teamsAdaptiveCardType   = "application/vnd.microsoft.card.adaptive"
+)
+
+var teamsTitleEscaper = strings.NewReplacer("[", "(", "]", ")", "\n", " ")
+
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string    `json:"contentType"`
+	Content     teamsCard `json:"content"`
+}
+
+type teamsCard struct {
+	Schema  string           `json:"$schema"`
+	Type    string           `json:"type"`
+	Version string           `json:"version"`
+	MsTeams teamsCardOptions `json:"msteams"`
+	Body    []teamsElement   `json:"body"`
+}
+
+type teamsCardOptions struct {
+	Width string `json:"width"`
+}
+
+// teamsElement container, text block or image of Adaptive Card
+type teamsElement struct {
+	Type         string         `json:"type"`
+	Text         string         `json:"text,omitempty"`
+	Url          string         `json:"url,omitempty"`
+	AltText      string         `json:"altText,omitempty"`
+	Size         string         `json:"size,omitempty"`
+	Weight       string         `json:"weight,omitempty"`
+	IsSubtle     bool           `json:"isSubtle,omitempty"`
+	Wrap         bool           `json:"wrap,omitempty"`
+	Spacing      string         `json:"spacing,omitempty"`
+	Separator    bool           `json:"separator,omitempty"`
+	Items        []teamsElement `json:"items,omitempty"`
+	SelectAction *teamsAction   `json:"selectAction,omitempty"`
+}
+
+type teamsAction struct {
+	Type string `json:"type"`
+	Url  string `json:"url"`
+}
+
+// TeamsSubscriber delivers outbox events to Microsoft Teams webhook as Adaptive Card messages
+type TeamsSubscriber struct {
+	delivery
+	name      string
+	config    *TeamsConfig
+	webhook   *chatWebhook
+	templates *messageTemplates
+}
+
+// NewTeamsSubscribers creates instances declared in SUBSCRIBERS and the default one configured by TEAMS_* variables
+func NewTeamsSubscribers(logger *logger.Logger, defaultConfig *TeamsConfig, config *Config) ([]Subscriber, error) {
+	instances, err := newInstances(config, TeamsType, func(name string, settings *TeamsConfig) (Subscriber, error) {
+		return NewTeamsSubscriber(logger, name, settings)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if defaultConfig.Enabled() {
+		instance, err := NewTeamsSubscriber(logger, TeamsType, defaultConfig)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+func NewTeamsSubscriber(logger *logger.Logger, name string, config *TeamsConfig) (*TeamsSubscriber, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	templates, err := newMessageTemplates(config.Template, config.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TeamsSubscriber{
+		name:      name,
+		config:    config,
+		webhook:   newChatWebhook(logger, TeamsType, name, config.WebhookUrl, config.MaxRetries, config.MaxRetryAfter),
+		templates: templates,
+		delivery:  newDelivery(config.Delivery, defaultBatching),
+	}, nil
+}
+
+func (s *TeamsSubscriber) Name() string {
+	return s.name
+}
+
+func (s *TeamsSubscriber) Subscribe(_ context.Context) error {
+	return nil
+}
+
+// Deliver sends articles as one or more cards
+func (s *TeamsSubscriber) Deliver(ctx context.Context, events []*model.FeedArticle) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	articles, err := s.templates.notifications(events)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range teamsMessages(articles) {
+		if err := s.webhook.send(ctx, message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Render returns messages of article as they are sent
+func (s *TeamsSubscriber) Render(_ context.Context, event *model.FeedArticle) (string, error) {
+	articles, err := s.templates.notifications([]*model.FeedArticle{event})
+	if err != nil {
+		return "", err
+	}
+
+	return renderPayload(teamsMessages(articles))
+}
+
+func (s *TeamsSubscriber) Close() error {
+	s.webhook.close()
+	return nil
+}
+
+// teamsMessages renders article containers and splits them by payload size
+func teamsMessages(articles []*notification) []teamsMessage {
+	containers := make([]teamsElement, len(articles))
+	for i, article := range articles {
+		containers[i] = teamsContainer(article)
+	}
+
+	chunks := splitByLength(containers, func(container teamsElement) int {
+		encoded, _ := json.Marshal(container)
+		return len(encoded)
+	}, teamsMaxPayload, len(containers))
+
+	messages := make([]teamsMessage, 0, len(chunks))
+	for _, chunk := range chunks {
+		for i := range chunk {
+			chunk[i].Separator = i > 0
+		}
+		messages = append(messages, teamsMessage{
+			Type: "message",
+			Attachments: []teamsAttachment{{
+				ContentType: teamsAdaptiveCardType,
+				Content: teamsCard{
+					Schema:  teamsAdaptiveCardSchema,
+					Type:    "AdaptiveCard",
+					Version: teamsCardVersion,
+					MsTeams: teamsCardOptions{Width: "Full"},
+					Body:    chunk,
+				},
+			}},
+		})
+	}
+
+	return messages
+}
+
+// teamsContainer renders title link, source with published date, image and summary of article
+func teamsContainer(article *notification) teamsElement {
+	title := teamsTitleEscaper.Replace(truncate(article.Title, teamsMaxTitle))
+	link := article.Link
+	if len(link) > teamsMaxUrl {
+		link = ""
+	}
+	if link != "" {
+		title = "[" + title + "](" + link + ")"
+	}
+
+	items := []teamsElement{{Type: "TextBlock", Text: title, Size: "Medium", Weight: "Bolder", Wrap: true}}
+
+	source := article.Source()
+	if !article.Published.IsZero() {
+		// Adaptive Card formats date in time zone of reader
+		published := article.Published.UTC().Format("2006-01-02T15:04:05Z")
+		source = strings.TrimPrefix(source+" · {{DATE("+published+", SHORT)}} {{TIME("+published+")}}", " · ")
+	}
+	if source != "" {
+		items = append(items, teamsElement{Type: "TextBlock", Text: source, Size: "Small", IsSubtle: true, Wrap: true, Spacing: "None"})
+	}
+
+	if article.Image != "" && len(article.Image) <= teamsMaxUrl {
+		items = append(items, teamsElement{Type: "Image", Url: article.Image, AltText: truncate(article.Title, teamsMaxTitle), Size: "Large"})
+	}
+
+	if description := article.Markdown(teamsDescriptionLength); description != "" {
+		items = append(items, teamsElement{Type: "TextBlock", Text: description, Wrap: true})
+	}
+
+	container := teamsElement{Type: "Container", Items: items}
+	if link != "" {
+		container.SelectAction = &teamsAction{Type: "Action.OpenUrl", Url: link}
+	}
+
+	return container
+}
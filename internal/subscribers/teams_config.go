@@ -0,0 +1,35 @@
+package subscribers
+
+import (
+	"fmt"
+	"time"
+)
+
+type TeamsConfig struct {
+	// WebhookUrl incoming webhook or workflow url accepting Adaptive Card messages
+	WebhookUrl string         `envconfig:"TEAMS_WEBHOOK_URL"`
+	Delivery   DeliveryConfig `envconfig:"TEAMS"`
+	// Template path of text/template rendering markdown of card, optional "title" and "footer" templates replace title and source
+	Template string `envconfig:"TEAMS_TEMPLATE"`
+	// Templates paths of templates by tags or resource urls, e.g. /etc/feed/releases.tmpl:release|https://go.dev/blog/feed.atom
+	Templates map[string]string `envconfig:"TEAMS_TEMPLATES"`
+	// MaxRetries how many times 429 responses are retried in place before batch goes back to outbox
+	MaxRetries int `envconfig:"TEAMS_MAX_RETRIES" default:"3"`
+	// MaxRetryAfter upper bound of waiting requested by Retry-After header
+	MaxRetryAfter time.Duration `envconfig:"TEAMS_MAX_RETRY_AFTER" default:"1m"`
+}
+
+func (c *TeamsConfig) Enabled() bool {
+	return c.WebhookUrl != ""
+}
+
+func (c *TeamsConfig) Validate() error {
+	if !c.Enabled() {
+		return fmt.Errorf("teams webhook url is required")
+	}
+	if !httpUrl(c.WebhookUrl) {
+		return fmt.Errorf("teams webhook url should be http url")
+	}
+
+	return c.Delivery.Validate()
+}
@@ -0,0 +1,126 @@
+package subscribers_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sealbro/go-feed-me/internal/subscribers"
+	"github.com/stretchr/testify/assert"
+)
+
+type teamsElement struct {
+	Type         string         `json:"type"`
+	Text         string         `json:"text"`
+	Url          string         `json:"url"`
+	Separator    bool           `json:"separator"`
+	Items        []teamsElement `json:"items"`
+	SelectAction *struct {
+		Url string `json:"url"`
+	} `json:"selectAction"`
+}
+
+type teamsMessage struct {
+	Type        string `json:"type"`
+	Attachments []struct {
+		ContentType string `json:"contentType"`
+		Content     struct {
+			Type    string         `json:"type"`
+			Version string         `json:"version"`
+			Body    []teamsElement `json:"body"`
+		} `json:"content"`
+	} `json:"attachments"`
+}
+
+func newTeamsSubscriber(t *testing.T, receiver *webhookReceiver) *subscribers.TeamsSubscriber {
+	subscriber, err := subscribers.NewTeamsSubscriber(newLogger(t), "teams", &subscribers.TeamsConfig{
+		WebhookUrl:    newServer(t, receiver),
+		MaxRetries:    2,
+		MaxRetryAfter: 10 * time.Millisecond,
+	})
+	assert.NoError(t, err, "error should be nil")
+
+	return subscriber
+}
+
+func TestTeamsSubscriber_Deliver(t *testing.T) {
+	receiver := &webhookReceiver{}
+	subscriber := newTeamsSubscriber(t, receiver)
+	assert.NoError(t, subscriber.Subscribe(context.Background()), "error should be nil")
+
+	articles := testArticles()
+	articles[0].Title = "Second [draft]"
+	articles[1].Description = `<h2>Notes</h2><p>Read <b>more</b> at <a href="https://example.com/docs">docs</a><img src="https://example.com/inline.png"></p>`
+	err := subscriber.Deliver(context.Background(), articles)
+	assert.NoError(t, err, "error should be nil")
+
+	messages := decodeRequests[teamsMessage](t, receiver)
+	assert.Len(t, messages, 1, "articles should be sent in one message")
+	assert.Equal(t, "message", messages[0].Type)
+	assert.Equal(t, "application/vnd.microsoft.card.adaptive", messages[0].Attachments[0].ContentType)
+
+	card := messages[0].Attachments[0].Content
+	assert.Equal(t, "AdaptiveCard", card.Type)
+	assert.Len(t, card.Body, 2)
+
+	first := card.Body[0]
+	assert.Equal(t, "Container", first.Type)
+	assert.False(t, first.Separator, "the first article should not be separated")
+	assert.Equal(t, "https://example.com/1", first.SelectAction.Url)
+	assert.Len(t, first.Items, 4, "title, source, image and summary should be rendered")
+	assert.Equal(t, "[First](https://example.com/1)", first.Items[0].Text)
+	assert.Equal(t, "Example blog · by Jane · {{DATE(2024-05-01T10:00:00Z, SHORT)}} {{TIME(2024-05-01T10:00:00Z)}}", first.Items[1].Text)
+	assert.Equal(t, "https://example.com/1.png", first.Items[2].Url)
+	assert.Equal(t, "**Notes**\n\nRead **more** at [docs](https://example.com/docs)", first.Items[3].Text)
+
+	second := card.Body[1]
+	assert.True(t, second.Separator, "articles should be separated")
+	assert.Equal(t, "[Second (draft)](https://example.com/2)", second.Items[0].Text, "brackets should not break title link")
+	assert.Len(t, second.Items, 2, "article without image and description should have title and source")
+}
+
+func TestTeamsSubscriber_Limits(t *testing.T) {
+	receiver := &webhookReceiver{}
+	subscriber := newTeamsSubscriber(t, receiver)
+
+	articles := longArticles(60)
+	for _, article := range articles {
+		article.Title += " " + strings.Repeat("title ", 100)
+	}
+	assert.NoError(t, subscriber.Deliver(context.Background(), articles), "error should be nil")
+
+	assert.Greater(t, len(receiver.requests), 1, "articles should be split by payload size")
+	count := 0
+	for i, message := range decodeRequests[teamsMessage](t, receiver) {
+		assert.LessOrEqual(t, len(receiver.requests[i].Body), 28*1024, "message should respect payload limit")
+		count += len(message.Attachments[0].Content.Body)
+	}
+	assert.Equal(t, 60, count, "all articles should be sent")
+}
+
+func TestTeamsSubscriber_RateLimit(t *testing.T) {
+	articles := testArticles()[1:]
+
+	receiver := &webhookReceiver{statuses: []int{http.StatusTooManyRequests}}
+	subscriber := newTeamsSubscriber(t, receiver)
+	assert.NoError(t, subscriber.Deliver(context.Background(), articles), "rate limited request should be retried")
+	assert.Len(t, receiver.requests, 2)
+
+	receiver = &webhookReceiver{statuses: []int{http.StatusBadRequest}}
+	subscriber = newTeamsSubscriber(t, receiver)
+	assert.ErrorContains(t, subscriber.Deliver(context.Background(), articles), "teams webhook responded 400")
+	assert.Len(t, receiver.requests, 1, "other errors should not be retried in place")
+}
+
+func TestTeamsConfig_Validate(t *testing.T) {
+	config := &subscribers.TeamsConfig{WebhookUrl: "https://example.webhook.office.com/webhookb2/id"}
+	assert.NoError(t, config.Validate(), "error should be nil")
+
+	config.WebhookUrl = "example.webhook.office.com"
+	assert.Error(t, config.Validate(), "only http urls should be accepted")
+
+	config.WebhookUrl = ""
+	assert.Error(t, config.Validate(), "url should be required")
+}
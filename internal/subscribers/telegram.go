@@ -0,0 +1,324 @@
+package subscribers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const TelegramType = "telegram"
+
+// Bot API limits, see https://core.telegram.org/bots/api#sendmessage
+const (
+	telegramMaxMessage        = 4096
+	telegramMaxCaption        = 1024
+	telegramDescriptionLength = 1000
+)
+
+type telegramMessage struct {
+	ChatId             string                      `json:"chat_id"`
+	Text               string                      `json:"text,omitempty"`
+	Photo              string                      `json:"photo,omitempty"`
+	Caption            string                      `json:"caption,omitempty"`
+	ParseMode          string                      `json:"parse_mode"`
+	LinkPreviewOptions *telegramLinkPreviewOptions `json:"link_preview_options,omitempty"`
+}
+
+type telegramLinkPreviewOptions struct {
+	IsDisabled bool `json:"is_disabled"`
+}
+
+type telegramResponse struct {
+	Ok          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  *struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// TelegramError error returned by Bot API
+type TelegramError struct {
+	Method      string
+	Code        int
+	Description string
+	RetryAfter  time.Duration
+}
+
+func (e *TelegramError) Error() string {
+	return fmt.Sprintf("telegram %s failed with %d: %s", e.Method, e.Code, e.Description)
+}
+
+// TelegramSubscriber delivers outbox events to telegram chats by Bot API
+type TelegramSubscriber struct {
+	delivery
+	name      string
+	config    *TelegramConfig
+	logger    *logger.Logger
+	client    *http.Client
+	templates *messageTemplates
+}
+
+// NewTelegramSubscribers creates instances declared in SUBSCRIBERS and the default one configured by TELEGRAM_* variables
+func NewTelegramSubscribers(logger *logger.Logger, defaultConfig *TelegramConfig, config *Config) ([]Subscriber, error) {
+	instances, err := newInstances(config, TelegramType, func(name string, settings *TelegramConfig) (Subscriber, error) {
+		return NewTelegramSubscriber(logger, name, settings)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if defaultConfig.Enabled() {
+		instance, err := NewTelegramSubscriber(logger, TelegramType, defaultConfig)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+func NewTelegramSubscriber(logger *logger.Logger, name string, config *TelegramConfig) (*TelegramSubscriber, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	templates, err := newMessageTemplates(config.Template, config.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TelegramSubscriber{
+		name:      name,
+		logger:    logger,
+		config:    config,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		templates: templates,
+		delivery:  newDelivery(config.Delivery, defaultBatching),
+	}, nil
+}
+
+func (s *TelegramSubscriber) Name() string {
+	return s.name
+}
+
+func (s *TelegramSubscriber) Subscribe(_ context.Context) error {
+	return nil
+}
+
+// Deliver sends articles to every chat
+func (s *TelegramSubscriber) Deliver(ctx context.Context, events []*model.FeedArticle) error {
+	if len(events) == 0 {
+		return nil
+	}
+	articles, err := s.templates.notifications(events)
+	if err != nil {
+		return err
+	}
+
+	for _, chatId := range s.config.ChatIds {
+		if s.config.Mode == TelegramDigestMode {
+			if err := s.sendText(ctx, chatId, telegramDigest(articles), true); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, article := range articles {
+			if err := s.sendArticle(ctx, chatId, article); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Render returns html text of article as it is sent, the beginning of text is a caption of image when photos are sent
+func (s *TelegramSubscriber) Render(_ context.Context, event *model.FeedArticle) (string, error) {
+	article, err := s.templates.notification(event)
+	if err != nil {
+		return "", err
+	}
+
+	if s.config.Mode == TelegramDigestMode {
+		return telegramDigest([]*notification{article}), nil
+	}
+
+	return telegramArticle(article), nil
+}
+
+func (s *TelegramSubscriber) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// sendArticle sends article image with the beginning of text as caption and the rest as messages,
+// text is sent without image when telegram can not take the image
+func (s *TelegramSubscriber) sendArticle(ctx context.Context, chatId string, article *notification) error {
+	text := telegramArticle(article)
+	if !s.config.SendPhoto || article.Image == "" {
+		return s.sendText(ctx, chatId, text, false)
+	}
+
+	parts := splitTelegramHTML(text, telegramMaxCaption)
+	if len(parts) == 0 {
+		return nil
+	}
+	err := s.call(ctx, "sendPhoto", telegramMessage{
+		ChatId:    chatId,
+		Photo:     article.Image,
+		Caption:   parts[0],
+		ParseMode: "HTML",
+	})
+
+	var telegramError *TelegramError
+	if errors.As(err, &telegramError) && telegramError.Code == http.StatusBadRequest {
+		s.logger.WarnContext(ctx, "Telegram rejected article image", slog.String("subscriber", s.name),
+			slog.String("image", article.Image), slog.Any("error", err))
+		return s.sendText(ctx, chatId, text, false)
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.sendText(ctx, chatId, strings.Join(parts[1:], ""), false)
+}
+
+// sendText sends text split by message limit
+func (s *TelegramSubscriber) sendText(ctx context.Context, chatId string, text string, disablePreview bool) error {
+	for _, part := range splitTelegramHTML(text, telegramMaxMessage) {
+		message := telegramMessage{
+			ChatId:    chatId,
+			Text:      part,
+			ParseMode: "HTML",
+		}
+		if disablePreview {
+			message.LinkPreviewOptions = &telegramLinkPreviewOptions{IsDisabled: true}
+		}
+
+		if err := s.call(ctx, "sendMessage", message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// call invokes Bot API method, rate limited requests are retried after retry_after delay
+func (s *TelegramSubscriber) call(ctx context.Context, method string, message telegramMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = s.post(ctx, method, body)
+
+		var telegramError *TelegramError
+		if !errors.As(err, &telegramError) || telegramError.Code != http.StatusTooManyRequests || attempt >= s.config.MaxRetries {
+			return err
+		}
+
+		retryAfter := min(telegramError.RetryAfter, s.config.MaxRetryAfter)
+		s.logger.WarnContext(ctx, "Telegram rate limited bot", slog.String("subscriber", s.name), slog.Duration("retry_after", retryAfter))
+
+		if err := sleep(ctx, retryAfter); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *TelegramSubscriber) post(ctx context.Context, method string, body []byte) error {
+	url := fmt.Sprintf("%s/bot%s/%s", strings.TrimRight(s.config.ApiUrl, "/"), s.config.BotToken, method)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		// url error contains bot token
+		return fmt.Errorf("telegram %s request failed: %w", method, errors.Unwrap(err))
+	}
+	defer response.Body.Close()
+
+	result := telegramResponse{}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return &TelegramError{Method: method, Code: response.StatusCode, Description: "can not decode response"}
+	}
+	if result.Ok {
+		return nil
+	}
+
+	telegramError := &TelegramError{Method: method, Code: result.ErrorCode, Description: result.Description, RetryAfter: time.Second}
+	if telegramError.Code == 0 {
+		telegramError.Code = response.StatusCode
+	}
+	if result.Parameters != nil && result.Parameters.RetryAfter >= 0 {
+		telegramError.RetryAfter = time.Duration(result.Parameters.RetryAfter) * time.Second
+	}
+
+	return telegramError
+}
+
+// telegramArticle renders title link, resource with author and sanitized description
+func telegramArticle(article *notification) string {
+	var builder strings.Builder
+	builder.WriteString(telegramLink(article))
+
+	source := make([]string, 0, 2)
+	if article.hasFooter {
+		if article.Footer != "" {
+			source = append(source, telegramEscaper.Replace(article.Footer))
+		}
+	} else if article.Resource != "" {
+		source = append(source, "<i>"+telegramEscaper.Replace(article.Resource)+"</i>")
+	}
+	if article.Author != "" && !article.hasFooter {
+		source = append(source, "by "+telegramEscaper.Replace(article.Author))
+	}
+	if len(source) > 0 {
+		builder.WriteString("\n" + strings.Join(source, " · "))
+	}
+
+	if description := article.HTML(telegramDescriptionLength); description != "" {
+		builder.WriteString("\n\n" + description)
+	}
+
+	return builder.String()
+}
+
+// telegramDigest renders list of article links with resources
+func telegramDigest(articles []*notification) string {
+	lines := make([]string, 0, len(articles)+2)
+	lines = append(lines, fmt.Sprintf("<b>%d new articles</b>", len(articles)), "")
+	for _, article := range articles {
+		line := "• " + telegramLink(article)
+		if article.Resource != "" {
+			line += " — <i>" + telegramEscaper.Replace(article.Resource) + "</i>"
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func telegramLink(article *notification) string {
+	title := telegramEscaper.Replace(truncate(article.Title, telegramMaxCaption/2))
+	if article.Link == "" {
+		return "<b>" + title + "</b>"
+	}
+
+	return `<b><a href="` + telegramAttributeEscaper.Replace(article.Link) + `">` + title + "</a></b>"
+}
@@ -0,0 +1,46 @@
+package subscribers
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// TelegramArticleMode sends every article as separate message
+	TelegramArticleMode = "article"
+	// TelegramDigestMode sends batch of articles as one list
+	TelegramDigestMode = "digest"
+)
+
+type TelegramConfig struct {
+	BotToken string `envconfig:"TELEGRAM_BOT_TOKEN"`
+	// ChatIds numeric ids or @channel usernames
+	ChatIds   []string       `envconfig:"TELEGRAM_CHAT_IDS"`
+	ApiUrl    string         `envconfig:"TELEGRAM_API_URL" default:"https://api.telegram.org"`
+	Mode      string         `envconfig:"TELEGRAM_MODE" default:"article"`
+	SendPhoto bool           `envconfig:"TELEGRAM_SEND_PHOTO" default:"false"`
+	Delivery  DeliveryConfig `envconfig:"TELEGRAM"`
+	// Template path of text/template rendering html of message, optional "title" and "footer" templates replace title and source
+	Template string `envconfig:"TELEGRAM_TEMPLATE"`
+	// Templates paths of templates by tags or resource urls, e.g. /etc/feed/releases.tmpl:release|https://go.dev/blog/feed.atom
+	Templates map[string]string `envconfig:"TELEGRAM_TEMPLATES"`
+	// MaxRetries how many times rate limited requests are retried in place before batch goes back to outbox
+	MaxRetries int `envconfig:"TELEGRAM_MAX_RETRIES" default:"3"`
+	// MaxRetryAfter upper bound of waiting requested by retry_after
+	MaxRetryAfter time.Duration `envconfig:"TELEGRAM_MAX_RETRY_AFTER" default:"1m"`
+}
+
+func (c *TelegramConfig) Enabled() bool {
+	return c.BotToken != "" && len(c.ChatIds) > 0
+}
+
+func (c *TelegramConfig) Validate() error {
+	if !c.Enabled() {
+		return fmt.Errorf("telegram bot token and chat ids are required")
+	}
+	if c.Mode != TelegramArticleMode && c.Mode != TelegramDigestMode {
+		return fmt.Errorf("unknown telegram mode %q, expected %s or %s", c.Mode, TelegramArticleMode, TelegramDigestMode)
+	}
+
+	return c.Delivery.Validate()
+}
@@ -0,0 +1,338 @@
+package subscribers
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	telegramEscaper          = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	telegramAttributeEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+	telegramTrailingSpaces   = regexp.MustCompile(`[ \t]+\n`)
+	telegramNewlines         = regexp.MustCompile(`\n{3,}`)
+)
+
+// telegramTags html tags mapped to tags supported by telegram HTML parse mode
+var telegramTags = map[string]string{
+	"b": "b", "strong": "b", "h1": "b", "h2": "b", "h3": "b", "h4": "b", "h5": "b", "h6": "b",
+	"i": "i", "em": "i", "cite": "i",
+	"u": "u", "ins": "u",
+	"s": "s", "strike": "s", "del": "s",
+	"code": "code", "pre": "pre", "blockquote": "blockquote",
+}
+
+// telegramBlocks html tags separated from surrounding text by new lines
+var telegramBlocks = map[string]string{
+	"p": "\n\n", "h1": "\n\n", "h2": "\n\n", "h3": "\n\n", "h4": "\n\n", "h5": "\n\n", "h6": "\n\n",
+	"div": "\n", "ul": "\n", "ol": "\n", "table": "\n", "tr": "\n", "pre": "\n", "blockquote": "\n",
+	"figure": "\n", "section": "\n", "article": "\n",
+}
+
+// telegramSkipped html tags dropped together with content
+var telegramSkipped = map[string]bool{
+	"script": true, "style": true, "noscript": true, "head": true, "title": true, "iframe": true,
+	"img": true, "svg": true, "video": true, "audio": true, "object": true, "embed": true, "form": true,
+}
+
+type telegramSanitizer struct {
+	builder strings.Builder
+	limit   int
+	length  int
+	pre     int
+	done    bool
+}
+
+// sanitizeTelegramHTML keeps only tags supported by telegram and truncates visible text to limit
+func sanitizeTelegramHTML(text string, limit int) string {
+	nodes, err := html.ParseFragment(strings.NewReader(text), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return telegramEscaper.Replace(truncate(text, limit))
+	}
+
+	sanitizer := &telegramSanitizer{limit: limit}
+	for _, node := range nodes {
+		sanitizer.walk(node)
+	}
+
+	sanitized := telegramTrailingSpaces.ReplaceAllString(sanitizer.builder.String(), "\n")
+	sanitized = telegramNewlines.ReplaceAllString(sanitized, "\n\n")
+
+	return strings.TrimSpace(sanitized)
+}
+
+func (s *telegramSanitizer) walk(node *html.Node) {
+	if s.done {
+		return
+	}
+
+	if node.Type == html.TextNode {
+		s.text(node.Data)
+		return
+	}
+	if node.Type != html.ElementNode {
+		s.children(node)
+		return
+	}
+
+	name := node.Data
+	if telegramSkipped[name] {
+		return
+	}
+	switch name {
+	case "br":
+		s.newline("\n")
+		return
+	case "li":
+		s.newline("\n")
+		s.write("• ", 2)
+	}
+
+	tag, open := telegramTags[name], ""
+	if tag != "" {
+		open = "<" + tag + ">"
+	}
+	if name == "a" {
+		if href := attribute(node, "href"); strings.HasPrefix(href, "https://") || strings.HasPrefix(href, "http://") {
+			tag, open = "a", `<a href="`+telegramAttributeEscaper.Replace(href)+`">`
+		}
+	}
+
+	s.newline(telegramBlocks[name])
+	s.write(open, 0)
+	if tag == "pre" {
+		s.pre++
+	}
+	s.children(node)
+	if tag == "pre" {
+		s.pre--
+	}
+	if open != "" {
+		s.write("</"+tag+">", 0)
+	}
+	s.newline(telegramBlocks[name])
+}
+
+func (s *telegramSanitizer) children(node *html.Node) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		s.walk(child)
+	}
+}
+
+func (s *telegramSanitizer) text(text string) {
+	if s.pre == 0 {
+		text = collapseSpaces(text)
+		if s.atLineStart() {
+			text = strings.TrimLeftFunc(text, unicode.IsSpace)
+		}
+	}
+	if text == "" {
+		return
+	}
+
+	if s.length+utf8.RuneCountInString(text) > s.limit {
+		text = cutWords(text, s.limit-s.length) + "…"
+		s.done = true
+	}
+
+	s.write(telegramEscaper.Replace(text), utf8.RuneCountInString(text))
+}
+
+func (s *telegramSanitizer) newline(newline string) {
+	if newline != "" && !s.atLineStart() {
+		s.write(newline, len(newline))
+	}
+}
+
+func (s *telegramSanitizer) write(text string, length int) {
+	s.builder.WriteString(text)
+	s.length += length
+}
+
+func (s *telegramSanitizer) atLineStart() bool {
+	text := s.builder.String()
+	trimmed := strings.TrimRight(text, " ")
+	for strings.HasSuffix(trimmed, ">") {
+		// open tags do not start new line
+		start := strings.LastIndex(trimmed, "<")
+		if start < 0 || strings.HasPrefix(trimmed[start:], "</") {
+			break
+		}
+		trimmed = trimmed[:start]
+	}
+
+	return trimmed == "" || strings.HasSuffix(trimmed, "\n") || strings.HasSuffix(trimmed, "• ")
+}
+
+// splitTelegramHTML splits sanitized html by byte limit, tags open at split point are closed and reopened in the next part
+func splitTelegramHTML(text string, limit int) []string {
+	if len(text) <= limit {
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		return []string{text}
+	}
+
+	type openTag struct {
+		name string
+		raw  string
+	}
+
+	var (
+		parts   []string
+		stack   []openTag
+		current strings.Builder
+		hasText bool
+	)
+	closing := func() string {
+		var builder strings.Builder
+		for i := len(stack) - 1; i >= 0; i-- {
+			builder.WriteString("</" + stack[i].name + ">")
+		}
+		return builder.String()
+	}
+	flush := func() {
+		if hasText {
+			parts = append(parts, strings.TrimSpace(current.String()+closing()))
+		}
+		current.Reset()
+		hasText = false
+		for _, tag := range stack {
+			current.WriteString(tag.raw)
+		}
+	}
+
+	tokenizer := html.NewTokenizer(strings.NewReader(text))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			if tokenizer.Err() != io.EOF {
+				return []string{truncate(text, limit)}
+			}
+			break
+		}
+
+		raw := string(tokenizer.Raw())
+		switch tokenType {
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			if current.Len()+len(raw)+len(closing())+len(name)+3 > limit {
+				flush()
+			}
+			current.WriteString(raw)
+			stack = append(stack, openTag{name: string(name), raw: raw})
+		case html.EndTagToken:
+			current.WriteString(raw)
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case html.TextToken:
+			for raw != "" {
+				available := limit - current.Len() - len(closing())
+				if len(raw) <= available {
+					current.WriteString(raw)
+					hasText = hasText || strings.TrimSpace(raw) != ""
+					break
+				}
+
+				cut := splitPoint(raw, available)
+				if cut <= 0 && hasText {
+					flush()
+					continue
+				}
+				if cut <= 0 {
+					cut = max(safeCut(raw, max(available, 1)), 1)
+				}
+
+				current.WriteString(raw[:cut])
+				hasText = true
+				flush()
+				raw = strings.TrimLeft(raw[cut:], " \n")
+			}
+		}
+	}
+	flush()
+
+	return parts
+}
+
+// splitPoint returns the last new line or space before limit
+func splitPoint(text string, limit int) int {
+	if limit <= 0 {
+		return 0
+	}
+	prefix := text[:safeCut(text, limit)]
+	if index := strings.LastIndex(prefix, "\n"); index > 0 {
+		return index
+	}
+	if index := strings.LastIndex(prefix, " "); index > 0 {
+		return index
+	}
+
+	return 0
+}
+
+// safeCut moves byte index back to not split rune or escaped entity
+func safeCut(text string, index int) int {
+	index = min(index, len(text))
+	for index > 0 && index < len(text) && !utf8.RuneStart(text[index]) {
+		index--
+	}
+	if amp := strings.LastIndex(text[:index], "&"); amp >= 0 && !strings.Contains(text[amp:index], ";") {
+		index = amp
+	}
+
+	return index
+}
+
+func collapseSpaces(text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		if text != "" {
+			return " "
+		}
+		return ""
+	}
+
+	collapsed := strings.Join(fields, " ")
+	if first, _ := utf8.DecodeRuneInString(text); unicode.IsSpace(first) {
+		collapsed = " " + collapsed
+	}
+	if last, _ := utf8.DecodeLastRuneInString(text); unicode.IsSpace(last) {
+		collapsed += " "
+	}
+
+	return collapsed
+}
+
+// cutWords cuts text to limit runes at word boundary when possible
+func cutWords(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	if limit <= 0 {
+		return ""
+	}
+
+	cut := string(runes[:limit])
+	if index := strings.LastIndexFunc(cut, unicode.IsSpace); index > len(cut)/2 {
+		cut = cut[:index]
+	}
+
+	return strings.TrimRightFunc(cut, unicode.IsSpace)
+}
+
+func attribute(node *html.Node, name string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == name {
+			return attr.Val
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,212 @@
+package subscribers_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/subscribers"
+	"github.com/stretchr/testify/assert"
+)
+
+type telegramCall struct {
+	Method             string
+	ChatId             string `json:"chat_id"`
+	Text               string `json:"text"`
+	Photo              string `json:"photo"`
+	Caption            string `json:"caption"`
+	ParseMode          string `json:"parse_mode"`
+	LinkPreviewOptions *struct {
+		IsDisabled bool `json:"is_disabled"`
+	} `json:"link_preview_options"`
+}
+
+// telegramBotApi fake Bot API, responds with given errors before success
+type telegramBotApi struct {
+	m        sync.Mutex
+	errors   []string
+	requests int
+	calls    []telegramCall
+}
+
+func (a *telegramBotApi) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	a.requests++
+	if !strings.HasPrefix(request.URL.Path, "/bottoken/") {
+		writer.WriteHeader(http.StatusNotFound)
+		_, _ = writer.Write([]byte(`{"ok":false,"error_code":404,"description":"Not Found"}`))
+		return
+	}
+	if len(a.errors) > 0 {
+		response := a.errors[0]
+		a.errors = a.errors[1:]
+		writer.WriteHeader(http.StatusBadRequest)
+		_, _ = writer.Write([]byte(response))
+		return
+	}
+
+	call := telegramCall{Method: strings.TrimPrefix(request.URL.Path, "/bottoken/")}
+	if err := json.NewDecoder(request.Body).Decode(&call); err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	a.calls = append(a.calls, call)
+	_, _ = writer.Write([]byte(`{"ok":true,"result":{}}`))
+}
+
+func newTelegramSubscriber(t *testing.T, api *telegramBotApi, configure func(config *subscribers.TelegramConfig)) *subscribers.TelegramSubscriber {
+	config := &subscribers.TelegramConfig{
+		BotToken:      "token",
+		ChatIds:       []string{"-100"},
+		ApiUrl:        newServer(t, api),
+		Mode:          subscribers.TelegramArticleMode,
+		MaxRetries:    2,
+		MaxRetryAfter: time.Second,
+	}
+	if configure != nil {
+		configure(config)
+	}
+	subscriber, err := subscribers.NewTelegramSubscriber(newLogger(t), "telegram", config)
+	assert.NoError(t, err, "error should be nil")
+	assert.NoError(t, subscriber.Subscribe(context.Background()), "error should be nil")
+
+	return subscriber
+}
+
+func TestTelegramSubscriber_Article(t *testing.T) {
+	api := &telegramBotApi{}
+	subscriber := newTelegramSubscriber(t, api, func(config *subscribers.TelegramConfig) {
+		config.ChatIds = []string{"-100", "@channel"}
+	})
+
+	err := subscriber.Deliver(context.Background(), []*model.FeedArticle{
+		{
+			Title:         "Second",
+			Link:          "https://example.com/2",
+			ResourceTitle: "Example & Co",
+		},
+		{
+			Title:         "First <release>",
+			Link:          "https://example.com/1?a=1&b=2",
+			ResourceTitle: "Example & Co",
+			Author:        "Jane",
+			Description: `<h2>Changes</h2><p>Read <strong>more</strong> at <a href="https://example.com/docs">docs</a>` +
+				`<img src="https://example.com/1.png"></p><script>alert(1)</script>` +
+				`<ul><li>first</li><li><span class="x">second</span></li></ul><a href="javascript:alert(1)">bad</a>`,
+		},
+	})
+	assert.NoError(t, err, "error should be nil")
+
+	assert.Len(t, api.calls, 4, "every article should be sent to every chat")
+	first := api.calls[0]
+	assert.Equal(t, "sendMessage", first.Method)
+	assert.Equal(t, "-100", first.ChatId)
+	assert.Equal(t, "HTML", first.ParseMode)
+	assert.Equal(t, `<b><a href="https://example.com/1?a=1&amp;b=2">First &lt;release&gt;</a></b>`+"\n"+
+		`<i>Example &amp; Co</i> · by Jane`+"\n\n"+
+		`<b>Changes</b>`+"\n\n"+
+		`Read <b>more</b> at <a href="https://example.com/docs">docs</a>`+"\n\n"+
+		"• first\n• second\nbad", first.Text)
+	assert.Equal(t, `<b><a href="https://example.com/2">Second</a></b>`+"\n"+`<i>Example &amp; Co</i>`, api.calls[1].Text)
+	assert.Equal(t, "@channel", api.calls[2].ChatId)
+}
+
+func TestTelegramSubscriber_Digest(t *testing.T) {
+	api := &telegramBotApi{}
+	subscriber := newTelegramSubscriber(t, api, func(config *subscribers.TelegramConfig) {
+		config.Mode = subscribers.TelegramDigestMode
+	})
+
+	articles := make([]*model.FeedArticle, 100)
+	for i := range articles {
+		articles[i] = &model.FeedArticle{
+			Title:         fmt.Sprintf("Article number %d with rather long title", i),
+			Link:          fmt.Sprintf("https://example.com/articles/%d", i),
+			ResourceTitle: "Example blog",
+		}
+	}
+
+	err := subscriber.Deliver(context.Background(), articles)
+	assert.NoError(t, err, "error should be nil")
+
+	assert.Greater(t, len(api.calls), 1, "long digest should be split")
+	text := ""
+	for _, call := range api.calls {
+		assert.LessOrEqual(t, len(call.Text), 4096, "message should respect length limit")
+		assert.True(t, call.LinkPreviewOptions.IsDisabled, "digest should not show link preview")
+		assert.Equal(t, strings.Count(call.Text, "<a "), strings.Count(call.Text, "</a>"), "tags should be closed in every part")
+		text += call.Text + "\n"
+	}
+	assert.True(t, strings.HasPrefix(text, "<b>100 new articles</b>"))
+	for i := range articles {
+		assert.Contains(t, text, fmt.Sprintf(`<a href="https://example.com/articles/%d">`, i), "all articles should be sent")
+	}
+}
+
+func TestTelegramSubscriber_Photo(t *testing.T) {
+	article := &model.FeedArticle{
+		Title:       "First",
+		Link:        "https://example.com/1",
+		Image:       "https://example.com/1.png",
+		Description: "<p>" + strings.Repeat("<b>long</b> description ", 200) + "</p>",
+	}
+
+	api := &telegramBotApi{}
+	subscriber := newTelegramSubscriber(t, api, func(config *subscribers.TelegramConfig) {
+		config.SendPhoto = true
+	})
+	assert.NoError(t, subscriber.Deliver(context.Background(), []*model.FeedArticle{article}), "error should be nil")
+
+	assert.Len(t, api.calls, 2, "text over caption limit should be sent as message")
+	assert.Equal(t, "sendPhoto", api.calls[0].Method)
+	assert.Equal(t, article.Image, api.calls[0].Photo)
+	assert.LessOrEqual(t, len(api.calls[0].Caption), 1024, "caption should respect length limit")
+	assert.Equal(t, "sendMessage", api.calls[1].Method)
+	assert.True(t, strings.HasSuffix(api.calls[1].Text, "…"), "description should be truncated")
+
+	api = &telegramBotApi{errors: []string{`{"ok":false,"error_code":400,"description":"Bad Request: wrong file identifier/HTTP URL specified"}`}}
+	subscriber = newTelegramSubscriber(t, api, func(config *subscribers.TelegramConfig) {
+		config.SendPhoto = true
+	})
+	assert.NoError(t, subscriber.Deliver(context.Background(), []*model.FeedArticle{article}), "rejected image should not fail delivery")
+
+	assert.Len(t, api.calls, 1)
+	assert.Equal(t, "sendMessage", api.calls[0].Method, "article should be sent without image")
+}
+
+func TestTelegramSubscriber_RateLimit(t *testing.T) {
+	articles := testArticles()[1:]
+	rateLimited := `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 0","parameters":{"retry_after":0}}`
+
+	api := &telegramBotApi{errors: []string{rateLimited, rateLimited}}
+	subscriber := newTelegramSubscriber(t, api, nil)
+	assert.NoError(t, subscriber.Deliver(context.Background(), articles), "rate limited request should be retried")
+	assert.Equal(t, 3, api.requests)
+	assert.Len(t, api.calls, 1)
+
+	api = &telegramBotApi{errors: []string{rateLimited, rateLimited, rateLimited}}
+	subscriber = newTelegramSubscriber(t, api, nil)
+	err := subscriber.Deliver(context.Background(), articles)
+	assert.ErrorContains(t, err, "429", "batch should go back to outbox after retries")
+	assert.NotContains(t, err.Error(), "token", "error should not leak bot token")
+	assert.Equal(t, 3, api.requests)
+}
+
+func TestTelegramConfig_Validate(t *testing.T) {
+	config := &subscribers.TelegramConfig{BotToken: "token", ChatIds: []string{"1"}, Mode: "weekly"}
+	assert.Error(t, config.Validate(), "unknown mode should be rejected")
+
+	config.Mode = subscribers.TelegramDigestMode
+	assert.NoError(t, config.Validate(), "error should be nil")
+
+	config.ChatIds = nil
+	assert.Error(t, config.Validate(), "chat ids should be required")
+}
@@ -0,0 +1,204 @@
+package subscribers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+	"time"
+)
+
+// templateFuncs helpers of message templates, e.g. {{.Description | markdown | truncate 300}}
+var templateFuncs = texttemplate.FuncMap{
+	"truncate": func(limit int, text string) string {
+		if limit <= 0 {
+			return ""
+		}
+		return truncate(text, limit)
+	},
+	"markdown": func(description string) string {
+		return markdown(description)
+	},
+	"stripHTML": func(description string) string {
+		return plainText(description, math.MaxInt)
+	},
+	"date": func(layout string, value time.Time) string {
+		return value.Format(layout)
+	},
+}
+
+// templateRule template of articles matching rule
+type templateRule struct {
+	articleRule
+	template *texttemplate.Template
+}
+
+// messageTemplates user templates of chat subscriber, template of article resource wins over template of article tags,
+// articles matching no rule use the default template or built-in rendering
+type messageTemplates struct {
+	fallback *texttemplate.Template
+	rules    []templateRule
+}
+
+// newMessageTemplates parses default template and templates by rules like /etc/feed/releases.tmpl:release|https://go.dev/blog/feed.atom,
+// every template is executed with sample article, so broken templates fail on startup
+func newMessageTemplates(path string, rules map[string]string) (*messageTemplates, error) {
+	templates := &messageTemplates{}
+
+	if path != "" {
+		template, err := parseMessageTemplate(path)
+		if err != nil {
+			return nil, err
+		}
+		templates.fallback = template
+	}
+
+	paths := make([]string, 0, len(rules))
+	for rulePath := range rules {
+		paths = append(paths, rulePath)
+	}
+	// order of paths keeps choice stable when article matches several tag rules
+	sort.Strings(paths)
+	for _, rulePath := range paths {
+		template, err := parseMessageTemplate(rulePath)
+		if err != nil {
+			return nil, err
+		}
+		templates.rules = append(templates.rules, templateRule{articleRule: newArticleRule(rules[rulePath]), template: template})
+	}
+
+	return templates, nil
+}
+
+// notifications maps articles oldest first and renders them by templates
+func (t *messageTemplates) notifications(events []*model.FeedArticle) ([]*notification, error) {
+	articles := make([]*notification, 0, len(events))
+	for _, event := range oldestFirst(events) {
+		article, err := t.notification(event)
+		if err != nil {
+			return nil, err
+		}
+		articles = append(articles, article)
+	}
+
+	return articles, nil
+}
+
+// notification maps article and renders title, footer and text by its template
+func (t *messageTemplates) notification(event *model.FeedArticle) (*notification, error) {
+	article := newNotification(event)
+	template := t.lookup(event)
+	if template == nil {
+		return article, nil
+	}
+
+	if err := renderMessageTemplate(template, article); err != nil {
+		return nil, fmt.Errorf("can not render template %s for %s: %w", template.Name(), event.Link, err)
+	}
+
+	return article, nil
+}
+
+func (t *messageTemplates) lookup(event *model.FeedArticle) *texttemplate.Template {
+	for _, rule := range t.rules {
+		if slices.Contains(rule.resources, event.ResourceID) {
+			return rule.template
+		}
+	}
+	for _, rule := range t.rules {
+		if rule.match(event) {
+			return rule.template
+		}
+	}
+
+	return t.fallback
+}
+
+func parseMessageTemplate(path string) (*texttemplate.Template, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can not read template: %w", err)
+	}
+
+	template, err := texttemplate.New(filepath.Base(path)).Funcs(templateFuncs).Parse(string(source))
+	if err != nil {
+		return nil, fmt.Errorf("can not parse template %s: %w", path, err)
+	}
+
+	sample := &notification{
+		Title:       "Sample article",
+		Link:        "https://example.com/article",
+		Author:      "Author",
+		Resource:    "Sample feed",
+		ResourceId:  "https://example.com/feed",
+		Image:       "https://example.com/image.png",
+		Published:   time.Now(),
+		Description: "<p>Sample <b>description</b></p>",
+		Tags:        []string{"sample"},
+		Enclosures:  []*model.Enclosure{{URL: "https://example.com/episode.mp3", Type: "audio/mpeg", Thumbnail: "https://example.com/image.png"}},
+	}
+	if err := renderMessageTemplate(template, sample); err != nil {
+		return nil, fmt.Errorf("can not execute template %s: %w", path, err)
+	}
+
+	return template, nil
+}
+
+// renderMessageTemplate replaces title and source line of article by "title" and "footer" templates when they are defined
+// and renders text of article by the main template when it is not empty
+func renderMessageTemplate(template *texttemplate.Template, article *notification) error {
+	data := *article
+
+	if title := template.Lookup("title"); title != nil {
+		text, err := executeTemplate(title, &data)
+		if err != nil {
+			return err
+		}
+		article.Title = firstNonEmpty(text, article.Title)
+	}
+
+	if footer := template.Lookup("footer"); footer != nil {
+		text, err := executeTemplate(footer, &data)
+		if err != nil {
+			return err
+		}
+		article.Footer, article.hasFooter = text, true
+	}
+
+	if template.Tree != nil && !parse.IsEmptyTree(template.Tree.Root) {
+		text, err := executeTemplate(template, &data)
+		if err != nil {
+			return err
+		}
+		article.Text, article.hasText = text, true
+	}
+
+	return nil
+}
+
+func executeTemplate(template *texttemplate.Template, data *notification) (string, error) {
+	var buffer bytes.Buffer
+	if err := template.Execute(&buffer, data); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(buffer.String()), nil
+}
+
+// renderPayload returns indented json of messages for preview
+func renderPayload(messages any) (string, error) {
+	payload, err := json.MarshalIndent(messages, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(payload), nil
+}
@@ -0,0 +1,229 @@
+package subscribers_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/subscribers"
+	"github.com/sealbro/go-feed-me/pkg/graceful"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTemplate(t *testing.T, name string, source string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(source), 0o600), "error should be nil")
+
+	return path
+}
+
+func templateArticle() *model.FeedArticle {
+	return &model.FeedArticle{
+		ResourceID:    "https://github.com/golang/go/releases.atom",
+		ResourceTitle: "Go releases",
+		Title:         "go1.23.0",
+		Link:          "https://github.com/golang/go/releases/tag/go1.23.0",
+		Author:        "gopher",
+		Published:     time.Date(2024, 8, 13, 10, 0, 0, 0, time.UTC),
+		Description:   `<p>Release <b>notes</b> of go1.23.0 with many changes</p>`,
+		Tags:          []string{"release"},
+	}
+}
+
+// telegramConfig returns valid config of telegram subscriber with template
+func telegramConfig(template string) *subscribers.TelegramConfig {
+	return &subscribers.TelegramConfig{BotToken: "token", ChatIds: []string{"-100"}, Mode: subscribers.TelegramArticleMode, Template: template}
+}
+
+func renderMattermost(t *testing.T, config *subscribers.MattermostConfig, article *model.FeedArticle) mattermostAttachment {
+	config.WebhookUrl = "https://mattermost.example.com/hooks/1"
+	subscriber, err := subscribers.NewMattermostSubscriber(newLogger(t), "mattermost", config)
+	assert.NoError(t, err, "error should be nil")
+
+	rendered, err := subscriber.Render(context.Background(), article)
+	assert.NoError(t, err, "error should be nil")
+
+	var messages []mattermostMessage
+	assert.NoError(t, json.Unmarshal([]byte(rendered), &messages), "preview should be json of messages")
+	assert.Len(t, messages, 1)
+	assert.Len(t, messages[0].Attachments, 1)
+
+	return messages[0].Attachments[0]
+}
+
+func TestMessageTemplates_Render(t *testing.T) {
+	releases := writeTemplate(t, "releases.tmpl", `{{define "title"}}🚀 {{.Title}}{{end}}
+{{define "footer"}}{{.Resource}} · {{date "2006-01-02" .Published}}{{end}}
+{{.Description | markdown | truncate 20}}`)
+	blog := writeTemplate(t, "blog.tmpl", `{{.Description | stripHTML}}`)
+	titles := writeTemplate(t, "titles.tmpl", `{{define "title"}}[{{.Resource}}] {{.Title}}{{end}}`)
+
+	testCases := []struct {
+		name      string
+		configure func(article *model.FeedArticle) *subscribers.MattermostConfig
+		expect    mattermostAttachment
+	}{
+		{
+			name: "built-in",
+			configure: func(article *model.FeedArticle) *subscribers.MattermostConfig {
+				return &subscribers.MattermostConfig{}
+			},
+			expect: mattermostAttachment{Title: "go1.23.0", AuthorName: "gopher", Text: "Release **notes** of go1.23.0 with many changes", Footer: "Go releases"},
+		},
+		{
+			name: "default template",
+			configure: func(article *model.FeedArticle) *subscribers.MattermostConfig {
+				return &subscribers.MattermostConfig{Template: releases}
+			},
+			expect: mattermostAttachment{Title: "🚀 go1.23.0", Text: "Release **notes** o…", Footer: "Go releases · 2024-08-13"},
+		},
+		{
+			name: "resource template wins over tag template",
+			configure: func(article *model.FeedArticle) *subscribers.MattermostConfig {
+				return &subscribers.MattermostConfig{Template: releases, Templates: map[string]string{
+					blog:   "https://github.com/golang/go/releases.atom",
+					titles: "release",
+				}}
+			},
+			expect: mattermostAttachment{Title: "go1.23.0", AuthorName: "gopher", Text: "Release notes of go1.23.0 with many changes", Footer: "Go releases"},
+		},
+		{
+			name: "title only template keeps description",
+			configure: func(article *model.FeedArticle) *subscribers.MattermostConfig {
+				article.ResourceID = "https://go.dev/blog/feed.atom"
+				return &subscribers.MattermostConfig{Templates: map[string]string{titles: "release"}}
+			},
+			expect: mattermostAttachment{Title: "[Go releases] go1.23.0", AuthorName: "gopher", Text: "Release **notes** of go1.23.0 with many changes", Footer: "Go releases"},
+		},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			article := templateArticle()
+			attachment := renderMattermost(t, testCase.configure(article), article)
+
+			assert.Equal(t, testCase.expect.Title, attachment.Title)
+			assert.Equal(t, testCase.expect.AuthorName, attachment.AuthorName)
+			assert.Equal(t, testCase.expect.Text, attachment.Text)
+			assert.Equal(t, testCase.expect.Footer, attachment.Footer)
+		})
+	}
+}
+
+func TestMessageTemplates_Validate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		source string
+		expect string
+	}{
+		{name: "syntax", source: `{{.Title`, expect: "can not parse template"},
+		{name: "unknown field", source: `{{.Summary}}`, expect: "can not execute template"},
+		{name: "unknown function", source: `{{.Title | upper}}`, expect: "can not parse template"},
+		{name: "wrong argument", source: `{{truncate .Title 10}}`, expect: "can not execute template"},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			config := &subscribers.DiscordConfig{WebhookId: 1, WebhookToken: "token", Template: writeTemplate(t, "broken.tmpl", testCase.source)}
+
+			_, err := subscribers.NewDiscordSubscriber(newLogger(t), "discord", config)
+			assert.ErrorContains(t, err, testCase.expect, "broken template should fail on startup")
+		})
+	}
+
+	config := telegramConfig("")
+	config.Templates = map[string]string{filepath.Join(t.TempDir(), "missing.tmpl"): "release"}
+	_, err := subscribers.NewTelegramSubscriber(newLogger(t), "telegram", config)
+	assert.ErrorContains(t, err, "can not read template")
+}
+
+func TestRegistry_Render(t *testing.T) {
+	template := writeTemplate(t, "telegram.tmpl", `{{define "footer"}}{{.Resource}}{{end}}<b>{{.Author}}</b>: <script>x</script>{{.Description}}`)
+	telegram, err := subscribers.NewTelegramSubscriber(newLogger(t), "telegram", telegramConfig(template))
+	assert.NoError(t, err, "error should be nil")
+
+	registry, err := subscribers.NewRegistry(newLogger(t), []subscribers.Subscriber{
+		telegram,
+		&fakeSubscriber{name: "fake"},
+	}, graceful.NewShutdownCloser())
+	assert.NoError(t, err, "error should be nil")
+
+	rendered, err := registry.Render(context.Background(), "telegram", templateArticle())
+	assert.NoError(t, err, "error should be nil")
+	assert.Equal(t, `<b><a href="https://github.com/golang/go/releases/tag/go1.23.0">go1.23.0</a></b>
+Go releases
+
+<b>gopher</b>:
+
+Release <b>notes</b> of go1.23.0 with many changes`, rendered, "template html should be sanitized")
+
+	_, err = registry.Render(context.Background(), "fake", templateArticle())
+	assert.ErrorContains(t, err, "can not render")
+
+	_, err = registry.Render(context.Background(), "unknown", templateArticle())
+	assert.ErrorContains(t, err, "not declared")
+}
+
+func TestNotification_Enclosures(t *testing.T) {
+	number := func(value int) *int {
+		return &value
+	}
+
+	testCases := []struct {
+		name       string
+		enclosures []*model.Enclosure
+		markdown   string
+		html       string
+	}{
+		{
+			name:     "no enclosures",
+			markdown: "Release **notes** of go1.23.0 with many changes",
+			html:     "Release <b>notes</b> of go1.23.0 with many changes",
+		},
+		{
+			name: "podcast episode",
+			enclosures: []*model.Enclosure{
+				{URL: "https://cdn.example.com/12.mp3", Type: "audio/mpeg", Duration: number(3723), Episode: number(12), Season: number(2)},
+			},
+			markdown: "Release **notes** of go1.23.0 with many changes\n\n[🎧 Listen · S2E12 · 1:02:03](https://cdn.example.com/12.mp3)",
+			html:     "Release <b>notes</b> of go1.23.0 with many changes\n\n<a href=\"https://cdn.example.com/12.mp3\">🎧 Listen · S2E12 · 1:02:03</a>",
+		},
+		{
+			name: "video, file and image",
+			enclosures: []*model.Enclosure{
+				{URL: "https://www.youtube.com/v/abc?version=3&t=1", Type: "application/x-shockwave-flash", Duration: number(610)},
+				{URL: "https://cdn.example.com/cover.jpg", Type: "image/jpeg"},
+				{URL: "https://cdn.example.com/notes.pdf", Type: "application/pdf", Episode: number(3)},
+				{URL: "ftp://cdn.example.com/12.mp3", Type: "audio/mpeg"},
+			},
+			markdown: "Release **notes** of go1.23.0 with many changes\n\n[🎬 Watch · 10:10](https://www.youtube.com/v/abc?version=3&t=1)\n[📎 Download · Episode 3](https://cdn.example.com/notes.pdf)",
+			html:     "Release <b>notes</b> of go1.23.0 with many changes\n\n<a href=\"https://www.youtube.com/v/abc?version=3&amp;t=1\">🎬 Watch · 10:10</a>\n<a href=\"https://cdn.example.com/notes.pdf\">📎 Download · Episode 3</a>",
+		},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			article := templateArticle()
+			article.Enclosures = testCase.enclosures
+
+			attachment := renderMattermost(t, &subscribers.MattermostConfig{}, article)
+			assert.Equal(t, testCase.markdown, attachment.Text)
+
+			telegram, err := subscribers.NewTelegramSubscriber(newLogger(t), "telegram", telegramConfig(""))
+			assert.NoError(t, err, "error should be nil")
+			rendered, err := telegram.Render(context.Background(), article)
+			assert.NoError(t, err, "error should be nil")
+			assert.Contains(t, rendered, testCase.html)
+		})
+	}
+}
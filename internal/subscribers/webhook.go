@@ -0,0 +1,301 @@
+package subscribers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sealbro/go-feed-me/graph/model"
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/pkg/logger"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+const (
+	WebhookType = "webhook"
+	// WebhookPayloadVersion version of default JSON payload, changed on breaking changes only
+	WebhookPayloadVersion = "1"
+
+	WebhookSignatureHeader = "X-Signature"
+	WebhookTimestampHeader = "X-Timestamp"
+	WebhookDeliveryHeader  = "X-Delivery-Id"
+)
+
+// WebhookPayload default body of webhook request, it is also passed to payload template
+type WebhookPayload struct {
+	Version string `json:"version"`
+	// Id the same for all attempts of a batch, so receiver can skip duplicates
+	Id         string               `json:"id"`
+	Subscriber string               `json:"subscriber"`
+	Timestamp  int64                `json:"timestamp"`
+	Articles   []*model.FeedArticle `json:"articles"`
+}
+
+// WebhookSignature returns X-Signature value, HMAC-SHA256 of timestamp, dot and body
+func WebhookSignature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookSubscriber posts batches of articles to HTTP endpoints and keeps log of attempts
+type WebhookSubscriber struct {
+	delivery
+	name       string
+	config     *WebhookConfig
+	logger     *logger.Logger
+	client     *http.Client
+	template   *texttemplate.Template
+	deliveries *storage.WebhookDeliveryRepository
+}
+
+// NewWebhookSubscribers creates instances declared in SUBSCRIBERS and the default one configured by WEBHOOK_* variables
+func NewWebhookSubscribers(logger *logger.Logger, defaultConfig *WebhookConfig, config *Config, deliveries *storage.WebhookDeliveryRepository) ([]Subscriber, error) {
+	instances, err := newInstances(config, WebhookType, func(name string, settings *WebhookConfig) (Subscriber, error) {
+		return NewWebhookSubscriber(logger, name, settings, deliveries)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if defaultConfig.Enabled() {
+		instance, err := NewWebhookSubscriber(logger, WebhookType, defaultConfig, deliveries)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+// NewWebhookSubscriber creates subscriber, nil deliveries repository disables delivery log
+func NewWebhookSubscriber(logger *logger.Logger, name string, config *WebhookConfig, deliveries *storage.WebhookDeliveryRepository) (*WebhookSubscriber, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	subscriber := &WebhookSubscriber{
+		name:       name,
+		logger:     logger,
+		config:     config,
+		client:     &http.Client{},
+		delivery:   newDelivery(config.Delivery, defaultBatching),
+		deliveries: deliveries,
+	}
+
+	if config.Template != "" {
+		source, err := os.ReadFile(config.Template)
+		if err != nil {
+			return nil, fmt.Errorf("can not read webhook template: %w", err)
+		}
+
+		subscriber.template, err = texttemplate.New("payload").Funcs(texttemplate.FuncMap{
+			"json": func(value any) (string, error) {
+				encoded, err := json.Marshal(value)
+				return string(encoded), err
+			},
+		}).Parse(string(source))
+		if err != nil {
+			return nil, fmt.Errorf("can not parse webhook template: %w", err)
+		}
+	}
+
+	return subscriber, nil
+}
+
+func (s *WebhookSubscriber) Name() string {
+	return s.name
+}
+
+func (s *WebhookSubscriber) Subscribe(_ context.Context) error {
+	return nil
+}
+
+// Deliver posts payload to every url, urls served before the failed one get the payload with the same id again
+func (s *WebhookSubscriber) Deliver(ctx context.Context, events []*model.FeedArticle) error {
+	if len(events) == 0 {
+		return nil
+	}
+	events = oldestFirst(events)
+
+	payload := &WebhookPayload{
+		Version:    WebhookPayloadVersion,
+		Id:         webhookDeliveryId(events),
+		Subscriber: s.name,
+		Timestamp:  time.Now().Unix(),
+		Articles:   events,
+	}
+	body, err := s.render(payload)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range s.config.Urls {
+		if err = s.post(ctx, target, payload, body); err != nil {
+			return err
+		}
+	}
+
+	if s.deliveries != nil && s.config.LogRetention > 0 {
+		if err = s.deliveries.PruneWebhookDeliveries(ctx, time.Now().Add(-s.config.LogRetention)); err != nil {
+			s.logger.WarnContext(ctx, "Can not prune webhook delivery log", slog.String("subscriber", s.name), slog.Any("error", err))
+		}
+	}
+
+	return nil
+}
+
+func (s *WebhookSubscriber) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+func (s *WebhookSubscriber) render(payload *WebhookPayload) ([]byte, error) {
+	if s.template == nil {
+		return json.Marshal(payload)
+	}
+
+	var body bytes.Buffer
+	if err := s.template.Execute(&body, payload); err != nil {
+		return nil, fmt.Errorf("can not render webhook payload: %w", err)
+	}
+
+	return body.Bytes(), nil
+}
+
+// post sends body to url, 5xx responses, 429 and timeouts are retried with exponential backoff
+func (s *WebhookSubscriber) post(ctx context.Context, target string, payload *WebhookPayload, body []byte) error {
+	for attempt := 1; ; attempt++ {
+		started := time.Now()
+		status, err := s.attempt(ctx, target, payload.Id, body)
+
+		s.log(ctx, &storage.WebhookDelivery{
+			Subscriber: s.name,
+			Url:        redactUrl(target),
+			DeliveryId: payload.Id,
+			Attempt:    attempt,
+			Articles:   len(payload.Articles),
+			StatusCode: status,
+			DurationMs: time.Since(started).Milliseconds(),
+			Error:      errorText(err),
+		})
+
+		if err == nil {
+			return nil
+		}
+		retryable := status == 0 || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+		if !retryable || attempt > s.config.MaxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		if err = sleep(ctx, s.backoff(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// attempt returns response status, 0 when there is no response
+func (s *WebhookSubscriber) attempt(ctx context.Context, target string, id string, body []byte) (int, error) {
+	if s.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.Timeout)
+		defer cancel()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("invalid webhook request to %s", redactUrl(target))
+	}
+
+	for name, value := range s.config.Headers {
+		request.Header.Set(name, value)
+	}
+	request.Header.Set("Content-Type", s.config.ContentType)
+	request.Header.Set("User-Agent", "go-feed-me")
+	request.Header.Set(WebhookDeliveryHeader, id)
+
+	timestamp := time.Now().Unix()
+	request.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	if s.config.Secret != "" {
+		request.Header.Set(WebhookSignatureHeader, WebhookSignature(s.config.Secret, timestamp, body))
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		// url error can contain credentials of url
+		return 0, fmt.Errorf("webhook %s request failed: %w", redactUrl(target), errors.Unwrap(err))
+	}
+	defer response.Body.Close()
+
+	responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("webhook %s responded %d: %s", redactUrl(target), response.StatusCode, strings.TrimSpace(string(responseBody)))
+	}
+
+	return response.StatusCode, nil
+}
+
+func (s *WebhookSubscriber) backoff(attempt int) time.Duration {
+	delay := s.config.RetryBase
+	for i := 1; i < attempt && delay < s.config.RetryMax; i++ {
+		delay *= 2
+	}
+
+	return min(delay, s.config.RetryMax)
+}
+
+func (s *WebhookSubscriber) log(ctx context.Context, delivery *storage.WebhookDelivery) {
+	if s.deliveries == nil {
+		return
+	}
+
+	if err := s.deliveries.Add(context.WithoutCancel(ctx), delivery); err != nil {
+		s.logger.WarnContext(ctx, "Can not write webhook delivery log", slog.String("subscriber", s.name), slog.Any("error", err))
+	}
+}
+
+// webhookDeliveryId stable id of batch derived from article links
+func webhookDeliveryId(events []*model.FeedArticle) string {
+	hash := sha256.New()
+	for _, event := range events {
+		hash.Write([]byte(event.Link + "\n"))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))[:32]
+}
+
+// redactUrl removes credentials, query and fragment which can keep secrets
+func redactUrl(target string) string {
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return "invalid url"
+	}
+	parsed.User = nil
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+
+	return parsed.String()
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
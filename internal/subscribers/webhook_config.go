@@ -0,0 +1,46 @@
+package subscribers
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+type WebhookConfig struct {
+	Urls []string `envconfig:"WEBHOOK_URLS"`
+	// Secret signs payload by HMAC-SHA256, empty secret sends payload without signature
+	Secret  string            `envconfig:"WEBHOOK_SECRET"`
+	Headers map[string]string `envconfig:"WEBHOOK_HEADERS"`
+	// Template path of text/template rendering payload, empty sends versioned JSON payload
+	Template    string        `envconfig:"WEBHOOK_TEMPLATE"`
+	ContentType string        `envconfig:"WEBHOOK_CONTENT_TYPE" default:"application/json"`
+	Timeout     time.Duration `envconfig:"WEBHOOK_TIMEOUT" default:"10s"`
+	// MaxRetries how many times 5xx responses and timeouts are retried in place before batch goes back to outbox
+	MaxRetries int           `envconfig:"WEBHOOK_MAX_RETRIES" default:"3"`
+	RetryBase  time.Duration `envconfig:"WEBHOOK_RETRY_BASE" default:"1s"`
+	RetryMax   time.Duration `envconfig:"WEBHOOK_RETRY_MAX" default:"30s"`
+	// LogRetention how long attempts are kept in delivery log
+	LogRetention time.Duration  `envconfig:"WEBHOOK_LOG_RETENTION" default:"168h"`
+	Delivery     DeliveryConfig `envconfig:"WEBHOOK"`
+}
+
+func (c *WebhookConfig) Enabled() bool {
+	return len(c.Urls) > 0
+}
+
+func (c *WebhookConfig) Validate() error {
+	if !c.Enabled() {
+		return fmt.Errorf("webhook urls are required")
+	}
+	for _, target := range c.Urls {
+		parsed, err := url.Parse(target)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("invalid webhook url %q", redactUrl(target))
+		}
+	}
+	if c.MaxRetries < 0 || c.RetryBase < 0 || c.RetryMax < 0 {
+		return fmt.Errorf("webhook retries should not be negative")
+	}
+
+	return c.Delivery.Validate()
+}
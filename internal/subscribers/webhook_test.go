@@ -0,0 +1,172 @@
+package subscribers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sealbro/go-feed-me/internal/storage"
+	"github.com/sealbro/go-feed-me/internal/subscribers"
+	"github.com/stretchr/testify/assert"
+)
+
+func newWebhookDeliveryRepository(t *testing.T) *storage.WebhookDeliveryRepository {
+	repository, err := storage.NewWebhookDeliveryRepository(newDatabase(t))
+	assert.NoError(t, err, "error should be nil")
+
+	return repository
+}
+
+func newWebhookConfig(url string) *subscribers.WebhookConfig {
+	return &subscribers.WebhookConfig{
+		Urls:         []string{url},
+		ContentType:  "application/json",
+		Timeout:      time.Second,
+		MaxRetries:   2,
+		RetryBase:    time.Millisecond,
+		RetryMax:     10 * time.Millisecond,
+		LogRetention: time.Hour,
+	}
+}
+
+func TestWebhookSubscriber_Deliver(t *testing.T) {
+	receiver := &webhookReceiver{}
+	serverUrl := newServer(t, receiver)
+
+	deliveries := newWebhookDeliveryRepository(t)
+	config := newWebhookConfig(serverUrl + "/hook?token=secret")
+	config.Secret = "signing-secret"
+	config.Headers = map[string]string{"X-Team": "ops"}
+
+	subscriber, err := subscribers.NewWebhookSubscriber(newLogger(t), "automation", config, deliveries)
+	assert.NoError(t, err, "error should be nil")
+	assert.NoError(t, subscriber.Subscribe(context.Background()), "error should be nil")
+
+	assert.NoError(t, subscriber.Deliver(context.Background(), testArticles()), "error should be nil")
+
+	assert.Len(t, receiver.requests, 1)
+	request := receiver.requests[0]
+	assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+	assert.Equal(t, "ops", request.Header.Get("X-Team"), "custom headers should be sent")
+
+	timestamp, err := strconv.ParseInt(request.Header.Get(subscribers.WebhookTimestampHeader), 10, 64)
+	assert.NoError(t, err, "timestamp should be unix seconds")
+	assert.Equal(t, subscribers.WebhookSignature("signing-secret", timestamp, request.Body),
+		request.Header.Get(subscribers.WebhookSignatureHeader), "signature should cover timestamp and body")
+
+	payload := subscribers.WebhookPayload{}
+	assert.NoError(t, json.Unmarshal(request.Body, &payload), "error should be nil")
+	assert.Equal(t, subscribers.WebhookPayloadVersion, payload.Version)
+	assert.Equal(t, "automation", payload.Subscriber)
+	assert.Equal(t, request.Header.Get(subscribers.WebhookDeliveryHeader), payload.Id)
+	assert.Equal(t, "https://example.com/1", payload.Articles[0].Link, "articles should be sent oldest first")
+
+	log, err := deliveries.WebhookDeliveries(context.Background(), "automation", 10)
+	assert.NoError(t, err, "error should be nil")
+	assert.Len(t, log, 1)
+	assert.Equal(t, http.StatusNoContent, log[0].StatusCode)
+	assert.Equal(t, 2, log[0].Articles)
+	assert.Equal(t, serverUrl+"/hook", log[0].Url, "log should not keep url query")
+}
+
+func TestWebhookSubscriber_Retries(t *testing.T) {
+	testCases := []struct {
+		name         string
+		statuses     []int
+		delay        time.Duration
+		expectError  bool
+		expectStatus []int
+	}{
+		{
+			name:         "server errors are retried",
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusTooManyRequests},
+			expectStatus: []int{http.StatusNoContent, http.StatusTooManyRequests, http.StatusServiceUnavailable},
+		},
+		{
+			name:         "retries are limited",
+			statuses:     []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway},
+			expectError:  true,
+			expectStatus: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway},
+		},
+		{
+			name:         "client errors are not retried",
+			statuses:     []int{http.StatusBadRequest},
+			expectError:  true,
+			expectStatus: []int{http.StatusBadRequest},
+		},
+		{
+			name:         "timeouts are retried",
+			delay:        200 * time.Millisecond,
+			expectError:  true,
+			expectStatus: []int{0, 0, 0},
+		},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			receiver := &webhookReceiver{statuses: testCase.statuses, delay: testCase.delay}
+			serverUrl := newServer(t, receiver)
+
+			deliveries := newWebhookDeliveryRepository(t)
+			config := newWebhookConfig(serverUrl)
+			config.Timeout = 50 * time.Millisecond
+
+			subscriber, err := subscribers.NewWebhookSubscriber(newLogger(t), "automation", config, deliveries)
+			assert.NoError(t, err, "error should be nil")
+
+			err = subscriber.Deliver(context.Background(), testArticles())
+			if testCase.expectError {
+				assert.Error(t, err, "error should be returned to outbox")
+			} else {
+				assert.NoError(t, err, "error should be nil")
+			}
+
+			log, err := deliveries.WebhookDeliveries(context.Background(), "", 10)
+			assert.NoError(t, err, "error should be nil")
+
+			statuses := make([]int, len(log))
+			for i, delivery := range log {
+				statuses[i] = delivery.StatusCode
+				assert.Equal(t, log[0].DeliveryId, delivery.DeliveryId, "attempts should share delivery id")
+				assert.Equal(t, len(log)-i, delivery.Attempt, "attempts should be numbered")
+			}
+			assert.Equal(t, testCase.expectStatus, statuses, "log should keep every attempt newest first")
+		})
+	}
+}
+
+func TestWebhookSubscriber_Template(t *testing.T) {
+	receiver := &webhookReceiver{}
+	serverUrl := newServer(t, receiver)
+
+	template := filepath.Join(t.TempDir(), "payload.tmpl")
+	err := os.WriteFile(template, []byte(`{"text": {{json (printf "%d new: %s" (len .Articles) (index .Articles 0).Title)}}}`), 0o600)
+	assert.NoError(t, err, "error should be nil")
+
+	config := newWebhookConfig(serverUrl)
+	config.Template = template
+	subscriber, err := subscribers.NewWebhookSubscriber(newLogger(t), "chat", config, nil)
+	assert.NoError(t, err, "error should be nil")
+
+	assert.NoError(t, subscriber.Deliver(context.Background(), testArticles()), "error should be nil")
+	assert.JSONEq(t, `{"text": "2 new: First"}`, string(receiver.requests[0].Body))
+	assert.Empty(t, receiver.requests[0].Header.Get(subscribers.WebhookSignatureHeader), "payload without secret should not be signed")
+}
+
+func TestWebhookConfig_Validate(t *testing.T) {
+	config := newWebhookConfig("https://example.com/hook")
+	assert.NoError(t, config.Validate(), "error should be nil")
+
+	config.Urls = []string{"ftp://example.com/hook"}
+	assert.Error(t, config.Validate(), "only http urls should be accepted")
+
+	config.Urls = nil
+	assert.Error(t, config.Validate(), "urls should be required")
+}
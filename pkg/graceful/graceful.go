@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"github.com/sealbro/go-feed-me/pkg/logger"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
@@ -36,8 +37,11 @@ func (g *Graceful) WaitExitSignal() {
 	ctx, cancelMainCtx := context.WithCancel(g.MainCtx)
 	go func() {
 		if err := g.StartAction(ctx); err != nil {
+			// errors after shutdown signal are expected, e.g. closed listeners
 			if ctx.Err() != nil {
-				g.Logger.DebugContext(ctx, "Application can't start", err)
+				g.Logger.DebugContext(ctx, "Application can't start", slog.Any("error", err))
+			} else {
+				g.Logger.ErrorContext(ctx, "Application can't start", slog.Any("error", err))
 			}
 		}
 		waitManualClosing <- struct{}{}
@@ -52,7 +56,7 @@ func (g *Graceful) WaitExitSignal() {
 	shutdown := func() {
 		ctx, cancelShutdownTimeoutCtx := context.WithTimeout(context.Background(), timeout)
 		if err := g.ShutdownAction(ctx); err != nil && !errors.Is(err, ctx.Err()) {
-			g.Logger.ErrorContext(ctx, "Application unexpected shutdown", err)
+			g.Logger.ErrorContext(ctx, "Application unexpected shutdown", slog.Any("error", err))
 		}
 		cancelShutdownTimeoutCtx()
 	}
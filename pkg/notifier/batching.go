@@ -1,11 +1,15 @@
 package notifier
 
 import (
+	"fmt"
+	"strings"
 	"time"
 )
 
 // SplitByBatchProcess batch processor that will split a channel into batches of a maximum size or after a maximum timeout.
 // main idea from https://elliotchance.medium.com/batch-a-channel-by-size-or-time-in-go-92fa3098f65
+//
+// Deprecated: subscribers of SubscriptionManager are batched by Batching, use WithBatching instead.
 func SplitByBatchProcess[TItem any](values <-chan TItem, maxItems int, maxTimeout time.Duration) <-chan []TItem {
 	batches := make(chan []TItem)
 
@@ -57,3 +61,144 @@ func getBatch[TItem any](values <-chan TItem, maxItems int, maxTimeout time.Dura
 
 	return batch, keepGoing
 }
+
+const digestLayout = "15:04"
+
+// Batching decides when events collected for a subscriber are sent as one batch
+type Batching struct {
+	// Size max number of events in batch, full batch is sent at once, 0 means no limit
+	Size int
+	// MaxWait how long the first event waits for others, 0 sends events immediately
+	MaxWait time.Duration
+	// Digest times of day in server local time like "09:00" or weekly times like "Mon 09:00",
+	// when set events are sent only at these times
+	Digest []string
+}
+
+func (b Batching) Validate() error {
+	if b.Size < 0 {
+		return fmt.Errorf("batch size should not be negative: %d", b.Size)
+	}
+	if b.MaxWait < 0 {
+		return fmt.Errorf("batch max wait should not be negative: %s", b.MaxWait)
+	}
+	for _, digest := range b.Digest {
+		if _, err := parseDigest(digest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Immediate reports whether events are sent as soon as they come
+func (b Batching) Immediate() bool {
+	return b.MaxWait == 0 && len(b.Digest) == 0
+}
+
+// Deadline returns when batch started by the first event has to be sent
+func (b Batching) Deadline(first time.Time) time.Time {
+	if len(b.Digest) == 0 {
+		return first.Add(b.MaxWait)
+	}
+
+	return b.nextDigest(first)
+}
+
+// Ready reports whether batch started by the first event and having count events should be sent at now
+func (b Batching) Ready(first time.Time, count int, now time.Time) bool {
+	if count == 0 {
+		return false
+	}
+	if len(b.Digest) == 0 && b.Size > 0 && count >= b.Size {
+		return true
+	}
+
+	return !now.Before(b.Deadline(first))
+}
+
+func (b Batching) nextDigest(after time.Time) time.Time {
+	after = after.In(time.Local)
+
+	var next time.Time
+	for _, digest := range b.Digest {
+		at, err := parseDigest(digest)
+		if err != nil {
+			continue
+		}
+
+		candidate := time.Date(after.Year(), after.Month(), after.Day(), at.hour, at.minute, 0, 0, time.Local)
+		period := 1
+		if at.weekly {
+			period = 7
+			candidate = candidate.AddDate(0, 0, (int(at.weekday)-int(after.Weekday())+7)%7)
+		}
+		if !candidate.After(after) {
+			candidate = candidate.AddDate(0, 0, period)
+		}
+		if next.IsZero() || candidate.Before(next) {
+			next = candidate
+		}
+	}
+
+	if next.IsZero() {
+		return after
+	}
+
+	return next
+}
+
+type digestTime struct {
+	weekly  bool
+	weekday time.Weekday
+	hour    int
+	minute  int
+}
+
+// parseDigest parses daily "15:04" or weekly "Mon 15:04" digest time, weekday can be full or short name
+func parseDigest(digest string) (digestTime, error) {
+	fields := strings.Fields(digest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return digestTime{}, fmt.Errorf("invalid digest time %q, expected HH:MM or weekday HH:MM", digest)
+	}
+
+	at, err := time.Parse(digestLayout, fields[len(fields)-1])
+	if err != nil {
+		return digestTime{}, fmt.Errorf("invalid digest time %q, expected HH:MM or weekday HH:MM: %w", digest, err)
+	}
+
+	parsed := digestTime{hour: at.Hour(), minute: at.Minute()}
+	if len(fields) == 2 {
+		weekday, ok := parseWeekday(fields[0])
+		if !ok {
+			return digestTime{}, fmt.Errorf("invalid digest weekday %q, expected e.g. Mon or Monday", fields[0])
+		}
+		parsed.weekly, parsed.weekday = true, weekday
+	}
+
+	return parsed, nil
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(name, weekday.String()) || strings.EqualFold(name, weekday.String()[:3]) {
+			return weekday, true
+		}
+	}
+
+	return 0, false
+}
+
+// SplitBySize splits events into batches of max size, 0 size keeps all events in one batch
+func SplitBySize[TItem any](items []TItem, size int) [][]TItem {
+	if size <= 0 || len(items) <= size {
+		return [][]TItem{items}
+	}
+
+	batches := make([][]TItem, 0, (len(items)+size-1)/size)
+	for start := 0; start < len(items); start += size {
+		batches = append(batches, items[start:min(start+size, len(items))])
+	}
+
+	return batches
+}
@@ -1,6 +1,7 @@
 package notifier_test
 
 import (
+	"context"
 	"github.com/sealbro/go-feed-me/pkg/notifier"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -82,3 +83,242 @@ func RunTestCase(t *testing.T, testCase struct {
 	assert.Equal(t, len(testCase.items), i, "number of items should be equal to original")
 	assert.Equal(t, testCase.expectGroups, groups, "number of groups should be equal to expected")
 }
+
+func TestBatchingReady(t *testing.T) {
+	first := time.Date(2024, 5, 10, 8, 30, 0, 0, time.Local)
+
+	testCases := []struct {
+		name     string
+		batching notifier.Batching
+		count    int
+		now      time.Time
+		expect   bool
+	}{
+		{
+			name:     "immediate",
+			batching: notifier.Batching{},
+			count:    1,
+			now:      first,
+			expect:   true,
+		},
+		{
+			name:     "no events",
+			batching: notifier.Batching{},
+			now:      first,
+			expect:   false,
+		},
+		{
+			name:     "waits for max wait",
+			batching: notifier.Batching{Size: 10, MaxWait: time.Minute},
+			count:    3,
+			now:      first.Add(30 * time.Second),
+			expect:   false,
+		},
+		{
+			name:     "after max wait",
+			batching: notifier.Batching{Size: 10, MaxWait: time.Minute},
+			count:    3,
+			now:      first.Add(time.Minute),
+			expect:   true,
+		},
+		{
+			name:     "full batch",
+			batching: notifier.Batching{Size: 3, MaxWait: time.Minute},
+			count:    3,
+			now:      first,
+			expect:   true,
+		},
+		{
+			name:     "digest ignores size",
+			batching: notifier.Batching{Size: 3, Digest: []string{"09:00", "18:00"}},
+			count:    5,
+			now:      first.Add(29 * time.Minute),
+			expect:   false,
+		},
+		{
+			name:     "at digest time",
+			batching: notifier.Batching{Size: 3, Digest: []string{"18:00", "09:00"}},
+			count:    1,
+			now:      first.Add(30 * time.Minute),
+			expect:   true,
+		},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expect, testCase.batching.Ready(first, testCase.count, testCase.now), "batch readiness should be equal to expected")
+		})
+	}
+}
+
+func TestBatchingDeadline(t *testing.T) {
+	batching := notifier.Batching{Digest: []string{"09:00", "18:00"}}
+
+	testCases := []struct {
+		name   string
+		first  time.Time
+		expect time.Time
+	}{
+		{
+			name:   "same day",
+			first:  time.Date(2024, 5, 10, 8, 30, 0, 0, time.Local),
+			expect: time.Date(2024, 5, 10, 9, 0, 0, 0, time.Local),
+		},
+		{
+			name:   "at digest time waits for next one",
+			first:  time.Date(2024, 5, 10, 9, 0, 0, 0, time.Local),
+			expect: time.Date(2024, 5, 10, 18, 0, 0, 0, time.Local),
+		},
+		{
+			name:   "next day",
+			first:  time.Date(2024, 5, 10, 20, 0, 0, 0, time.Local),
+			expect: time.Date(2024, 5, 11, 9, 0, 0, 0, time.Local),
+		},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expect, batching.Deadline(testCase.first), "deadline should be the next digest time")
+		})
+	}
+}
+
+func TestBatchingWeeklyDeadline(t *testing.T) {
+	batching := notifier.Batching{Digest: []string{"Mon 09:00", "thursday 18:00"}}
+
+	testCases := []struct {
+		name   string
+		first  time.Time
+		expect time.Time
+	}{
+		{
+			name:   "next week",
+			first:  time.Date(2024, 5, 10, 8, 30, 0, 0, time.Local),
+			expect: time.Date(2024, 5, 13, 9, 0, 0, 0, time.Local),
+		},
+		{
+			name:   "same day",
+			first:  time.Date(2024, 5, 16, 8, 30, 0, 0, time.Local),
+			expect: time.Date(2024, 5, 16, 18, 0, 0, 0, time.Local),
+		},
+		{
+			name:   "at digest time waits for next one",
+			first:  time.Date(2024, 5, 13, 9, 0, 0, 0, time.Local),
+			expect: time.Date(2024, 5, 16, 18, 0, 0, 0, time.Local),
+		},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expect, batching.Deadline(testCase.first), "deadline should be the next weekly digest time")
+		})
+	}
+}
+
+func TestBatchingValidate(t *testing.T) {
+	assert.NoError(t, notifier.Batching{Size: 1, MaxWait: time.Second, Digest: []string{"07:30"}}.Validate(), "error should be nil")
+	assert.Error(t, notifier.Batching{Size: -1}.Validate(), "negative size should be rejected")
+	assert.Error(t, notifier.Batching{MaxWait: -time.Second}.Validate(), "negative max wait should be rejected")
+	assert.Error(t, notifier.Batching{Digest: []string{"7pm"}}.Validate(), "invalid digest time should be rejected")
+	assert.NoError(t, notifier.Batching{Digest: []string{"Fri 17:00", "sunday 10:00"}}.Validate(), "error should be nil")
+	assert.Error(t, notifier.Batching{Digest: []string{"Someday 17:00"}}.Validate(), "invalid digest weekday should be rejected")
+}
+
+func TestSplitBySize(t *testing.T) {
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, notifier.SplitBySize([]int{1, 2, 3, 4, 5}, 2))
+	assert.Equal(t, [][]int{{1, 2, 3}}, notifier.SplitBySize([]int{1, 2, 3}, 0))
+}
+
+func TestSubscriberBatching(t *testing.T) {
+	testCases := []struct {
+		name          string
+		batching      notifier.Batching
+		notify        [][]int
+		expectBatches [][]int
+	}{
+		{
+			name:          "immediate",
+			batching:      notifier.Batching{},
+			notify:        [][]int{{1}, {2, 3}},
+			expectBatches: [][]int{{1}, {2, 3}},
+		},
+		{
+			name:          "immediate with max size",
+			batching:      notifier.Batching{Size: 2},
+			notify:        [][]int{{1, 2, 3}},
+			expectBatches: [][]int{{1, 2}, {3}},
+		},
+		{
+			name:          "by size",
+			batching:      notifier.Batching{Size: 3, MaxWait: time.Minute},
+			notify:        [][]int{{1}, {2}, {3}, {4, 5, 6}},
+			expectBatches: [][]int{{1, 2, 3}, {4, 5, 6}},
+		},
+		{
+			name:          "by max wait",
+			batching:      notifier.Batching{Size: 10, MaxWait: 20 * time.Millisecond},
+			notify:        [][]int{{1}, {2}, {3}},
+			expectBatches: [][]int{{1, 2, 3}},
+		},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			manager := newManager(t, newConfig())
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			subscriber, err := manager.AddSubscriber(ctx, "subscriber-1", notifier.WithBatching[int](testCase.batching))
+			assert.NoError(t, err, "error should be nil")
+			other, err := manager.AddSubscriber(ctx, "subscriber-2")
+			assert.NoError(t, err, "error should be nil")
+
+			for _, events := range testCase.notify {
+				manager.Notify(events...)
+			}
+
+			var batches [][]int
+			for len(batches) < len(testCase.expectBatches) {
+				select {
+				case batch := <-subscriber:
+					batches = append(batches, batch)
+				case <-time.After(time.Second):
+					assert.FailNow(t, "timeout waiting for batch", "received %v", batches)
+				}
+			}
+			assert.Equal(t, testCase.expectBatches, batches, "batches should be equal to expected")
+			assert.Empty(t, other, "subscriber with default batching should still wait")
+		})
+	}
+}
+
+func TestSubscriberBatchingDigestWaits(t *testing.T) {
+	manager := newManager(t, newConfig())
+	next := time.Now().Add(2 * time.Hour).Format("15:04")
+
+	subscriber, err := manager.AddSubscriber(context.Background(), "digest",
+		notifier.WithBatching[int](notifier.Batching{Size: 1, Digest: []string{next}}))
+	assert.NoError(t, err, "error should be nil")
+
+	manager.Notify(1, 2, 3)
+
+	assert.Empty(t, subscriber, "digest should not send events before digest time")
+	assert.Equal(t, 3, manager.Stats()[0].Pending, "events should wait for digest")
+	assert.NoError(t, manager.Close(), "error should be nil")
+}
+
+func TestSubscriptionManagerRejectsInvalidBatching(t *testing.T) {
+	manager := newManager(t, newConfig())
+
+	_, err := manager.AddSubscriber(context.Background(), "subscriber-1", notifier.WithBatching[int](notifier.Batching{Digest: []string{"noon"}}))
+	assert.Error(t, err, "invalid batching should be rejected")
+}
@@ -0,0 +1,49 @@
+package notifier
+
+import (
+	"fmt"
+	"time"
+)
+
+// OverflowPolicy decides what happens when subscriber buffer is full
+type OverflowPolicy string
+
+const (
+	// DropOldest discards the oldest buffered batch to make room for the new one
+	DropOldest OverflowPolicy = "drop_oldest"
+	// DropNewest discards the new batch and keeps buffered ones
+	DropNewest OverflowPolicy = "drop_newest"
+	// Disconnect removes slow subscriber and closes its channel
+	Disconnect OverflowPolicy = "disconnect"
+)
+
+func (p OverflowPolicy) Validate() error {
+	switch p {
+	case DropOldest, DropNewest, Disconnect:
+		return nil
+	default:
+		return fmt.Errorf("unknown overflow policy: %q", p)
+	}
+}
+
+type Config struct {
+	// BatchSize default max number of events in subscriber batch
+	BatchSize int `envconfig:"NOTIFIER_BATCH_SIZE" default:"10"`
+	// BatchTime default max wait of the first event in subscriber batch
+	BatchTime time.Duration `envconfig:"NOTIFIER_BATCH_TIME" default:"1m"`
+	// Digest default times of day when subscriber batches are sent, overrides BatchTime
+	Digest []string `envconfig:"NOTIFIER_DIGEST"`
+	// BufferSize default number of batches buffered per subscriber
+	BufferSize int `envconfig:"NOTIFIER_BUFFER_SIZE" default:"16"`
+	// OverflowPolicy default policy of subscribers with full buffer
+	OverflowPolicy OverflowPolicy `envconfig:"NOTIFIER_OVERFLOW_POLICY" default:"drop_oldest"`
+}
+
+// Batching returns default batching of subscribers
+func (c *Config) Batching() Batching {
+	return Batching{
+		Size:    c.BatchSize,
+		MaxWait: c.BatchTime,
+		Digest:  c.Digest,
+	}
+}
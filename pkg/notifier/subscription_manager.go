@@ -6,102 +6,451 @@ import (
 	"github.com/sealbro/go-feed-me/pkg/graceful"
 	"github.com/sealbro/go-feed-me/pkg/logger"
 	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-var ErrSubscriptionManagerClosed = fmt.Errorf("subscription manager closed")
+var (
+	ErrSubscriptionManagerClosed = fmt.Errorf("subscription manager closed")
+	ErrSubscriberExists          = fmt.Errorf("subscriber already exists")
+)
+
+// SubscriberOption overrides manager defaults for a single subscriber of events T
+type SubscriberOption[T any] func(options *subscriberOptions[T])
+
+type subscriberOptions[T any] struct {
+	bufferSize     int
+	overflowPolicy OverflowPolicy
+	filter         func(event T) bool
+	batching       Batching
+	kind           string
+}
+
+// WithBufferSize sets how many batches are buffered for slow subscriber
+func WithBufferSize[T any](size int) SubscriberOption[T] {
+	return func(options *subscriberOptions[T]) {
+		options.bufferSize = size
+	}
+}
+
+// WithOverflowPolicy sets what happens when subscriber buffer is full
+func WithOverflowPolicy[T any](policy OverflowPolicy) SubscriberOption[T] {
+	return func(options *subscriberOptions[T]) {
+		options.overflowPolicy = policy
+	}
+}
+
+// WithFilter sends subscriber only events matching filter, filter runs in dispatcher, so it should be fast
+func WithFilter[T any](filter func(event T) bool) SubscriberOption[T] {
+	return func(options *subscriberOptions[T]) {
+		options.filter = filter
+	}
+}
+
+// WithKind groups subscriber stats by kind, e.g. graphql for subscriptions of api clients
+func WithKind[T any](kind string) SubscriberOption[T] {
+	return func(options *subscriberOptions[T]) {
+		options.kind = kind
+	}
+}
 
-type batchConfig struct {
-	BatchSize int
-	BatchTime time.Duration
+// WithBatching sets when events are grouped and sent to subscriber
+func WithBatching[T any](batching Batching) SubscriberOption[T] {
+	return func(options *subscriberOptions[T]) {
+		options.batching = batching
+	}
+}
+
+// SubscriberStats delivery counters of a single subscriber
+type SubscriberStats struct {
+	Id   string
+	Kind string
+	// Delivered number of events put into subscriber buffer
+	Delivered int64
+	// Dropped number of events discarded by overflow policy
+	Dropped int64
+	// Buffered number of batches waiting for subscriber
+	Buffered int
+	// Pending number of events collected for the next batch
+	Pending int
+}
+
+// KindStats delivery counters of all subscribers of a kind, counters of removed subscribers are kept
+type KindStats struct {
+	Kind string
+	// Subscribers number of current subscribers
+	Subscribers int
+	Delivered   int64
+	Dropped     int64
+	Buffered    int
+	Pending     int
+}
+
+type subscriber[T any] struct {
+	id        string
+	kind      string
+	events    chan []T
+	policy    OverflowPolicy
+	filter    func(event T) bool
+	batching  Batching
+	delivered atomic.Int64
+	dropped   atomic.Int64
+	// disconnect removes subscriber when batch sent by timer hits disconnect policy
+	disconnect func()
+
+	// m guards pending batch, timer and closed, batch is sent either by dispatcher or by timer
+	m       sync.Mutex
+	pending []T
+	timer   *time.Timer
+	// generation invalidates timers of already sent batches
+	generation uint64
+	closed     bool
 }
 
 type SubscriptionManager[T any] struct {
-	*batchConfig
+	config *Config
+	logger *logger.Logger
 
-	subscribers map[string]chan []T
-	output      <-chan []T
-	input       chan T
+	// m guards subscribers and closed, events are dispatched only under read lock
+	// and subscribers are closed only under write lock
+	m           sync.RWMutex
+	subscribers map[string]*subscriber[T]
 	closed      bool
-	logger      *logger.Logger
+	// removed counters of removed subscribers by kind
+	removed map[string]*KindStats
 }
 
-func NewSubscriptionManager[T any](logger *logger.Logger, shutdownCloser *graceful.ShutdownCloser) *SubscriptionManager[T] {
+func NewSubscriptionManager[T any](logger *logger.Logger, config *Config, shutdownCloser *graceful.ShutdownCloser) (*SubscriptionManager[T], error) {
+	if err := config.OverflowPolicy.Validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Batching().Validate(); err != nil {
+		return nil, err
+	}
+
 	manager := &SubscriptionManager[T]{
-		batchConfig: &batchConfig{
-			BatchSize: 10,
-			BatchTime: 1 * time.Minute,
-		},
+		config:      config,
 		logger:      logger,
-		subscribers: map[string]chan []T{},
-		input:       make(chan T),
-		closed:      false,
+		subscribers: map[string]*subscriber[T]{},
+		removed:     map[string]*KindStats{},
 	}
 
-	manager.output = SplitByBatchProcess(manager.input, manager.BatchSize, manager.BatchTime)
-
-	go func() {
-		for events := range manager.output {
-			logger.Info("Send events to subscribers", slog.Int("links", len(events)), slog.Int("subscribers", len(manager.subscribers)))
-			for _, subscriber := range manager.subscribers {
-				subscriber <- events
-			}
-		}
-	}()
-
 	shutdownCloser.Register(manager)
 
-	return manager
+	return manager, nil
 }
 
+// Notify never blocks on subscribers, events are batched per subscriber
 func (manager *SubscriptionManager[T]) Notify(events ...T) {
-	if manager.closed {
+	if len(events) == 0 {
 		return
 	}
 
-	for _, eventData := range events {
-		manager.input <- eventData
+	var disconnected []*subscriber[T]
+
+	manager.m.RLock()
+	manager.logger.Info("Send events to subscribers", slog.Int("links", len(events)), slog.Int("subscribers", len(manager.subscribers)))
+	for _, sub := range manager.subscribers {
+		matched := sub.match(events)
+		if len(matched) == 0 {
+			continue
+		}
+
+		if !sub.add(matched) {
+			disconnected = append(disconnected, sub)
+		}
+	}
+	manager.m.RUnlock()
+
+	for _, sub := range disconnected {
+		sub.disconnect()
 	}
 }
 
-func (manager *SubscriptionManager[T]) AddSubscriber(ctx context.Context, uniqSubscriberId string) (chan []T, error) {
-	if manager.closed {
-		return nil, ErrSubscriptionManagerClosed
+// AddSubscriber returns channel with batches of events, channel is closed when ctx is done,
+// subscriber is disconnected by overflow policy or manager is closed
+func (manager *SubscriptionManager[T]) AddSubscriber(ctx context.Context, uniqSubscriberId string, opts ...SubscriberOption[T]) (<-chan []T, error) {
+	options := &subscriberOptions[T]{
+		bufferSize:     manager.config.BufferSize,
+		overflowPolicy: manager.config.OverflowPolicy,
+		batching:       manager.config.Batching(),
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if err := options.overflowPolicy.Validate(); err != nil {
+		return nil, err
+	}
+	if err := options.batching.Validate(); err != nil {
+		return nil, err
 	}
 
 	key := uniqSubscriberId
-	ch := make(chan []T)
-	manager.subscribers[key] = ch
+	sub := &subscriber[T]{
+		id:       key,
+		kind:     options.kind,
+		events:   make(chan []T, max(options.bufferSize, 1)),
+		policy:   options.overflowPolicy,
+		filter:   options.filter,
+		batching: options.batching,
+	}
+	sub.disconnect = func() {
+		if manager.removeSubscriber(sub) {
+			manager.logger.Warn("SubscriptionManager - Disconnected slow subscriber", slog.String("subscriber_id", key))
+		}
+	}
+
+	manager.m.Lock()
+	if manager.closed {
+		manager.m.Unlock()
+		return nil, ErrSubscriptionManagerClosed
+	}
+	if _, ok := manager.subscribers[key]; ok {
+		manager.m.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrSubscriberExists, key)
+	}
+	manager.subscribers[key] = sub
+	manager.m.Unlock()
 
 	manager.logger.InfoContext(ctx, "SubscriptionManager - Added new subscriber", slog.String("subscriber_id", key))
 
 	go func() {
 		<-ctx.Done()
-		manager.RemoveSubscriber(key)
-		manager.logger.InfoContext(ctx, "SubscriptionManager - Removed subscriber", slog.String("subscriber_id", key))
+		if manager.removeSubscriber(sub) {
+			manager.logger.InfoContext(ctx, "SubscriptionManager - Removed subscriber", slog.String("subscriber_id", key))
+		}
 	}()
 
-	return ch, nil
+	return sub.events, nil
 }
 
 func (manager *SubscriptionManager[T]) RemoveSubscriber(key string) {
-	if ch, ok := manager.subscribers[key]; ok {
-		if ch != nil {
-			close(ch)
+	manager.m.Lock()
+	defer manager.m.Unlock()
+
+	manager.removeSubscriberLocked(key)
+}
+
+// Stats returns delivery counters of current subscribers ordered by id
+func (manager *SubscriptionManager[T]) Stats() []SubscriberStats {
+	manager.m.RLock()
+	defer manager.m.RUnlock()
+
+	stats := make([]SubscriberStats, 0, len(manager.subscribers))
+	for _, sub := range manager.subscribers {
+		sub.m.Lock()
+		pending := len(sub.pending)
+		sub.m.Unlock()
+
+		stats = append(stats, SubscriberStats{
+			Id:        sub.id,
+			Kind:      sub.kind,
+			Delivered: sub.delivered.Load(),
+			Dropped:   sub.dropped.Load(),
+			Buffered:  len(sub.events),
+			Pending:   pending,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Id < stats[j].Id
+	})
+
+	return stats
+}
+
+// KindStats returns delivery counters of subscribers grouped by kind ordered by kind,
+// delivered and dropped counters include removed subscribers, so they never decrease
+func (manager *SubscriptionManager[T]) KindStats() []KindStats {
+	grouped := map[string]*KindStats{}
+
+	manager.m.RLock()
+	for kind, removed := range manager.removed {
+		stats := *removed
+		grouped[kind] = &stats
+	}
+	manager.m.RUnlock()
+
+	for _, sub := range manager.Stats() {
+		stats, ok := grouped[sub.Kind]
+		if !ok {
+			stats = &KindStats{Kind: sub.Kind}
+			grouped[sub.Kind] = stats
 		}
+		stats.Subscribers++
+		stats.Delivered += sub.Delivered
+		stats.Dropped += sub.Dropped
+		stats.Buffered += sub.Buffered
+		stats.Pending += sub.Pending
+	}
 
-		delete(manager.subscribers, key)
+	kinds := make([]KindStats, 0, len(grouped))
+	for _, stats := range grouped {
+		kinds = append(kinds, *stats)
 	}
+	sort.Slice(kinds, func(i, j int) bool {
+		return kinds[i].Kind < kinds[j].Kind
+	})
+
+	return kinds
 }
 
+// Close disconnects all subscribers, events waiting for batch are discarded
 func (manager *SubscriptionManager[T]) Close() error {
-	manager.closed = true
+	manager.m.Lock()
+	defer manager.m.Unlock()
 
+	manager.closed = true
 	for key := range manager.subscribers {
-		manager.RemoveSubscriber(key)
+		manager.removeSubscriberLocked(key)
 	}
 
-	close(manager.input)
-
 	return nil
 }
+
+// removeSubscriber removes exactly this subscriber, a new subscriber with the same id stays untouched
+func (manager *SubscriptionManager[T]) removeSubscriber(sub *subscriber[T]) bool {
+	manager.m.Lock()
+	defer manager.m.Unlock()
+
+	if manager.subscribers[sub.id] != sub {
+		return false
+	}
+
+	manager.removeSubscriberLocked(sub.id)
+
+	return true
+}
+
+func (manager *SubscriptionManager[T]) removeSubscriberLocked(key string) {
+	if sub, ok := manager.subscribers[key]; ok {
+		sub.close()
+		delete(manager.subscribers, key)
+
+		removed, ok := manager.removed[sub.kind]
+		if !ok {
+			removed = &KindStats{Kind: sub.kind}
+			manager.removed[sub.kind] = removed
+		}
+		removed.Delivered += sub.delivered.Load()
+		removed.Dropped += sub.dropped.Load()
+	}
+}
+
+// match returns events passing subscriber filter, batch is shared between subscribers so it is never modified
+func (s *subscriber[T]) match(events []T) []T {
+	if s.filter == nil {
+		return events
+	}
+
+	matched := make([]T, 0, len(events))
+	for _, event := range events {
+		if s.filter(event) {
+			matched = append(matched, event)
+		}
+	}
+
+	return matched
+}
+
+// add collects events into pending batch, returns false when subscriber has to be disconnected
+func (s *subscriber[T]) add(events []T) bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.closed {
+		return true
+	}
+
+	if len(s.pending) == 0 && s.timer == nil && !s.batching.Immediate() {
+		generation := s.generation
+		s.timer = time.AfterFunc(time.Until(s.batching.Deadline(time.Now())), func() {
+			s.onTimer(generation)
+		})
+	}
+	s.pending = append(s.pending, events...)
+
+	if s.batching.Immediate() || (len(s.batching.Digest) == 0 && s.batching.Size > 0 && len(s.pending) >= s.batching.Size) {
+		return s.flushLocked()
+	}
+
+	return true
+}
+
+func (s *subscriber[T]) onTimer(generation uint64) {
+	s.m.Lock()
+	ok := true
+	if !s.closed && s.generation == generation {
+		ok = s.flushLocked()
+	}
+	s.m.Unlock()
+
+	if !ok {
+		s.disconnect()
+	}
+}
+
+// flushLocked sends pending events split by batch size
+func (s *subscriber[T]) flushLocked() bool {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.generation++
+
+	pending := s.pending
+	s.pending = nil
+	if len(pending) == 0 {
+		return true
+	}
+
+	for _, batch := range SplitBySize(pending, s.batching.Size) {
+		if !s.offer(batch) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *subscriber[T]) close() {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.pending = nil
+	s.closed = true
+	close(s.events)
+}
+
+// offer puts batch into buffer, returns false when subscriber has to be disconnected
+func (s *subscriber[T]) offer(events []T) bool {
+	for {
+		select {
+		case s.events <- events:
+			s.delivered.Add(int64(len(events)))
+			return true
+		default:
+		}
+
+		switch s.policy {
+		case DropNewest:
+			s.dropped.Add(int64(len(events)))
+			return true
+		case Disconnect:
+			s.dropped.Add(int64(len(events)))
+			return false
+		default:
+			// only holder of subscriber lock sends to buffer, so after taking the oldest batch the next send succeeds
+			select {
+			case oldest := <-s.events:
+				s.dropped.Add(int64(len(oldest)))
+			default:
+			}
+		}
+	}
+}
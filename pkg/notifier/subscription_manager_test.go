@@ -2,12 +2,14 @@ package notifier_test
 
 import (
 	"context"
+	"fmt"
 	"github.com/sealbro/go-feed-me/pkg/graceful"
 	"github.com/sealbro/go-feed-me/pkg/logger"
 	"github.com/sealbro/go-feed-me/pkg/notifier"
 	"github.com/stretchr/testify/assert"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestSubscriptionManagerSubscribeAndClose(t *testing.T) {
@@ -17,7 +19,8 @@ func TestSubscriptionManagerSubscribeAndClose(t *testing.T) {
 	assert.NoError(t, err, "error should be nil")
 
 	closer := graceful.NewShutdownCloser()
-	manager := notifier.NewSubscriptionManager[int](newLogger, closer)
+	manager, err := notifier.NewSubscriptionManager[int](newLogger, newConfig(), closer)
+	assert.NoError(t, err, "error should be nil")
 	ctx := context.Background()
 	subscriber, err := manager.AddSubscriber(ctx, "subscriber-1")
 
@@ -50,7 +53,8 @@ func TestSubscriptionManagerGetAllEventsAfterClose(t *testing.T) {
 	assert.NoError(t, err, "error should be nil")
 
 	closer := graceful.NewShutdownCloser()
-	manager := notifier.NewSubscriptionManager[int](newLogger, closer)
+	manager, err := notifier.NewSubscriptionManager[int](newLogger, newConfig(), closer)
+	assert.NoError(t, err, "error should be nil")
 	ctx := context.Background()
 	subscriber, err := manager.AddSubscriber(ctx, "subscriber-1")
 
@@ -73,3 +77,236 @@ func TestSubscriptionManagerGetAllEventsAfterClose(t *testing.T) {
 	assert.Empty(t, values, "values should be empty after close")
 	//assert.Equal(t, items, values, "values should be equal")
 }
+
+func TestSubscriptionManagerOverflowPolicies(t *testing.T) {
+	testCases := []struct {
+		name            string
+		policy          notifier.OverflowPolicy
+		expectBatches   [][]int
+		expectDelivered int64
+		expectDropped   int64
+		expectRemoved   bool
+	}{
+		{
+			name:            "drop oldest",
+			policy:          notifier.DropOldest,
+			expectBatches:   [][]int{{4}, {5}},
+			expectDelivered: 5,
+			expectDropped:   3,
+		},
+		{
+			name:            "drop newest",
+			policy:          notifier.DropNewest,
+			expectBatches:   [][]int{{1}, {2}},
+			expectDelivered: 2,
+			expectDropped:   3,
+		},
+		{
+			name:          "disconnect",
+			policy:        notifier.Disconnect,
+			expectBatches: [][]int{{1}, {2}},
+			expectRemoved: true,
+		},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			config := newConfig()
+			config.BatchSize = 1
+			manager := newManager(t, config)
+
+			subscriber, err := manager.AddSubscriber(context.Background(), "slow",
+				notifier.WithBufferSize[int](2), notifier.WithOverflowPolicy[int](testCase.policy))
+			assert.NoError(t, err, "error should be nil")
+
+			manager.Notify(1, 2, 3, 4, 5)
+
+			if testCase.expectRemoved {
+				assert.Eventually(t, func() bool {
+					return len(manager.Stats()) == 0
+				}, time.Second, time.Millisecond, "slow subscriber should be disconnected")
+			} else {
+				assert.Eventually(t, func() bool {
+					stats := manager.Stats()
+					return len(stats) == 1 &&
+						stats[0].Delivered == testCase.expectDelivered && stats[0].Dropped == testCase.expectDropped
+				}, time.Second, time.Millisecond, "delivered and dropped events should be counted")
+				assert.Equal(t, len(testCase.expectBatches), manager.Stats()[0].Buffered, "buffer should be full")
+				assert.NoError(t, manager.Close(), "error should be nil")
+			}
+
+			var batches [][]int
+			for batch := range subscriber {
+				batches = append(batches, batch)
+			}
+			assert.Equal(t, testCase.expectBatches, batches, "buffered batches should be equal")
+		})
+	}
+}
+
+func TestSubscriptionManagerSlowSubscriberDoesNotBlockOthers(t *testing.T) {
+	config := newConfig()
+	config.BatchSize = 1
+	manager := newManager(t, config)
+	ctx := context.Background()
+
+	_, err := manager.AddSubscriber(ctx, "slow", notifier.WithBufferSize[int](1), notifier.WithOverflowPolicy[int](notifier.DropNewest))
+	assert.NoError(t, err, "error should be nil")
+	fast, err := manager.AddSubscriber(ctx, "fast", notifier.WithBufferSize[int](1), notifier.WithOverflowPolicy[int](notifier.DropNewest))
+	assert.NoError(t, err, "error should be nil")
+
+	received := make(chan int, 20)
+	go func() {
+		for batch := range fast {
+			for _, value := range batch {
+				received <- value
+			}
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		manager.Notify(i)
+		select {
+		case value := <-received:
+			assert.Equal(t, i, value, "fast subscriber should receive every event")
+		case <-time.After(time.Second):
+			assert.FailNow(t, "fast subscriber is blocked by slow subscriber")
+		}
+	}
+
+	stats := manager.Stats()
+	assert.Equal(t, "fast", stats[0].Id, "stats should be ordered by id")
+	assert.Equal(t, int64(20), stats[0].Delivered, "fast subscriber should get all events")
+	assert.Equal(t, int64(19), stats[1].Dropped, "slow subscriber should drop events over buffer")
+}
+
+func TestSubscriptionManagerFilter(t *testing.T) {
+	config := newConfig()
+	config.BatchSize = 4
+	config.BatchTime = 0
+	manager := newManager(t, config)
+	ctx := context.Background()
+
+	even, err := manager.AddSubscriber(ctx, "even", notifier.WithFilter(func(event int) bool { return event%2 == 0 }))
+	assert.NoError(t, err, "error should be nil")
+	none, err := manager.AddSubscriber(ctx, "none", notifier.WithFilter(func(event int) bool { return event > 100 }))
+	assert.NoError(t, err, "error should be nil")
+	all, err := manager.AddSubscriber(ctx, "all")
+	assert.NoError(t, err, "error should be nil")
+
+	manager.Notify(1, 2, 3, 4)
+
+	assert.Equal(t, []int{2, 4}, <-even, "subscriber should get only matching events")
+	assert.Equal(t, []int{1, 2, 3, 4}, <-all, "filter should not change batch of other subscribers")
+	assert.NoError(t, manager.Close(), "error should be nil")
+	_, ok := <-none
+	assert.False(t, ok, "subscriber without matching events should get no batches")
+}
+
+func TestSubscriptionManagerKindStats(t *testing.T) {
+	config := newConfig()
+	config.BatchSize = 2
+	config.BatchTime = 0
+	manager := newManager(t, config)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	first, err := manager.AddSubscriber(ctx, "first", notifier.WithKind[int]("graphql"))
+	assert.NoError(t, err, "error should be nil")
+	_, err = manager.AddSubscriber(context.Background(), "second", notifier.WithKind[int]("graphql"))
+	assert.NoError(t, err, "error should be nil")
+	_, err = manager.AddSubscriber(context.Background(), "other")
+	assert.NoError(t, err, "error should be nil")
+
+	manager.Notify(1, 2)
+	assert.Equal(t, []int{1, 2}, <-first, "values should be equal")
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		return len(manager.Stats()) == 2
+	}, time.Second, time.Millisecond, "subscriber should be removed after ctx is done")
+
+	assert.Equal(t, []notifier.KindStats{
+		{Kind: "", Subscribers: 1, Delivered: 2, Buffered: 1},
+		{Kind: "graphql", Subscribers: 1, Delivered: 4, Buffered: 1},
+	}, manager.KindStats(), "removed subscriber should be counted in its kind")
+}
+
+func TestSubscriptionManagerDuplicateSubscriber(t *testing.T) {
+	manager := newManager(t, newConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, err := manager.AddSubscriber(ctx, "subscriber-1")
+	assert.NoError(t, err, "error should be nil")
+
+	_, err = manager.AddSubscriber(context.Background(), "subscriber-1")
+	assert.ErrorIs(t, err, notifier.ErrSubscriberExists, "error should be ErrSubscriberExists")
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		_, err = manager.AddSubscriber(context.Background(), "subscriber-1")
+		return err == nil
+	}, time.Second, time.Millisecond, "id should be free after ctx is done")
+}
+
+func TestSubscriptionManagerConcurrentAccess(t *testing.T) {
+	config := newConfig()
+	config.BatchSize = 3
+	config.BatchTime = time.Millisecond
+	config.BufferSize = 1
+	manager := newManager(t, config)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				manager.Notify(i, j)
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				ctx, cancel := context.WithCancel(context.Background())
+				subscriber, err := manager.AddSubscriber(ctx, fmt.Sprintf("subscriber-%d-%d", i, j))
+				if err != nil {
+					cancel()
+					continue
+				}
+				select {
+				case <-subscriber:
+				case <-time.After(time.Millisecond):
+				}
+				_ = manager.Stats()
+				cancel()
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	assert.NoError(t, manager.Close(), "error should be nil")
+	assert.NoError(t, manager.Close(), "second close should be no-op")
+	manager.Notify(1, 2, 3)
+}
+
+func newConfig() *notifier.Config {
+	return &notifier.Config{
+		BatchSize:      10,
+		BatchTime:      1 * time.Minute,
+		BufferSize:     16,
+		OverflowPolicy: notifier.DropOldest,
+	}
+}
+
+func newManager(t *testing.T, config *notifier.Config) *notifier.SubscriptionManager[int] {
+	newLogger, err := logger.NewLogger(&logger.Config{LogLevel: "WARN"})
+	assert.NoError(t, err, "error should be nil")
+
+	manager, err := notifier.NewSubscriptionManager[int](newLogger, config, graceful.NewShutdownCloser())
+	assert.NoError(t, err, "error should be nil")
+
+	return manager
+}